@@ -0,0 +1,399 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const indexAuditSchema = `CREATE TABLE IF NOT EXISTS _index_audit (
+	id INTEGER PRIMARY KEY,
+	time TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+	action TEXT NOT NULL,
+	tbl TEXT NOT NULL,
+	idx TEXT NOT NULL,
+	sql TEXT NOT NULL,
+	fingerprint TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	mean INTEGER NOT NULL,
+	error TEXT
+)`
+
+// Actions of an IndexAdvisor, as logged in its audit
+const (
+	IndexRecommended = "recommended"
+	IndexCreated     = "created"
+	IndexFailed      = "failed"
+)
+
+// IndexAdvice is an index recommended for the statements of a fingerprint, which scan
+// the table in its entirety without it, and search it with it
+type IndexAdvice struct {
+	Fingerprint string
+	Example     string
+	Count       int64
+	Mean        time.Duration
+	Index       IndexSpec
+	SQL         string // the statement creating the index
+}
+
+// IndexAction is an entry of the audit of an IndexAdvisor
+type IndexAction struct {
+	Time        time.Time
+	Action      string // one of IndexRecommended, IndexCreated, or IndexFailed
+	Table       string
+	Index       string
+	SQL         string
+	Fingerprint string
+	Count       int64
+	Mean        time.Duration
+	Error       string
+}
+
+// MaintenanceWindow is a daily period, from Start to End after midnight, local time,
+// e.g. 2h to 4h, which wraps past midnight if End is before Start. The zero window
+// is always open
+type MaintenanceWindow struct {
+	Start, End time.Duration
+}
+
+// Contains reports whether the time is in the window
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	if w.Start == w.End {
+		return true
+	}
+	y, m, d := t.Date()
+	since := t.Sub(time.Date(y, m, d, 0, 0, 0, 0, t.Location()))
+	if w.Start < w.End {
+		return since >= w.Start && since < w.End
+	}
+	return since >= w.Start || since < w.End
+}
+
+// IndexAdvisor is an opt-in adaptive mode for the indexes of a database opened
+// WithQueryStats: it looks for the statements gathered that scan tables in their
+// entirety, and recommends indexes on the columns they are filtered or joined by,
+// which are checked to be used by the statements in place of the scans. The
+// recommendations are reported, or with Auto, created, and each is logged once
+// in an audit, the table _index_audit
+type IndexAdvisor struct {
+	DB       *sql.DB
+	MinCount int64         // statements run fewer times are ignored
+	MinMean  time.Duration // statements taking less time, on average, are ignored
+	Auto     bool          // create the recommended indexes, rather than only report them
+	Window   MaintenanceWindow
+	Interval time.Duration
+	OnError  func(error)       // called for failed runs, which are logged if nil
+	OnAction func(IndexAction) // optional, called with each action logged
+}
+
+// scanStep matches a step of a plan scanning a table, with its alias
+var scanStep = regexp.MustCompile(`^SCAN (?:TABLE )?([^ ]+)(?: AS ([^ ]+))?$`)
+
+// Advise returns the indexes recommended for the statements gathered so far
+func (a *IndexAdvisor) Advise() ([]IndexAdvice, error) {
+	stats := StatsOf(a.DB)
+	if stats == nil {
+		return nil, fmt.Errorf("index advisor: database not opened WithQueryStats")
+	}
+	var advice []IndexAdvice
+	seen := make(map[string]bool)
+	for _, stat := range stats.Top(0) {
+		if stat.Count < a.MinCount || stat.Mean() < a.MinMean {
+			continue
+		}
+		switch strings.ToUpper(strings.SplitN(strings.TrimSpace(stat.Example)+" ", " ", 2)[0]) {
+		case "SELECT", "WITH", "UPDATE", "DELETE":
+		default:
+			continue
+		}
+		plan, err := QueryPlan(a.DB, stat.Example, explainArgs(stat.Example)...)
+		if err != nil {
+			// e.g. statements of tables since dropped
+			continue
+		}
+		for _, step := range plan {
+			m := scanStep.FindStringSubmatch(step.Detail)
+			if m == nil || strings.HasPrefix(m[1], "sqlite_") || strings.HasPrefix(m[1], "_") {
+				continue
+			}
+			spec, err := a.indexFor(stat.Example, m[1], m[2])
+			if err != nil {
+				return advice, err
+			}
+			if spec == nil || seen[spec.IndexName()] {
+				continue
+			}
+			seen[spec.IndexName()] = true
+			stmt, _ := spec.SQL()
+			advice = append(advice, IndexAdvice{
+				Fingerprint: stat.Fingerprint,
+				Example:     stat.Example,
+				Count:       stat.Count,
+				Mean:        stat.Mean(),
+				Index:       *spec,
+				SQL:         stmt,
+			})
+		}
+	}
+	return advice, nil
+}
+
+// indexFor returns an index of the table on the columns the statement filters it by,
+// equalities first, if the statement would search the table with it, or nil
+func (a *IndexAdvisor) indexFor(stmt, table, alias string) (*IndexSpec, error) {
+	columns, err := tableColumns(a.DB, table)
+	if err != nil {
+		return nil, nil
+	}
+	known := make(map[string]string)
+	for _, c := range columns {
+		known[strings.ToLower(c)] = c
+	}
+	var on []string
+	match := func(ref columnRef) (string, bool) {
+		q := strings.ToLower(ref.qualifier)
+		if q != "" && q != strings.ToLower(table) && q != strings.ToLower(alias) {
+			return "", false
+		}
+		c, ok := known[strings.ToLower(ref.column)]
+		return c, ok && !contains(on, c)
+	}
+	eq, rng := predicateColumns(stmt)
+	for _, ref := range eq {
+		if c, ok := match(ref); ok {
+			on = append(on, c)
+		}
+	}
+	// a single range column, after the equalities, as any after it can't narrow the search
+	for _, ref := range rng {
+		if c, ok := match(ref); ok {
+			on = append(on, c)
+			break
+		}
+	}
+	if len(on) == 0 {
+		return nil, nil
+	}
+	spec := &IndexSpec{Table: table, Columns: on}
+	create, err := spec.SQL()
+	if err != nil {
+		return nil, err
+	}
+
+	// the index is created in a transaction, rolled back, to check the plan with it
+	tx, err := a.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(create); err != nil {
+		return nil, nil
+	}
+	plan, err := QueryPlan(tx, stmt, explainArgs(stmt)...)
+	if err != nil {
+		return nil, err
+	}
+	uses := regexp.MustCompile(`\bINDEX ` + regexp.QuoteMeta(spec.IndexName()) + `\b`)
+	for _, step := range plan {
+		if uses.MatchString(step.Detail) {
+			return spec, nil
+		}
+	}
+	return nil, nil
+}
+
+// explainArgs returns NULL arguments for the parameters of the statement, to explain it
+func explainArgs(stmt string) []interface{} {
+	n := 0
+	named := make(map[string]bool)
+	for _, tok := range fingerprintToken.FindAllString(stmt, -1) {
+		switch {
+		case tok == "?":
+			n++
+		case tok[0] == '?':
+			if i, err := strconv.Atoi(tok[1:]); err == nil && i > n {
+				n = i
+			}
+		case len(tok) > 1 && (tok[0] == ':' || tok[0] == '@' || tok[0] == '$') && !named[tok]:
+			named[tok] = true
+			n++
+		}
+	}
+	return make([]interface{}, n)
+}
+
+// contains reports whether the list has the string
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// columnRef is a column referred to by a statement, with its qualifier, if any
+type columnRef struct {
+	qualifier, column string
+}
+
+// predicateColumns returns the columns compared by the WHERE and ON clauses of the
+// statement, those compared for equality (=, IN, IS) and those compared by range
+func predicateColumns(stmt string) (eq, rng []columnRef) {
+	var tokens []string
+	for _, tok := range fingerprintToken.FindAllString(stmt, -1) {
+		if strings.TrimSpace(tok) == "" || strings.HasPrefix(tok, "--") || strings.HasPrefix(tok, "/*") {
+			continue
+		}
+		tokens = append(tokens, tok)
+	}
+	ident := func(tok string) (string, bool) {
+		c := tok[0]
+		switch {
+		case c == '"' || c == '`':
+			return strings.ReplaceAll(tok[1:len(tok)-1], tok[:1]+tok[:1], tok[:1]), true
+		case c == '[':
+			return tok[1 : len(tok)-1], true
+		case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			return tok, !sqlKeywords[strings.ToUpper(tok)]
+		}
+		return "", false
+	}
+	active := false
+	for i := 0; i < len(tokens); i++ {
+		switch strings.ToUpper(tokens[i]) {
+		case "WHERE", "ON":
+			active = true
+			continue
+		case "SELECT", "SET", "GROUP", "ORDER", "LIMIT", "HAVING", "WINDOW", "RETURNING":
+			active = false
+			continue
+		}
+		if !active {
+			continue
+		}
+		name, ok := ident(tokens[i])
+		if !ok {
+			continue
+		}
+		ref := columnRef{column: name}
+		if i+2 < len(tokens) && tokens[i+1] == "." {
+			if column, ok := ident(tokens[i+2]); ok {
+				ref = columnRef{qualifier: name, column: column}
+				i += 2
+			}
+		}
+		if i+1 >= len(tokens) {
+			break
+		}
+		switch op := strings.ToUpper(tokens[i+1]); op {
+		case "=", "IN", "IS":
+			eq = append(eq, ref)
+		case "<", ">", "BETWEEN":
+			rng = append(rng, ref)
+		}
+	}
+	return eq, rng
+}
+
+// RunOnce gets the advice, and creates the indexes recommended if Auto, logging
+// the actions not already in the audit, which are returned
+func (a *IndexAdvisor) RunOnce() ([]IndexAction, error) {
+	if _, err := a.DB.Exec(indexAuditSchema); err != nil {
+		return nil, err
+	}
+	advice, err := a.Advise()
+	if err != nil {
+		return nil, err
+	}
+	var actions []IndexAction
+	for _, adv := range advice {
+		action := IndexAction{
+			Time:        time.Now(),
+			Action:      IndexRecommended,
+			Table:       adv.Index.Table,
+			Index:       adv.Index.IndexName(),
+			SQL:         adv.SQL,
+			Fingerprint: adv.Fingerprint,
+			Count:       adv.Count,
+			Mean:        adv.Mean,
+		}
+		if a.Auto {
+			action.Action = IndexCreated
+			if err := EnsureIndex(a.DB, adv.Index); err != nil {
+				action.Action, action.Error = IndexFailed, err.Error()
+			}
+		} else {
+			var logged int
+			if err := row(a.DB, []interface{}{&logged}, "SELECT count(*) FROM _index_audit WHERE action=? AND idx=?", IndexRecommended, action.Index); err != nil {
+				return actions, err
+			}
+			if logged > 0 {
+				continue
+			}
+		}
+		const insert = `INSERT INTO _index_audit (action, tbl, idx, sql, fingerprint, count, mean, error)
+VALUES(?, ?, ?, ?, ?, ?, ?, nullif(?, ''))`
+		if _, err := a.DB.Exec(insert, action.Action, action.Table, action.Index, action.SQL, action.Fingerprint, action.Count, int64(action.Mean), action.Error); err != nil {
+			return actions, err
+		}
+		actions = append(actions, action)
+		if a.OnAction != nil {
+			a.OnAction(action)
+		}
+	}
+	return actions, nil
+}
+
+// Run runs the advisor at every interval, when in the maintenance window, until the context is done
+func (a *IndexAdvisor) Run(ctx context.Context) error {
+	if a.Interval <= 0 {
+		return fmt.Errorf("invalid index advisor interval: %v", a.Interval)
+	}
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+	for {
+		if a.Window.Contains(time.Now()) {
+			if _, err := a.RunOnce(); err != nil {
+				if a.OnError != nil {
+					a.OnError(err)
+				} else {
+					loggerOf(a.DB).Error("index advisor failed", "db", logName(a.DB), "op", "advise", "error", err)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// IndexAudit returns the actions logged by the index advisors of the database, oldest first
+func IndexAudit(db *sql.DB) ([]IndexAction, error) {
+	if _, err := db.Exec(indexAuditSchema); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT time, action, tbl, idx, sql, fingerprint, count, mean, coalesce(error, '') FROM _index_audit ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var actions []IndexAction
+	for rows.Next() {
+		var a IndexAction
+		var mean int64
+		if err := rows.Scan(&a.Time, &a.Action, &a.Table, &a.Index, &a.SQL, &a.Fingerprint, &a.Count, &mean, &a.Error); err != nil {
+			return nil, err
+		}
+		a.Mean = time.Duration(mean)
+		actions = append(actions, a)
+	}
+	return actions, rows.Err()
+}
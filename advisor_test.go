@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPredicateColumns(t *testing.T) {
+	eq, rng := predicateColumns(`SELECT a.x, b.y FROM a JOIN b ON b.id = a.b_id WHERE a."kind" IN (1, 2) AND a.at > ? AND "Z" BETWEEN 1 AND 2 ORDER BY a.w = 1`)
+	if len(eq) != 2 || eq[0] != (columnRef{"b", "id"}) || eq[1] != (columnRef{"a", "kind"}) {
+		t.Errorf("unexpected equalities: %v", eq)
+	}
+	if len(rng) != 2 || rng[0] != (columnRef{"a", "at"}) || rng[1] != (columnRef{"", "Z"}) {
+		t.Errorf("unexpected ranges: %v", rng)
+	}
+	if eq, _ := predicateColumns("UPDATE t SET a = 1 WHERE b = 2"); len(eq) != 1 || eq[0].column != "b" {
+		t.Errorf("unexpected equalities: %v", eq)
+	}
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	at := func(h int) time.Time {
+		return time.Date(2021, 6, 1, h, 30, 0, 0, time.Local)
+	}
+	night := MaintenanceWindow{Start: 22 * time.Hour, End: 2 * time.Hour}
+	if !night.Contains(at(23)) || !night.Contains(at(1)) || night.Contains(at(12)) {
+		t.Error("unexpected wrapped window")
+	}
+	early := MaintenanceWindow{Start: 2 * time.Hour, End: 4 * time.Hour}
+	if !early.Contains(at(3)) || early.Contains(at(4)) {
+		t.Error("unexpected window")
+	}
+	if !(MaintenanceWindow{}).Contains(at(12)) {
+		t.Error("expected the zero window to be open")
+	}
+}
+
+func TestIndexAdvisor(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "advisor.db"), WithQueryStats())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	prepare(db)
+	var n int
+	for i := 0; i < 5; i++ {
+		if err := row(db, []interface{}{&n}, "select count(*) from structs where kind = ?", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// searches by rowid don't need an index
+	if err := row(db, []interface{}{&n}, "select count(*) from structs where id = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	advisor := &IndexAdvisor{DB: db, MinCount: 2}
+	actions, err := advisor.RunOnce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].Action != IndexRecommended || actions[0].Index != "idx_structs_kind" || actions[0].Count != 5 {
+		t.Fatalf("unexpected actions: %+v", actions)
+	}
+	// recommendations are logged once, and not created
+	if actions, err := advisor.RunOnce(); err != nil || len(actions) != 0 {
+		t.Errorf("expected no new actions but got: %+v (%v)", actions, err)
+	}
+	AssertPlan(t, db, "select * from structs where kind = 1", PlanExpectations{})
+	if err := CheckPlan(db, "select * from structs where kind = 1", PlanExpectations{NoFullScan: true}); err == nil {
+		t.Error("expected the index not to be created")
+	}
+
+	advisor.Auto = true
+	var seen []IndexAction
+	advisor.OnAction = func(a IndexAction) {
+		seen = append(seen, a)
+	}
+	if actions, err := advisor.RunOnce(); err != nil || len(actions) != 1 || actions[0].Action != IndexCreated || len(seen) != 1 {
+		t.Fatalf("unexpected actions: %+v (%v)", actions, err)
+	}
+	AssertPlan(t, db, "select * from structs where kind = 1", PlanExpectations{UsesIndex: "idx_structs_kind", NoFullScan: true})
+	if actions, err := advisor.RunOnce(); err != nil || len(actions) != 0 {
+		t.Errorf("expected no actions once indexed but got: %+v (%v)", actions, err)
+	}
+
+	audit, err := IndexAudit(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audit) != 2 || audit[0].Action != IndexRecommended || audit[1].Action != IndexCreated || audit[1].Time.IsZero() || audit[1].SQL == "" {
+		t.Errorf("unexpected audit: %+v", audit)
+	}
+
+	plain := memDB(t)
+	defer plain.Close()
+	if _, err := (&IndexAdvisor{DB: plain}).Advise(); err == nil {
+		t.Error("expected error without query stats")
+	}
+}
@@ -0,0 +1,359 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// Backup backs up the open database
+func Backup(db *sql.DB, dest string) error {
+	return backup(db, dest, 1024, ioutil.Discard)
+}
+
+// BackupProgress backs up the open database, reporting progress to w
+func BackupProgress(db *sql.DB, dest string, w io.Writer) error {
+	return backup(db, dest, 1024, w)
+}
+
+// BackupSchema backs up a schema of the open database ("main", "temp", or the alias
+// of an attached database) into the main schema of the file dest.
+// Attached databases are per connection, so attach them with WithQuery
+// to have them on every connection
+func BackupSchema(db *sql.DB, schema, dest string, w io.Writer) error {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	return backupSchema(db, schema, dest, 1024, w)
+}
+
+// CopySchema copies schema srcSchema of src into schema dstSchema of dst,
+// replacing its contents. See BackupSchema regarding attached databases
+func CopySchema(src *sql.DB, srcSchema string, dst *sql.DB, dstSchema string, w io.Writer) error {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	return copySchema(src, srcSchema, dst, dstSchema, 1024, w)
+}
+
+func backup(db *sql.DB, dest string, step int, w io.Writer) error {
+	return backupSchema(db, "main", dest, step, w)
+}
+
+func backupSchema(db *sql.DB, schema, dest string, step int, w io.Writer) error {
+	os.Remove(dest)
+
+	destDb, err := Open(dest)
+	if err != nil {
+		return err
+	}
+	defer destDb.Close()
+
+	if err = destDb.Ping(); err != nil {
+		return err
+	}
+	return copySchema(db, schema, destDb, "main", step, w)
+}
+
+// BackupDB backs up the open database src into the open database dst, replacing its contents.
+// Unlike Backup, the destination can be any database, including one in memory. Each connection
+// of an in-memory database has its own, so limit dst to one (SetMaxOpenConns(1)) to use the copy
+func BackupDB(src, dst *sql.DB) error {
+	return copyDB(src, dst, 1024, ioutil.Discard)
+}
+
+// copyDB copies the contents of one open database into another via the backup API
+func copyDB(src, dst *sql.DB, step int, w io.Writer) error {
+	return copySchema(src, "main", dst, "main", step, w)
+}
+
+// copySchema copies a schema of one open database into a schema of another via the backup API,
+// using a connection of each
+func copySchema(src *sql.DB, srcSchema string, dst *sql.DB, dstSchema string, step int, w io.Writer) error {
+	if src == dst {
+		return fmt.Errorf("can't back up a database into itself")
+	}
+	return WithRawConn(src, func(from *sqlite3.SQLiteConn) error {
+		return WithRawConn(dst, func(to *sqlite3.SQLiteConn) error {
+			return backupConn(from, srcSchema, to, dstSchema, step, w)
+		})
+	})
+}
+
+func backupConn(from *sqlite3.SQLiteConn, fromSchema string, to *sqlite3.SQLiteConn, toSchema string, step int, w io.Writer) (err error) {
+	bk, err := to.Backup(toSchema, from, fromSchema)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if berr := bk.Finish(); err == nil {
+			err = berr
+		}
+	}()
+
+	for {
+		fmt.Fprintf(w, "pagecount: %d remaining: %d\n", bk.PageCount(), bk.Remaining())
+		var done bool
+		done, err = bk.Step(step)
+		if done || err != nil {
+			break
+		}
+	}
+	return err
+}
+
+// Restore replaces the contents of the open database with those of the backup file
+func Restore(db *sql.DB, src string, w io.Writer) error {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	srcDb, err := Open(src, WithExists(true))
+	if err != nil {
+		return err
+	}
+	defer srcDb.Close()
+
+	return copyDB(srcDb, db, 1024, w)
+}
+
+// IntegrityCheck runs "PRAGMA integrity_check" and returns an error describing any problems found
+func IntegrityCheck(db *sql.DB) error {
+	var problems []string
+	fn := func(_ []string, row []interface{}) {
+		problems = append(problems, fmt.Sprint(row[0]))
+	}
+	if err := query(db, fn, "PRAGMA integrity_check"); err != nil {
+		return err
+	}
+	if len(problems) == 1 && problems[0] == "ok" {
+		return nil
+	}
+	return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+}
+
+// Verify opens the database file and checks its integrity
+func Verify(file string) error {
+	db, err := Open(file, WithExists(true))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return IntegrityCheck(db)
+}
+
+// RestoreOptions are what TestRestore expects of a backup
+type RestoreOptions struct {
+	ApplicationID int                 // the expected "PRAGMA application_id", checked if not zero
+	UserVersion   int                 // the expected "PRAGMA user_version", checked if not zero
+	Queries       []string            // each must return a single row whose first value is true, e.g. "SELECT count(*) > 0 FROM users"
+	Validate      func(*sql.DB) error // optional, further checks of the backup
+}
+
+// TestRestore proves the backup file can be restored: it is opened read-only, its
+// integrity checked, its application_id and user_version compared to those expected,
+// and the validation queries of the options run against it
+func TestRestore(backupPath string, opts *RestoreOptions) error {
+	if opts == nil {
+		opts = &RestoreOptions{}
+	}
+	db, err := Open(readOnlyDSN(backupPath), WithExists(true))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := IntegrityCheck(db); err != nil {
+		return err
+	}
+	if opts.ApplicationID != 0 {
+		id, err := ApplicationID(db)
+		if err != nil {
+			return err
+		}
+		if id != opts.ApplicationID {
+			return fmt.Errorf("application_id is %d, expected %d", id, opts.ApplicationID)
+		}
+	}
+	if opts.UserVersion != 0 {
+		version, err := UserVersion(db)
+		if err != nil {
+			return err
+		}
+		if version != opts.UserVersion {
+			return fmt.Errorf("user_version is %d, expected %d", version, opts.UserVersion)
+		}
+	}
+	for _, q := range opts.Queries {
+		var ok bool
+		if err := row(db, []interface{}{&ok}, q); err != nil {
+			return fmt.Errorf("validation: %s, error: %w", q, err)
+		}
+		if !ok {
+			return fmt.Errorf("validation failed: %s", q)
+		}
+	}
+	if opts.Validate != nil {
+		return opts.Validate(db)
+	}
+	return nil
+}
+
+// Destination stores completed backup files
+type Destination interface {
+	Put(name string, r io.Reader, size int64) error
+}
+
+// DirDestination stores backups in a local directory
+type DirDestination string
+
+// Put copies the backup into the directory
+func (d DirDestination) Put(name string, r io.Reader, size int64) error {
+	if err := os.MkdirAll(string(d), 0777); err != nil {
+		return err
+	}
+	path := filepath.Join(string(d), name)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (d DirDestination) String() string {
+	return string(d)
+}
+
+// BackupTo backs up the database to a temporary file and hands it to the destination
+func BackupTo(db *sql.DB, dest Destination, name string, w io.Writer) error {
+	_, err := backupTo(db, dest, name, w, nil)
+	return err
+}
+
+// backupTo backs up the database to the destination, after testing the backup can be
+// restored with the options, if any, and returns its record
+func backupTo(db *sql.DB, dest Destination, name string, w io.Writer, check *RestoreOptions) (BackupRecord, error) {
+	r := BackupRecord{Name: name, Dest: fmt.Sprint(dest), Path: backupPath(dest, name), Taken: time.Now()}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	dir, err := ioutil.TempDir("", "sqlite-backup")
+	if err != nil {
+		return r, err
+	}
+	defer os.RemoveAll(dir)
+
+	if r.DataVersion, err = DataVersion(db); err != nil {
+		return r, err
+	}
+	tmp := filepath.Join(dir, name)
+	if err := backup(db, tmp, 1024, w); err != nil {
+		return r, err
+	}
+	r.Duration = time.Since(r.Taken)
+	if check != nil {
+		if err := TestRestore(tmp, check); err != nil {
+			return r, fmt.Errorf("restore test failed: %w", err)
+		}
+	}
+	f, err := os.Open(tmp)
+	if err != nil {
+		return r, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if r.Size, err = io.Copy(h, f); err != nil {
+		return r, err
+	}
+	r.Checksum = hex.EncodeToString(h.Sum(nil))
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return r, err
+	}
+	return r, dest.Put(name, f, r.Size)
+}
+
+// BackupScheduler periodically backs up a database to a destination
+type BackupScheduler struct {
+	DB       *sql.DB
+	Dest     Destination
+	Interval time.Duration
+	Prefix   string          // prefix of the generated backup names
+	Progress io.Writer       // optional progress output
+	OnError  func(error)     // called for failed backups, which are logged if nil
+	OnBackup func(string)    // optional, called with the name of each completed backup
+	Check    *RestoreOptions // optional, each backup must pass TestRestore with these to be stored
+	Catalog  *sql.DB         // optional, each backup is recorded in, see ListBackups
+}
+
+// BackupName returns the name used for a backup taken at the given time
+func (s *BackupScheduler) BackupName(t time.Time) string {
+	return s.Prefix + t.UTC().Format("20060102T150405Z") + ".db"
+}
+
+// RunOnce performs a single backup, returning its name
+func (s *BackupScheduler) RunOnce() (string, error) {
+	name := s.BackupName(time.Now())
+	r, err := backupTo(s.DB, s.Dest, name, s.Progress, s.Check)
+	if err != nil {
+		return name, fmt.Errorf("backup: %s, error: %w", name, err)
+	}
+	if s.Catalog != nil {
+		if err := recordBackup(s.Catalog, r); err != nil {
+			return name, fmt.Errorf("backup: %s, catalog error: %w", name, err)
+		}
+	}
+	if s.OnBackup != nil {
+		s.OnBackup(name)
+	}
+	return name, nil
+}
+
+// Prune removes the backups of the catalog beyond those the options keep, see PruneBackups
+func (s *BackupScheduler) Prune(opts *PruneOptions) ([]BackupRecord, error) {
+	return PruneBackups(s.Catalog, s.Dest, opts)
+}
+
+// Run backs up immediately and then at every interval until the context is done
+func (s *BackupScheduler) Run(ctx context.Context) error {
+	if s.Interval <= 0 {
+		return fmt.Errorf("invalid backup interval: %v", s.Interval)
+	}
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RunOnce(); err != nil {
+			if s.OnError != nil {
+				s.OnError(err)
+			} else {
+				loggerOf(s.DB).Error("backup failed", "db", logName(s.DB), "op", "backup", "error", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func fileDB(t *testing.T, dir string) *sql.DB {
+	t.Helper()
+	db, err := Open(filepath.Join(dir, "source.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	prepare(db)
+	return db
+}
+
+func TestBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	db := fileDB(t, dir)
+
+	saved := filepath.Join(dir, "saved.db")
+	if err := BackupProgress(db, saved, testout); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(saved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("delete from structs"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(db, saved, testout); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := row(db, []interface{}{&count}, "select count(*) from structs"); err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Fatalf("expected 4 restored rows but got: %d", count)
+	}
+}
+
+func TestRestoreMissing(t *testing.T) {
+	db := fileDB(t, t.TempDir())
+	if err := Restore(db, "/this/path/does/not/exist.db", nil); err == nil {
+		t.Fatal("expected error for missing backup")
+	} else {
+		t.Log(err)
+	}
+}
+
+func TestVerifyCorrupt(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "corrupt.db")
+	if err := ioutil.WriteFile(file, []byte("this is not a database file"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(file); err == nil {
+		t.Fatal("expected error for corrupt file")
+	} else {
+		t.Log(err)
+	}
+}
+
+func TestRestoreVerification(t *testing.T) {
+	dir := t.TempDir()
+	db := fileDB(t, dir)
+	if _, err := db.Exec("PRAGMA application_id = 1234; PRAGMA user_version = 7"); err != nil {
+		t.Fatal(err)
+	}
+	saved := filepath.Join(dir, "saved.db")
+	if err := Backup(db, saved); err != nil {
+		t.Fatal(err)
+	}
+
+	validated := false
+	opts := &RestoreOptions{
+		ApplicationID: 1234,
+		UserVersion:   7,
+		Queries:       []string{"select count(*) = 4 from structs", "select max(kind) > 50 from structs"},
+		Validate: func(db *sql.DB) error {
+			validated = true
+			_, err := db.Exec("delete from structs")
+			if err == nil {
+				t.Error("expected the backup to be read-only")
+			}
+			return nil
+		},
+	}
+	if err := TestRestore(saved, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !validated {
+		t.Error("expected validation to be called")
+	}
+	if err := TestRestore(saved, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	failures := []*RestoreOptions{
+		{ApplicationID: 99},
+		{UserVersion: 8},
+		{Queries: []string{"select count(*) = 5 from structs"}},
+		{Queries: []string{"select * from nosuch"}},
+		{Validate: func(*sql.DB) error { return errors.New("invalid") }},
+	}
+	for i, opts := range failures {
+		if err := TestRestore(saved, opts); err == nil {
+			t.Errorf("%d: expected failure", i)
+		} else {
+			t.Log(err)
+		}
+	}
+	if err := TestRestore(filepath.Join(dir, "missing.db"), nil); err == nil {
+		t.Error("expected error for missing backup")
+	}
+
+	// scheduled backups that fail are not stored
+	s := &BackupScheduler{
+		DB:    db,
+		Dest:  DirDestination(filepath.Join(dir, "backups")),
+		Check: &RestoreOptions{UserVersion: 8},
+	}
+	if _, err := s.RunOnce(); err == nil {
+		t.Fatal("expected restore test to fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "backups")); !os.IsNotExist(err) {
+		t.Errorf("expected no backups to be stored: %v", err)
+	}
+	s.Check.UserVersion = 7
+	if _, err := s.RunOnce(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackupScheduler(t *testing.T) {
+	dir := t.TempDir()
+	db := fileDB(t, dir)
+
+	var names []string
+	s := &BackupScheduler{
+		DB:       db,
+		Dest:     DirDestination(filepath.Join(dir, "backups")),
+		Interval: time.Hour,
+		Prefix:   "test-",
+		OnError:  func(err error) { t.Error(err) },
+		OnBackup: func(name string) { names = append(names, name) },
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := s.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(names) != 1 {
+		t.Fatalf("expected one backup but got: %v", names)
+	}
+	if err := Verify(filepath.Join(dir, "backups", names[0])); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackupSchema(t *testing.T) {
+	dir := t.TempDir()
+	aux := filepath.Join(dir, "aux.db")
+	auxDB, err := Open(aux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auxDB.Exec("create table extras (name text); insert into extras values('attached')"); err != nil {
+		t.Fatal(err)
+	}
+	auxDB.Close()
+
+	db, err := Open(filepath.Join(dir, "main.db"), WithQuery("ATTACH '"+aux+"' AS aux"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	saved := filepath.Join(dir, "saved.db")
+	if err := BackupSchema(db, "aux", saved, nil); err != nil {
+		t.Fatal(err)
+	}
+	savedDB, err := Open(saved, WithExists(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer savedDB.Close()
+	var name string
+	if err := row(savedDB, []interface{}{&name}, "select name from extras"); err != nil || name != "attached" {
+		t.Fatalf("expected attached row but got: %q (%v)", name, err)
+	}
+
+	// copy it back into the attached database
+	if _, err := db.Exec("delete from aux.extras"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopySchema(savedDB, "main", db, "aux", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := row(db, []interface{}{&name}, "select name from aux.extras"); err != nil || name != "attached" {
+		t.Fatalf("expected copied row but got: %q (%v)", name, err)
+	}
+	if err := BackupSchema(db, "nosuch", saved, nil); err == nil {
+		t.Fatal("expected error for unknown schema")
+	}
+}
+
+func TestBackupDB(t *testing.T) {
+	db := fileDB(t, t.TempDir())
+	mem := memDB(t)
+	defer mem.Close()
+	mem.SetMaxOpenConns(1)
+
+	if err := BackupDB(db, mem); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := row(mem, []interface{}{&count}, "select count(*) from structs"); err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Fatalf("expected 4 rows in the copy but got: %d", count)
+	}
+
+	// and back again, between two memory databases
+	other := memDB(t)
+	defer other.Close()
+	other.SetMaxOpenConns(1)
+	if err := BackupDB(mem, other); err != nil {
+		t.Fatal(err)
+	}
+	if err := row(other, []interface{}{&count}, "select count(*) from structs"); err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Fatalf("expected 4 rows in the second copy but got: %d", count)
+	}
+	if err := BackupDB(mem, mem); err == nil {
+		t.Fatal("expected an error backing up into itself")
+	}
+}
+
+func TestBackupMemory(t *testing.T) {
+	// two memory databases, which used to share a registry entry
+	a, b := memDB(t), memDB(t)
+	defer a.Close()
+	defer b.Close()
+	if _, err := a.Exec("create table only_a (x)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Exec("create table only_b (x)"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := Connection(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Memory() || info.DSN == "" {
+		t.Fatalf("unexpected connection info: %+v", info)
+	}
+
+	saved := filepath.Join(t.TempDir(), "a.db")
+	if err := Backup(a, saved); err != nil {
+		t.Fatal(err)
+	}
+	copied, err := Open(saved, WithExists(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+	tables, err := Tables(copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || tables[0] != "only_a" {
+		t.Fatalf("expected the tables of a but got: %v", tables)
+	}
+	if info, err := Connection(copied); err != nil || info.File != saved || info.Memory() {
+		t.Fatalf("unexpected connection info: %+v (%v)", info, err)
+	}
+}
+
+func TestConnections(t *testing.T) {
+	before := len(Connections())
+	db := memDB(t)
+	db.SetMaxIdleConns(2)
+	if err := WarmUp(db, 2); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(Connections()); n != before+2 {
+		t.Fatalf("expected %d connections but got: %d", before+2, n)
+	}
+	db.Close()
+	if n := len(Connections()); n != before {
+		t.Fatalf("expected the connections to be unregistered, but have: %d", n)
+	}
+}
@@ -0,0 +1,51 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// BatchError is the error of ExecBatch, identifying the parameter set that failed
+type BatchError struct {
+	Index int // of the failing parameter set
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("parameter set %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the error of the failing statement
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ExecBatch runs the statement once for each set of parameters, preparing it once
+// and running it in a single transaction, returning the total rows affected.
+// If any set fails, nothing is changed, and the error is a *BatchError
+func ExecBatch(db *sql.DB, query string, paramSets [][]interface{}) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var affected int64
+	for i, params := range paramSets {
+		result, err := stmt.Exec(params...)
+		if err != nil {
+			return 0, &BatchError{Index: i, Err: err}
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, &BatchError{Index: i, Err: err}
+		}
+		affected += n
+	}
+	return affected, tx.Commit()
+}
@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExecBatch(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("create table batch (id integer primary key, name text not null)"); err != nil {
+		t.Fatal(err)
+	}
+	n, err := ExecBatch(db, "insert into batch (id, name) values(?,?)", [][]interface{}{
+		{1, "one"}, {2, "two"}, {3, "three"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 rows affected but got: %d", n)
+	}
+
+	_, err = ExecBatch(db, "insert into batch (id, name) values(?,?)", [][]interface{}{
+		{4, "four"}, {5, nil}, {6, "six"},
+	})
+	var be *BatchError
+	if !errors.As(err, &be) || be.Index != 1 {
+		t.Fatalf("expected the second set to fail but got: %v", err)
+	}
+	var count int
+	if err := row(db, []interface{}{&count}, "select count(*) from batch"); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("expected the failed batch to be rolled back, but have %d rows", count)
+	}
+
+	if _, err := ExecBatch(db, "insert into nosuch values(?)", [][]interface{}{{1}}); err == nil || errors.As(err, &be) {
+		t.Fatalf("expected a prepare error but got: %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+// Package bench runs read/write workloads against an sqlite database file
+// to compare the throughput and latency of pragma settings
+package bench
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paulstuart/sqlite"
+)
+
+// Profile is a named set of pragmas applied to each connection
+type Profile struct {
+	Name    string
+	Pragmas []string
+}
+
+// Profiles are the standard journal and sync combinations worth comparing
+var Profiles = []Profile{
+	{"delete", []string{"journal_mode=DELETE", "synchronous=FULL"}},
+	{"wal", []string{"journal_mode=WAL", "synchronous=FULL"}},
+	{"wal-normal", []string{"journal_mode=WAL", "synchronous=NORMAL"}},
+	{"wal-off", []string{"journal_mode=WAL", "synchronous=OFF"}},
+	{"memory", []string{"journal_mode=MEMORY", "synchronous=OFF"}},
+}
+
+// FindProfile returns the named profile from Profiles
+func FindProfile(name string) (Profile, error) {
+	for _, p := range Profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Profile{}, fmt.Errorf("unknown profile: %q", name)
+}
+
+// Config describes a workload
+type Config struct {
+	File        string        // database file, recreated for each run
+	Profile     Profile       //
+	Duration    time.Duration // how long to run
+	Concurrency int           // number of concurrent workers
+	ReadRatio   float64       // fraction of operations that are reads, 0.0 to 1.0
+	PayloadSize int           // bytes written per row
+	Rows        int           // rows loaded before the workload starts
+	BusyTimeout time.Duration // how long writers wait for locks
+}
+
+// Stats summarize the latency of one kind of operation
+type Stats struct {
+	Count      int
+	Errors     int
+	Throughput float64 // operations per second
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+}
+
+// Result is the outcome of a workload
+type Result struct {
+	Profile string
+	Elapsed time.Duration
+	Reads   Stats
+	Writes  Stats
+}
+
+type sample struct {
+	read    bool
+	latency time.Duration
+	err     error
+}
+
+// Run executes the workload, removing any existing file first
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	if cfg.BusyTimeout == 0 {
+		cfg.BusyTimeout = 5 * time.Second
+	}
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		os.Remove(cfg.File + suffix)
+	}
+
+	pragmas := append([]string{fmt.Sprintf("busy_timeout=%d", cfg.BusyTimeout.Milliseconds())}, cfg.Profile.Pragmas...)
+	db, err := sqlite.Open(cfg.File,
+		sqlite.WithDriver("sqlbench_"+cfg.Profile.Name),
+		sqlite.WithPragmas(pragmas...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer sqlite.Close(db)
+	db.SetMaxOpenConns(cfg.Concurrency)
+
+	if err := load(db, cfg); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	results := make(chan []sample, cfg.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			results <- worker(ctx, db, cfg, rand.New(rand.NewSource(seed)))
+		}(start.UnixNano() + int64(i))
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	close(results)
+
+	var reads, writes []sample
+	for samples := range results {
+		for _, s := range samples {
+			if s.read {
+				reads = append(reads, s)
+			} else {
+				writes = append(writes, s)
+			}
+		}
+	}
+	return &Result{
+		Profile: cfg.Profile.Name,
+		Elapsed: elapsed,
+		Reads:   summarize(reads, elapsed),
+		Writes:  summarize(writes, elapsed),
+	}, nil
+}
+
+func load(db *sql.DB, cfg Config) error {
+	const create = "CREATE TABLE bench (id integer primary key, payload blob)"
+	if _, err := db.Exec(create); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	payload := make([]byte, cfg.PayloadSize)
+	for i := 0; i < cfg.Rows; i++ {
+		rand.Read(payload)
+		if _, err := tx.Exec("INSERT INTO bench (payload) VALUES(?)", payload); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func worker(ctx context.Context, db *sql.DB, cfg Config, rnd *rand.Rand) []sample {
+	var samples []sample
+	payload := make([]byte, cfg.PayloadSize)
+	var blob []byte
+	for ctx.Err() == nil {
+		s := sample{read: rnd.Float64() < cfg.ReadRatio}
+		start := time.Now()
+		if s.read {
+			id := rnd.Intn(cfg.Rows+1) + 1
+			s.err = db.QueryRowContext(ctx, "SELECT payload FROM bench WHERE id=?", id).Scan(&blob)
+			if s.err == sql.ErrNoRows {
+				s.err = nil
+			}
+		} else {
+			rnd.Read(payload)
+			_, s.err = db.ExecContext(ctx, "INSERT INTO bench (payload) VALUES(?)", payload)
+		}
+		s.latency = time.Since(start)
+		if ctx.Err() != nil {
+			// operations cut short by the deadline are not representative
+			break
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+func summarize(samples []sample, elapsed time.Duration) Stats {
+	var st Stats
+	latencies := make([]time.Duration, 0, len(samples))
+	for _, s := range samples {
+		if s.err != nil {
+			st.Errors++
+			continue
+		}
+		latencies = append(latencies, s.latency)
+	}
+	st.Count = len(latencies)
+	if st.Count == 0 {
+		return st
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		return latencies[int(float64(len(latencies)-1)*p)]
+	}
+	st.Throughput = float64(st.Count) / elapsed.Seconds()
+	st.P50 = percentile(0.50)
+	st.P90 = percentile(0.90)
+	st.P99 = percentile(0.99)
+	st.Max = latencies[len(latencies)-1]
+	return st
+}
+
+// ReportHeader writes the column headings for Report
+func ReportHeader(w io.Writer) {
+	fmt.Fprintf(w, "%-12s %-6s %9s %7s %12s %10s %10s %10s %10s\n",
+		"profile", "op", "count", "errors", "ops/sec", "p50", "p90", "p99", "max")
+	fmt.Fprintln(w, strings.Repeat("-", 94))
+}
+
+// Report writes a line each for reads and writes
+func (r *Result) Report(w io.Writer) {
+	line := func(op string, s Stats) {
+		fmt.Fprintf(w, "%-12s %-6s %9d %7d %12.1f %10v %10v %10v %10v\n",
+			r.Profile, op, s.Count, s.Errors, s.Throughput,
+			s.P50.Round(time.Microsecond), s.P90.Round(time.Microsecond),
+			s.P99.Round(time.Microsecond), s.Max.Round(time.Microsecond))
+	}
+	line("read", r.Reads)
+	line("write", r.Writes)
+}
@@ -0,0 +1,50 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	profile, err := FindProfile("wal-normal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{
+		File:        filepath.Join(t.TempDir(), "bench.db"),
+		Profile:     profile,
+		Duration:    200 * time.Millisecond,
+		Concurrency: 4,
+		ReadRatio:   0.5,
+		PayloadSize: 64,
+		Rows:        100,
+	}
+	result, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Reads.Count == 0 || result.Writes.Count == 0 {
+		t.Fatalf("expected reads and writes but got: %+v", result)
+	}
+	if result.Reads.P50 > result.Reads.P99 || result.Reads.P99 > result.Reads.Max {
+		t.Errorf("percentiles out of order: %+v", result.Reads)
+	}
+
+	var buf bytes.Buffer
+	ReportHeader(&buf)
+	result.Report(&buf)
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 4 {
+		t.Errorf("unexpected report:\n%s", buf.String())
+	}
+	t.Log("\n" + buf.String())
+}
+
+func TestFindProfile(t *testing.T) {
+	if _, err := FindProfile("no-such-profile"); err == nil {
+		t.Fatal("expected error for unknown profile")
+	}
+}
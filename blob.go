@@ -0,0 +1,196 @@
+package sqlite
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+
+// The sqlite3 symbols are provided by the go-sqlite3 driver, see handle.go
+typedef struct sqlite3 sqlite3;
+typedef struct sqlite3_blob sqlite3_blob;
+
+extern int sqlite3_blob_open(sqlite3*, const char*, const char*, const char*, long long, int, sqlite3_blob**);
+extern int sqlite3_blob_close(sqlite3_blob*);
+extern int sqlite3_blob_bytes(sqlite3_blob*);
+extern int sqlite3_blob_read(sqlite3_blob*, void*, int, int);
+extern int sqlite3_blob_write(sqlite3_blob*, const void*, int, int);
+extern const char *sqlite3_errmsg(sqlite3*);
+extern const char *sqlite3_errstr(int);
+
+static sqlite3 *db_handle(uintptr_t handle) {
+	return (sqlite3*)handle;
+}
+*/
+import "C"
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unsafe"
+)
+
+// Blob reads and writes a blob value incrementally, without loading it into memory.
+// Writes can not change the size of a blob, so space for a new blob must be
+// allocated beforehand, e.g. by inserting zeroblob(size).
+//
+// A Blob holds a connection of its DB until it is closed. If the row is
+// changed or deleted while the blob is open, further reads and writes fail
+type Blob struct {
+	conn   *sql.Conn
+	db     *C.sqlite3
+	blob   *C.sqlite3_blob
+	size   int64
+	offset int64
+}
+
+var _ interface {
+	io.ReadWriteSeeker
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+} = (*Blob)(nil)
+
+// ErrBlobSize is returned by Blob.Write when writing past the end of the blob
+var ErrBlobSize = errors.New("write exceeds blob size")
+
+// OpenBlob opens the blob in column of the table row with the given rowid.
+// The table may be qualified by the name of an attached database, e.g. "aux.files"
+func OpenBlob(db *sql.DB, table, column string, rowid int64) (*Blob, error) {
+	schema := "main"
+	if i := strings.Index(table, "."); i > 0 {
+		schema, table = table[:i], table[i+1:]
+	}
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	b := &Blob{conn: conn}
+	err = conn.Raw(func(dc interface{}) error {
+		handle, err := connHandle(rawConn(dc))
+		if err != nil {
+			return err
+		}
+		b.db = C.db_handle(C.uintptr_t(handle))
+		cSchema, cTable, cColumn := C.CString(schema), C.CString(table), C.CString(column)
+		defer C.free(unsafe.Pointer(cSchema))
+		defer C.free(unsafe.Pointer(cTable))
+		defer C.free(unsafe.Pointer(cColumn))
+		if rc := C.sqlite3_blob_open(b.db, cSchema, cTable, cColumn, C.longlong(rowid), 1, &b.blob); rc != 0 {
+			return fmt.Errorf("open blob %s.%s rowid %d: %s", table, column, rowid, C.GoString(C.sqlite3_errmsg(b.db)))
+		}
+		b.size = int64(C.sqlite3_blob_bytes(b.blob))
+		return nil
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Blob) error(op string, rc C.int) error {
+	return fmt.Errorf("blob %s: %s", op, C.GoString(C.sqlite3_errstr(rc)))
+}
+
+// Size returns the size of the blob in bytes
+func (b *Blob) Size() int64 {
+	return b.size
+}
+
+// ReadAt implements io.ReaderAt
+func (b *Blob) ReadAt(p []byte, off int64) (int, error) {
+	if b.blob == nil {
+		return 0, os.ErrClosed
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("blob read: negative offset")
+	}
+	if off >= b.size {
+		return 0, io.EOF
+	}
+	n := len(p)
+	var err error
+	if remain := b.size - off; int64(n) > remain {
+		n, err = int(remain), io.EOF
+	}
+	if n == 0 {
+		return 0, err
+	}
+	if rc := C.sqlite3_blob_read(b.blob, unsafe.Pointer(&p[0]), C.int(n), C.int(off)); rc != 0 {
+		return 0, b.error("read", rc)
+	}
+	return n, err
+}
+
+// WriteAt implements io.WriterAt
+func (b *Blob) WriteAt(p []byte, off int64) (int, error) {
+	if b.blob == nil {
+		return 0, os.ErrClosed
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("blob write: negative offset")
+	}
+	if off+int64(len(p)) > b.size {
+		return 0, ErrBlobSize
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if rc := C.sqlite3_blob_write(b.blob, unsafe.Pointer(&p[0]), C.int(len(p)), C.int(off)); rc != 0 {
+		return 0, b.error("write", rc)
+	}
+	return len(p), nil
+}
+
+// Read implements io.Reader
+func (b *Blob) Read(p []byte) (int, error) {
+	n, err := b.ReadAt(p, b.offset)
+	b.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Write implements io.Writer
+func (b *Blob) Write(p []byte) (int, error) {
+	n, err := b.WriteAt(p, b.offset)
+	b.offset += int64(n)
+	return n, err
+}
+
+// Seek implements io.Seeker
+func (b *Blob) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.offset
+	case io.SeekEnd:
+		offset += b.size
+	default:
+		return 0, fmt.Errorf("blob seek: invalid whence: %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("blob seek: negative position")
+	}
+	b.offset = offset
+	return offset, nil
+}
+
+// Close closes the blob and returns its connection to the DB
+func (b *Blob) Close() error {
+	if b.blob == nil {
+		return nil
+	}
+	rc := C.sqlite3_blob_close(b.blob)
+	b.blob = nil
+	err := b.conn.Close()
+	if rc != 0 {
+		return b.error("close", rc)
+	}
+	return err
+}
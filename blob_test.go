@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestBlob(t *testing.T) {
+	db := memDB(t)
+	const size = 3 << 20
+	if _, err := db.Exec("create table files (name text, data blob); insert into files values('big', zeroblob(?))", size); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := OpenBlob(db, "files", "data", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Size() != size {
+		t.Fatalf("expected size %d but got: %d", size, b.Size())
+	}
+	chunk := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	for written := 0; written < size; written += len(chunk) {
+		if _, err := b.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := b.Write([]byte("x")); err != ErrBlobSize {
+		t.Fatalf("expected size error but got: %v", err)
+	}
+	if _, err := b.Seek(-16, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	tail, err := ioutil.ReadAll(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(tail) != "0123456789abcdef" {
+		t.Fatalf("unexpected tail: %q", tail)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var data []byte
+	if err := row(db, []interface{}{&data}, "select data from files"); err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != size || !bytes.Equal(data[:len(chunk)], chunk) {
+		t.Fatalf("unexpected blob contents, size: %d", len(data))
+	}
+
+	if _, err := OpenBlob(db, "files", "data", 99); err == nil {
+		t.Fatal("expected error for missing row")
+	} else {
+		t.Log(err)
+	}
+}
@@ -0,0 +1,196 @@
+package sqlite
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultBlobThreshold is the size above which a BlobStore keeps blobs in files
+const DefaultBlobThreshold = 64 << 10
+
+// blobRefPrefix marks the text values that refer to external blobs
+const blobRefPrefix = "sha256:"
+
+// BlobStore keeps small blobs inline in the database and spills larger ones
+// into a content-addressed sidecar directory, storing a reference in their place.
+// Inline blobs are stored as BLOB values and references as TEXT values
+// of the form "sha256:<hex digest>", so identical blobs are only stored once.
+//
+// The SQL functions returned by Funcs resolve stored values in queries
+type BlobStore struct {
+	Dir       string // sidecar directory for external blobs
+	Threshold int    // blobs larger than this many bytes are external
+	Prefix    string // prefix for the names of the SQL functions, defaults to "blob"
+}
+
+// NewBlobStore returns a BlobStore using dir, which is created if need be.
+// A threshold of 0 uses DefaultBlobThreshold
+func NewBlobStore(dir string, threshold int) (*BlobStore, error) {
+	if threshold <= 0 {
+		threshold = DefaultBlobThreshold
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &BlobStore{Dir: dir, Threshold: threshold}, nil
+}
+
+// path returns the file for the digest, fanned out by its first two digits
+func (s *BlobStore) path(digest string) string {
+	return filepath.Join(s.Dir, digest[:2], digest[2:])
+}
+
+// blobRef returns the digest of a reference value, if it is one
+func blobRef(v interface{}) (string, bool) {
+	ref, ok := v.(string)
+	if !ok || !strings.HasPrefix(ref, blobRefPrefix) {
+		return "", false
+	}
+	digest := ref[len(blobRefPrefix):]
+	if len(digest) != sha256.Size*2 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", false
+	}
+	return digest, true
+}
+
+// Value returns the value to store for the blob: the blob itself if it is small,
+// otherwise a reference to the file it has been written to
+func (s *BlobStore) Value(data []byte) (interface{}, error) {
+	if len(data) <= s.Threshold {
+		return data, nil
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	file := s.path(digest)
+	if _, err := os.Stat(file); err == nil {
+		return blobRefPrefix + digest, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, err
+	}
+	// write to a temporary file first so a partial blob is never visible
+	tmp, err := ioutil.TempFile(filepath.Dir(file), ".tmp-")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return blobRefPrefix + digest, nil
+}
+
+// Resolve returns the blob for a stored value
+func (s *BlobStore) Resolve(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	}
+	digest, ok := blobRef(v)
+	if !ok {
+		return nil, fmt.Errorf("invalid blob reference: %v", v)
+	}
+	return ioutil.ReadFile(s.path(digest))
+}
+
+// Size returns the size of the blob for a stored value
+func (s *BlobStore) Size(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case []byte:
+		return int64(len(v)), nil
+	}
+	digest, ok := blobRef(v)
+	if !ok {
+		return 0, fmt.Errorf("invalid blob reference: %v", v)
+	}
+	fi, err := os.Stat(s.path(digest))
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// Funcs returns SQL functions for querying stored values, named with the store's prefix:
+//
+//	blob(v)          the blob itself
+//	blob_size(v)     the size of the blob
+//	blob_external(v) whether the blob is kept in a file
+func (s *BlobStore) Funcs() []FuncReg {
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = "blob"
+	}
+	external := func(v interface{}) bool {
+		_, ok := blobRef(v)
+		return ok
+	}
+	return []FuncReg{
+		{Name: prefix, Impl: s.Resolve},
+		{Name: prefix + "_size", Impl: s.Size},
+		{Name: prefix + "_external", Impl: external, Pure: true},
+	}
+}
+
+// Prune removes the files that are not referenced by the given columns,
+// each given as "table.column", returning the number removed
+func (s *BlobStore) Prune(db *sql.DB, columns ...string) (int, error) {
+	used := make(map[string]bool)
+	for _, tc := range columns {
+		i := strings.LastIndex(tc, ".")
+		if i < 1 {
+			return 0, fmt.Errorf("column must be given as table.column: %q", tc)
+		}
+		table, column := tc[:i], tc[i+1:]
+		q := fmt.Sprintf("SELECT DISTINCT %s FROM %s WHERE typeof(%s)='text'", quoteIdent(column), quoteIdent(table), quoteIdent(column))
+		fn := func(_ []string, row []interface{}) {
+			if digest, ok := blobRef(row[0]); ok {
+				used[digest] = true
+			}
+		}
+		if err := query(db, fn, q); err != nil {
+			return 0, err
+		}
+	}
+	removed := 0
+	err := filepath.Walk(s.Dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(s.Dir, path)
+		if err != nil {
+			return err
+		}
+		digest := strings.Replace(filepath.ToSlash(rel), "/", "", 1)
+		if _, ok := blobRef(blobRefPrefix + digest); !ok || used[digest] {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
@@ -0,0 +1,70 @@
+package sqlite
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBlobStore(t *testing.T) {
+	store, err := NewBlobStore(t.TempDir(), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(":memory:", WithFunctions(store.Funcs()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("create table files (name text, data blob)"); err != nil {
+		t.Fatal(err)
+	}
+
+	small := []byte("tiny")
+	large := bytes.Repeat([]byte("large blob "), 100)
+	for name, data := range map[string][]byte{"small": small, "large": large, "copy": large} {
+		v, err := store.Value(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("insert into files values(?,?)", name, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var data []byte
+	var size int64
+	var external bool
+	const q = "select blob(data), blob_size(data), blob_external(data) from files where name=?"
+	if err := row(db, []interface{}{&data, &size, &external}, q, "large"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, large) || size != int64(len(large)) || !external {
+		t.Fatalf("unexpected large blob: %d bytes, size: %d, external: %t", len(data), size, external)
+	}
+	if err := row(db, []interface{}{&data, &size, &external}, q, "small"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, small) || size != int64(len(small)) || external {
+		t.Fatalf("unexpected small blob: %q, size: %d, external: %t", data, size, external)
+	}
+
+	// identical blobs share a file, which is kept until neither refers to it
+	if n, err := store.Prune(db, "files.data"); err != nil || n != 0 {
+		t.Fatalf("expected nothing pruned but got: %d (%v)", n, err)
+	}
+	if _, err := db.Exec("delete from files where name='large'"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.Prune(db, "files.data"); err != nil || n != 0 {
+		t.Fatalf("expected nothing pruned but got: %d (%v)", n, err)
+	}
+	if _, err := db.Exec("delete from files where name='copy'"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.Prune(db, "files.data"); err != nil || n != 1 {
+		t.Fatalf("expected one file pruned but got: %d (%v)", n, err)
+	}
+	if _, err := store.Resolve("sha256:nope"); err == nil {
+		t.Fatal("expected error for invalid reference")
+	}
+}
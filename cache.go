@@ -0,0 +1,112 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CacheTable is a persistent read-through cache, memoizing the values of a loader in a table.
+// Concurrent loads of the same key share a single call of the loader
+type CacheTable struct {
+	db     *sql.DB
+	name   string
+	ttl    time.Duration
+	loader func(key string) ([]byte, error)
+
+	mu    sync.Mutex
+	calls map[string]*cacheCall
+}
+
+// cacheCall is a load in progress
+type cacheCall struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+// Cache returns a cache kept in the named table, which is created if need be.
+// Values are loaded when missing or older than ttl, and never expire if ttl is 0
+func Cache(db *sql.DB, name string, ttl time.Duration, loader func(key string) ([]byte, error)) (*CacheTable, error) {
+	if loader == nil {
+		return nil, fmt.Errorf("cache %s has no loader", name)
+	}
+	const create = "CREATE TABLE IF NOT EXISTS %s (key TEXT PRIMARY KEY, value BLOB, expires INTEGER)"
+	if _, err := db.Exec(fmt.Sprintf(create, quoteIdent(name))); err != nil {
+		return nil, err
+	}
+	return &CacheTable{
+		db:     db,
+		name:   name,
+		ttl:    ttl,
+		loader: loader,
+		calls:  make(map[string]*cacheCall),
+	}, nil
+}
+
+// cacheNow returns the current time in the form kept in the expires column
+func cacheNow() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// Get returns the value for the key, from the cache if it is there and fresh,
+// otherwise from the loader
+func (c *CacheTable) Get(key string) ([]byte, error) {
+	var value []byte
+	q := fmt.Sprintf("SELECT value FROM %s WHERE key=? AND (expires IS NULL OR expires > ?)", quoteIdent(c.name))
+	switch err := row(c.db, []interface{}{&value}, q, key, cacheNow()); err {
+	case nil:
+		return value, nil
+	case sql.ErrNoRows:
+	default:
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	call.value, call.err = c.loader(key)
+	if call.err == nil {
+		call.err = c.Set(key, call.value)
+	}
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.mu.Unlock()
+	close(call.done)
+	return call.value, call.err
+}
+
+// Set stores the value for the key
+func (c *CacheTable) Set(key string, value []byte) error {
+	var expires interface{}
+	if c.ttl > 0 {
+		expires = cacheNow() + int64(c.ttl/time.Millisecond)
+	}
+	q := fmt.Sprintf("INSERT OR REPLACE INTO %s (key, value, expires) VALUES(?,?,?)", quoteIdent(c.name))
+	_, err := c.db.Exec(q, key, value, expires)
+	return err
+}
+
+// Invalidate removes the key from the cache
+func (c *CacheTable) Invalidate(key string) error {
+	_, err := c.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE key=?", quoteIdent(c.name)), key)
+	return err
+}
+
+// Purge removes the expired entries from the cache, returning how many were removed
+func (c *CacheTable) Purge() (int64, error) {
+	result, err := c.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE expires <= ?", quoteIdent(c.name)), cacheNow())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
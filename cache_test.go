@@ -0,0 +1,66 @@
+package sqlite
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	db := memDB(t)
+	var loads int32
+	release := make(chan struct{})
+	loader := func(key string) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		if key == "bad" {
+			return nil, errors.New("cannot load")
+		}
+		return []byte("value of " + key), nil
+	}
+	c, err := Cache(db, "memo", 50*time.Millisecond, loader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// concurrent misses share a single load
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.Get("a"); err != nil || string(v) != "value of a" {
+				t.Errorf("unexpected value: %q (%v)", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Fatalf("expected one load but got: %d", n)
+	}
+
+	if _, err := c.Get("a"); err != nil || atomic.LoadInt32(&loads) != 1 {
+		t.Fatalf("expected cached value but got loads: %d (%v)", loads, err)
+	}
+	if _, err := c.Get("bad"); err == nil {
+		t.Fatal("expected loader error")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if n, err := c.Purge(); err != nil || n != 1 {
+		t.Fatalf("expected one expired entry but got: %d (%v)", n, err)
+	}
+	if _, err := c.Get("a"); err != nil || atomic.LoadInt32(&loads) != 3 {
+		t.Fatalf("expected reload but got loads: %d (%v)", loads, err)
+	}
+	if err := c.Invalidate("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("a"); err != nil || atomic.LoadInt32(&loads) != 4 {
+		t.Fatalf("expected reload but got loads: %d (%v)", loads, err)
+	}
+}
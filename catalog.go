@@ -0,0 +1,150 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupRecord is a backup recorded in a catalog, see BackupScheduler.Catalog
+type BackupRecord struct {
+	Name        string
+	Dest        string // the destination it is stored in
+	Path        string // of the backup in its destination
+	Size        int64
+	Checksum    string // the SHA-256 of the backup, in hex
+	Taken       time.Time
+	Duration    time.Duration // of the backup, before it was stored
+	DataVersion int64         // "PRAGMA data_version" of the source when it was taken
+}
+
+// Remover is a Destination that can remove the backups it stores, so they can be pruned
+type Remover interface {
+	Remove(name string) error
+}
+
+// PruneOptions are the backups kept by PruneBackups
+type PruneOptions struct {
+	Keep   int           // the newest backups kept, regardless of their age
+	MaxAge time.Duration // others are kept until this old, if not zero
+}
+
+const catalogSchema = `CREATE TABLE IF NOT EXISTS backups (
+  name TEXT PRIMARY KEY,
+  dest TEXT NOT NULL,
+  path TEXT NOT NULL,
+  size INTEGER NOT NULL,
+  checksum TEXT NOT NULL,
+  taken TEXT NOT NULL,
+  duration INTEGER NOT NULL,
+  data_version INTEGER
+)`
+
+// catalogTime is the format of the times of the catalog, which SQLite can compare
+const catalogTime = "2006-01-02 15:04:05.000"
+
+// Remove removes the backup from the directory
+func (d DirDestination) Remove(name string) error {
+	err := os.Remove(filepath.Join(string(d), name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// backupPath returns the path of the backup in the destination
+func backupPath(dest Destination, name string) string {
+	if dir, ok := dest.(DirDestination); ok {
+		return filepath.Join(string(dir), name)
+	}
+	return strings.TrimSuffix(fmt.Sprint(dest), "/") + "/" + name
+}
+
+// recordBackup adds the backup to the catalog, creating its table if need be
+func recordBackup(catalog *sql.DB, r BackupRecord) error {
+	if _, err := catalog.Exec(catalogSchema); err != nil {
+		return err
+	}
+	const insert = `INSERT OR REPLACE INTO backups (name, dest, path, size, checksum, taken, duration, data_version)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := catalog.Exec(insert, r.Name, r.Dest, r.Path, r.Size, r.Checksum,
+		r.Taken.UTC().Format(catalogTime), int64(r.Duration), r.DataVersion)
+	return err
+}
+
+// ListBackups returns the backups recorded in the catalog, newest first
+func ListBackups(catalog *sql.DB) ([]BackupRecord, error) {
+	return QueryBackups(catalog, nil)
+}
+
+// QueryBackups returns the backups recorded in the catalog that the filter matches,
+// newest first. The filter is of the columns of the catalog's table "backups", e.g.
+//
+//	QueryBackups(catalog, W().Ge("taken", "2021-06-01").Eq("dest", "/var/backups"))
+func QueryBackups(catalog *sql.DB, filter *Where) ([]BackupRecord, error) {
+	if _, err := catalog.Exec(catalogSchema); err != nil {
+		return nil, err
+	}
+	clause, args := whereClause("", filter)
+	q := `SELECT name, dest, path, size, checksum, taken, duration, data_version FROM backups` +
+		clause + ` ORDER BY taken DESC, name DESC`
+	rows, err := catalog.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []BackupRecord
+	for rows.Next() {
+		var r BackupRecord
+		var taken string
+		var duration int64
+		var version sql.NullInt64
+		if err := rows.Scan(&r.Name, &r.Dest, &r.Path, &r.Size, &r.Checksum, &taken, &duration, &version); err != nil {
+			return nil, err
+		}
+		if r.Taken, err = time.Parse(catalogTime, taken); err != nil {
+			return nil, fmt.Errorf("backup: %s, invalid time: %w", r.Name, err)
+		}
+		r.Duration, r.DataVersion = time.Duration(duration), version.Int64
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// PruneBackups removes the backups of the destination recorded in the catalog that the
+// options don't keep, from the destination, which must be a Remover, and the catalog.
+// It returns the records of those removed
+func PruneBackups(catalog *sql.DB, dest Destination, opts *PruneOptions) ([]BackupRecord, error) {
+	if catalog == nil {
+		return nil, errors.New("no backup catalog")
+	}
+	if opts == nil {
+		opts = &PruneOptions{}
+	}
+	remover, ok := dest.(Remover)
+	if !ok {
+		return nil, fmt.Errorf("backups can't be removed from: %v", dest)
+	}
+	records, err := QueryBackups(catalog, W().Eq("dest", fmt.Sprint(dest)))
+	if err != nil {
+		return nil, err
+	}
+	var pruned []BackupRecord
+	for i, r := range records {
+		if i < opts.Keep || (opts.MaxAge > 0 && time.Since(r.Taken) < opts.MaxAge) {
+			continue
+		}
+		if err := remover.Remove(r.Name); err != nil {
+			return pruned, fmt.Errorf("backup: %s, error: %w", r.Name, err)
+		}
+		if _, err := catalog.Exec("DELETE FROM backups WHERE name=?", r.Name); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, r)
+	}
+	return pruned, nil
+}
@@ -0,0 +1,114 @@
+package sqlite
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupCatalog(t *testing.T) {
+	dir := t.TempDir()
+	db := fileDB(t, dir)
+	catalog, err := Open(filepath.Join(dir, "catalog.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer catalog.Close()
+
+	dest := DirDestination(filepath.Join(dir, "backups"))
+	s := &BackupScheduler{DB: db, Dest: dest, Prefix: "first-", Catalog: catalog}
+	name, err := s.RunOnce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := ListBackups(catalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 backup but got: %d", len(records))
+	}
+	r := records[0]
+	path := filepath.Join(string(dest), name)
+	if r.Name != name || r.Path != path || r.Dest != string(dest) {
+		t.Errorf("unexpected record: %+v", r)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(b)
+	if r.Size != int64(len(b)) || r.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected size %d and checksum of the file but got: %+v", len(b), r)
+	}
+	if time.Since(r.Taken) > time.Minute || r.Duration <= 0 {
+		t.Errorf("unexpected time: %v, duration: %v", r.Taken, r.Duration)
+	}
+
+	// older backups, recorded as of their time
+	for i, age := range []time.Duration{time.Hour, 48 * time.Hour, 72 * time.Hour} {
+		old := BackupRecord{
+			Name:  s.Prefix + string(rune('a'+i)) + ".db",
+			Dest:  string(dest),
+			Taken: time.Now().Add(-age),
+		}
+		if err := ioutil.WriteFile(filepath.Join(string(dest), old.Name), nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := recordBackup(catalog, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recordBackup(catalog, BackupRecord{Name: "elsewhere.db", Dest: "/elsewhere", Taken: time.Now().Add(-100 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	records, err = ListBackups(catalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 || records[0].Name != name || records[4].Name != "elsewhere.db" {
+		t.Fatalf("expected backups newest first but got: %+v", records)
+	}
+	records, err = QueryBackups(catalog, W().Lt("taken", time.Now().Add(-24*time.Hour).UTC().Format(catalogTime)).Eq("dest", string(dest)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Errorf("expected 2 backups older than a day but got: %d", len(records))
+	}
+
+	pruned, err := s.Prune(&PruneOptions{Keep: 1, MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 2 || pruned[0].Name != "first-b.db" || pruned[1].Name != "first-c.db" {
+		t.Errorf("unexpected backups pruned: %+v", pruned)
+	}
+	if _, err := os.Stat(filepath.Join(string(dest), "first-b.db")); !os.IsNotExist(err) {
+		t.Errorf("expected pruned backup to be removed: %v", err)
+	}
+	pruned, err = PruneBackups(catalog, dest, &PruneOptions{Keep: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 1 || pruned[0].Name != "first-a.db" {
+		t.Errorf("unexpected backups pruned: %+v", pruned)
+	}
+	records, err = ListBackups(catalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Name != name {
+		t.Errorf("expected the newest backup and that of the other destination but got: %+v", records)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected the newest backup to be kept: %v", err)
+	}
+	if _, err := PruneBackups(nil, dest, nil); err == nil {
+		t.Error("expected error without a catalog")
+	}
+}
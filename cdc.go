@@ -0,0 +1,377 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// Change is a change to a row, as captured by a ChangeCapture
+type Change struct {
+	Seq      int64                  `json:"seq"` // its position in the change log
+	Op       string                 `json:"op"`  // INSERT, UPDATE or DELETE
+	Database string                 `json:"database"`
+	Table    string                 `json:"table"`
+	RowID    int64                  `json:"rowid"`
+	Row      map[string]interface{} `json:"row,omitempty"` // the row when the change was logged, nil if it was gone by then
+	Time     time.Time              `json:"time"`
+}
+
+// ChangeSink receives the changes exported by a ChangeExporter.
+// If it fails, the changes are sent again on the next export
+type ChangeSink interface {
+	Publish(changes []Change) error
+}
+
+// ChangeFunc is a ChangeSink calling a function, e.g. one producing to a message queue
+type ChangeFunc func(changes []Change) error
+
+// Publish implements ChangeSink
+func (fn ChangeFunc) Publish(changes []Change) error {
+	return fn(changes)
+}
+
+// FileSink writes changes to w as lines of JSON
+func FileSink(w io.Writer) ChangeSink {
+	enc := json.NewEncoder(w)
+	return ChangeFunc(func(changes []Change) error {
+		for _, c := range changes {
+			if err := enc.Encode(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WebhookSink posts changes to the url as a JSON array, with client (or the default client if nil).
+// A response other than 2xx fails the export
+func WebhookSink(url string, client *http.Client) ChangeSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return ChangeFunc(func(changes []Change) error {
+		body, err := json.Marshal(changes)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("webhook: %s, status: %s", url, resp.Status)
+		}
+		return nil
+	})
+}
+
+// cdcPrefix is the prefix of the tables of the change log, whose changes aren't captured,
+// nor are those of SQLite's own tables, e.g. sqlite_sequence
+const cdcPrefix = "_cdc_"
+
+const cdcSchema = `CREATE TABLE IF NOT EXISTS _cdc_log (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	op TEXT NOT NULL,
+	db TEXT NOT NULL,
+	tbl TEXT NOT NULL,
+	row_id INTEGER NOT NULL,
+	data TEXT,
+	time INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS _cdc_offsets (
+	sink TEXT PRIMARY KEY,
+	seq INTEGER NOT NULL
+);`
+
+// ChangeCapture captures the changes committed by the connections of a database, with
+// SQLite's update hook, until they are logged by a ChangeExporter. It is added with
+// WithChangeCapture. Changes are captured as the rowids of the rows changed, and only
+// for tables with rowids; the hook doesn't see changes made by other processes
+type ChangeCapture struct {
+	mu      sync.Mutex
+	tables  map[string]bool
+	pending map[*sqlite3.SQLiteConn][]Change // changes of open transactions
+	queue   []Change                         // committed changes yet to be logged
+}
+
+// NewChangeCapture returns a capture of the changes to the tables, or to all tables if none are given
+func NewChangeCapture(tables ...string) *ChangeCapture {
+	cc := &ChangeCapture{tables: make(map[string]bool), pending: make(map[*sqlite3.SQLiteConn][]Change)}
+	for _, table := range tables {
+		cc.tables[strings.ToLower(table)] = true
+	}
+	return cc
+}
+
+// WithChangeCapture captures the changes made by each connection with cc
+func WithChangeCapture(cc *ChangeCapture) Optional {
+	return func(c *Config) {
+		c.capture = cc
+	}
+}
+
+// rowWatcher is told of the rows changed by a connection, and whether they're committed,
+// as SQLite has a single update, commit, and rollback hook for each connection
+type rowWatcher interface {
+	changed(conn *sqlite3.SQLiteConn, op int, db, table string, rowid int64)
+	committed(conn *sqlite3.SQLiteConn)
+	rolledBack(conn *sqlite3.SQLiteConn)
+}
+
+// watchRows sets the hooks of the connection to tell the watchers of its changes
+func watchRows(conn *sqlite3.SQLiteConn, watchers ...rowWatcher) {
+	if len(watchers) == 0 {
+		return
+	}
+	conn.RegisterUpdateHook(func(op int, db, table string, rowid int64) {
+		for _, w := range watchers {
+			w.changed(conn, op, db, table, rowid)
+		}
+	})
+	conn.RegisterCommitHook(func() int {
+		for _, w := range watchers {
+			w.committed(conn)
+		}
+		return 0
+	})
+	conn.RegisterRollbackHook(func() {
+		for _, w := range watchers {
+			w.rolledBack(conn)
+		}
+	})
+}
+
+// changed captures a change of the connection
+func (cc *ChangeCapture) changed(conn *sqlite3.SQLiteConn, op int, db, table string, rowid int64) {
+	if db == "temp" || strings.HasPrefix(table, cdcPrefix) || strings.HasPrefix(table, "sqlite_") {
+		return
+	}
+	if len(cc.tables) > 0 && !cc.tables[strings.ToLower(table)] {
+		return
+	}
+	c := Change{Database: db, Table: table, RowID: rowid, Time: time.Now()}
+	switch op {
+	case sqlite3.SQLITE_INSERT:
+		c.Op = "INSERT"
+	case sqlite3.SQLITE_UPDATE:
+		c.Op = "UPDATE"
+	case sqlite3.SQLITE_DELETE:
+		c.Op = "DELETE"
+	}
+	cc.mu.Lock()
+	cc.pending[conn] = append(cc.pending[conn], c)
+	cc.mu.Unlock()
+}
+
+// committed queues the changes of the connection's transaction
+func (cc *ChangeCapture) committed(conn *sqlite3.SQLiteConn) {
+	cc.mu.Lock()
+	cc.queue = append(cc.queue, cc.pending[conn]...)
+	delete(cc.pending, conn)
+	cc.mu.Unlock()
+}
+
+// rolledBack drops the changes of the connection's transaction
+func (cc *ChangeCapture) rolledBack(conn *sqlite3.SQLiteConn) {
+	cc.mu.Lock()
+	delete(cc.pending, conn)
+	cc.mu.Unlock()
+}
+
+// take returns the committed changes, leaving none
+func (cc *ChangeCapture) take() []Change {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	changes := cc.queue
+	cc.queue = nil
+	// forget connections closed in a transaction
+	rmu.Lock()
+	for conn := range cc.pending {
+		if _, ok := registry[conn]; !ok {
+			delete(cc.pending, conn)
+		}
+	}
+	rmu.Unlock()
+	return changes
+}
+
+// putBack returns changes that couldn't be logged to the front of the queue
+func (cc *ChangeCapture) putBack(changes []Change) {
+	cc.mu.Lock()
+	cc.queue = append(changes, cc.queue...)
+	cc.mu.Unlock()
+}
+
+// ChangeExporter delivers the changes captured in a database to a sink, at least once.
+// Captured changes are first written to a change log in the database, the table _cdc_log,
+// and then published from there, with the position of the last change the sink accepted
+// kept in the table _cdc_offsets, so changes are published again if the sink fails, or
+// the process stops before the position is saved. Changes captured but not yet logged
+// when the process stops are lost, so Export should be run often, e.g. by Run.
+// Any number of exporters, with different names, can deliver the same changes
+type ChangeExporter struct {
+	db    *sql.DB
+	cc    *ChangeCapture
+	name  string
+	sink  ChangeSink
+	Batch int // the most changes published at once, 100 if not set
+}
+
+// NewChangeExporter returns an exporter of the changes captured by cc in db, to the sink
+// of the given name, creating the change log if need be
+func NewChangeExporter(db *sql.DB, cc *ChangeCapture, name string, sink ChangeSink) (*ChangeExporter, error) {
+	if _, err := db.Exec(cdcSchema); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec("INSERT OR IGNORE INTO _cdc_offsets (sink, seq) VALUES(?, 0)", name); err != nil {
+		return nil, err
+	}
+	return &ChangeExporter{db: db, cc: cc, name: name, sink: sink}, nil
+}
+
+// Export logs the changes captured, then publishes those not yet accepted by the sink,
+// returning the number published
+func (e *ChangeExporter) Export() (int, error) {
+	if err := e.log(); err != nil {
+		return 0, err
+	}
+	batch := e.Batch
+	if batch <= 0 {
+		batch = 100
+	}
+	published := 0
+	for {
+		changes, err := e.next(batch)
+		if err != nil {
+			return published, err
+		}
+		if len(changes) == 0 {
+			break
+		}
+		if err := e.sink.Publish(changes); err != nil {
+			return published, fmt.Errorf("sink: %s, error: %w", e.name, err)
+		}
+		last := changes[len(changes)-1].Seq
+		if _, err := e.db.Exec("UPDATE _cdc_offsets SET seq=? WHERE sink=?", last, e.name); err != nil {
+			return published, err
+		}
+		published += len(changes)
+		if len(changes) < batch {
+			break
+		}
+	}
+	// changes published to every sink are no longer needed
+	_, err := e.db.Exec("DELETE FROM _cdc_log WHERE seq <= (SELECT min(seq) FROM _cdc_offsets)")
+	return published, err
+}
+
+// Run exports changes at the interval until the context is done, or an export fails
+func (e *ChangeExporter) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := e.Export(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			_, err := e.Export()
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// log writes the captured changes to the change log, with the rows as they are now
+func (e *ChangeExporter) log() error {
+	changes := e.cc.take()
+	if len(changes) == 0 {
+		return nil
+	}
+	err := func() error {
+		tx, err := e.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		const insert = "INSERT INTO _cdc_log (op, db, tbl, row_id, data, time) VALUES(?,?,?,?,?,?)"
+		for _, c := range changes {
+			var data interface{}
+			if c.Op != "DELETE" {
+				row, err := changedRow(tx, c)
+				if err != nil {
+					return err
+				}
+				if row != nil {
+					b, err := json.Marshal(row)
+					if err != nil {
+						return err
+					}
+					data = string(b)
+				}
+			}
+			if _, err := tx.Exec(insert, c.Op, c.Database, c.Table, c.RowID, data, c.Time.UnixNano()); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	}()
+	if err != nil {
+		e.cc.putBack(changes)
+	}
+	return err
+}
+
+// changedRow returns the row of the change, nil if it's gone
+func changedRow(tx *sql.Tx, c Change) (map[string]interface{}, error) {
+	var row map[string]interface{}
+	fn := func(columns []string, values []interface{}) {
+		row = make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			row[col] = values[i]
+		}
+	}
+	q := fmt.Sprintf("SELECT * FROM %s.%s WHERE rowid=?", quoteIdent(c.Database), quoteIdent(c.Table))
+	return row, query(tx, fn, q, c.RowID)
+}
+
+// next returns the logged changes after those accepted by the sink
+func (e *ChangeExporter) next(limit int) ([]Change, error) {
+	const q = `SELECT seq, op, db, tbl, row_id, data, time FROM _cdc_log
+WHERE seq > (SELECT seq FROM _cdc_offsets WHERE sink=?) ORDER BY seq LIMIT ?`
+	rows, err := e.db.Query(q, e.name, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var changes []Change
+	for rows.Next() {
+		var c Change
+		var data sql.NullString
+		var t int64
+		if err := rows.Scan(&c.Seq, &c.Op, &c.Database, &c.Table, &c.RowID, &data, &t); err != nil {
+			return nil, err
+		}
+		if data.Valid {
+			if err := json.Unmarshal([]byte(data.String), &c.Row); err != nil {
+				return nil, err
+			}
+		}
+		c.Time = time.Unix(0, t)
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,119 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChangeExporter(t *testing.T) {
+	cc := NewChangeCapture("users")
+	db, err := Open(filepath.Join(t.TempDir(), "cdc.db"), WithChangeCapture(cc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, q := range []string{
+		"create table users (id integer primary key, name text)",
+		"create table other (id integer primary key)",
+		"insert into users (name) values ('alice'), ('bob')",
+		"insert into other (id) values (1)",
+		"update users set name='carol' where id=2",
+		"delete from users where id=1",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// rolled back changes aren't captured
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Exec("insert into users (name) values ('dave')")
+	tx.Rollback()
+
+	var got []Change
+	fail := true
+	sink := ChangeFunc(func(changes []Change) error {
+		if fail {
+			return errors.New("unavailable")
+		}
+		got = append(got, changes...)
+		return nil
+	})
+	e, err := NewChangeExporter(db, cc, "test", sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Export(); err == nil {
+		t.Fatal("expected sink error")
+	}
+	fail = false
+	n, err := e.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 || len(got) != 4 {
+		t.Fatalf("got %d changes: %+v", n, got)
+	}
+	ops := []string{"INSERT", "INSERT", "UPDATE", "DELETE"}
+	for i, c := range got {
+		if c.Op != ops[i] || c.Table != "users" || c.Database != "main" {
+			t.Errorf("change %d: %+v", i, c)
+		}
+	}
+	// the row is as it was when logged, so bob is already carol
+	if got[1].Row["name"] != "carol" || got[3].Row != nil {
+		t.Errorf("unexpected rows: %+v, %+v", got[1].Row, got[3].Row)
+	}
+
+	// delivered changes aren't sent again, and are pruned
+	if n, err := e.Export(); err != nil || n != 0 {
+		t.Fatalf("exported again: %d, %v", n, err)
+	}
+	var logged int
+	if err := db.QueryRow("select count(*) from _cdc_log").Scan(&logged); err != nil {
+		t.Fatal(err)
+	}
+	if logged != 0 {
+		t.Errorf("%d changes left in log", logged)
+	}
+}
+
+func TestChangeSinks(t *testing.T) {
+	changes := []Change{{Seq: 1, Op: "INSERT", Database: "main", Table: "t", RowID: 1}}
+	var buf bytes.Buffer
+	if err := FileSink(&buf).Publish(changes); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"op":"INSERT"`) {
+		t.Errorf("unexpected output: %s", buf.String())
+	}
+
+	status := http.StatusOK
+	var posted []Change
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &posted)
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+	sink := WebhookSink(ts.URL, nil)
+	if err := sink.Publish(changes); err != nil {
+		t.Fatal(err)
+	}
+	if len(posted) != 1 || posted[0].Table != "t" {
+		t.Errorf("posted: %+v", posted)
+	}
+	status = http.StatusInternalServerError
+	if err := sink.Publish(changes); err == nil {
+		t.Error("expected webhook error")
+	}
+}
@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"strings"
+)
+
+// Kind is the kind of a statement, as classified by Classify
+type Kind string
+
+// Kinds of statements
+const (
+	KindQuery       Kind = "query"       // SELECT, VALUES, or EXPLAIN
+	KindWrite       Kind = "write"       // INSERT, UPDATE, DELETE, or REPLACE
+	KindDDL         Kind = "ddl"         // CREATE, DROP, or ALTER
+	KindTransaction Kind = "transaction" // BEGIN, COMMIT, END, ROLLBACK, SAVEPOINT, or RELEASE
+	KindPragma      Kind = "pragma"
+	KindAttach      Kind = "attach"      // ATTACH or DETACH
+	KindMaintenance Kind = "maintenance" // VACUUM, ANALYZE, or REINDEX
+	KindOther       Kind = "other"       // not recognized
+)
+
+// statementKinds are the kinds of the leading keywords of statements
+var statementKinds = map[string]Kind{
+	"SELECT":    KindQuery,
+	"VALUES":    KindQuery,
+	"EXPLAIN":   KindQuery,
+	"INSERT":    KindWrite,
+	"UPDATE":    KindWrite,
+	"DELETE":    KindWrite,
+	"REPLACE":   KindWrite,
+	"CREATE":    KindDDL,
+	"DROP":      KindDDL,
+	"ALTER":     KindDDL,
+	"BEGIN":     KindTransaction,
+	"COMMIT":    KindTransaction,
+	"END":       KindTransaction,
+	"ROLLBACK":  KindTransaction,
+	"SAVEPOINT": KindTransaction,
+	"RELEASE":   KindTransaction,
+	"PRAGMA":    KindPragma,
+	"ATTACH":    KindAttach,
+	"DETACH":    KindAttach,
+	"VACUUM":    KindMaintenance,
+	"ANALYZE":   KindMaintenance,
+	"REINDEX":   KindMaintenance,
+}
+
+// Classify returns the kind of the statement, by its leading keyword, ignoring comments.
+// The kind of a statement with common table expressions is that of the statement
+// following them, e.g. a write for "WITH old AS (...) DELETE FROM ..."
+func Classify(stmt string) Kind {
+	with := false
+	depth := 0
+	for _, tok := range fingerprintToken.FindAllString(stmt, -1) {
+		switch {
+		case strings.TrimSpace(tok) == "", strings.HasPrefix(tok, "--"), strings.HasPrefix(tok, "/*"):
+			continue
+		case tok == "(":
+			depth++
+			continue
+		case tok == ")":
+			depth--
+			continue
+		}
+		upper := strings.ToUpper(tok)
+		if !with {
+			if upper == "WITH" {
+				with = true
+				continue
+			}
+			if kind, ok := statementKinds[upper]; ok {
+				return kind
+			}
+			return KindOther
+		}
+		if depth > 0 {
+			continue
+		}
+		// the CTEs are in parentheses, so the first statement keyword outside them follows them
+		if kind, ok := statementKinds[upper]; ok && (kind == KindQuery || kind == KindWrite) {
+			return kind
+		}
+	}
+	return KindOther
+}
+
+// allowed reports whether the kind is among those allowed, all of them if none are listed
+func allowed(kind Kind, allow []Kind) bool {
+	if len(allow) == 0 {
+		return true
+	}
+	for _, k := range allow {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
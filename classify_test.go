@@ -0,0 +1,39 @@
+package sqlite
+
+import (
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		sql  string
+		kind Kind
+	}{
+		{"select * from t", KindQuery},
+		{"  -- leading comment\n/* and another */ SELECT 1", KindQuery},
+		{"values(1),(2)", KindQuery},
+		{"explain query plan select 1", KindQuery},
+		{"insert into t values(1)", KindWrite},
+		{"replace into t values(1)", KindWrite},
+		{"with old(id) as (select id from t where x < 1) delete from t where id in old", KindWrite},
+		{"WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i+1 FROM n WHERE i < 5) SELECT i FROM n", KindQuery},
+		{"with x as (insert) update t set a = 1", KindWrite},
+		{"create table t (a)", KindDDL},
+		{"Drop Index i", KindDDL},
+		{"alter table t add column b", KindDDL},
+		{"begin immediate", KindTransaction},
+		{"savepoint a", KindTransaction},
+		{"pragma journal_mode=wal", KindPragma},
+		{"attach 'other.db' as other", KindAttach},
+		{"detach other", KindAttach},
+		{"vacuum", KindMaintenance},
+		{"analyze", KindMaintenance},
+		{"frobnicate", KindOther},
+		{"", KindOther},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.sql); got != tt.kind {
+			t.Errorf("%q: expected %s but got %s", tt.sql, tt.kind, got)
+		}
+	}
+}
@@ -1,30 +1,127 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 
 	"github.com/paulstuart/sqlite"
 )
 
+// result reports the outcome of loading a source for --json-output
+type result struct {
+	Source string `json:"source"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Printf("Usage: %s <db-file> <sql-file>\n", os.Args[0])
+	echo := flag.Bool("echo", false, "echo each statement before executing it")
+	jsonOutput := flag.Bool("json-output", false, "report the outcome of each source as JSON")
+	tx := flag.Bool("tx", false, "load all sources in a single transaction")
+	flag.BoolVar(&sqlite.EchoComments, "comments", false, "include comments when echoing statements")
+	output := flag.String("o", "", "write query results to this file instead of stdout")
+	pragmas := flag.Bool("pragmas", false, "print the database's pragmas as JSON and exit")
+	flag.IntVar(&sqlite.DefaultLimit, "limit", sqlite.DefaultLimit, "maximum rows shown per query, 0 for no limit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] <db-file> [sql-file...]\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "SQL is read from stdin if no files are given, or for a file named \"-\"")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
 		os.Exit(1)
 	}
-	polygon := sqlite.FuncReg{"polygon", sqlite.ToPolygon, true}
-	db, err := sqlite.Open(os.Args[1], sqlite.WithFunctions(polygon))
+	sources := flag.Args()[1:]
+	if len(sources) == 0 {
+		sources = []string{"-"}
+	}
+
+	db, err := sqlite.Open(flag.Arg(0), sqlite.WithFunctions(sqlite.GeoFuncs...), sqlite.WithAggregates(sqlite.GeoAggregates...))
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
-	file, err := os.ReadFile(os.Args[2])
-	if err != nil {
-		log.Fatal(err)
+	if *pragmas {
+		if err := sqlite.WritePragmas(db, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	if _, err := db.Exec(string(file)); err != nil {
-		log.Fatal(err)
+
+	// echoed statements go to stderr, keeping stdout for results
+	sio := sqlite.ShellIO{Results: os.Stdout, Echo: os.Stderr}
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		sio.Results = f
+	}
+	sh := sqlite.NewShell(db, sio)
+
+	if *tx {
+		// statements must share the connection holding the transaction
+		db.SetMaxOpenConns(1)
+		if _, err := db.Exec("BEGIN"); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var results []result
+	failed := false
+	for _, source := range sources {
+		var err error
+		sh.Echo = *echo
+		if source == "-" {
+			var buf []byte
+			if buf, err = ioutil.ReadAll(os.Stdin); err == nil {
+				err = sh.Run(string(buf))
+			}
+			source = "stdin"
+		} else {
+			err = sh.File(source)
+		}
+		r := result{Source: source, OK: err == nil}
+		if err != nil {
+			r.Error = err.Error()
+			failed = true
+			if !*jsonOutput {
+				log.Printf("%s: %v", source, err)
+			}
+		}
+		results = append(results, r)
+		if failed {
+			break
+		}
+	}
+
+	if *tx {
+		end := "COMMIT"
+		if failed {
+			end = "ROLLBACK"
+		}
+		if _, err := db.Exec(end); err != nil {
+			log.Printf("%s: %v", end, err)
+			failed = true
+		}
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if failed {
+		db.Close()
+		os.Exit(1)
 	}
 }
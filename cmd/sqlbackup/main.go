@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/paulstuart/sqlite"
+)
+
+// exit codes, suitable for cron and monitoring
+const (
+	exitOK      = 0
+	exitFailed  = 1
+	exitUsage   = 2
+	exitCorrupt = 3
+)
+
+const usage = `Usage: %s <command> [options] <args>
+
+Commands:
+  backup   [-progress] <db-file> <dest>   back up the database
+  restore  [-progress] <db-file> <backup> replace the database with the backup
+  verify   <file>...                      check the integrity of database files
+  schedule [-progress] [-every duration] <db-file> <dest>
+                                          back up periodically until interrupted
+
+A destination is a file path, a directory (existing, or ending in "/"),
+or s3://bucket/prefix using the standard AWS environment variables.
+
+Exit codes: 0 success, 1 failure, 2 usage error, 3 integrity check failed
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		os.Exit(exitUsage)
+	}
+
+	cmd, args := os.Args[1], os.Args[2:]
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	progress := fs.Bool("progress", false, "report progress to stderr")
+	every := fs.Duration("every", time.Hour, "interval between scheduled backups")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+	}
+	if err := fs.Parse(args); err != nil {
+		os.Exit(exitUsage)
+	}
+	args = fs.Args()
+
+	var w io.Writer = ioutil.Discard
+	if *progress {
+		w = os.Stderr
+	}
+
+	switch cmd {
+	case "backup":
+		needArgs(args, 2)
+		os.Exit(doBackup(args[0], args[1], w))
+	case "restore":
+		needArgs(args, 2)
+		os.Exit(doRestore(args[0], args[1], w))
+	case "verify":
+		if len(args) < 1 {
+			fs.Usage()
+			os.Exit(exitUsage)
+		}
+		os.Exit(doVerify(args...))
+	case "schedule":
+		needArgs(args, 2)
+		os.Exit(doSchedule(args[0], args[1], *every, w))
+	default:
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+}
+
+func needArgs(args []string, n int) {
+	if len(args) != n {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		os.Exit(exitUsage)
+	}
+}
+
+// destination returns the storage for a non-file destination, or nil
+func destination(dest string) (sqlite.Destination, error) {
+	if strings.HasPrefix(dest, "s3://") {
+		return newS3Destination(dest)
+	}
+	if strings.HasSuffix(dest, "/") {
+		return sqlite.DirDestination(dest), nil
+	}
+	if info, err := os.Stat(dest); err == nil && info.IsDir() {
+		return sqlite.DirDestination(dest), nil
+	}
+	return nil, nil
+}
+
+func scheduler(file, dest string, w io.Writer) (*sqlite.BackupScheduler, func(), error) {
+	to, err := destination(dest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if to == nil {
+		return nil, nil, fmt.Errorf("scheduled backups require a directory or s3 destination, not %q", dest)
+	}
+	db, err := sqlite.Open(file, sqlite.WithExists(true))
+	if err != nil {
+		return nil, nil, err
+	}
+	base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	s := &sqlite.BackupScheduler{
+		DB:       db,
+		Dest:     to,
+		Prefix:   base + "-",
+		Progress: w,
+		OnBackup: func(name string) {
+			log.Printf("backed up %s to %s%s", file, dest, name)
+		},
+	}
+	return s, func() { db.Close() }, nil
+}
+
+func doBackup(file, dest string, w io.Writer) int {
+	if to, err := destination(dest); err != nil {
+		log.Println(err)
+		return exitFailed
+	} else if to != nil {
+		s, done, err := scheduler(file, dest, w)
+		if err != nil {
+			log.Println(err)
+			return exitFailed
+		}
+		defer done()
+		if _, err := s.RunOnce(); err != nil {
+			log.Println(err)
+			return exitFailed
+		}
+		return exitOK
+	}
+
+	db, err := sqlite.Open(file, sqlite.WithExists(true))
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	defer db.Close()
+
+	if err := sqlite.BackupProgress(db, dest, w); err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+func doRestore(file, src string, w io.Writer) int {
+	if err := sqlite.Verify(src); err != nil {
+		log.Printf("backup %s: %v", src, err)
+		return exitCorrupt
+	}
+	db, err := sqlite.Open(file)
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	defer db.Close()
+
+	if err := sqlite.Restore(db, src, w); err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+func doVerify(files ...string) int {
+	code := exitOK
+	for _, file := range files {
+		if err := sqlite.Verify(file); err != nil {
+			log.Printf("%s: %v", file, err)
+			code = exitCorrupt
+		} else {
+			fmt.Printf("%s: ok\n", file)
+		}
+	}
+	return code
+}
+
+func doSchedule(file, dest string, every time.Duration, w io.Writer) int {
+	s, done, err := scheduler(file, dest, w)
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	defer done()
+	s.Interval = every
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	if err := s.Run(ctx); err != nil && err != context.Canceled {
+		log.Println(err)
+		return exitFailed
+	}
+	return exitOK
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/paulstuart/sqlite/bench"
+)
+
+func main() {
+	var names []string
+	for _, p := range bench.Profiles {
+		names = append(names, p.Name)
+	}
+	var (
+		file        = flag.String("file", "sqlbench.db", "database file to create for each run (removed afterwards)")
+		profiles    = flag.String("profiles", strings.Join(names, ","), "comma separated pragma profiles to run")
+		duration    = flag.Duration("duration", 10*time.Second, "duration of each run")
+		concurrency = flag.Int("concurrency", 4, "number of concurrent workers")
+		readRatio   = flag.Float64("read-ratio", 0.8, "fraction of operations that are reads")
+		payload     = flag.Int("payload", 256, "bytes written per row")
+		rows        = flag.Int("rows", 10000, "rows loaded before each run")
+		keep        = flag.Bool("keep", false, "keep the database file after the last run")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options]\n", os.Args[0])
+		for _, p := range bench.Profiles {
+			fmt.Fprintf(flag.CommandLine.Output(), "  profile %-12s %s\n", p.Name, strings.Join(p.Pragmas, ", "))
+		}
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *readRatio < 0 || *readRatio > 1 {
+		log.Fatal("read-ratio must be between 0 and 1")
+	}
+
+	fmt.Printf("workload: %d workers, %.0f%% reads, %d byte payload, %d rows, %v per profile\n\n",
+		*concurrency, *readRatio*100, *payload, *rows, *duration)
+	bench.ReportHeader(os.Stdout)
+	for _, name := range strings.Split(*profiles, ",") {
+		profile, err := bench.FindProfile(strings.TrimSpace(name))
+		if err != nil {
+			log.Fatal(err)
+		}
+		result, err := bench.Run(context.Background(), bench.Config{
+			File:        *file,
+			Profile:     profile,
+			Duration:    *duration,
+			Concurrency: *concurrency,
+			ReadRatio:   *readRatio,
+			PayloadSize: *payload,
+			Rows:        *rows,
+		})
+		if err != nil {
+			log.Fatalf("profile: %s, error: %v", profile.Name, err)
+		}
+		result.Report(os.Stdout)
+	}
+	if !*keep {
+		for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+			os.Remove(*file + suffix)
+		}
+	}
+}
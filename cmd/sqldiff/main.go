@@ -0,0 +1,159 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/paulstuart/sqlite"
+)
+
+// exit codes follow diff(1)
+const (
+	exitSame    = 0
+	exitDiffers = 1
+	exitTrouble = 2
+)
+
+func main() {
+	schemaOnly := flag.Bool("schema", false, "compare only the schema")
+	dataOnly := flag.Bool("data", false, "compare only the data")
+	emitSQL := flag.Bool("sql", false, "emit SQL that makes the first database match the second")
+	tables := flag.String("tables", "", "comma separated list of tables to compare (default all)")
+	pragmas := flag.Bool("pragmas", false, "compare only the pragmas, printing the differences as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] <from-db> <to-db>\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Exits 0 if the databases match, 1 if they differ, 2 on error")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(exitTrouble)
+	}
+	log.SetFlags(0)
+
+	from, err := sqlite.Open(flag.Arg(0), sqlite.WithExists(true))
+	if err != nil {
+		log.Println(err)
+		os.Exit(exitTrouble)
+	}
+	defer from.Close()
+	to, err := sqlite.Open(flag.Arg(1), sqlite.WithExists(true))
+	if err != nil {
+		log.Println(err)
+		os.Exit(exitTrouble)
+	}
+	defer to.Close()
+
+	if *pragmas {
+		os.Exit(comparePragmas(from, to))
+	}
+
+	var only []string
+	if *tables != "" {
+		only = strings.Split(*tables, ",")
+	}
+	wanted := func(table string) bool {
+		if len(only) == 0 {
+			return true
+		}
+		for _, t := range only {
+			if t == table {
+				return true
+			}
+		}
+		return false
+	}
+
+	differs := false
+	changed := make(map[string]bool)
+	if !*dataOnly {
+		changes, err := sqlite.SchemaDiff(from, to)
+		if err != nil {
+			log.Println(err)
+			os.Exit(exitTrouble)
+		}
+		for _, c := range changes {
+			if !wanted(c.Table) {
+				continue
+			}
+			differs = true
+			if c.Type == "table" {
+				changed[c.Name] = true
+			}
+			if *emitSQL {
+				fmt.Println(c.SQL(from, to))
+			} else {
+				fmt.Println(c)
+			}
+		}
+	}
+
+	if !*schemaOnly {
+		list := only
+		if len(list) == 0 {
+			if list, err = sqlite.Tables(from); err != nil {
+				log.Println(err)
+				os.Exit(exitTrouble)
+			}
+		}
+		var compare []string
+		for _, table := range list {
+			// rows can't be compared until the table definitions match
+			if !changed[table] {
+				compare = append(compare, table)
+			}
+		}
+		changes, err := sqlite.DataDiff(from, to, compare...)
+		if err != nil {
+			log.Println(err)
+			os.Exit(exitTrouble)
+		}
+		for _, c := range changes {
+			differs = true
+			if *emitSQL {
+				fmt.Println(c.SQL())
+			} else {
+				fmt.Println(c)
+			}
+		}
+	}
+
+	if differs {
+		os.Exit(exitDiffers)
+	}
+	os.Exit(exitSame)
+}
+
+// comparePragmas prints the pragmas that differ as JSON, and returns the exit code
+func comparePragmas(from, to *sql.DB) int {
+	a, err := sqlite.PragmaSnapshot(from)
+	if err != nil {
+		log.Println(err)
+		return exitTrouble
+	}
+	b, err := sqlite.PragmaSnapshot(to)
+	if err != nil {
+		log.Println(err)
+		return exitTrouble
+	}
+	changes := sqlite.ComparePragmas(a, b)
+	if changes == nil {
+		changes = []sqlite.PragmaChange{}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(changes); err != nil {
+		log.Println(err)
+		return exitTrouble
+	}
+	if len(changes) > 0 {
+		return exitDiffers
+	}
+	return exitSame
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paulstuart/sqlite"
+)
+
+// whereList collects repeated -where table:clause flags
+type whereList map[string]string
+
+func (w whereList) String() string {
+	return fmt.Sprint(map[string]string(w))
+}
+
+func (w whereList) Set(value string) error {
+	i := strings.Index(value, ":")
+	if i < 1 {
+		return fmt.Errorf("expected table:clause but got %q", value)
+	}
+	w[value[:i]] = value[i+1:]
+	return nil
+}
+
+func main() {
+	var (
+		tables     = flag.String("tables", "", "comma separated list of tables to export (default all)")
+		format     = flag.String("format", "sql", "output format: sql, csv, or parquet")
+		output     = flag.String("o", "", "output file (sql) or directory (csv, parquet); stdout if empty")
+		schemaOnly = flag.Bool("schema-only", false, "dump only the schema (sql format)")
+		dataOnly   = flag.Bool("data-only", false, "dump only the data (sql format)")
+		pragmas    = flag.Bool("pragmas", false, "print the database's pragmas as JSON and exit")
+		scrubFile  = flag.String("scrub", "", "JSON file of rules for scrubbing sensitive columns (sql format)")
+		encoding   = flag.String("encoding", "utf-8", "encoding of the output: utf-8, utf-16, utf-16le, utf-16be, latin-1, or shift-jis (csv format)")
+		where      = make(whereList)
+	)
+	flag.Var(where, "where", "filter rows of a table, as table:clause (repeatable)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] <db-file>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	db, err := sqlite.Open(flag.Arg(0), sqlite.WithExists(true))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	if *pragmas {
+		if err := sqlite.WritePragmas(db, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var list []string
+	if *tables != "" {
+		list = strings.Split(*tables, ",")
+	}
+
+	switch *format {
+	case "sql":
+		w, closer := create(*output)
+		defer closer()
+		opts := &sqlite.DumpOptions{
+			Tables:     list,
+			Where:      where,
+			SchemaOnly: *schemaOnly,
+			DataOnly:   *dataOnly,
+		}
+		if *scrubFile != "" {
+			if opts.Scrub, err = loadScrubber(*scrubFile); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err := sqlite.Dump(db, w, opts); err != nil {
+			log.Fatal(err)
+		}
+	case "csv", "parquet":
+		if len(list) == 0 {
+			if list, err = sqlite.Tables(db); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *output == "" && len(list) > 1 {
+			log.Fatalf("an output directory is required when exporting multiple tables as %s", *format)
+		}
+		for _, table := range list {
+			name := ""
+			if *output != "" {
+				name = filepath.Join(*output, table+"."+*format)
+			}
+			w, closer := create(name)
+			if err := export(db, w, *format, *encoding, table, where[table]); err != nil {
+				log.Fatalf("table: %s, error: %v", table, err)
+			}
+			closer()
+		}
+	default:
+		log.Fatalf("unknown format: %q", *format)
+	}
+}
+
+// export writes the rows of the table in the format, parquet or csv in the encoding
+func export(db *sql.DB, w io.Writer, format, encoding, table, where string) error {
+	if format == "parquet" {
+		return sqlite.ExportTableParquet(db, w, table, where)
+	}
+	ew, err := sqlite.EncodeWriter(w, encoding)
+	if err != nil {
+		return err
+	}
+	err = sqlite.ExportTableCSV(db, ew, table, where)
+	if cerr := ew.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// create opens the named output file, or stdout if no name is given
+func create(name string) (io.Writer, func()) {
+	if name == "" {
+		return os.Stdout, func() {}
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		log.Fatal(err)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return f, func() {
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// loadScrubber reads scrubbing rules, e.g.:
+//
+//	{"salt": "s3cret", "rules": [{"table": "users", "column": "email", "action": "hash"}]}
+func loadScrubber(file string) (*sqlite.Scrubber, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	scrub := new(sqlite.Scrubber)
+	if err := json.Unmarshal(buf, scrub); err != nil {
+		return nil, fmt.Errorf("scrub file: %s, error: %w", file, err)
+	}
+	return scrub, scrub.Validate()
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/paulstuart/sqlite"
+)
+
+// column is a column of a table, as it appears in the generated code
+type column struct {
+	Name  string // in the database
+	Field string // of the struct
+	Type  string // of the field
+	Key   bool   // part of the primary key
+}
+
+// table is a table, as it appears in the generated code
+type table struct {
+	Name    string
+	Type    string // of the struct for its rows
+	Plural  string // for functions returning many rows
+	Columns []column
+	Keys    []column
+	Values  []column // columns not in the primary key
+}
+
+func main() {
+	var (
+		pkg    = flag.String("pkg", "models", "package of the generated code")
+		output = flag.String("o", "", "output file; stdout if empty")
+		tables = flag.String("tables", "", "comma separated list of tables to generate (default all)")
+		schema = flag.String("schema", "", "SQL file of the schema, instead of a database")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] <db-file>\n       %s [options] -schema <sql-file>\n", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var db *sql.DB
+	var err error
+	switch {
+	case *schema != "" && flag.NArg() == 0:
+		if db, err = sqlite.Open(":memory:"); err != nil {
+			log.Fatal(err)
+		}
+		db.SetMaxOpenConns(1)
+		if err := sqlite.File(db, *schema, false, ioutil.Discard); err != nil {
+			log.Fatal(err)
+		}
+	case *schema == "" && flag.NArg() == 1:
+		if db, err = sqlite.Open(flag.Arg(0), sqlite.WithExists(true)); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	defer db.Close()
+
+	names, err := sqlite.Tables(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *tables != "" {
+		names = strings.Split(*tables, ",")
+	}
+	var list []table
+	for _, name := range names {
+		t, err := describe(db, strings.TrimSpace(name))
+		if err != nil {
+			log.Fatal(err)
+		}
+		list = append(list, t)
+	}
+
+	src, err := generate(*pkg, list)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *output == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// describe returns the table as it appears in the generated code
+func describe(db *sql.DB, name string) (table, error) {
+	t := table{Name: name, Type: goName(singular(name)), Plural: goName(name)}
+	if t.Plural == t.Type {
+		t.Plural += "List"
+	}
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", quote(name)))
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+	type info struct {
+		name, decl  string
+		notNull, pk int
+	}
+	var infos []info
+	keys := 0
+	for rows.Next() {
+		var cid int
+		var dflt interface{}
+		var i info
+		if err := rows.Scan(&cid, &i.name, &i.decl, &i.notNull, &dflt, &i.pk); err != nil {
+			return t, err
+		}
+		if i.pk > 0 {
+			keys++
+		}
+		infos = append(infos, i)
+	}
+	if err := rows.Err(); err != nil {
+		return t, err
+	}
+	if len(infos) == 0 {
+		return t, fmt.Errorf("no such table: %s", name)
+	}
+	byKey := make(map[int]column)
+	for _, i := range infos {
+		// an INTEGER PRIMARY KEY is the rowid, which is never null
+		rowid := i.pk == 1 && keys == 1 && strings.EqualFold(i.decl, "integer")
+		c := column{Name: i.name, Field: goName(i.name), Type: goType(i.decl, i.notNull == 1 || rowid), Key: i.pk > 0}
+		t.Columns = append(t.Columns, c)
+		if i.pk > 0 {
+			byKey[i.pk] = c
+		} else {
+			t.Values = append(t.Values, c)
+		}
+	}
+	for i := 1; i <= keys; i++ {
+		t.Keys = append(t.Keys, byKey[i])
+	}
+	return t, nil
+}
+
+// goType returns the Go type of a column with the declared type, per SQLite's
+// rules for the affinity of columns, with nullable columns as pointers
+func goType(decl string, notNull bool) string {
+	upper := strings.ToUpper(decl)
+	has := func(subs ...string) bool {
+		for _, sub := range subs {
+			if strings.Contains(upper, sub) {
+				return true
+			}
+		}
+		return false
+	}
+	var typ string
+	switch {
+	case has("BOOL"):
+		typ = "bool"
+	case has("DATE", "TIME"):
+		typ = "time.Time"
+	case has("INT"):
+		typ = "int64"
+	case has("CHAR", "CLOB", "TEXT"):
+		typ = "string"
+	case has("BLOB"):
+		return "[]byte"
+	case upper == "":
+		return "interface{}"
+	default: // REAL, FLOAT, DOUBLE, and NUMERIC
+		typ = "float64"
+	}
+	if !notNull {
+		typ = "*" + typ
+	}
+	return typ
+}
+
+// initialisms are written in upper case in Go names
+var initialisms = map[string]bool{
+	"ID": true, "URL": true, "URI": true, "UUID": true, "IP": true, "HTTP": true, "JSON": true,
+	"SQL": true, "API": true, "HTML": true, "XML": true, "CPU": true, "UID": true,
+}
+
+// goName converts an SQL name, e.g. "user_id", to an exported Go name, e.g. "UserID"
+func goName(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var sb strings.Builder
+	for _, w := range words {
+		if upper := strings.ToUpper(w); initialisms[upper] {
+			sb.WriteString(upper)
+			continue
+		}
+		runes := []rune(w)
+		sb.WriteRune(unicode.ToUpper(runes[0]))
+		sb.WriteString(string(runes[1:]))
+	}
+	s := sb.String()
+	if s == "" || unicode.IsDigit([]rune(s)[0]) {
+		s = "X" + s
+	}
+	return s
+}
+
+// singular guesses the singular of a table name, e.g. "users" or "categories"
+func singular(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "xes"), strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "shes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(lower, "s") && !strings.HasSuffix(lower, "ss") && len(name) > 1:
+		return name[:len(name)-1]
+	}
+	return name
+}
+
+// quote quotes an SQL name
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// generate returns the formatted source of the code for the tables
+func generate(pkg string, tables []table) ([]byte, error) {
+	usesTime := false
+	for _, t := range tables {
+		for _, c := range t.Columns {
+			if strings.Contains(c.Type, "time.Time") {
+				usesTime = true
+			}
+		}
+	}
+	var buf bytes.Buffer
+	data := struct {
+		Package string
+		Time    bool
+		Tables  []table
+	}{pkg, usesTime, tables}
+	if err := codeTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+var codeTemplate = template.Must(template.New("code").Funcs(template.FuncMap{
+	"quote": quote,
+	"list": func(columns []column) string {
+		names := make([]string, len(columns))
+		for i, c := range columns {
+			names[i] = quote(c.Name)
+		}
+		return strings.Join(names, ", ")
+	},
+	"params": func(columns []column) string {
+		return strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	},
+	"assign": func(columns []column, sep string) string {
+		names := make([]string, len(columns))
+		for i, c := range columns {
+			names[i] = quote(c.Name) + "=?"
+		}
+		return strings.Join(names, sep)
+	},
+	"fields": func(columns []column, recv string) string {
+		names := make([]string, len(columns))
+		for i, c := range columns {
+			names[i] = recv + "." + c.Field
+		}
+		return strings.Join(names, ", ")
+	},
+}).Parse(`// Code generated by sqlgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	"database/sql"
+{{- if .Time}}
+	"time"
+{{- end}}
+
+	"github.com/paulstuart/sqlite"
+)
+
+// stream calls fn for each row of the results of the query
+func stream(db sqlite.Queryer, query string, args []interface{}, fn func(rows *sql.Rows) error) error {
+	rows, err := db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+{{range $t := .Tables}}
+// {{.Type}} is a row of the table {{.Name}}
+type {{.Type}} struct {
+{{- range .Columns}}
+	{{.Field}} {{.Type}} ` + "`sql:\"{{.Name}}\"`" + `
+{{- end}}
+}
+
+// Statements of the table {{.Name}}
+const (
+	{{.Type}}Columns = ` + "`{{list .Columns}}`" + `
+	Select{{.Plural}} = ` + "`SELECT {{list .Columns}} FROM {{quote .Name}}`" + `
+	Insert{{.Type}} = ` + "`INSERT INTO {{quote .Name}} ({{list .Columns}}) VALUES({{params .Columns}})`" + `
+{{- if .Keys}}
+	Get{{.Type}} = ` + "`SELECT {{list .Columns}} FROM {{quote .Name}} WHERE {{assign .Keys \" AND \"}}`" + `
+{{- if .Values}}
+	Update{{.Type}} = ` + "`UPDATE {{quote .Name}} SET {{assign .Values \", \"}} WHERE {{assign .Keys \" AND \"}}`" + `
+{{- end}}
+	Delete{{.Type}} = ` + "`DELETE FROM {{quote .Name}} WHERE {{assign .Keys \" AND \"}}`" + `
+{{- end}}
+)
+
+// InsertArgs returns the arguments of Insert{{.Type}}
+func (r *{{.Type}}) InsertArgs() []interface{} {
+	return []interface{}{ {{fields .Columns "r"}} }
+}
+{{if and .Keys .Values}}
+// UpdateArgs returns the arguments of Update{{.Type}}
+func (r *{{.Type}}) UpdateArgs() []interface{} {
+	return []interface{}{ {{fields .Values "r"}}, {{fields .Keys "r"}} }
+}
+{{end}}
+{{- if .Keys}}
+// KeyArgs returns the arguments of Get{{.Type}} and Delete{{.Type}}
+func (r *{{.Type}}) KeyArgs() []interface{} {
+	return []interface{}{ {{fields .Keys "r"}} }
+}
+{{end}}
+// Scan{{.Type}} scans the current row into a {{.Type}}, by the names of its columns
+func Scan{{.Type}}(rows *sql.Rows) (*{{.Type}}, error) {
+	var r {{.Type}}
+	return &r, sqlite.ScanRow(rows, &r)
+}
+
+// Stream{{.Plural}} calls fn with each row selected by the query, e.g. Select{{.Plural}} + " WHERE ..."
+func Stream{{.Plural}}(db sqlite.Queryer, query string, fn func(*{{.Type}}) error, args ...interface{}) error {
+	return stream(db, query, args, func(rows *sql.Rows) error {
+		r, err := Scan{{.Type}}(rows)
+		if err != nil {
+			return err
+		}
+		return fn(r)
+	})
+}
+{{end}}`))
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulstuart/sqlite"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGoType(t *testing.T) {
+	tests := []struct {
+		decl    string
+		notNull bool
+		want    string
+	}{
+		{"INTEGER", true, "int64"},
+		{"integer", false, "*int64"},
+		{"BIGINT", true, "int64"},
+		{"TEXT", true, "string"},
+		{"VARCHAR(255)", false, "*string"},
+		{"CLOB", true, "string"},
+		{"BOOLEAN", true, "bool"},
+		{"bool", false, "*bool"},
+		{"DATETIME", true, "time.Time"},
+		{"DATE", false, "*time.Time"},
+		{"TIMESTAMP", true, "time.Time"},
+		{"BLOB", true, "[]byte"},
+		{"BLOB", false, "[]byte"},
+		{"REAL", true, "float64"},
+		{"DOUBLE PRECISION", false, "*float64"},
+		{"NUMERIC", true, "float64"},
+		{"JSON", true, "float64"},
+		{"", true, "interface{}"},
+		{"", false, "interface{}"},
+	}
+	for _, tt := range tests {
+		if got := goType(tt.decl, tt.notNull); got != tt.want {
+			t.Errorf("goType(%q, %t): expected %q but got %q", tt.decl, tt.notNull, tt.want, got)
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"users", "Users"},
+		{"user_id", "UserID"},
+		{"user_name", "UserName"},
+		{"homepage_url", "HomepageURL"},
+		{"api-key", "APIKey"},
+		{"createdAt", "CreatedAt"},
+		{"first name", "FirstName"},
+		{"2fa", "X2fa"},
+		{"_", "X"},
+		{"", "X"},
+		{"id", "ID"},
+		{"uuid", "UUID"},
+		{"été", "Été"},
+	}
+	for _, tt := range tests {
+		if got := goName(tt.name); got != tt.want {
+			t.Errorf("goName(%q): expected %q but got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSingular(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"users", "user"},
+		{"categories", "category"},
+		{"Categories", "Category"},
+		{"classes", "class"},
+		{"boxes", "box"},
+		{"matches", "match"},
+		{"wishes", "wish"},
+		{"status", "statu"},
+		{"address", "address"},
+		{"person", "person"},
+		{"ies", "ie"},
+		{"s", "s"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := singular(tt.name); got != tt.want {
+			t.Errorf("singular(%q): expected %q but got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	db, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if err := sqlite.File(db, filepath.Join("testdata", "schema.sql"), false, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	names, err := sqlite.Tables(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []table
+	for _, name := range names {
+		tbl, err := describe(db, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, tbl)
+	}
+	if _, err := describe(db, "missing"); err == nil {
+		t.Fatal("expected error for missing table")
+	}
+	src, err := generate("models", list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "models.go.golden")
+	if *update {
+		if err := ioutil.WriteFile(golden, src, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Errorf("generated code differs from %s, run with -update to see:\n%s", golden, src)
+	}
+
+	// the generated code is built within this module, to use its sqlite package
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	dir, err := ioutil.TempDir("testdata", "build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "models.go"), src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goTool, "build", ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("generated code does not build: %v\n%s", err, out)
+	}
+}
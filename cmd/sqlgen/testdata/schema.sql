@@ -0,0 +1,26 @@
+CREATE TABLE users (
+	id INTEGER PRIMARY KEY,
+	user_name TEXT NOT NULL,
+	email VARCHAR(255),
+	active BOOLEAN NOT NULL DEFAULT 1,
+	score REAL,
+	created_at DATETIME NOT NULL,
+	avatar BLOB,
+	extra
+);
+
+CREATE TABLE categories (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL
+);
+
+CREATE TABLE user_categories (
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	category_id INTEGER NOT NULL REFERENCES categories(id),
+	PRIMARY KEY (user_id, category_id)
+);
+
+CREATE TABLE events (
+	kind TEXT,
+	payload JSON
+);
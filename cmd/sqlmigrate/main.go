@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/paulstuart/sqlite"
+)
+
+const usage = `Usage: %s [options] <db-file> <command> [arg]
+       %s -pragmas <db-file>
+
+Commands:
+  status        list applied and pending migrations
+  up [version]  apply pending migrations, up to version if given
+  down [n]      roll back the last n migrations (default 1)
+
+Options:
+`
+
+func main() {
+	dir := flag.String("dir", "migrations", "directory containing the migration files")
+	dryRun := flag.Bool("dry-run", false, "show the migrations that would run without applying them")
+	pragmas := flag.Bool("pragmas", false, "print the database's pragmas as JSON and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), usage, os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *pragmas && flag.NArg() == 1 {
+		db, err := sqlite.Open(flag.Arg(0), sqlite.WithExists(true))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+		if err := sqlite.WritePragmas(db, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if flag.NArg() < 2 || flag.NArg() > 3 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	file, cmd := flag.Arg(0), flag.Arg(1)
+	var arg int64
+	if flag.NArg() == 3 {
+		var err error
+		if arg, err = strconv.ParseInt(flag.Arg(2), 10, 64); err != nil || arg < 0 {
+			log.Fatalf("invalid argument: %q", flag.Arg(2))
+		}
+	}
+
+	migrations, err := sqlite.LoadMigrationsDir(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// only applying migrations may create the database, so a mistyped path isn't
+	// silently created by the commands that read it
+	db, err := sqlite.Open(file, sqlite.WithExists(cmd != "up" || *dryRun))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	switch cmd {
+	case "status":
+		status, err := sqlite.MigrationsStatus(db, migrations)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range status {
+			state := "pending"
+			switch {
+			case s.Missing:
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05") + " (missing file)"
+			case s.Applied:
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%6d  %-30s  %s\n", s.Version, s.Name, state)
+		}
+	case "up":
+		var list []sqlite.Migration
+		if *dryRun {
+			list, err = sqlite.PlanUp(db, migrations, arg)
+		} else {
+			list, err = sqlite.MigrateUp(db, migrations, arg)
+		}
+		report("up", list, *dryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "down":
+		steps := int(arg)
+		if steps == 0 {
+			steps = 1
+		}
+		var list []sqlite.Migration
+		if *dryRun {
+			list, err = sqlite.PlanDown(db, migrations, steps)
+		} else {
+			list, err = sqlite.MigrateDown(db, migrations, steps)
+		}
+		report("down", list, *dryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func report(direction string, list []sqlite.Migration, dryRun bool) {
+	verb := "applied"
+	if dryRun {
+		verb = "would apply"
+	}
+	if len(list) == 0 {
+		fmt.Println("nothing to do")
+	}
+	for _, m := range list {
+		fmt.Printf("%s %s %d_%s\n", verb, direction, m.Version, m.Name)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paulstuart/sqlite"
+)
+
+func main() {
+	var (
+		addr     = flag.String("addr", ":8080", "address to listen on")
+		readOnly = flag.Bool("readonly", false, "open the databases read-only and refuse writes")
+		token    = flag.String("token", os.Getenv("SQLSERVE_TOKEN"), "bearer token required of clients (default $SQLSERVE_TOKEN)")
+		basic    = flag.String("basic", os.Getenv("SQLSERVE_BASIC"), "basic auth credentials as user:password (default $SQLSERVE_BASIC)")
+		cors     = flag.String("cors", "", `comma separated origins allowed cross-origin access, "*" for any`)
+		maxRows  = flag.Int("max-rows", 10000, "limit on rows returned per query, 0 for no limit")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] <db-file>...\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Each database is served under its base name, e.g. /sales/query for sales.db")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := sqlite.HandlerOptions{
+		ReadOnly: *readOnly,
+		Token:    *token,
+		MaxRows:  *maxRows,
+	}
+	if *basic != "" {
+		i := strings.Index(*basic, ":")
+		if i < 1 {
+			log.Fatal("basic auth credentials must be given as user:password")
+		}
+		opts.Username, opts.Password = (*basic)[:i], (*basic)[i+1:]
+	}
+	if *cors != "" {
+		opts.CORSOrigins = strings.Split(*cors, ",")
+	}
+
+	dbs := make(map[string]*sql.DB)
+	for _, file := range flag.Args() {
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if _, ok := dbs[name]; ok {
+			log.Fatalf("duplicate database name: %s", name)
+		}
+		dsn := file
+		if *readOnly {
+			dsn = "file:" + file + "?mode=ro"
+		}
+		db, err := sqlite.Open(dsn, sqlite.WithExists(true))
+		if err != nil {
+			log.Fatalf("%s: %v", file, err)
+		}
+		defer db.Close()
+		dbs[name] = db
+		log.Printf("serving %s as /%s/", file, name)
+	}
+	if opts.Token == "" && opts.Username == "" {
+		log.Println("warning: no authentication configured")
+	}
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, sqlite.NewHandler(dbs, opts)))
+}
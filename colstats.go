@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// statsSample is the most rows of a table ColumnStats examines
+const statsSample = 100000
+
+// ColumnStat is the distribution of the values of a column
+type ColumnStat struct {
+	Table        string
+	Column       string
+	Rows         int64 // rows in the table
+	Sampled      int64 // rows examined, a random sample if fewer than Rows
+	Nulls        int64 // null values in the rows examined
+	NullFraction float64
+	Distinct     int64 // distinct values in the rows examined
+	Min, Max     interface{}
+	Common       []ValueCount // the most common values, most common first
+}
+
+// ValueCount is a value and the times it occurs
+type ValueCount struct {
+	Value interface{}
+	Count int64
+}
+
+// Selectivity estimates the fraction of rows matching a value of the column, as the
+// query planner would for an equality constraint, where smaller is more selective
+func (s ColumnStat) Selectivity() float64 {
+	if s.Distinct == 0 {
+		return 1
+	}
+	return 1 / float64(s.Distinct)
+}
+
+// ColumnStats returns the distribution of the values of a column, e.g. to judge whether
+// it's worth indexing. Tables of more than 100,000 rows are sampled, so their distinct
+// values and null fraction are estimates, and their min and max are those of the sample
+func ColumnStats(db *sql.DB, table, column string) (ColumnStat, error) {
+	stat := ColumnStat{Table: table, Column: column}
+	col := quoteIdent(column)
+	if err := row(db, []interface{}{&stat.Rows}, "SELECT count(*) FROM "+quoteIdent(table)); err != nil {
+		return stat, err
+	}
+	src := fmt.Sprintf("(SELECT %s AS v FROM %s)", col, quoteIdent(table))
+	if stat.Rows > statsSample {
+		src = fmt.Sprintf("(SELECT %s AS v FROM %s ORDER BY random() LIMIT %d)", col, quoteIdent(table), statsSample)
+	}
+
+	var nonNull int64
+	dest := []interface{}{&stat.Sampled, &nonNull, &stat.Distinct, &stat.Min, &stat.Max}
+	if err := row(db, dest, "SELECT count(*), count(v), count(DISTINCT v), min(v), max(v) FROM "+src); err != nil {
+		return stat, fmt.Errorf("column: %s.%s, error: %w", table, column, err)
+	}
+	stat.Nulls = stat.Sampled - nonNull
+	if stat.Sampled > 0 {
+		stat.NullFraction = float64(stat.Nulls) / float64(stat.Sampled)
+	}
+
+	q := "SELECT v, count(*) AS n FROM " + src + " WHERE v IS NOT NULL GROUP BY v ORDER BY n DESC, v LIMIT 10"
+	rows, err := db.QueryContext(context.Background(), q)
+	if err != nil {
+		return stat, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var vc ValueCount
+		if err := rows.Scan(&vc.Value, &vc.Count); err != nil {
+			return stat, err
+		}
+		stat.Common = append(stat.Common, vc)
+	}
+	return stat, rows.Err()
+}
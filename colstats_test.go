@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"testing"
+)
+
+func TestColumnStats(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	if _, err := db.Exec("create table people (id integer primary key, city text, age int)"); err != nil {
+		t.Fatal(err)
+	}
+	cities := []interface{}{"oslo", "rome", "oslo", nil, "lima", "oslo", "rome", nil}
+	for i, city := range cities {
+		if _, err := db.Exec("insert into people (city, age) values(?,?)", city, 20+i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stat, err := ColumnStats(db, "people", "city")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Rows != 8 || stat.Sampled != 8 || stat.Nulls != 2 || stat.NullFraction != 0.25 || stat.Distinct != 3 {
+		t.Errorf("unexpected stats: %+v", stat)
+	}
+	if stat.Min != "lima" || stat.Max != "rome" {
+		t.Errorf("unexpected min and max: %v, %v", stat.Min, stat.Max)
+	}
+	if len(stat.Common) != 3 || stat.Common[0].Value != "oslo" || stat.Common[0].Count != 3 || stat.Common[1].Value != "rome" {
+		t.Errorf("unexpected common values: %+v", stat.Common)
+	}
+
+	stat, err = ColumnStats(db, "people", "age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Distinct != 8 || stat.Selectivity() != 0.125 || stat.Min != int64(20) {
+		t.Errorf("unexpected stats: %+v", stat)
+	}
+	if _, err := ColumnStats(db, "nope", "city"); err == nil {
+		t.Error("expected error for missing table")
+	}
+}
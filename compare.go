@@ -0,0 +1,171 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// RowDiff is the difference between the results of two queries, found by CompareQueries
+type RowDiff struct {
+	Columns []string        // of the first results
+	Missing [][]interface{} // rows of the first results not in the second
+	Extra   [][]interface{} // rows of the second results not in the first
+	Changed []RowChange     // rows with the same key but other values, when compared by key
+}
+
+// RowChange is a row whose values differ between the results of two queries
+type RowChange struct {
+	Key      []interface{}
+	From, To []interface{}
+}
+
+// Equal reports whether the results are the same
+func (d RowDiff) Equal() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.Changed) == 0
+}
+
+func (d RowDiff) String() string {
+	return fmt.Sprintf("%d missing, %d extra, %d changed", len(d.Missing), len(d.Extra), len(d.Changed))
+}
+
+// CompareQueries runs a query on each database, e.g. before and after a migration, or on
+// a primary and its replica, and returns the difference between their results, in any order.
+// The results must have the same number of columns. Rows are matched by the values of the
+// key columns, which must be unique, or by all their values if no keys are given, in which
+// case duplicate rows are counted. Values match if they are the same SQL literal, so 1 and
+// 1.0 differ. Both results are held in memory
+func CompareQueries(db1 Queryer, q1 string, db2 Queryer, q2 string, keys ...string) (RowDiff, error) {
+	var diff RowDiff
+	columns, rows1, err := queryRows(db1, q1)
+	if err != nil {
+		return diff, err
+	}
+	columns2, rows2, err := queryRows(db2, q2)
+	if err != nil {
+		return diff, err
+	}
+	if len(columns) != len(columns2) {
+		return diff, fmt.Errorf("results have %d and %d columns", len(columns), len(columns2))
+	}
+	diff.Columns = columns
+
+	index := make([]int, len(keys))
+	for i, key := range keys {
+		index[i] = -1
+		for j, c := range columns {
+			if strings.EqualFold(c, key) {
+				index[i] = j
+			}
+		}
+		if index[i] < 0 {
+			return diff, fmt.Errorf("no key column: %s", key)
+		}
+	}
+	if len(keys) == 0 {
+		// every column is the key, and duplicates are counted
+		counts := make(map[string]int)
+		for _, row := range rows2 {
+			counts[rowLiteral(row, nil)]++
+		}
+		for _, row := range rows1 {
+			k := rowLiteral(row, nil)
+			if counts[k] > 0 {
+				counts[k]--
+				continue
+			}
+			diff.Missing = append(diff.Missing, row)
+		}
+		for _, row := range rows2 {
+			k := rowLiteral(row, nil)
+			if counts[k] > 0 {
+				counts[k]--
+				diff.Extra = append(diff.Extra, row)
+			}
+		}
+		return diff, nil
+	}
+
+	byKey := make(map[string][]interface{}, len(rows2))
+	var order []string
+	for _, row := range rows2 {
+		k := rowLiteral(row, index)
+		if _, ok := byKey[k]; ok {
+			return diff, fmt.Errorf("duplicate key in second results: %s", k)
+		}
+		byKey[k] = row
+		order = append(order, k)
+	}
+	seen := make(map[string]bool, len(rows1))
+	for _, row := range rows1 {
+		k := rowLiteral(row, index)
+		if seen[k] {
+			return diff, fmt.Errorf("duplicate key in first results: %s", k)
+		}
+		seen[k] = true
+		other, ok := byKey[k]
+		switch {
+		case !ok:
+			diff.Missing = append(diff.Missing, row)
+		case rowLiteral(row, nil) != rowLiteral(other, nil):
+			key := make([]interface{}, len(index))
+			for i, j := range index {
+				key[i] = row[j]
+			}
+			diff.Changed = append(diff.Changed, RowChange{Key: key, From: row, To: other})
+		}
+	}
+	for _, k := range order {
+		if !seen[k] {
+			diff.Extra = append(diff.Extra, byKey[k])
+		}
+	}
+	return diff, nil
+}
+
+// queryRows returns the columns and rows of the results of a query
+func queryRows(db Queryer, q string) ([]string, [][]interface{}, error) {
+	rows, err := db.QueryContext(context.Background(), q)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	var results [][]interface{}
+	for rows.Next() {
+		row := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, nil, err
+		}
+		results = append(results, row)
+	}
+	return columns, results, rows.Err()
+}
+
+// rowLiteral returns the values of a row as SQL literals, all of them if index is nil
+func rowLiteral(row []interface{}, index []int) string {
+	var sb strings.Builder
+	add := func(v interface{}) {
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(sqlLiteral(v))
+	}
+	if index == nil {
+		for _, v := range row {
+			add(v)
+		}
+	} else {
+		for _, i := range index {
+			add(row[i])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestCompareQueries(t *testing.T) {
+	db1, db2 := memDB(t), memDB(t)
+	const schema = "create table t (id integer primary key, name text, n int);"
+	for _, db := range []*sql.DB{db1, db2} {
+		if _, err := db.Exec(schema); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db1.Exec("insert into t values (1,'a',1),(2,'b',2),(3,'c',3),(4,'d',4)")
+	db2.Exec("insert into t values (4,'d',4),(3,'c',30),(2,'b',2),(5,'e',5)")
+
+	diff, err := CompareQueries(db1, "select * from t", db2, "select * from t order by id desc", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Missing) != 1 || diff.Missing[0][0] != int64(1) {
+		t.Errorf("unexpected missing: %v", diff.Missing)
+	}
+	if len(diff.Extra) != 1 || diff.Extra[0][0] != int64(5) {
+		t.Errorf("unexpected extra: %v", diff.Extra)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Key[0] != int64(3) || diff.Changed[0].To[2] != int64(30) {
+		t.Errorf("unexpected changed: %v", diff.Changed)
+	}
+	if diff.Equal() {
+		t.Error("expected differences")
+	}
+
+	// without keys, a changed row is both missing and extra, and duplicates count
+	diff, err = CompareQueries(db1, "select name from t union all select 'b'", db2, "select name from t where id < 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Missing) != 2 || len(diff.Extra) != 0 {
+		t.Errorf("unexpected diff: %s %v %v", diff, diff.Missing, diff.Extra)
+	}
+
+	diff, err = CompareQueries(db1, "select id, name from t where id between 2 and 4", db2, "select id, name from t where id < 5", "id")
+	if err != nil || !diff.Equal() {
+		t.Errorf("expected equal results but got %s, %v", diff, err)
+	}
+	if diff, err := CompareQueries(db1, "select * from t where 0", db2, "select * from t where 0"); err != nil || !diff.Equal() {
+		t.Errorf("expected equal empty results but got %s, %v", diff, err)
+	}
+
+	if _, err := CompareQueries(db1, "select id from t", db2, "select id, name from t"); err == nil {
+		t.Error("expected error for different columns")
+	}
+	if _, err := CompareQueries(db1, "select name from t union all select 'a'", db2, "select name from t", "name"); err == nil {
+		t.Error("expected error for duplicate key")
+	}
+	if _, err := CompareQueries(db1, "select id from t", db2, "select id from t", "nope"); err == nil {
+		t.Error("expected error for missing key")
+	}
+}
@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// ColumnStorage is how the values of a computed column are kept
+type ColumnStorage string
+
+// Storage of computed columns
+const (
+	StorageVirtual ColumnStorage = "VIRTUAL" // a generated column computed when read
+	StorageStored  ColumnStorage = "STORED"  // a generated column computed when written
+	StorageTrigger ColumnStorage = "TRIGGER" // a plain column kept up to date by triggers
+)
+
+// generatedColumns reports whether the SQLite library supports generated columns,
+// which were added in 3.31.0
+var generatedColumns = func() bool {
+	_, version, _ := sqlite3.Version()
+	return version >= 3031000
+}
+
+// AddComputedColumn adds a column to the table whose values are the result of the
+// expression of its other columns, which may call functions registered with
+// WithFunctions if they're pure. The column is a generated column kept as given,
+// or for versions of SQLite without them, a plain column set by triggers on insert
+// and update, and the storage used is returned. Stored columns can't be added by
+// ALTER TABLE, so the table is rebuilt with the column and its indexes and triggers
+// recreated. Tables without a rowid can't have columns kept by triggers
+func AddComputedColumn(db *sql.DB, table, name, expr string, storage ColumnStorage) (ColumnStorage, error) {
+	switch storage {
+	case StorageVirtual, StorageStored:
+		if !generatedColumns() {
+			storage = StorageTrigger
+		}
+	case StorageTrigger:
+	default:
+		return storage, fmt.Errorf("unknown column storage: %q", storage)
+	}
+	var err error
+	switch storage {
+	case StorageVirtual:
+		_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s AS (%s) VIRTUAL", quoteIdent(table), quoteIdent(name), expr))
+	case StorageStored:
+		err = addStoredColumn(db, table, name, expr)
+	case StorageTrigger:
+		err = addTriggerColumn(db, table, name, expr)
+	}
+	if err != nil {
+		return storage, fmt.Errorf("computed column: %s.%s, error: %w", table, name, err)
+	}
+	return storage, nil
+}
+
+// addStoredColumn rebuilds the table with a stored generated column added after its others
+func addStoredColumn(db *sql.DB, table, name, expr string) error {
+	var create string
+	if err := row(db, []interface{}{&create}, "SELECT sql FROM sqlite_master WHERE type='table' AND name=?", table); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no such table: %s", table)
+		}
+		return err
+	}
+	end, err := columnDefsEnd(create)
+	if err != nil {
+		return err
+	}
+	create = fmt.Sprintf("%s, %s AS (%s) STORED%s", create[:end], quoteIdent(name), expr, create[end:])
+
+	// the indexes and triggers of the table are dropped with it
+	var after []string
+	fn := func(_ []string, row []interface{}) {
+		after = append(after, fmt.Sprint(row[0]))
+	}
+	const q = "SELECT sql FROM sqlite_master WHERE type IN ('index','trigger') AND tbl_name=? AND sql IS NOT NULL ORDER BY type"
+	if err := query(db, fn, q, table); err != nil {
+		return err
+	}
+	return rebuildTable(db, db, table, create, after...)
+}
+
+// columnDefsEnd returns the offset in the statement creating a table of the end of
+// the definition of its last column, before any table constraints
+func columnDefsEnd(create string) (int, error) {
+	depth, start, end := 0, 0, -1
+	for i := 0; i < len(create); i++ {
+		switch c := create[i]; c {
+		case '\'', '"', '`', '[':
+			if c == '[' {
+				c = ']'
+			}
+			j := strings.IndexByte(create[i+1:], c)
+			if j < 0 {
+				return 0, fmt.Errorf("unterminated quote in: %s", create)
+			}
+			i += j + 1
+		case '-', '/':
+			if i+1 < len(create) && create[i+1] == '-' && c == '-' {
+				j := strings.IndexByte(create[i:], '\n')
+				if j < 0 {
+					j = len(create) - i
+				}
+				i += j
+			} else if i+1 < len(create) && create[i+1] == '*' && c == '/' {
+				j := strings.Index(create[i+2:], "*/")
+				if j < 0 {
+					return 0, fmt.Errorf("unterminated comment in: %s", create)
+				}
+				i += j + 3
+			}
+		case '(':
+			depth++
+			if depth == 1 {
+				start = i + 1
+			}
+		case ',', ')':
+			if c == ')' {
+				depth--
+			}
+			if (c == ',' && depth == 1) || (c == ')' && depth == 0) {
+				def := strings.Fields(strings.ToUpper(create[start:i]))
+				if len(def) > 0 {
+					switch def[0] {
+					case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+						return end, nil
+					}
+				}
+				end, start = i, i+1
+				if c == ')' {
+					return end, nil
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("no column definitions in: %s", create)
+}
+
+// addTriggerColumn adds a plain column, set to the expression for the existing
+// rows, and by triggers for rows inserted or updated
+func addTriggerColumn(db *sql.DB, table, name, expr string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	t, c := quoteIdent(table), quoteIdent(name)
+	set := fmt.Sprintf("UPDATE %s SET %s = (%s)", t, c, expr)
+	stmts := []string{
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", t, c),
+		set,
+		fmt.Sprintf("CREATE TRIGGER %s AFTER INSERT ON %s BEGIN %s WHERE rowid = NEW.rowid; END",
+			quoteIdent("_computed_"+table+"_"+name+"_insert"), t, set),
+		fmt.Sprintf("CREATE TRIGGER %s AFTER UPDATE ON %s BEGIN %s WHERE rowid = NEW.rowid; END",
+			quoteIdent("_computed_"+table+"_"+name+"_update"), t, set),
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"testing"
+)
+
+func TestAddComputedColumn(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	const create = `
+create table items (
+	id integer primary key,
+	price real, -- per unit, (in cents)
+	qty int,
+	unique (price, qty)
+);
+create index items_qty on items(qty);
+create table audit (n int);
+create trigger items_audit after insert on items begin insert into audit values(1); end;
+insert into items (price, qty) values(2.5, 4);
+`
+	if _, err := db.Exec(create); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name    string
+		expr    string
+		storage ColumnStorage
+	}{
+		{"total", "price * qty", StorageVirtual},
+		{"stored_total", "price * qty", StorageStored},
+		{"kept_total", "price * qty", StorageTrigger},
+	} {
+		storage, err := AddComputedColumn(db, "items", c.name, c.expr, c.storage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if storage != c.storage {
+			t.Errorf("%s: expected %s but got %s", c.name, c.storage, storage)
+		}
+	}
+	if _, err := db.Exec("insert into items (price, qty) values(1.5, 2)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("update items set qty = 10 where id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	var total, stored, kept float64
+	for id, want := range map[int]float64{1: 25, 2: 3} {
+		if err := row(db, []interface{}{&total, &stored, &kept}, "select total, stored_total, kept_total from items where id=?", id); err != nil {
+			t.Fatal(err)
+		}
+		if total != want || stored != want || kept != want {
+			t.Errorf("row %d: expected %v but got: %v, %v, %v", id, want, total, stored, kept)
+		}
+	}
+	var indexes, audits int
+	if err := row(db, []interface{}{&indexes}, "select count(*) from sqlite_master where name='items_qty'"); err != nil || indexes != 1 {
+		t.Errorf("expected index to be kept: %d (%v)", indexes, err)
+	}
+	if err := row(db, []interface{}{&audits}, "select count(*) from audit"); err != nil || audits != 2 {
+		t.Errorf("expected trigger to be kept: %d (%v)", audits, err)
+	}
+
+	// older versions fall back to triggers
+	supported := generatedColumns
+	generatedColumns = func() bool { return false }
+	defer func() { generatedColumns = supported }()
+	storage, err := AddComputedColumn(db, "items", "half", "qty / 2", StorageVirtual)
+	if err != nil || storage != StorageTrigger {
+		t.Errorf("expected fallback to triggers but got: %s (%v)", storage, err)
+	}
+	if _, err := AddComputedColumn(db, "items", "x", "1", "SOMETIMES"); err == nil {
+		t.Error("expected error for unknown storage")
+	}
+}
+
+func TestColumnDefsEnd(t *testing.T) {
+	for create, want := range map[string]string{
+		"CREATE TABLE t (a int, b text)":                                        "CREATE TABLE t (a int, b text",
+		`CREATE TABLE "x(" (a int, "b,c" text, primary key (a))`:                `CREATE TABLE "x(" (a int, "b,c" text`,
+		"CREATE TABLE t (a int check (a > 0), b /* , */ int) WITHOUT ROWID":     "CREATE TABLE t (a int check (a > 0), b /* , */ int",
+		"CREATE TABLE t (a int -- the a, (really)\n, constraint pk unique (a))": "CREATE TABLE t (a int -- the a, (really)\n",
+	} {
+		end, err := columnDefsEnd(create)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := create[:end]; got != want {
+			t.Errorf("expected %q but got %q", want, got)
+		}
+	}
+}
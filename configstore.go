@@ -0,0 +1,358 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+const configSchema = `CREATE TABLE IF NOT EXISTS _config (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS _config_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	key TEXT NOT NULL,
+	old TEXT,
+	new TEXT,
+	time TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+CREATE TRIGGER IF NOT EXISTS _config_insert AFTER INSERT ON _config BEGIN
+	INSERT INTO _config_history (key, new) VALUES (new.key, new.value);
+END;
+CREATE TRIGGER IF NOT EXISTS _config_update AFTER UPDATE ON _config WHEN old.value IS NOT new.value BEGIN
+	INSERT INTO _config_history (key, old, new) VALUES (new.key, old.value, new.value);
+END;
+CREATE TRIGGER IF NOT EXISTS _config_delete AFTER DELETE ON _config BEGIN
+	INSERT INTO _config_history (key, old) VALUES (old.key, old.value);
+END;`
+
+// ConfigChange is a change to a setting of a ConfigStore
+type ConfigChange struct {
+	Seq      int64
+	Key      string
+	Old, New string
+	Deleted  bool // the setting was deleted, and New is empty
+	Time     time.Time
+}
+
+// configWatch is a func watching a setting, or all settings if key is empty
+type configWatch struct {
+	key string
+	fn  func(ConfigChange)
+}
+
+// ConfigStore keeps settings, e.g. feature flags, in a database, in the table _config,
+// with each change to them logged in the table _config_history by triggers, so they
+// are recorded whether made by the store or by SQL. It is added with WithConfigStore,
+// and is ready once the database is open. The changes committed by the database's
+// connections are seen with SQLite's update hook, and passed to the funcs given to
+// Watch, in order, from a goroutine of the store's, until it is closed
+type ConfigStore struct {
+	mu      sync.Mutex
+	db      *sql.DB
+	watches map[int]configWatch
+	next    int
+	pending map[*sqlite3.SQLiteConn][]int64 // history of open transactions
+	queue   []int64                         // committed history yet to be watched
+	signal  chan struct{}
+	done    chan struct{}
+}
+
+// NewConfigStore returns a store to be opened with WithConfigStore
+func NewConfigStore() *ConfigStore {
+	return &ConfigStore{
+		watches: make(map[int]configWatch),
+		pending: make(map[*sqlite3.SQLiteConn][]int64),
+		signal:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// WithConfigStore keeps the settings of cs in the database, watching the changes of each connection
+func WithConfigStore(cs *ConfigStore) Optional {
+	return func(c *Config) {
+		c.configs = cs
+	}
+}
+
+// bind creates the tables of the store in the database it is opened with
+func (cs *ConfigStore) bind(db *sql.DB) error {
+	cs.mu.Lock()
+	bound := cs.db
+	cs.mu.Unlock()
+	if bound == db {
+		return nil
+	}
+	if bound != nil {
+		return fmt.Errorf("config store is already open with another database")
+	}
+	// not locked, as the hooks of the connection lock the store
+	if _, err := db.Exec(configSchema); err != nil {
+		return err
+	}
+	cs.mu.Lock()
+	cs.db = db
+	cs.mu.Unlock()
+	go cs.notify()
+	return nil
+}
+
+// Close stops the watching of changes
+func (cs *ConfigStore) Close() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	select {
+	case <-cs.done:
+	default:
+		close(cs.done)
+	}
+}
+
+// changed notes the history logged by the connection
+func (cs *ConfigStore) changed(conn *sqlite3.SQLiteConn, op int, db, table string, rowid int64) {
+	if op != sqlite3.SQLITE_INSERT || db != "main" || table != "_config_history" {
+		return
+	}
+	cs.mu.Lock()
+	cs.pending[conn] = append(cs.pending[conn], rowid)
+	cs.mu.Unlock()
+}
+
+// committed queues the history of the connection's transaction for the watchers
+func (cs *ConfigStore) committed(conn *sqlite3.SQLiteConn) {
+	cs.mu.Lock()
+	ids := cs.pending[conn]
+	delete(cs.pending, conn)
+	cs.queue = append(cs.queue, ids...)
+	cs.mu.Unlock()
+	if len(ids) > 0 {
+		select {
+		case cs.signal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// rolledBack drops the history of the connection's transaction
+func (cs *ConfigStore) rolledBack(conn *sqlite3.SQLiteConn) {
+	cs.mu.Lock()
+	delete(cs.pending, conn)
+	cs.mu.Unlock()
+}
+
+// notify passes the committed changes to the watchers. The commit hook is called
+// before the commit is done, so changes not yet visible are read again shortly
+func (cs *ConfigStore) notify() {
+	for {
+		select {
+		case <-cs.done:
+			return
+		case <-cs.signal:
+		}
+		cs.mu.Lock()
+		ids := cs.queue
+		cs.queue = nil
+		cs.mu.Unlock()
+		for _, id := range ids {
+			c, err := cs.change(id)
+			for retry := 0; err == sql.ErrNoRows && retry < 100; retry++ {
+				time.Sleep(10 * time.Millisecond)
+				c, err = cs.change(id)
+			}
+			if err != nil {
+				loggerOf(cs.db).Error("config change", "seq", id, "error", err)
+				continue
+			}
+			cs.mu.Lock()
+			var fns []func(ConfigChange)
+			for i := 0; i < cs.next; i++ {
+				if w, ok := cs.watches[i]; ok && (w.key == "" || w.key == c.Key) {
+					fns = append(fns, w.fn)
+				}
+			}
+			cs.mu.Unlock()
+			for _, fn := range fns {
+				fn(c)
+			}
+		}
+	}
+}
+
+// Watch calls fn with each change of the setting, or of every setting if key
+// is empty, until the returned func is called
+func (cs *ConfigStore) Watch(key string, fn func(ConfigChange)) (cancel func()) {
+	cs.mu.Lock()
+	id := cs.next
+	cs.next++
+	cs.watches[id] = configWatch{key: key, fn: fn}
+	cs.mu.Unlock()
+	return func() {
+		cs.mu.Lock()
+		delete(cs.watches, id)
+		cs.mu.Unlock()
+	}
+}
+
+const configChangeQuery = "SELECT id, key, coalesce(old, ''), coalesce(new, ''), new IS NULL, time FROM _config_history"
+
+// scanChange reads a change from the history
+func scanChange(scan func(dest ...interface{}) error) (ConfigChange, error) {
+	var c ConfigChange
+	err := scan(&c.Seq, &c.Key, &c.Old, &c.New, &c.Deleted, &c.Time)
+	return c, err
+}
+
+// change returns the change logged with the id
+func (cs *ConfigStore) change(id int64) (ConfigChange, error) {
+	return scanChange(cs.db.QueryRow(configChangeQuery+" WHERE id=?", id).Scan)
+}
+
+// History returns the changes to the setting, or to every setting if key is empty, oldest first
+func (cs *ConfigStore) History(key string) ([]ConfigChange, error) {
+	q := configChangeQuery + " WHERE ?1 = '' OR key = ?1 ORDER BY id"
+	rows, err := cs.db.Query(q, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var changes []ConfigChange
+	for rows.Next() {
+		c, err := scanChange(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// Set sets the setting to the value, which is kept as text: strings as is,
+// durations as formatted by time.Duration, numbers and bools as formatted
+// by strconv, and anything else as JSON
+func (cs *ConfigStore) Set(key string, value interface{}) error {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case time.Duration:
+		text = v.String()
+	case bool:
+		text = strconv.FormatBool(v)
+	case int:
+		text = strconv.Itoa(v)
+	case int64:
+		text = strconv.FormatInt(v, 10)
+	case float64:
+		text = strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("config: %s, error: %w", key, err)
+		}
+		text = string(b)
+	}
+	_, err := cs.db.Exec("INSERT INTO _config (key, value) VALUES(?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value", key, text)
+	return err
+}
+
+// Delete deletes the setting
+func (cs *ConfigStore) Delete(key string) error {
+	_, err := cs.db.Exec("DELETE FROM _config WHERE key=?", key)
+	return err
+}
+
+// Get returns the text of the setting, and whether it is set
+func (cs *ConfigStore) Get(key string) (string, bool, error) {
+	var value string
+	err := row(cs.db, []interface{}{&value}, "SELECT value FROM _config WHERE key=?", key)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	return value, err == nil, err
+}
+
+// All returns the text of every setting
+func (cs *ConfigStore) All() (map[string]string, error) {
+	settings := make(map[string]string)
+	err := query(cs.db, func(_ []string, row []interface{}) {
+		settings[fmt.Sprint(row[0])] = fmt.Sprint(row[1])
+	}, "SELECT key, value FROM _config")
+	return settings, err
+}
+
+// parse parses the setting with fn, returning whether it is set
+func (cs *ConfigStore) parse(key string, fn func(string) error) (bool, error) {
+	value, ok, err := cs.Get(key)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := fn(value); err != nil {
+		return false, fmt.Errorf("config: %s, error: %w", key, err)
+	}
+	return true, nil
+}
+
+// String returns the setting, or def if it isn't set
+func (cs *ConfigStore) String(key, def string) (string, error) {
+	value, ok, err := cs.Get(key)
+	if !ok {
+		return def, err
+	}
+	return value, nil
+}
+
+// Int returns the setting as an integer, or def if it isn't set
+func (cs *ConfigStore) Int(key string, def int64) (int64, error) {
+	_, err := cs.parse(key, func(s string) (err error) {
+		def, err = strconv.ParseInt(s, 10, 64)
+		return err
+	})
+	return def, err
+}
+
+// Float returns the setting as a float, or def if it isn't set
+func (cs *ConfigStore) Float(key string, def float64) (float64, error) {
+	_, err := cs.parse(key, func(s string) (err error) {
+		def, err = strconv.ParseFloat(s, 64)
+		return err
+	})
+	return def, err
+}
+
+// Bool returns the setting as a bool, or def if it isn't set
+func (cs *ConfigStore) Bool(key string, def bool) (bool, error) {
+	_, err := cs.parse(key, func(s string) (err error) {
+		def, err = strconv.ParseBool(s)
+		return err
+	})
+	return def, err
+}
+
+// Duration returns the setting as a duration, or def if it isn't set
+func (cs *ConfigStore) Duration(key string, def time.Duration) (time.Duration, error) {
+	_, err := cs.parse(key, func(s string) (err error) {
+		def, err = time.ParseDuration(s)
+		return err
+	})
+	return def, err
+}
+
+// JSON unmarshals the setting into dest, returning whether it is set
+func (cs *ConfigStore) JSON(key string, dest interface{}) (bool, error) {
+	return cs.parse(key, func(s string) error {
+		return json.Unmarshal([]byte(s), dest)
+	})
+}
+
+// Enabled reports whether the feature flag is set to true, treating
+// flags that aren't set, or aren't bools, as disabled
+func (cs *ConfigStore) Enabled(flag string) bool {
+	on, err := cs.Bool(flag, false)
+	return err == nil && on
+}
@@ -0,0 +1,120 @@
+package sqlite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cs := NewConfigStore()
+	defer cs.Close()
+	// captured changes are still seen with the config store
+	cc := NewChangeCapture()
+	db, err := Open(filepath.Join(dir, "config.db"), WithConfigStore(cs), WithChangeCapture(cc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	changes := make(chan ConfigChange, 10)
+	cancel := cs.Watch("workers", func(c ConfigChange) {
+		changes <- c
+	})
+	next := func() ConfigChange {
+		select {
+		case c := <-changes:
+			return c
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for change")
+		}
+		return ConfigChange{}
+	}
+
+	if n, err := cs.Int("workers", 2); err != nil || n != 2 {
+		t.Errorf("expected default but got: %d (%v)", n, err)
+	}
+	if err := cs.Set("workers", 4); err != nil {
+		t.Fatal(err)
+	}
+	if c := next(); c.Key != "workers" || c.Old != "" || c.New != "4" || c.Deleted || c.Time.IsZero() {
+		t.Errorf("unexpected change: %+v", c)
+	}
+	if n, err := cs.Int("workers", 2); err != nil || n != 4 {
+		t.Errorf("expected 4 workers but got: %d (%v)", n, err)
+	}
+	// changes made with SQL are seen too, but not those rolled back
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("update _config set value='6' where key='workers'"); err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+	if _, err := db.Exec("update _config set value='8' where key='workers'"); err != nil {
+		t.Fatal(err)
+	}
+	if c := next(); c.Old != "4" || c.New != "8" {
+		t.Errorf("unexpected change: %+v", c)
+	}
+	cancel()
+
+	if err := cs.Set("timeout", 90*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := cs.Duration("timeout", time.Second); err != nil || d != 90*time.Second {
+		t.Errorf("unexpected timeout: %v (%v)", d, err)
+	}
+	if err := cs.Set("ratio", 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := cs.Float("ratio", 1); err != nil || f != 0.5 {
+		t.Errorf("unexpected ratio: %v (%v)", f, err)
+	}
+	if err := cs.Set("hosts", []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	var hosts []string
+	if ok, err := cs.JSON("hosts", &hosts); err != nil || !ok || len(hosts) != 2 {
+		t.Errorf("unexpected hosts: %v %v (%v)", hosts, ok, err)
+	}
+	if _, err := cs.Int("hosts", 0); err == nil {
+		t.Error("expected error for invalid integer")
+	}
+
+	if err := cs.Set("new_ui", true); err != nil {
+		t.Fatal(err)
+	}
+	if !cs.Enabled("new_ui") || cs.Enabled("old_ui") || cs.Enabled("hosts") {
+		t.Error("unexpected feature flags")
+	}
+	if err := cs.Delete("new_ui"); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := cs.String("new_ui", "unset"); err != nil || s != "unset" {
+		t.Errorf("expected deleted flag but got: %s (%v)", s, err)
+	}
+
+	all, err := cs.All()
+	if err != nil || len(all) != 4 || all["workers"] != "8" {
+		t.Errorf("unexpected settings: %v (%v)", all, err)
+	}
+	history, err := cs.History("new_ui")
+	if err != nil || len(history) != 2 || history[0].New != "true" || !history[1].Deleted || history[1].Old != "true" {
+		t.Errorf("unexpected history: %+v (%v)", history, err)
+	}
+	if history, err := cs.History(""); err != nil || len(history) != 7 {
+		t.Errorf("unexpected history: %+v (%v)", history, err)
+	}
+	if changes := cc.take(); len(changes) == 0 {
+		t.Error("expected captured changes")
+	}
+}
@@ -0,0 +1,172 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// CopyOptions controls CopyTables
+type CopyOptions struct {
+	Where     map[string]string // optional filter for rows of a table, keyed by table name
+	Filters   map[string]*Where // optional parameterized filter for rows of a table, with its Where
+	Replace   bool              // drop tables that already exist in the destination
+	BatchSize int               // rows per transaction, defaults to 1000
+	Progress  io.Writer         // receives progress reports
+	Scrub     *Scrubber         // optional scrubbing of sensitive columns
+}
+
+// CopyTables recreates tables in dst, along with their indexes, and copies their rows
+// from src. All tables are copied if none are given
+func CopyTables(src, dst *sql.DB, tables []string, opts *CopyOptions) error {
+	if opts == nil {
+		opts = &CopyOptions{}
+	}
+	if opts.Scrub != nil {
+		if err := opts.Scrub.Validate(); err != nil {
+			return err
+		}
+	}
+	if len(tables) == 0 {
+		var err error
+		if tables, err = Tables(src); err != nil {
+			return err
+		}
+	}
+	for _, table := range tables {
+		if err := copyTable(src, dst, table, opts); err != nil {
+			return fmt.Errorf("copy table: %s, error: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// schemaSQL returns the SQL that creates objects of the given kind for a table
+func schemaSQL(db *sql.DB, kind, table string) ([]string, error) {
+	var list []string
+	fn := func(_ []string, row []interface{}) {
+		list = append(list, fmt.Sprint(row[0]))
+	}
+	const q = "select sql from sqlite_master where type=? and tbl_name=? and sql is not null order by name"
+	return list, query(db, fn, q, kind, table)
+}
+
+func copyTable(src, dst *sql.DB, table string, opts *CopyOptions) error {
+	w := opts.Progress
+	if w == nil {
+		w = ioutil.Discard
+	}
+	batch := opts.BatchSize
+	if batch <= 0 {
+		batch = 1000
+	}
+
+	create, err := schemaSQL(src, "table", table)
+	if err != nil {
+		return err
+	}
+	if len(create) == 0 {
+		return fmt.Errorf("no such table")
+	}
+	indexes, err := schemaSQL(src, "index", table)
+	if err != nil {
+		return err
+	}
+	columns, err := tableColumns(src, table)
+	if err != nil {
+		return err
+	}
+
+	if opts.Replace {
+		if _, err := dst.Exec("DROP TABLE IF EXISTS " + quoteIdent(table)); err != nil {
+			return err
+		}
+	}
+	if _, err := dst.Exec(create[0]); err != nil {
+		return err
+	}
+
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdent(c)
+	}
+	selectSQL := fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ","), quoteIdent(table))
+	clause, args := whereClause(opts.Where[table], opts.Filters[table])
+	selectSQL += clause
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES(%s)", quoteIdent(table), strings.Join(quoted, ","),
+		strings.TrimSuffix(strings.Repeat("?,", len(columns)), ","))
+
+	rows, err := src.Query(selectSQL, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	actions := opts.Scrub.actions(table, columns)
+	values := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	var (
+		tx    *sql.Tx
+		stmt  *sql.Stmt
+		count int
+	)
+	// commit completes the current batch
+	commit := func() error {
+		if tx == nil {
+			return nil
+		}
+		stmt.Close()
+		err := tx.Commit()
+		tx = nil
+		fmt.Fprintf(w, "table: %s rows: %d\n", table, count)
+		return err
+	}
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		if tx == nil {
+			if tx, err = dst.Begin(); err != nil {
+				return err
+			}
+			if stmt, err = tx.Prepare(insertSQL); err != nil {
+				return err
+			}
+		}
+		opts.Scrub.scrub(actions, values)
+		if _, err := stmt.Exec(values...); err != nil {
+			return err
+		}
+		if count++; count%batch == 0 {
+			if err := commit(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := commit(); err != nil {
+		return err
+	}
+
+	// indexes are faster to build once the rows are in place
+	for _, index := range indexes {
+		if _, err := dst.Exec(index); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "table: %s copied: %d rows, %d indexes\n", table, count, len(indexes))
+	return nil
+}
@@ -0,0 +1,61 @@
+package sqlite
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCopyTables(t *testing.T) {
+	src := memDB(t)
+	defer src.Close()
+	const setup = `
+create table items (id integer primary key, name text, price real);
+create index items_name on items(name);
+create table other (x int);
+`
+	if _, err := src.Exec(setup); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 25; i++ {
+		if _, err := src.Exec("insert into items (name, price) values(?, ?)", "item", float64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := memDB(t)
+	defer dst.Close()
+	var progress bytes.Buffer
+	opts := &CopyOptions{
+		Where:     map[string]string{"items": "price >= 5"},
+		BatchSize: 7,
+		Progress:  &progress,
+	}
+	if err := CopyTables(src, dst, []string{"items"}, opts); err != nil {
+		t.Fatal(err)
+	}
+	t.Log(progress.String())
+
+	var count int
+	if err := row(dst, []interface{}{&count}, "select count(*) from items"); err != nil || count != 20 {
+		t.Fatalf("expected 20 rows but got: %d (%v)", count, err)
+	}
+	if err := row(dst, []interface{}{&count}, "select count(*) from sqlite_master where type='index' and name='items_name'"); err != nil || count != 1 {
+		t.Fatalf("expected index to be copied but got: %d (%v)", count, err)
+	}
+	if tables, _ := Tables(dst); len(tables) != 1 {
+		t.Fatalf("expected only the items table but got: %v", tables)
+	}
+
+	if err := CopyTables(src, dst, []string{"items"}, nil); err == nil {
+		t.Fatal("expected error copying over an existing table")
+	}
+	if err := CopyTables(src, dst, nil, &CopyOptions{Replace: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := row(dst, []interface{}{&count}, "select count(*) from items"); err != nil || count != 25 {
+		t.Fatalf("expected 25 rows but got: %d (%v)", count, err)
+	}
+	if err := CopyTables(src, dst, []string{"nosuch"}, nil); err == nil {
+		t.Fatal("expected error for missing table")
+	}
+}
@@ -0,0 +1,114 @@
+package sqlite
+
+/*
+typedef struct sqlite3_stmt sqlite3_stmt;
+extern int sqlite3_column_count(sqlite3_stmt*);
+extern const char *sqlite3_column_name(sqlite3_stmt*, int);
+extern const char *sqlite3_column_decltype(sqlite3_stmt*, int);
+*/
+import "C"
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// ColumnInfo describes a column of the results of a query. The columns of tables
+// are known only when SQLite's column metadata is enabled, which the driver does
+// with the build tags "sqlite_vtable" or "vtable"; otherwise only the name and
+// declared type are known
+type ColumnInfo struct {
+	Name       string
+	DeclType   string // the declared type of the column it's from, empty for an expression
+	Database   string // the database, table, and column it's from, empty for an expression
+	Table      string //
+	Column     string //
+	NotNull    bool   // the column it's from is declared NOT NULL
+	PrimaryKey bool   // the column it's from is part of the primary key
+}
+
+// Nullable reports whether the column may be null, as it's not from a column declared NOT NULL
+func (c ColumnInfo) Nullable() bool {
+	return !c.NotNull
+}
+
+// columnOrigin returns the database, table, and column a result column is from,
+// set when SQLite's column metadata is available
+var columnOrigin func(stmt unsafe.Pointer, i int) (database, table, column string)
+
+// DescribeQuery returns the columns of the results of the query, without running it
+func DescribeQuery(db *sql.DB, q string) ([]ColumnInfo, error) {
+	var columns []ColumnInfo
+	err := WithRawConn(db, func(conn *sqlite3.SQLiteConn) error {
+		ds, err := conn.Prepare(q)
+		if err != nil {
+			return err
+		}
+		defer ds.Close()
+		stmt := stmtHandle(ds.(*sqlite3.SQLiteStmt))
+		if stmt == nil {
+			return fmt.Errorf("no statement: %q", q)
+		}
+		cs := (*C.sqlite3_stmt)(stmt)
+		n := int(C.sqlite3_column_count(cs))
+		for i := 0; i < n; i++ {
+			c := ColumnInfo{Name: C.GoString(C.sqlite3_column_name(cs, C.int(i)))}
+			if decl := C.sqlite3_column_decltype(cs, C.int(i)); decl != nil {
+				c.DeclType = C.GoString(decl)
+			}
+			if columnOrigin != nil {
+				c.Database, c.Table, c.Column = columnOrigin(stmt, i)
+			}
+			columns = append(columns, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the constraints of the columns the results are from
+	type key struct{ database, table string }
+	type tableInfo struct {
+		columns map[string]ColumnInfo
+		keys    int // columns of the primary key
+	}
+	infos := make(map[key]*tableInfo)
+	for i, c := range columns {
+		if c.Table == "" {
+			continue
+		}
+		k := key{c.Database, c.Table}
+		info, ok := infos[k]
+		if !ok {
+			info = &tableInfo{columns: make(map[string]ColumnInfo)}
+			fn := func(_ []string, row []interface{}) {
+				col := ColumnInfo{NotNull: fmt.Sprint(row[3]) == "1", PrimaryKey: fmt.Sprint(row[5]) != "0"}
+				if col.PrimaryKey {
+					info.keys++
+				}
+				info.columns[strings.ToLower(fmt.Sprint(row[1]))] = col
+			}
+			pragma := fmt.Sprintf("PRAGMA %s.table_xinfo(%s)", quoteIdent(c.Database), quoteIdent(c.Table))
+			if err := query(db, fn, pragma); err != nil {
+				return nil, err
+			}
+			infos[k] = info
+		}
+		// a rowid alias, an INTEGER PRIMARY KEY, is never null
+		col := info.columns[strings.ToLower(c.Column)]
+		rowid := col.PrimaryKey && info.keys == 1 && strings.EqualFold(c.DeclType, "integer")
+		columns[i].NotNull = col.NotNull || rowid
+		columns[i].PrimaryKey = col.PrimaryKey
+	}
+	return columns, nil
+}
+
+// stmtHandle returns the sqlite3_stmt of a prepared statement
+func stmtHandle(stmt *sqlite3.SQLiteStmt) unsafe.Pointer {
+	return unsafe.Pointer(reflect.ValueOf(stmt).Elem().FieldByName("s").Pointer())
+}
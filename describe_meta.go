@@ -0,0 +1,33 @@
+//go:build sqlite_vtable || vtable
+// +build sqlite_vtable vtable
+
+// SQLite's column metadata, which the driver only enables with these build tags
+
+package sqlite
+
+/*
+typedef struct sqlite3_stmt sqlite3_stmt;
+extern const char *sqlite3_column_database_name(sqlite3_stmt*, int);
+extern const char *sqlite3_column_table_name(sqlite3_stmt*, int);
+extern const char *sqlite3_column_origin_name(sqlite3_stmt*, int);
+*/
+import "C"
+
+import "unsafe"
+
+func init() {
+	columnOrigin = func(stmt unsafe.Pointer, i int) (string, string, string) {
+		cs := (*C.sqlite3_stmt)(stmt)
+		return goString(C.sqlite3_column_database_name(cs, C.int(i))),
+			goString(C.sqlite3_column_table_name(cs, C.int(i))),
+			goString(C.sqlite3_column_origin_name(cs, C.int(i)))
+	}
+}
+
+// goString converts a C string that may be null
+func goString(s *C.char) string {
+	if s == nil {
+		return ""
+	}
+	return C.GoString(s)
+}
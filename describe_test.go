@@ -0,0 +1,53 @@
+package sqlite
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDescribeQuery(t *testing.T) {
+	db := memDB(t)
+	const schema = `
+create table users (id integer primary key, name text not null, email varchar(255));
+create table orders (id integer primary key, user_id integer references users(id), total real);
+`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	columns, err := DescribeQuery(db, "select u.id, u.name as who, u.email, sum(o.total) from users u join orders o on o.user_id=u.id group by u.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(columns) != 4 {
+		t.Fatalf("got %d columns", len(columns))
+	}
+	want := []ColumnInfo{
+		{Name: "id", DeclType: "INTEGER"},
+		{Name: "who", DeclType: "text"},
+		{Name: "email", DeclType: "varchar(255)"},
+		{Name: "sum(o.total)"},
+	}
+	for i, c := range columns {
+		if c.Name != want[i].Name || !strings.EqualFold(c.DeclType, want[i].DeclType) {
+			t.Errorf("column %d: got %+v, want %+v", i, c, want[i])
+		}
+	}
+	if columnOrigin != nil {
+		if c := columns[1]; c.Database != "main" || c.Table != "users" || c.Column != "name" || c.Nullable() {
+			t.Errorf("unexpected origin: %+v", c)
+		}
+		if c := columns[0]; !c.PrimaryKey || c.Nullable() {
+			t.Errorf("expected primary key: %+v", c)
+		}
+		if c := columns[2]; !c.Nullable() {
+			t.Errorf("expected nullable: %+v", c)
+		}
+		if c := columns[3]; c.Table != "" {
+			t.Errorf("expected expression: %+v", c)
+		}
+	}
+
+	if _, err := DescribeQuery(db, "select nope from users"); err == nil {
+		t.Error("expected error for invalid query")
+	}
+}
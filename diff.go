@@ -0,0 +1,341 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DiffAction describes how an object differs between two databases
+type DiffAction string
+
+// The kinds of differences reported by SchemaDiff and DataDiff
+const (
+	DiffAdded   DiffAction = "added"
+	DiffRemoved DiffAction = "removed"
+	DiffChanged DiffAction = "changed"
+)
+
+// SchemaChange is a difference in a schema object (table, index, view, or trigger)
+type SchemaChange struct {
+	Type   string
+	Name   string
+	Table  string
+	Action DiffAction
+	From   string // creation sql in the source database
+	To     string // creation sql in the target database
+}
+
+func (c SchemaChange) String() string {
+	return fmt.Sprintf("%s %s: %s", c.Type, c.Name, c.Action)
+}
+
+// SQL returns the statements that apply the change to the source database.
+// Tables that changed in ways ALTER TABLE cannot express are returned as a comment
+func (c SchemaChange) SQL(from, to *sql.DB) string {
+	switch c.Action {
+	case DiffAdded:
+		return c.To + ";"
+	case DiffRemoved:
+		return fmt.Sprintf("DROP %s IF EXISTS %s;", strings.ToUpper(c.Type), quoteIdent(c.Name))
+	}
+	if c.Type != "table" {
+		return fmt.Sprintf("DROP %s IF EXISTS %s;\n%s;", strings.ToUpper(c.Type), quoteIdent(c.Name), c.To)
+	}
+	if alter, ok := addedColumns(from, to, c.Name); ok {
+		return alter
+	}
+	return fmt.Sprintf("-- table %s requires a manual rebuild:\n-- %s", c.Name, strings.ReplaceAll(c.To, "\n", "\n-- "))
+}
+
+// addedColumns returns ALTER TABLE statements if the only change to the table
+// is columns appended to the end
+func addedColumns(from, to *sql.DB, table string) (string, bool) {
+	fromCols, err := columnDefs(from, table)
+	if err != nil {
+		return "", false
+	}
+	toCols, err := columnDefs(to, table)
+	if err != nil || len(toCols) <= len(fromCols) {
+		return "", false
+	}
+	for i, col := range fromCols {
+		if col != toCols[i] {
+			return "", false
+		}
+	}
+	var alters []string
+	for _, col := range toCols[len(fromCols):] {
+		alters = append(alters, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", quoteIdent(table), col))
+	}
+	return strings.Join(alters, "\n"), true
+}
+
+// columnDefs returns simplified column definitions from table_info
+func columnDefs(db *sql.DB, table string) ([]string, error) {
+	var defs []string
+	fn := func(_ []string, row []interface{}) {
+		def := quoteIdent(fmt.Sprint(row[1]))
+		if kind := fmt.Sprint(row[2]); kind != "" {
+			def += " " + kind
+		}
+		if fmt.Sprint(row[3]) == "1" {
+			def += " NOT NULL"
+		}
+		if row[4] != nil {
+			def += fmt.Sprintf(" DEFAULT %v", row[4])
+		}
+		defs = append(defs, def)
+	}
+	return defs, query(db, fn, "PRAGMA table_info("+quoteIdent(table)+")")
+}
+
+type schemaObject struct {
+	kind, name, table, sql string
+}
+
+func schemaObjects(db *sql.DB) (map[string]schemaObject, error) {
+	const q = `
+SELECT type, name, tbl_name, sql FROM sqlite_master
+WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+`
+	objects := make(map[string]schemaObject)
+	fn := func(_ []string, row []interface{}) {
+		o := schemaObject{fmt.Sprint(row[0]), fmt.Sprint(row[1]), fmt.Sprint(row[2]), fmt.Sprint(row[3])}
+		objects[o.kind+" "+o.name] = o
+	}
+	return objects, query(db, fn, q)
+}
+
+// schemaOrder sorts tables before the objects that depend on them
+var schemaOrder = map[string]int{"table": 0, "view": 1, "index": 2, "trigger": 3}
+
+// SchemaDiff returns the changes needed to make the schema of from match that of to
+func SchemaDiff(from, to *sql.DB) ([]SchemaChange, error) {
+	a, err := schemaObjects(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := schemaObjects(to)
+	if err != nil {
+		return nil, err
+	}
+	var changes []SchemaChange
+	for key, o := range a {
+		if n, ok := b[key]; !ok {
+			changes = append(changes, SchemaChange{Type: o.kind, Name: o.name, Table: o.table, Action: DiffRemoved, From: o.sql})
+		} else if normalizeSQL(o.sql) != normalizeSQL(n.sql) {
+			changes = append(changes, SchemaChange{Type: o.kind, Name: o.name, Table: o.table, Action: DiffChanged, From: o.sql, To: n.sql})
+		}
+	}
+	for key, n := range b {
+		if _, ok := a[key]; !ok {
+			changes = append(changes, SchemaChange{Type: n.kind, Name: n.name, Table: n.table, Action: DiffAdded, To: n.sql})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		ci, cj := changes[i], changes[j]
+		// removals come first so that replaced objects don't collide
+		if (ci.Action == DiffRemoved) != (cj.Action == DiffRemoved) {
+			return ci.Action == DiffRemoved
+		}
+		if ci.Type != cj.Type {
+			return schemaOrder[ci.Type] < schemaOrder[cj.Type]
+		}
+		return ci.Name < cj.Name
+	})
+	return changes, nil
+}
+
+// normalizeSQL collapses whitespace so formatting differences are ignored
+func normalizeSQL(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// DataChange is a row that differs between two databases
+type DataChange struct {
+	Table   string
+	Action  DiffAction
+	Columns []string
+	Key     []string // primary key (or rowid) columns
+	KeyVals []string // quoted key values
+	Values  []string // quoted values in the target database, if present there
+}
+
+func (c DataChange) String() string {
+	return fmt.Sprintf("%s [%s]: %s", c.Table, strings.Join(c.KeyVals, ","), c.Action)
+}
+
+// SQL returns the statement that applies the change to the source database
+func (c DataChange) SQL() string {
+	where := make([]string, len(c.Key))
+	for i, k := range c.Key {
+		where[i] = quoteIdent(k) + "=" + c.KeyVals[i]
+	}
+	table := quoteIdent(c.Table)
+	switch c.Action {
+	case DiffRemoved:
+		return fmt.Sprintf("DELETE FROM %s WHERE %s;", table, strings.Join(where, " AND "))
+	case DiffAdded:
+		cols := make([]string, len(c.Columns))
+		for i, col := range c.Columns {
+			cols[i] = quoteIdent(col)
+		}
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES(%s);", table, strings.Join(cols, ","), strings.Join(c.Values, ","))
+	}
+	set := make([]string, len(c.Columns))
+	for i, col := range c.Columns {
+		set[i] = quoteIdent(col) + "=" + c.Values[i]
+	}
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s;", table, strings.Join(set, ","), strings.Join(where, " AND "))
+}
+
+// primaryKey returns the primary key columns of the table, or rowid if it has none
+func primaryKey(db *sql.DB, table string) ([]string, error) {
+	type pk struct {
+		name  string
+		order int64
+	}
+	var keys []pk
+	fn := func(_ []string, row []interface{}) {
+		if order, ok := row[5].(int64); ok && order > 0 {
+			keys = append(keys, pk{fmt.Sprint(row[1]), order})
+		}
+	}
+	if err := query(db, fn, "PRAGMA table_info("+quoteIdent(table)+")"); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return []string{"rowid"}, nil
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].order < keys[j].order })
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.name
+	}
+	return names, nil
+}
+
+// quotedRows calls fn with the quoted key and column values of each row
+func quotedRows(db *sql.DB, table string, key, columns []string, fn func(keys, values []string)) error {
+	exprs := make([]string, 0, len(key)+len(columns))
+	for _, col := range append(append([]string{}, key...), columns...) {
+		exprs = append(exprs, "quote("+quoteIdent(col)+")")
+	}
+	q := fmt.Sprintf("SELECT %s FROM %s", strings.Join(exprs, ","), quoteIdent(table))
+	rows, err := db.Query(q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	values := make([]string, len(exprs))
+	ptrs := make([]interface{}, len(exprs))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		row := append([]string{}, values...)
+		fn(row[:len(key)], row[len(key):])
+	}
+	return rows.Err()
+}
+
+// DataDiff returns the row changes needed to make the listed tables in from match those in to.
+// If no tables are given, all tables present in both databases are compared.
+// Rows are matched by primary key (or rowid), and the target rows are held in memory
+func DataDiff(from, to *sql.DB, tables ...string) ([]DataChange, error) {
+	if len(tables) == 0 {
+		a, err := Tables(from)
+		if err != nil {
+			return nil, err
+		}
+		b, err := Tables(to)
+		if err != nil {
+			return nil, err
+		}
+		inB := make(map[string]bool)
+		for _, t := range b {
+			inB[t] = true
+		}
+		for _, t := range a {
+			if inB[t] {
+				tables = append(tables, t)
+			}
+		}
+	}
+	var changes []DataChange
+	for _, table := range tables {
+		c, err := tableDiff(from, to, table)
+		if err != nil {
+			return nil, fmt.Errorf("table: %s, error: %w", table, err)
+		}
+		changes = append(changes, c...)
+	}
+	return changes, nil
+}
+
+func tableDiff(from, to *sql.DB, table string) ([]DataChange, error) {
+	columns, err := tableColumns(from, table)
+	if err != nil {
+		return nil, err
+	}
+	toColumns, err := tableColumns(to, table)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Join(columns, ",") != strings.Join(toColumns, ",") {
+		return nil, fmt.Errorf("columns differ: (%s) vs (%s)", strings.Join(columns, ","), strings.Join(toColumns, ","))
+	}
+	key, err := primaryKey(from, table)
+	if err != nil {
+		return nil, err
+	}
+	if key[0] == "rowid" {
+		// without a primary key the rowid must be copied to keep rows aligned
+		columns = append([]string{"rowid"}, columns...)
+	}
+
+	type quoted struct{ keys, values []string }
+	target := make(map[string]quoted)
+	var order []string
+	err = quotedRows(to, table, key, columns, func(keys, values []string) {
+		k := strings.Join(keys, ",")
+		target[k] = quoted{keys, values}
+		order = append(order, k)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []DataChange
+	seen := make(map[string]bool)
+	err = quotedRows(from, table, key, columns, func(keys, values []string) {
+		k := strings.Join(keys, ",")
+		seen[k] = true
+		c := DataChange{Table: table, Columns: columns, Key: key, KeyVals: keys}
+		if newer, ok := target[k]; !ok {
+			c.Action = DiffRemoved
+		} else if strings.Join(newer.values, ",") != strings.Join(values, ",") {
+			c.Action = DiffChanged
+			c.Values = newer.values
+		} else {
+			return
+		}
+		changes = append(changes, c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range order {
+		if seen[k] {
+			continue
+		}
+		changes = append(changes, DataChange{Table: table, Action: DiffAdded, Columns: columns, Key: key, KeyVals: target[k].keys, Values: target[k].values})
+	}
+	return changes, nil
+}
@@ -0,0 +1,135 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func diffDBs(t *testing.T) (*sql.DB, *sql.DB) {
+	t.Helper()
+	from := structDb(t)
+	to := structDb(t)
+	t.Cleanup(func() {
+		from.Close()
+		to.Close()
+	})
+	return from, to
+}
+
+func TestSchemaDiff(t *testing.T) {
+	from, to := diffDBs(t)
+
+	for _, q := range []string{
+		"create table gone (id int)",
+		"create index structs_name on structs(name)",
+	} {
+		if _, err := from.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, q := range []string{
+		"create table fresh (id int)",
+		"create index structs_name on structs(name, kind)",
+		"alter table structs add column extra text default 'x'",
+	} {
+		if _, err := to.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changes, err := SchemaDiff(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, len(changes))
+	for i, c := range changes {
+		got[i] = c.String()
+	}
+	want := "table gone: removed,table fresh: added,table structs: changed,index structs_name: changed"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("expected: %s\nbut got: %s", want, strings.Join(got, ","))
+	}
+
+	// applying the reconciliation sql must leave no differences
+	for _, c := range changes {
+		if _, err := from.Exec(c.SQL(from, to)); err != nil {
+			t.Fatalf("%s: %v", c.SQL(from, to), err)
+		}
+	}
+	if changes, err = SchemaDiff(from, to); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range changes {
+		// ALTER TABLE leaves the table sql formatted differently
+		if c.Type != "table" || c.Action != DiffChanged {
+			t.Errorf("unexpected change: %s", c)
+		}
+	}
+}
+
+func TestDataDiff(t *testing.T) {
+	from, to := diffDBs(t)
+
+	for _, q := range []string{
+		"delete from structs where name='abc'",
+		"update structs set kind=99 where name='def'",
+		"insert into structs(id, name, kind) values(10, 'new', 1)",
+	} {
+		if _, err := to.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changes, err := DataDiff(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes but got: %v", changes)
+	}
+	for _, c := range changes {
+		t.Log(c, c.SQL())
+		if _, err := from.Exec(c.SQL()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if changes, err = DataDiff(from, to); err != nil {
+		t.Fatal(err)
+	} else if len(changes) != 0 {
+		t.Fatalf("expected no changes but got: %v", changes)
+	}
+}
+
+func TestDataDiffRowid(t *testing.T) {
+	from, to := diffDBs(t)
+	for _, db := range []*sql.DB{from, to} {
+		if _, err := db.Exec("create table plain (a text, b int); insert into plain values('x', 1)"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := to.Exec("insert into plain values('y', 2)"); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := DataDiff(from, to, "plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Action != DiffAdded {
+		t.Fatalf("expected one added row but got: %v", changes)
+	}
+	if sql := changes[0].SQL(); sql != `INSERT INTO "plain" ("rowid","a","b") VALUES(2,'y',2);` {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+}
+
+func TestDataDiffColumns(t *testing.T) {
+	from, to := diffDBs(t)
+	if _, err := to.Exec("alter table structs add column extra text"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DataDiff(from, to, "structs"); err == nil {
+		t.Fatal("expected error for mismatched columns")
+	} else {
+		t.Log(err)
+	}
+}
@@ -0,0 +1,211 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Fill is how Downsample fills the buckets without samples
+type Fill int
+
+// Ways of filling gaps
+const (
+	FillNone     Fill = iota // gaps are left out
+	FillNull                 // gaps have NaN values, or NULL when materialized
+	FillZero                 // gaps have zero values
+	FillPrevious             // gaps have the values of the bucket before them
+	FillLinear               // gaps have values interpolated between the buckets around them
+)
+
+// DownsampleOptions are the options for Downsample
+type DownsampleOptions struct {
+	From, To time.Time // the range of the buckets, that of the samples if not set
+	Fill     Fill
+	Unix     bool   // the timestamps are unix seconds, rather than text SQLite can parse
+	Into     string // optional table the buckets are written to, created if needed, replacing those there
+}
+
+// Bucket is the aggregate of the samples of a period
+type Bucket struct {
+	Start  time.Time
+	Count  int64     // the samples in the bucket, 0 for filled gaps
+	Values []float64 // the aggregate of each value column
+}
+
+// downsampleAggs are the aggregates Downsample allows
+var downsampleAggs = map[string]bool{"avg": true, "min": true, "max": true, "sum": true, "total": true, "count": true}
+
+// Downsample aggregates the values of a table of samples, e.g. metrics, into buckets of a
+// period, using the SQL aggregate, one of avg, min, max, sum, total, or count, and returns
+// them in order, with any gaps filled per the options. The buckets start at multiples of the
+// period since the unix epoch, and timestamps are read to the second
+func Downsample(db *sql.DB, table, tsCol string, valueCols []string, bucket time.Duration, agg string, opts *DownsampleOptions) ([]Bucket, error) {
+	if opts == nil {
+		opts = &DownsampleOptions{}
+	}
+	agg = strings.ToLower(agg)
+	switch {
+	case !downsampleAggs[agg]:
+		return nil, fmt.Errorf("unsupported aggregate: %q", agg)
+	case bucket < time.Second:
+		return nil, fmt.Errorf("bucket must be at least a second: %v", bucket)
+	case len(valueCols) == 0:
+		return nil, fmt.Errorf("no value columns")
+	}
+	secs := int64(bucket / time.Second)
+	epoch := fmt.Sprintf("CAST(strftime('%%s', %s) AS INTEGER)", quoteIdent(tsCol))
+	if opts.Unix {
+		epoch = fmt.Sprintf("CAST(%s AS INTEGER)", quoteIdent(tsCol))
+	}
+	aggs := make([]string, len(valueCols))
+	for i, c := range valueCols {
+		aggs[i] = fmt.Sprintf("%s(%s)", agg, quoteIdent(c))
+	}
+	var where []string
+	var args []interface{}
+	if !opts.From.IsZero() {
+		where = append(where, epoch+" >= ?")
+		args = append(args, opts.From.Unix())
+	}
+	if !opts.To.IsZero() {
+		where = append(where, epoch+" < ?")
+		args = append(args, opts.To.Unix())
+	}
+	q := fmt.Sprintf("SELECT %s / %d * %d AS bucket, count(*), %s FROM %s WHERE %s IS NOT NULL",
+		epoch, secs, secs, strings.Join(aggs, ", "), quoteIdent(table), epoch)
+	if len(where) > 0 {
+		q += " AND " + strings.Join(where, " AND ")
+	}
+	q += " GROUP BY bucket ORDER BY bucket"
+
+	var buckets []Bucket
+	rows, err := db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var start int64
+		b := Bucket{Values: make([]float64, len(valueCols))}
+		values := make([]sql.NullFloat64, len(valueCols))
+		dest := []interface{}{&start, &b.Count}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		b.Start = time.Unix(start, 0).UTC()
+		for i, v := range values {
+			b.Values[i] = math.NaN()
+			if v.Valid {
+				b.Values[i] = v.Float64
+			}
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if opts.Fill != FillNone {
+		first, last := opts.From, opts.To.Add(-time.Second)
+		if len(buckets) > 0 {
+			if first.IsZero() {
+				first = buckets[0].Start
+			}
+			if opts.To.IsZero() {
+				last = buckets[len(buckets)-1].Start
+			}
+		}
+		if !first.IsZero() && !last.IsZero() {
+			buckets = fillBuckets(buckets, first.Unix()/secs*secs, last.Unix()/secs*secs, secs, len(valueCols), opts.Fill)
+		}
+	}
+	if opts.Into != "" {
+		if err := writeBuckets(db, opts.Into, valueCols, buckets, opts.Unix); err != nil {
+			return buckets, fmt.Errorf("downsample: %s, error: %w", opts.Into, err)
+		}
+	}
+	return buckets, nil
+}
+
+// fillBuckets returns the buckets from first to last, every secs, with the gaps filled
+func fillBuckets(buckets []Bucket, first, last, secs int64, n int, fill Fill) []Bucket {
+	var filled []Bucket
+	next := 0
+	for start := first; start <= last; start += secs {
+		if next < len(buckets) && buckets[next].Start.Unix() == start {
+			filled = append(filled, buckets[next])
+			next++
+			continue
+		}
+		b := Bucket{Start: time.Unix(start, 0).UTC(), Values: make([]float64, n)}
+		for i := range b.Values {
+			switch {
+			case fill == FillZero:
+				b.Values[i] = 0
+			case fill == FillPrevious && len(filled) > 0:
+				b.Values[i] = filled[len(filled)-1].Values[i]
+			case fill == FillLinear && len(filled) > 0 && next < len(buckets):
+				prev, after := filled[len(filled)-1], buckets[next]
+				frac := float64(start-prev.Start.Unix()) / float64(after.Start.Unix()-prev.Start.Unix())
+				b.Values[i] = prev.Values[i] + frac*(after.Values[i]-prev.Values[i])
+			default:
+				b.Values[i] = math.NaN()
+			}
+		}
+		filled = append(filled, b)
+	}
+	return filled
+}
+
+// writeBuckets replaces the buckets in the table, creating it if needed
+func writeBuckets(db *sql.DB, table string, valueCols []string, buckets []Bucket, unix bool) error {
+	defs := [][2]string{{"bucket", "DATETIME PRIMARY KEY"}, {"count", "INTEGER"}}
+	if unix {
+		defs[0][1] = "INTEGER PRIMARY KEY"
+	}
+	for _, c := range valueCols {
+		defs = append(defs, [2]string{quoteIdent(c), "REAL"})
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s %s", quoteIdent(table), tableDef(db, defs))); err != nil {
+		return err
+	}
+	columns := append([]string{"bucket", "count"}, valueCols...)
+	insert := fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)", quoteIdent(table), quotedList(columns), placeholders(len(columns)))
+	stmt, err := tx.Prepare(insert)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, b := range buckets {
+		args := make([]interface{}, 0, len(columns))
+		if unix {
+			args = append(args, b.Start.Unix())
+		} else {
+			args = append(args, b.Start.Format("2006-01-02 15:04:05"))
+		}
+		args = append(args, b.Count)
+		for _, v := range b.Values {
+			if math.IsNaN(v) {
+				args = append(args, nil)
+			} else {
+				args = append(args, v)
+			}
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDownsample(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	if _, err := db.Exec("create table metrics (at timestamp, cpu real, mem real)"); err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	// samples in minutes 0, 1, and 4, leaving 2 and 3 empty
+	samples := []struct {
+		offset   time.Duration
+		cpu, mem float64
+	}{
+		{0, 10, 100}, {30 * time.Second, 20, 200},
+		{time.Minute, 30, 300},
+		{4*time.Minute + 10*time.Second, 60, 600},
+	}
+	for _, s := range samples {
+		if _, err := db.Exec("insert into metrics values(?,?,?)", base.Add(s.offset), s.cpu, s.mem); err != nil {
+			t.Fatal(err)
+		}
+	}
+	values := func(buckets []Bucket, col int) []float64 {
+		list := make([]float64, len(buckets))
+		for i, b := range buckets {
+			list[i] = b.Values[col]
+		}
+		return list
+	}
+	equal := func(got, want []float64) bool {
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range got {
+			if got[i] != want[i] && !(math.IsNaN(got[i]) && math.IsNaN(want[i])) {
+				return false
+			}
+		}
+		return true
+	}
+
+	buckets, err := Downsample(db, "metrics", "at", []string{"cpu", "mem"}, time.Minute, "avg", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 3 || !buckets[0].Start.Equal(base) || buckets[0].Count != 2 || !equal(values(buckets, 0), []float64{15, 30, 60}) {
+		t.Errorf("unexpected buckets: %+v", buckets)
+	}
+
+	nan := math.NaN()
+	for fill, want := range map[Fill][]float64{
+		FillNull:     {15, 30, nan, nan, 60},
+		FillZero:     {15, 30, 0, 0, 60},
+		FillPrevious: {15, 30, 30, 30, 60},
+		FillLinear:   {15, 30, 40, 50, 60},
+	} {
+		buckets, err := Downsample(db, "metrics", "at", []string{"cpu"}, time.Minute, "avg", &DownsampleOptions{Fill: fill})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := values(buckets, 0); !equal(got, want) {
+			t.Errorf("fill %d: expected %v but got %v", fill, want, got)
+		}
+	}
+
+	// a range beyond the samples is filled too, and materialized
+	opts := &DownsampleOptions{From: base.Add(-time.Minute), To: base.Add(7 * time.Minute), Fill: FillNull, Into: "metrics_1m"}
+	buckets, err = Downsample(db, "metrics", "at", []string{"cpu", "mem"}, time.Minute, "max", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 8 || buckets[0].Count != 0 || buckets[1].Values[1] != 200 {
+		t.Errorf("unexpected buckets: %+v", buckets)
+	}
+	var count, nulls int
+	var mem float64
+	if err := row(db, []interface{}{&count}, "select count(*) from metrics_1m"); err != nil || count != 8 {
+		t.Errorf("expected 8 materialized buckets but got: %d (%v)", count, err)
+	}
+	if err := row(db, []interface{}{&nulls}, "select count(*) from metrics_1m where cpu is null"); err != nil || nulls != 5 {
+		t.Errorf("expected 5 empty buckets but got: %d (%v)", nulls, err)
+	}
+	if err := row(db, []interface{}{&mem}, "select mem from metrics_1m where bucket = '2021-06-01 12:04:00'"); err != nil || mem != 600 {
+		t.Errorf("unexpected materialized value: %v (%v)", mem, err)
+	}
+	// rerunning replaces the buckets
+	if _, err := Downsample(db, "metrics", "at", []string{"cpu", "mem"}, time.Minute, "max", opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := row(db, []interface{}{&count}, "select count(*) from metrics_1m"); err != nil || count != 8 {
+		t.Errorf("expected 8 materialized buckets but got: %d (%v)", count, err)
+	}
+
+	if _, err := Downsample(db, "metrics", "at", []string{"cpu"}, time.Minute, "median", nil); err == nil {
+		t.Error("expected error for unsupported aggregate")
+	}
+}
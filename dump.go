@@ -0,0 +1,259 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// DumpOptions select what is emitted by Dump
+type DumpOptions struct {
+	Tables     []string          // tables to dump, all tables when empty
+	Where      map[string]string // optional WHERE clause per table
+	Filters    map[string]*Where // optional parameterized filter per table, with its Where clause
+	SchemaOnly bool              // omit table contents
+	DataOnly   bool              // omit schema statements
+	Scrub      *Scrubber         // optional scrubbing of sensitive columns
+}
+
+// quoteIdent quotes an SQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Tables returns the names of all user tables in the database
+func Tables(db *sql.DB) ([]string, error) {
+	const q = `
+SELECT name FROM sqlite_master
+WHERE type='table' AND name NOT LIKE 'sqlite_%'
+ORDER BY name
+`
+	var names []string
+	fn := func(_ []string, row []interface{}) {
+		if len(row) > 0 {
+			names = append(names, fmt.Sprint(row[0]))
+		}
+	}
+	return names, query(db, fn, q)
+}
+
+// tableColumns returns the column names of the table
+func tableColumns(db Queryer, table string) ([]string, error) {
+	var columns []string
+	fn := func(_ []string, row []interface{}) {
+		columns = append(columns, fmt.Sprint(row[1]))
+	}
+	if err := query(db, fn, "PRAGMA table_info("+quoteIdent(table)+")"); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no such table: %s", table)
+	}
+	return columns, nil
+}
+
+// Dump emulates ".dump", writing the schema and contents of the database as SQL
+func Dump(db *sql.DB, w io.Writer, opts *DumpOptions) error {
+	if opts == nil {
+		opts = &DumpOptions{}
+	}
+	if opts.Scrub != nil {
+		if err := opts.Scrub.Validate(); err != nil {
+			return err
+		}
+	}
+	tables := opts.Tables
+	all := len(tables) == 0
+	if all {
+		var err error
+		if tables, err = Tables(db); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w, "PRAGMA foreign_keys=OFF;")
+	fmt.Fprintln(w, "BEGIN TRANSACTION;")
+	for _, table := range tables {
+		if !opts.DataOnly {
+			if err := dumpSchema(db, w, "table", table); err != nil {
+				return err
+			}
+		}
+		if !opts.SchemaOnly {
+			if err := dumpRows(db, w, table, opts.Where[table], opts.Filters[table], opts.Scrub); err != nil {
+				return err
+			}
+		}
+	}
+	if all && !opts.SchemaOnly {
+		if err := dumpSequence(db, w); err != nil {
+			return err
+		}
+	}
+	if !opts.DataOnly {
+		for _, kind := range []string{"index", "trigger", "view"} {
+			if err := dumpSchema(db, w, kind, tables...); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Fprintln(w, "COMMIT;")
+	return nil
+}
+
+// dumpSchema writes the creation statements for the given object type,
+// limited to those associated with the tables
+func dumpSchema(db *sql.DB, w io.Writer, kind string, tables ...string) error {
+	const q = `
+SELECT tbl_name, sql FROM sqlite_master
+WHERE type=? AND sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+ORDER BY rowid
+`
+	wanted := make(map[string]bool)
+	for _, table := range tables {
+		wanted[table] = true
+	}
+	fn := func(_ []string, row []interface{}) {
+		table := fmt.Sprint(row[0])
+		// views are not tied to a single table so are always included
+		if kind == "view" || wanted[table] {
+			fmt.Fprintf(w, "%s;\n", FormatSQL(fmt.Sprint(row[1])))
+		}
+	}
+	return query(db, fn, q, kind)
+}
+
+// dumpRows writes an INSERT statement for each row in the table
+func dumpRows(db *sql.DB, w io.Writer, table, where string, filter *Where, scrub *Scrubber) error {
+	columns, err := tableColumns(db, table)
+	if err != nil {
+		return err
+	}
+	if actions := scrub.actions(table, columns); actions != nil {
+		return dumpScrubbed(db, w, table, where, filter, columns, scrub, actions)
+	}
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = "quote(" + quoteIdent(column) + ")"
+	}
+	q := fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, "||','||"), quoteIdent(table))
+	clause, args := whereClause(where, filter)
+	q += clause
+	prefix := "INSERT INTO " + quoteIdent(table) + " VALUES("
+	fn := func(_ []string, row []interface{}) {
+		fmt.Fprintf(w, "%s%s);\n", prefix, row[0])
+	}
+	if err := query(db, fn, q, args...); err != nil {
+		return fmt.Errorf("dump table: %s, error: %w", table, err)
+	}
+	return nil
+}
+
+// dumpScrubbed writes an INSERT statement for each row in the table, with its values scrubbed
+func dumpScrubbed(db *sql.DB, w io.Writer, table, where string, filter *Where, columns []string, scrub *Scrubber, actions map[int]ScrubAction) error {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdent(column)
+	}
+	q := fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ","), quoteIdent(table))
+	clause, args := whereClause(where, filter)
+	q += clause
+	prefix := "INSERT INTO " + quoteIdent(table) + " VALUES("
+	literals := make([]string, len(columns))
+	fn := func(_ []string, row []interface{}) {
+		scrub.scrub(actions, row)
+		for i, v := range row {
+			literals[i] = sqlLiteral(v)
+		}
+		fmt.Fprintf(w, "%s%s);\n", prefix, strings.Join(literals, ","))
+	}
+	if err := query(db, fn, q, args...); err != nil {
+		return fmt.Errorf("dump table: %s, error: %w", table, err)
+	}
+	return nil
+}
+
+// dumpSequence preserves AUTOINCREMENT state, if any
+func dumpSequence(db *sql.DB, w io.Writer) error {
+	var count int
+	if err := row(db, []interface{}{&count}, "SELECT count(*) FROM sqlite_master WHERE name='sqlite_sequence'"); err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	fmt.Fprintln(w, "DELETE FROM sqlite_sequence;")
+	return dumpRows(db, w, "sqlite_sequence", "", nil, nil)
+}
+
+// ExportCSV writes the results of the query as CSV, with a header row of column names
+func ExportCSV(db *sql.DB, w io.Writer, query string, args ...interface{}) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	_, err = writeCSV(w, rows)
+	return err
+}
+
+// writeCSV writes the rows as CSV, with a header row of column names, returning the number of rows
+func writeCSV(w io.Writer, rows *sql.Rows) (int, error) {
+	columns, err := getColumns(rows)
+	if err != nil {
+		return 0, err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return 0, err
+	}
+	dest := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range dest {
+		ptrs[i] = &dest[i]
+	}
+	record := make([]string, len(columns))
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return n, err
+		}
+		for i, value := range dest {
+			switch value := value.(type) {
+			case nil:
+				record[i] = ""
+			case []byte:
+				record[i] = string(value)
+			case time.Time:
+				record[i] = value.Format(sqlite3.SQLiteTimestampFormats[0])
+			default:
+				record[i] = fmt.Sprint(value)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	cw.Flush()
+	return n, cw.Error()
+}
+
+// ExportTableCSV writes the contents of the table as CSV, optionally filtered by the where clause
+func ExportTableCSV(db *sql.DB, w io.Writer, table, where string) error {
+	return ExportTableCSVFilter(db, w, table, W().Raw(where))
+}
+
+// ExportTableCSVFilter writes the contents of the table as CSV, optionally filtered
+func ExportTableCSVFilter(db *sql.DB, w io.Writer, table string, filter *Where) error {
+	clause, args := whereClause("", filter)
+	return ExportCSV(db, w, "SELECT * FROM "+quoteIdent(table)+clause, args...)
+}
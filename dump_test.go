@@ -0,0 +1,109 @@
+package sqlite
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTables(t *testing.T) {
+	db := structDb(t)
+	defer db.Close()
+
+	tables, err := Tables(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || tables[0] != "structs" {
+		t.Fatalf("expected [structs] but got: %v", tables)
+	}
+}
+
+func TestDump(t *testing.T) {
+	db := structDb(t)
+	defer db.Close()
+
+	if _, err := db.Exec("create index structs_kind on structs(kind)"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Dump(db, &buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	dump := buf.String()
+	fmt.Fprintln(testout, dump)
+	for _, want := range []string{
+		"CREATE TABLE structs",
+		`INSERT INTO "structs" VALUES(1,'abc',23,'what ev er',`,
+		"CREATE INDEX structs_kind",
+		"COMMIT;",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("dump is missing: %s", want)
+		}
+	}
+
+	// the dump must restore to an identical copy
+	cp := memDB(t)
+	defer cp.Close()
+	if _, err := cp.Exec(dump); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := row(cp, []interface{}{&count}, "select count(*) from structs"); err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Fatalf("expected 4 rows but got: %d", count)
+	}
+}
+
+func TestDumpWhere(t *testing.T) {
+	db := structDb(t)
+	defer db.Close()
+
+	var buf bytes.Buffer
+	opts := &DumpOptions{
+		Tables: []string{"structs"},
+		Where:  map[string]string{"structs": "kind > 40"},
+	}
+	if err := Dump(db, &buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "INSERT INTO"); n != 2 {
+		t.Fatalf("expected 2 inserts but got: %d", n)
+	}
+}
+
+func TestDumpMissingTable(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+
+	opts := &DumpOptions{Tables: []string{"nope"}}
+	if err := Dump(db, &bytes.Buffer{}, opts); err == nil {
+		t.Fatal("expected error for missing table")
+	} else {
+		t.Log(err)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	db := structDb(t)
+	defer db.Close()
+
+	var buf bytes.Buffer
+	if err := ExportTableCSV(db, &buf, "structs", "kind=69"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines but got: %q", lines)
+	}
+	if lines[0] != "id,name,kind,data,modified" {
+		t.Errorf("bad header: %s", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "2,def,69,m'kay,") {
+		t.Errorf("bad row: %s", lines[1])
+	}
+}
@@ -0,0 +1,215 @@
+package sqlite
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// encodings are the encodings of text that is imported and exported, by name, as SQLite
+// text must be UTF-8 (or UTF-16). UTF-8 is nil, as its text is not converted
+var encodings = map[string]encoding.Encoding{
+	"utf-8":      nil,
+	"utf8":       nil,
+	"utf-16":     unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	"utf-16le":   unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"utf-16be":   unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	"latin-1":    charmap.ISO8859_1,
+	"latin1":     charmap.ISO8859_1,
+	"iso-8859-1": charmap.ISO8859_1,
+	"shift-jis":  japanese.ShiftJIS,
+	"shift_jis":  japanese.ShiftJIS,
+	"sjis":       japanese.ShiftJIS,
+}
+
+// byte order marks
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// lookupEncoding returns the encoding of the name
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	enc, ok := encodings[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encoding: %s", name)
+	}
+	return enc, nil
+}
+
+// DetectEncoding returns the likely encoding of the text: by its byte order mark, if it
+// has one, or else "utf-16le" or "utf-16be" for text of mostly ASCII characters with
+// zero bytes between them, "utf-8" for valid UTF-8 (so ASCII), "shift-jis" for text
+// that is valid Shift-JIS and unlikely to be Latin-1, and "latin-1" otherwise
+func DetectEncoding(text []byte) string {
+	return detectEncoding(text, true)
+}
+
+// detectEncoding returns the likely encoding of the text, which is the start of
+// the input, if not eof, so may end within a character
+func detectEncoding(text []byte, eof bool) string {
+	switch {
+	case bytes.HasPrefix(text, utf8BOM):
+		return "utf-8"
+	case bytes.HasPrefix(text, utf16LEBOM):
+		return "utf-16le"
+	case bytes.HasPrefix(text, utf16BEBOM):
+		return "utf-16be"
+	}
+	var zeros [2]int
+	for i, b := range text {
+		if b == 0 {
+			zeros[i%2]++
+		}
+	}
+	switch half := len(text) / 2; {
+	case half > 0 && zeros[0] == 0 && 2*zeros[1] > half:
+		return "utf-16le"
+	case half > 0 && zeros[1] == 0 && 2*zeros[0] > half:
+		return "utf-16be"
+	}
+	valid := utf8.Valid(text)
+	for cut := 1; !valid && !eof && cut < utf8.UTFMax && cut <= len(text); cut++ {
+		// the text may end within a character
+		valid = utf8.Valid(text[:len(text)-cut])
+	}
+	switch {
+	case valid:
+		return "utf-8"
+	case shiftJIS(text, eof):
+		return "shift-jis"
+	}
+	return "latin-1"
+}
+
+// shiftJIS reports whether the text is valid Shift-JIS, with double byte characters that
+// are unlikely to be pairs of Latin-1 characters: those with a first byte that would be
+// a C1 control, which text doesn't have, or with most second bytes that aren't ASCII,
+// as Latin-1 letters are mostly between ASCII ones
+func shiftJIS(text []byte, eof bool) bool {
+	pairs, high, c1 := 0, 0, false
+	for i := 0; i < len(text); i++ {
+		switch b := text[i]; {
+		case b < 0x80 || b >= 0xA1 && b <= 0xDF:
+			// ASCII, or a half width katakana
+		case b >= 0x81 && b <= 0x9F || b >= 0xE0 && b <= 0xFC:
+			if i+1 == len(text) {
+				if eof {
+					return false
+				}
+				break
+			}
+			i++
+			if t := text[i]; t < 0x40 || t == 0x7F || t > 0xFC {
+				return false
+			} else if t >= 0x80 {
+				high++
+			}
+			pairs++
+			c1 = c1 || b <= 0x9F
+		default:
+			return false
+		}
+	}
+	return pairs > 0 && (c1 || 2*high > pairs)
+}
+
+// decodeReader returns a reader of the text of r as UTF-8, from the encoding, or the
+// one detected if it's empty. Text with a byte order mark is read by its encoding
+func decodeReader(r io.Reader, name string) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+	head, err := br.Peek(64 << 10)
+	eof := err == io.EOF
+	if err != nil && !eof && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(head, utf8BOM):
+		br.Discard(len(utf8BOM))
+		return br, nil
+	case bytes.HasPrefix(head, utf16LEBOM):
+		return transform.NewReader(br, unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder()), nil
+	case bytes.HasPrefix(head, utf16BEBOM):
+		return transform.NewReader(br, unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder()), nil
+	}
+	if name == "" {
+		name = detectEncoding(head, eof)
+	}
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		return br, nil
+	}
+	return transform.NewReader(br, enc.NewDecoder()), nil
+}
+
+// nopWriteCloser is a writer with a Close that does nothing
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// EncodeWriter returns a writer of UTF-8 text to w in the encoding, one of those of
+// DetectEncoding, or "utf-16", which is little endian with a byte order mark, e.g. to
+// export CSV to systems that expect another encoding. It must be closed to write the
+// end of the text, and fails writes of characters the encoding doesn't have
+func EncodeWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		return nopWriteCloser{w}, nil
+	}
+	return transform.NewWriter(w, enc.NewEncoder()), nil
+}
+
+// decodeText returns the text in the encoding as UTF-8, for the decode_text SQL function
+func decodeText(text []byte, name string) (string, error) {
+	if name == "" {
+		name = DetectEncoding(text)
+	}
+	switch {
+	case bytes.HasPrefix(text, utf8BOM):
+		text, name = text[len(utf8BOM):], "utf-8"
+	case bytes.HasPrefix(text, utf16LEBOM), bytes.HasPrefix(text, utf16BEBOM):
+		name = "utf-16"
+	}
+	enc, err := lookupEncoding(name)
+	if err != nil || enc == nil {
+		return string(text), err
+	}
+	b, err := enc.NewDecoder().Bytes(text)
+	return string(b), err
+}
+
+// EncodingFuncs are the SQL functions registered by WithEncodingFuncs
+var EncodingFuncs = []FuncReg{
+	{"detect_encoding", DetectEncoding, true},
+	{"decode_text", decodeText, true},
+}
+
+// WithEncodingFuncs registers the SQL functions detect_encoding(blob), returning the
+// encoding of the blob as DetectEncoding does, and decode_text(blob, encoding), returning
+// the text of the blob in the encoding as UTF-8, or in that detected if it's empty, e.g.
+// to repair text imported as blobs, as it wasn't UTF-8:
+//
+//	UPDATE notes SET body = decode_text(body, '') WHERE typeof(body) = 'blob'
+func WithEncodingFuncs() Optional {
+	return WithFunctions(EncodingFuncs...)
+}
@@ -0,0 +1,121 @@
+package sqlite
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// encode returns the text in the encoding
+func encode(t *testing.T, text, name string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := EncodeWriter(&buf, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		text     []byte
+		expected string
+	}{
+		{[]byte("plain ascii"), "utf-8"},
+		{[]byte("São Paulo"), "utf-8"},
+		{[]byte("S\xE3o Paulo"), "latin-1"},
+		{[]byte("caf\xE9"), "latin-1"},
+		{append(utf8BOM, "x"...), "utf-8"},
+		{encode(t, "name,city\nJosé,São Paulo\n", "utf-16"), "utf-16le"},
+		{encode(t, "name,city\n", "utf-16le"), "utf-16le"},
+		{encode(t, "name,city\n", "utf-16be"), "utf-16be"},
+		{encode(t, "こんにちは、東京", "shift-jis"), "shift-jis"},
+		{encode(t, "テスト", "shift-jis"), "shift-jis"},
+		{[]byte{}, "utf-8"},
+	}
+	for _, test := range tests {
+		if got := DetectEncoding(test.text); got != test.expected {
+			t.Errorf("%q: expected %s but got: %s", test.text, test.expected, got)
+		}
+	}
+}
+
+func TestImportEncodings(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	for i, name := range []string{"utf-8", "utf-16", "utf-16le", "utf-16be", "shift-jis"} {
+		table := strings.Replace("people_"+name, "-", "_", -1)
+		input := "name,city\nJosé,São Paulo\n山田,東京\n"
+		if name == "shift-jis" {
+			input = "name,city\n山田,東京\n"
+		}
+		for _, given := range []string{name, ""} {
+			opts := &CSVOptions{ImportOptions: ImportOptions{Encoding: given}}
+			if _, err := ImportCSV(db, table, bytes.NewReader(encode(t, input, name)), opts); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+		var city string
+		if err := row(db, []interface{}{&city}, "select city from "+table+" where name = '山田'"); err != nil || city != "東京" {
+			t.Errorf("%d %s: unexpected city: %q (%v)", i, name, city, err)
+		}
+	}
+	if _, err := EncodeWriter(&bytes.Buffer{}, "ebcdic"); err == nil {
+		t.Error("expected error for unsupported encoding")
+	}
+	if _, err := ImportCSV(db, "nope", strings.NewReader("x\n1\n"), &CSVOptions{ImportOptions: ImportOptions{Encoding: "ebcdic"}}); err == nil {
+		t.Error("expected error for unsupported encoding")
+	}
+
+	// exported in another encoding
+	var buf bytes.Buffer
+	w, err := EncodeWriter(&buf, "latin-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportCSV(db, w, "select distinct name, city from people_utf_8 where name = 'José'"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if buf.String() != "name,city\nJos\xE9,S\xE3o Paulo\n" {
+		t.Errorf("unexpected export: %q", buf.String())
+	}
+	w, _ = EncodeWriter(&bytes.Buffer{}, "latin-1")
+	err = ExportCSV(db, w, "select * from people_utf_8")
+	if err == nil {
+		err = w.Close()
+	}
+	if err == nil {
+		t.Error("expected error for characters not in latin-1")
+	}
+}
+
+func TestEncodingFuncs(t *testing.T) {
+	db, err := Open(":memory:", WithEncodingFuncs(), WithDriver("encodings"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	sjis := encode(t, "東京", "shift-jis")
+	var detected, decoded, given string
+	err = row(db, []interface{}{&detected, &decoded, &given}, "select detect_encoding(?), decode_text(?, ''), decode_text(?, 'latin-1')", sjis, sjis, []byte("caf\xE9"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detected != "shift-jis" || decoded != "東京" || given != "café" {
+		t.Errorf("unexpected results: %q %q %q", detected, decoded, given)
+	}
+	if err := row(db, []interface{}{&decoded}, "select decode_text(?, '')", encode(t, "héllo", "utf-16")); err != nil || decoded != "héllo" {
+		t.Errorf("unexpected text: %q (%v)", decoded, err)
+	}
+	if err := row(db, []interface{}{&decoded}, "select decode_text(x'41', 'ebcdic')"); err == nil {
+		t.Error("expected error for unsupported encoding")
+	}
+}
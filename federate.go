@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FederatedSource is the name of the column added by UnionView, holding the alias of the source of each row
+const FederatedSource = "_source"
+
+// Federate returns a handle on an in-memory database with each of the files attached,
+// under an alias derived from its base name (e.g. "2024_01" for "logs/2024-01.db"),
+// for querying databases sharded by e.g. month as one. See UnionView.
+// SQLite limits the number of attached databases, to 10 by default.
+//
+// Attached databases, and the views of UnionView, belong to a connection,
+// so the handle has a single connection, which attaches the files when opened
+func Federate(files []string) (*sql.DB, error) {
+	used := make(map[string]bool)
+	var attach strings.Builder
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil {
+			return nil, err
+		}
+		alias := federatedAlias(file, used)
+		fmt.Fprintf(&attach, "ATTACH DATABASE %s AS %s;\n", sqlLiteral(file), quoteIdent(alias))
+	}
+	db, err := Open(":memory:", WithQuery(attach.String()))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// federatedAlias returns a unique alias for an attached file
+func federatedAlias(file string, used map[string]bool) string {
+	base := filepath.Base(file)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	alias := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, base)
+	switch strings.ToLower(alias) {
+	case "", "main", "temp":
+		alias = "db_" + alias
+	}
+	unique := alias
+	for i := 2; used[strings.ToLower(unique)]; i++ {
+		unique = fmt.Sprintf("%s_%d", alias, i)
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
+
+// UnionView creates a temporary view of the rows of a table in each of the attached
+// databases that have it, with the alias of its database in the column FederatedSource.
+// The tables must have the same columns, though not necessarily in the same order
+func UnionView(db *sql.DB, view, table string) error {
+	dbs, err := Databases(db)
+	if err != nil {
+		return err
+	}
+	var columns []string
+	var selects []string
+	for _, d := range dbs {
+		if d.Name == "main" || d.Name == "temp" {
+			continue
+		}
+		var cols []string
+		fn := func(_ []string, row []interface{}) {
+			cols = append(cols, fmt.Sprint(row[1]))
+		}
+		if err := query(db, fn, fmt.Sprintf("PRAGMA %s.table_info(%s)", quoteIdent(d.Name), quoteIdent(table))); err != nil {
+			return err
+		}
+		if len(cols) == 0 {
+			continue // the table isn't in this database
+		}
+		if columns == nil {
+			columns = cols
+		} else if !sameColumns(columns, cols) {
+			return fmt.Errorf("table %s in %s has columns %v, not %v", table, d.Name, cols, columns)
+		}
+		selects = append(selects, fmt.Sprintf("SELECT %s, %s AS %s FROM %s.%s",
+			quotedList(columns), sqlLiteral(d.Name), quoteIdent(FederatedSource), quoteIdent(d.Name), quoteIdent(table)))
+	}
+	if len(selects) == 0 {
+		return fmt.Errorf("no attached database has table: %s", table)
+	}
+	create := fmt.Sprintf("CREATE TEMP VIEW %s AS\n%s", quoteIdent(view), strings.Join(selects, "\nUNION ALL\n"))
+	_, err = db.Exec(create)
+	return err
+}
+
+// sameColumns reports whether the lists have the same columns, in any order
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	have := make(map[string]bool)
+	for _, c := range a {
+		have[strings.ToLower(c)] = true
+	}
+	for _, c := range b {
+		if !have[strings.ToLower(c)] {
+			return false
+		}
+	}
+	return true
+}
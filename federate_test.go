@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestFederate(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i, month := range []string{"2024-01", "2024-02", "other/2024-02"} {
+		file := filepath.Join(dir, month+".db")
+		db, err := Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schema := "create table events (id integer primary key, name text)"
+		if i == 1 {
+			schema = "create table events (name text, id integer primary key)"
+		}
+		if _, err := db.Exec(schema); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j <= i; j++ {
+			if _, err := db.Exec("insert into events (name) values (?)", fmt.Sprintf("%s-%d", month, j)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		db.Close()
+		files = append(files, file)
+	}
+
+	db, err := Federate(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	dbs, err := Databases(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var aliases []string
+	for _, d := range dbs[1:] {
+		aliases = append(aliases, d.Name)
+	}
+	if fmt.Sprint(aliases) != "[2024_01 2024_02 2024_02_2]" {
+		t.Fatalf("unexpected aliases: %v", aliases)
+	}
+
+	if err := UnionView(db, "all_events", "events"); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := row(db, []interface{}{&count}, "select count(*) from all_events"); err != nil {
+		t.Fatal(err)
+	}
+	if count != 6 {
+		t.Fatalf("expected 6 events but got: %d", count)
+	}
+	var name string
+	if err := row(db, []interface{}{&name}, "select name from all_events where _source = '2024_02' and id = 2"); err != nil {
+		t.Fatal(err)
+	}
+	if name != "2024-02-1" {
+		t.Fatalf("unexpected event: %q", name)
+	}
+
+	if err := UnionView(db, "nothing", "nosuch"); err == nil {
+		t.Fatal("expected an error for a missing table")
+	}
+	if _, err := Federate([]string{filepath.Join(dir, "missing.db")}); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package sqlite
+
+import (
+	"os"
+	"syscall"
+)
+
+// flock takes an advisory lock on the file without waiting
+func flock(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return ErrLocked
+	}
+	return err
+}
+
+func funlock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,312 @@
+package sqlite
+
+import (
+	"bytes"
+	"strings"
+)
+
+// FormatIndent is the indentation of the SQL formatted by FormatSQL
+var FormatIndent = "  "
+
+// reservedKeywords are the keywords that can't be used as bare identifiers,
+// so are always upper cased by FormatSQL. Other keywords are upper cased only
+// where they can't be the names of tables or columns, see formatter.keyword
+var reservedKeywords = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`ADD ALL ALTER AND AS AUTOINCREMENT BETWEEN CASE CHECK
+		COLLATE COMMIT CONSTRAINT CREATE DEFAULT DEFERRABLE DELETE DISTINCT DROP ELSE
+		ESCAPE EXCEPT EXISTS FOREIGN FROM GROUP HAVING IN INDEX INDEXED INSERT INTERSECT
+		INTO IS ISNULL JOIN LIMIT NOT NOTHING NOTNULL NULL ON OR ORDER PRIMARY REFERENCES
+		RETURNING ROLLBACK SELECT SET TABLE THEN TO TRANSACTION UNION UNIQUE UPDATE USING
+		VALUES WHEN WHERE`) {
+		reservedKeywords[k] = true
+	}
+}
+
+// followingKeywords are the keywords upper cased after the keyword preceding them
+var followingKeywords = map[string]string{
+	"PRIMARY":   "KEY",
+	"FOREIGN":   "KEY",
+	"ORDER":     "BY",
+	"GROUP":     "BY",
+	"CREATE":    "TEMP TEMPORARY VIRTUAL VIEW TRIGGER",
+	"TEMP":      "VIEW TRIGGER",
+	"TEMPORARY": "VIEW TRIGGER",
+	"DROP":      "VIEW TRIGGER",
+	"ON":        "CONFLICT",
+	"OR":        "REPLACE IGNORE ABORT FAIL ROLLBACK",
+	"WITH":      "RECURSIVE",
+	"NOT":       "EXISTS",
+}
+
+// clauseKeywords start a new line when at the level of a statement or subquery
+var clauseKeywords = map[string]bool{
+	"FROM": true, "WHERE": true, "GROUP": true, "HAVING": true, "ORDER": true, "LIMIT": true,
+	"UNION": true, "EXCEPT": true, "INTERSECT": true, "SET": true, "VALUES": true,
+	"JOIN": true, "LEFT": true, "RIGHT": true, "FULL": true, "INNER": true, "CROSS": true, "NATURAL": true,
+	"WINDOW": true, "RETURNING": true,
+}
+
+// joinKeywords may precede JOIN, which then continues their line
+var joinKeywords = map[string]bool{
+	"LEFT": true, "RIGHT": true, "FULL": true, "INNER": true, "CROSS": true, "NATURAL": true, "OUTER": true,
+}
+
+// triggerKeywords are upper cased in the headers of triggers
+var triggerKeywords = map[string]bool{
+	"BEFORE": true, "AFTER": true, "INSTEAD": true, "OF": true, "FOR": true, "EACH": true, "ROW": true,
+}
+
+// kinds of parentheses
+const (
+	parenPlain = iota // an expression, or the arguments of a function
+	parenList         // the columns of a table, one per line
+	parenQuery        // a subquery, with its clauses on lines of their own
+)
+
+// FormatSQL formats SQL to be read and compared: keywords are upper cased, whitespace
+// is collapsed, the clauses of queries and the columns of tables start lines of their own,
+// and subqueries and trigger bodies are indented. Strings, quoted identifiers, and
+// comments are kept as they are, as are the names of tables and columns, so the
+// formatted statements are equivalent to the originals
+func FormatSQL(sql string) string {
+	f := &formatter{tokens: fingerprintToken.FindAllString(sql, -1)}
+	f.format()
+	return strings.TrimSpace(f.b.String())
+}
+
+type formatter struct {
+	b        bytes.Buffer
+	tokens   []string
+	parens   []int
+	indent   int
+	space    bool   // whitespace preceded the token
+	start    bool   // at the start of a line
+	indented int    // the length of the output before the line
+	prev     string // the last token written, upper cased if a keyword
+	first    bool   // the next word is the first of a statement
+	verb     string // the first word of the statement
+	create   string // what the statement creates, e.g. TABLE (or VIRTUAL, for virtual tables)
+	cases    int    // the CASE expressions open
+	trigger  bool   // within the body of a trigger
+}
+
+// line starts a new line, at the indentation
+func (f *formatter) line() {
+	if f.start {
+		// nothing was written to the line, so it is indented anew
+		f.b.Truncate(f.indented)
+	}
+	if f.b.Len() > 0 {
+		f.indented = f.b.Len()
+		f.b.WriteByte('\n')
+		f.b.WriteString(strings.Repeat(FormatIndent, f.indent))
+	}
+	f.space = false
+	f.start = true
+}
+
+// write writes the token, after a space if one preceded it
+func (f *formatter) write(tok string) {
+	if f.space && f.b.Len() > 0 && !f.start {
+		f.b.WriteByte(' ')
+	}
+	f.b.WriteString(tok)
+	f.space = false
+	f.start = false
+	f.prev = tok
+}
+
+// next returns the next token that isn't whitespace or a comment, upper cased
+func (f *formatter) next(i int) string {
+	for _, tok := range f.tokens[i+1:] {
+		if strings.TrimSpace(tok) != "" && !strings.HasPrefix(tok, "--") && !strings.HasPrefix(tok, "/*") {
+			return strings.ToUpper(tok)
+		}
+	}
+	return ""
+}
+
+// block reports whether the tokens are at the level of a statement or subquery
+func (f *formatter) block() bool {
+	return len(f.parens) == 0 || f.parens[len(f.parens)-1] == parenQuery
+}
+
+// keyword returns the word upper cased if it is a keyword where it is
+func (f *formatter) keyword(word string, i int) string {
+	upper := strings.ToUpper(word)
+	if upper == "ROWID" && f.prev == "WITHOUT" {
+		return upper
+	}
+	if !sqlKeywords[upper] {
+		return word
+	}
+	switch {
+	case reservedKeywords[upper], f.first:
+		return upper
+	case triggerKeywords[upper] && f.create == "TRIGGER" && !f.trigger:
+		// in the header of a trigger, but not its name or table
+		if p := f.prev; p != "TRIGGER" && p != "EXISTS" && p != "ON" && p != "." {
+			return upper
+		}
+	case strings.Contains(" "+followingKeywords[f.prev]+" ", " "+upper+" "):
+		return upper
+	case joinKeywords[upper]:
+		if next := f.next(i); next == "JOIN" || joinKeywords[next] {
+			return upper
+		}
+	case upper == "IF":
+		if next := f.next(i); next == "NOT" || next == "EXISTS" {
+			return upper
+		}
+	case upper == "ASC" || upper == "DESC":
+		// not a column, which follows "(" or "," rather than a name
+		if p := f.prev; p != "(" && p != "," && !reservedKeywords[p] {
+			if next := f.next(i); next == "," || next == ")" || next == ";" || next == "" || sqlKeywords[next] {
+				return upper
+			}
+		}
+	case upper == "END":
+		if f.cases > 0 || f.trigger {
+			return upper
+		}
+	case upper == "BEGIN":
+		if f.create == "TRIGGER" {
+			return upper
+		}
+	case upper == "WITHOUT":
+		if f.prev == ")" && f.next(i) == "ROWID" {
+			return upper
+		}
+	}
+	return word
+}
+
+func (f *formatter) format() {
+	f.first = true
+	for i, tok := range f.tokens {
+		switch {
+		case strings.TrimSpace(tok) == "":
+			f.space = true
+			continue
+		case strings.HasPrefix(tok, "--"):
+			f.space = true
+			f.write(tok)
+			f.line()
+			continue
+		case strings.HasPrefix(tok, "/*"):
+			f.space = true
+			f.write(tok)
+			f.space = true
+			continue
+		}
+		c := tok[0]
+		if c != '_' && !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z') {
+			f.punctuation(tok, i)
+			continue
+		}
+		word := f.keyword(tok, i)
+		upper := strings.ToUpper(tok)
+		if f.first {
+			f.verb, f.create = upper, ""
+		} else if f.verb == "CREATE" && f.create == "" && (upper == "TABLE" || upper == "VIRTUAL" || upper == "INDEX" || upper == "VIEW" || upper == "TRIGGER") {
+			f.create = upper
+		}
+		switch {
+		case word != upper:
+			// not a keyword here
+		case upper == "CASE":
+			f.cases++
+		case upper == "END" && f.cases > 0:
+			f.cases--
+		case upper == "END" && f.trigger:
+			f.trigger = false
+			f.indent--
+			f.line()
+		case !f.first && f.block() && clauseKeywords[upper] && !f.continues(upper):
+			f.line()
+		case f.verb == "WITH" && f.prev == ")" && len(f.parens) == 0:
+			// the statement following its common table expressions
+			if kind := statementKinds[upper]; kind == KindQuery || kind == KindWrite {
+				f.line()
+			}
+		}
+		f.write(word)
+		f.first = false
+		if word == "BEGIN" && f.create == "TRIGGER" {
+			f.trigger = true
+			f.indent++
+			f.line()
+			f.first = true
+		}
+	}
+}
+
+// continues reports whether the clause keyword continues the line of the one before it,
+// e.g. JOIN after LEFT, or FROM after DELETE
+func (f *formatter) continues(upper string) bool {
+	switch {
+	case joinKeywords[f.prev]:
+		return true
+	case upper == "FROM":
+		return f.prev == "DELETE" || f.prev == "DISTINCT"
+	case upper == "VALUES":
+		return f.prev == "DEFAULT"
+	case upper == "SET":
+		// e.g. ON DELETE SET NULL
+		return f.prev == "DELETE" || f.prev == "UPDATE"
+	}
+	return false
+}
+
+// punctuation writes a token that isn't a word
+func (f *formatter) punctuation(tok string, i int) {
+	switch tok {
+	case "(":
+		kind := parenPlain
+		switch next := f.next(i); {
+		case next == "SELECT" || next == "WITH" || next == "VALUES":
+			kind = parenQuery
+		case f.create == "TABLE" && f.verb == "CREATE" && len(f.parens) == 0 && f.prev != "AS":
+			kind = parenList
+			f.space = true
+		}
+		f.write(tok)
+		f.parens = append(f.parens, kind)
+		if kind != parenPlain {
+			f.indent++
+			f.line()
+		}
+		return
+	case ")":
+		kind := parenPlain
+		if n := len(f.parens); n > 0 {
+			kind, f.parens = f.parens[n-1], f.parens[:n-1]
+		}
+		if kind != parenPlain {
+			f.indent--
+			f.line()
+		}
+		f.space = false
+		f.write(tok)
+		return
+	case ",":
+		f.space = false
+		f.write(tok)
+		if n := len(f.parens); n > 0 && f.parens[n-1] == parenList {
+			f.line()
+		}
+		return
+	case ";":
+		f.space = false
+		f.write(tok)
+		f.line()
+		f.first = true
+		return
+	}
+	if f.first {
+		f.first = false
+	}
+	f.write(tok)
+}
@@ -0,0 +1,81 @@
+package sqlite
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFormatSQL(t *testing.T) {
+	tests := []struct {
+		sql, want string
+	}{
+		{
+			"create table if not exists key (key text primary key, desc text not null default 'a;b', check(length(key) > 0)) without rowid",
+			`CREATE TABLE IF NOT EXISTS key (
+  key text PRIMARY KEY,
+  desc text NOT NULL DEFAULT 'a;b',
+  CHECK(length(key) > 0)
+) WITHOUT ROWID`,
+		},
+		{
+			"select a, count(*) as n from t left join u on t.id=u.tid where a in (select b from v order by b desc) group by a order by n desc limit 5",
+			`SELECT a, count(*) AS n
+FROM t
+LEFT JOIN u ON t.id=u.tid
+WHERE a IN (
+  SELECT b
+  FROM v
+  ORDER BY b DESC
+)
+GROUP BY a
+ORDER BY n DESC
+LIMIT 5`,
+		},
+		{
+			"create trigger tr after insert on t for each row begin update u set n = n + 1 where id = new.id; end",
+			`CREATE TRIGGER tr AFTER INSERT ON t FOR EACH ROW BEGIN
+  UPDATE u
+  SET n = n + 1
+  WHERE id = new.id;
+END`,
+		},
+		{
+			"with old as (select id from t) delete from t where id in old -- stale\n",
+			`WITH old AS (
+  SELECT id
+  FROM t
+)
+DELETE FROM t
+WHERE id IN old -- stale`,
+		},
+		{"insert into \"Select\" values('select  from')", "INSERT INTO \"Select\"\nVALUES('select  from')"},
+	}
+	for _, tt := range tests {
+		if got := FormatSQL(tt.sql); got != tt.want {
+			t.Errorf("%q: expected:\n%s\nbut got:\n%s", tt.sql, tt.want, got)
+		}
+	}
+
+	// formatted statements are equivalent to the originals
+	db := memDB(t)
+	if _, err := db.Exec("create table t (id integer, a, tid); create table u (id integer, tid, n); create table v (b)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests[:3] {
+		if _, err := db.Exec(FormatSQL(tt.sql)); err != nil {
+			t.Errorf("formatted %q failed: %v", tt.sql, err)
+		}
+	}
+	if columns, _ := tableColumns(db, "key"); strings.Join(columns, ",") != "key,desc" {
+		t.Errorf("expected column names kept but got: %v", columns)
+	}
+
+	var buf bytes.Buffer
+	if err := Commands(db, ".schema KEY", false, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "CREATE TABLE key (\n  key text PRIMARY KEY,") {
+		t.Errorf("unexpected schema: %q", buf.String())
+	}
+}
@@ -0,0 +1,289 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+const intentSchema = `CREATE TABLE IF NOT EXISTS _intents (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	sql TEXT NOT NULL,
+	time TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+	applied TIMESTAMP,
+	last_id INTEGER,
+	affected INTEGER,
+	error TEXT
+);
+CREATE TABLE IF NOT EXISTS _intent_args (
+	seq INTEGER NOT NULL,
+	pos INTEGER NOT NULL,
+	value,
+	PRIMARY KEY (seq, pos)
+) WITHOUT ROWID;`
+
+// intentOffsetSchema is kept in the database the intents are applied to, so
+// the intents are applied once, in the transactions that apply them
+const intentOffsetSchema = `CREATE TABLE IF NOT EXISTS _intent_offsets (
+	journal TEXT PRIMARY KEY,
+	seq INTEGER NOT NULL
+)`
+
+// errResultLost is recorded for intents applied whose results weren't recorded
+const errResultLost = "applied, but its result was lost"
+
+// IntentResult is the result of an intent applied by a Server
+type IntentResult struct {
+	Seq          int64
+	LastInsertID int64
+	RowsAffected int64
+	Err          error // the statement failed, or its result was lost
+}
+
+// IntentJournal is a database, separate from one owned by a Server, in which other
+// processes append the writes they would make to it as intents, for the server to
+// apply, so that processes sharing a database don't contend for its write lock,
+// only briefly for that of the journal. The arguments of the statements keep their
+// SQLite types, and the server records the result of each intent in the journal
+type IntentJournal struct {
+	db   *sql.DB
+	file string
+}
+
+// OpenIntentJournal opens the journal, creating it if needed
+func OpenIntentJournal(file string) (*IntentJournal, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	db, err := Open(abs)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(intentSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("intent journal: %s, error: %w", file, err)
+	}
+	return &IntentJournal{db: db, file: abs}, nil
+}
+
+// Close closes the journal
+func (j *IntentJournal) Close() error {
+	return j.db.Close()
+}
+
+// Append records the statement as an intent, returning its sequence
+func (j *IntentJournal) Append(q string, args ...interface{}) (int64, error) {
+	tx, err := j.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec("INSERT INTO _intents (sql) VALUES(?)", q)
+	if err != nil {
+		return 0, err
+	}
+	seq, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	for i, arg := range args {
+		if _, err := tx.Exec("INSERT INTO _intent_args (seq, pos, value) VALUES(?, ?, ?)", seq, i, arg); err != nil {
+			return 0, err
+		}
+	}
+	return seq, tx.Commit()
+}
+
+// Result returns the result of the intent, and whether it has been applied
+func (j *IntentJournal) Result(seq int64) (IntentResult, bool, error) {
+	r := IntentResult{Seq: seq}
+	var applied sql.NullTime
+	var last, affected sql.NullInt64
+	var failed sql.NullString
+	dest := []interface{}{&applied, &last, &affected, &failed}
+	if err := row(j.db, dest, "SELECT applied, last_id, affected, error FROM _intents WHERE seq=?", seq); err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("no intent: %d", seq)
+		}
+		return r, false, err
+	}
+	r.LastInsertID, r.RowsAffected = last.Int64, affected.Int64
+	if failed.Valid {
+		r.Err = errors.New(failed.String)
+	}
+	return r, applied.Valid, nil
+}
+
+// Wait waits for the intent to be applied, and returns its result
+func (j *IntentJournal) Wait(ctx context.Context, seq int64) (IntentResult, error) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		r, applied, err := j.Result(seq)
+		if err != nil || applied {
+			return r, err
+		}
+		select {
+		case <-ctx.Done():
+			return r, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune deletes the intents applied before the time, returning the number deleted
+func (j *IntentJournal) Prune(before time.Time) (int64, error) {
+	tx, err := j.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	const applied = "SELECT seq FROM _intents WHERE applied < ?"
+	cutoff := before.UTC().Format("2006-01-02 15:04:05.000")
+	if _, err := tx.Exec("DELETE FROM _intent_args WHERE seq IN ("+applied+")", cutoff); err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec("DELETE FROM _intents WHERE applied < ?", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	return n, tx.Commit()
+}
+
+// intent is a statement of a journal to apply
+type intent struct {
+	seq  int64
+	sql  string
+	args []interface{}
+}
+
+// pending returns the intents after the sequence, in order
+func (j *IntentJournal) pending(after int64, limit int) ([]intent, error) {
+	var intents []intent
+	rows, err := j.db.Query("SELECT seq, sql FROM _intents WHERE seq > ? ORDER BY seq LIMIT ?", after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var in intent
+		if err := rows.Scan(&in.seq, &in.sql); err != nil {
+			return nil, err
+		}
+		intents = append(intents, in)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	for i := range intents {
+		err := query(j.db, func(_ []string, row []interface{}) {
+			intents[i].args = append(intents[i].args, row[0])
+		}, "SELECT value FROM _intent_args WHERE seq=? ORDER BY pos", intents[i].seq)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return intents, nil
+}
+
+// record records the result of the intent
+func (j *IntentJournal) record(r IntentResult) error {
+	var failed interface{}
+	if r.Err != nil {
+		failed = r.Err.Error()
+	}
+	const update = `UPDATE _intents SET applied = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+last_id = ?, affected = ?, error = ? WHERE seq = ?`
+	_, err := j.db.Exec(update, r.LastInsertID, r.RowsAffected, failed, r.Seq)
+	return err
+}
+
+// ApplyIntents applies the intents of the journal not yet applied, in order, each in a
+// transaction that also records it as applied in the table _intent_offsets of the
+// database, so each is applied once, even if the server stops before recording its
+// result in the journal. A statement that fails is recorded as applied, with its error.
+// It returns the number of intents applied
+func (s *Server) ApplyIntents(j *IntentJournal) (int, error) {
+	var offset int64
+	err := s.do(PriorityNormal, "intent offset", func(db *sql.DB) error {
+		if _, err := db.Exec(intentOffsetSchema); err != nil {
+			return err
+		}
+		err := row(db, []interface{}{&offset}, "SELECT seq FROM _intent_offsets WHERE journal=?", j.file)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	// intents applied whose results weren't recorded
+	const lost = `UPDATE _intents SET applied = strftime('%Y-%m-%d %H:%M:%f', 'now'), error = ?
+WHERE seq <= ? AND applied IS NULL`
+	if _, err := j.db.Exec(lost, errResultLost, offset); err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for {
+		intents, err := j.pending(offset, 100)
+		if err != nil || len(intents) == 0 {
+			return applied, err
+		}
+		for _, in := range intents {
+			r := IntentResult{Seq: in.seq}
+			const advance = `INSERT INTO _intent_offsets (journal, seq) VALUES(?, ?)
+ON CONFLICT(journal) DO UPDATE SET seq = excluded.seq`
+			err := s.Transact(func(tx Tx) error {
+				ctx := context.Background()
+				result, err := tx.ExecContext(ctx, "SAVEPOINT intent")
+				if err == nil {
+					result, err = tx.ExecContext(ctx, in.sql, in.args...)
+				}
+				if err != nil {
+					r.Err = err
+					tx.ExecContext(ctx, "ROLLBACK TO intent")
+				} else {
+					r.LastInsertID, _ = result.LastInsertId()
+					r.RowsAffected, _ = result.RowsAffected()
+				}
+				_, err = tx.ExecContext(ctx, advance, j.file, in.seq)
+				return err
+			})
+			if err != nil {
+				return applied, fmt.Errorf("intent: %d, error: %w", in.seq, err)
+			}
+			offset = in.seq
+			applied++
+			if err := j.record(r); err != nil {
+				return applied, err
+			}
+		}
+	}
+}
+
+// Forward applies the intents of the journal at every interval until the context is done
+func (s *Server) Forward(ctx context.Context, j *IntentJournal, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid forward interval: %v", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.ApplyIntents(j); err != nil {
+			loggerOf(s.DB).Error("applying intents failed", "db", logName(s.DB), "op", "forward", "journal", j.file, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIntentJournal(t *testing.T) {
+	dir := t.TempDir()
+	db := fileDB(t, dir)
+	s := NewServer(db)
+	defer s.Close()
+
+	journal := filepath.Join(dir, "source.intents")
+	owner, err := OpenIntentJournal(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer owner.Close()
+	// another process, appending its writes
+	writer, err := OpenIntentJournal(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	seq, err := writer.Append("insert into structs(name, kind, data) values(?, ?, ?)", "forwarded", 7, []byte{0, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad, err := writer.Append("insert into nosuch values(?)", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, applied, err := writer.Result(seq); err != nil || applied {
+		t.Errorf("expected the intent to be pending: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Forward(ctx, owner, 10*time.Millisecond)
+
+	wait, cancelWait := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelWait()
+	r, err := writer.Wait(wait, seq)
+	if err != nil || r.Err != nil || r.RowsAffected != 1 || r.LastInsertID != 5 {
+		t.Errorf("unexpected result: %+v (%v)", r, err)
+	}
+	if r, err := writer.Wait(wait, bad); err != nil || r.Err == nil {
+		t.Errorf("expected the statement to fail but got: %+v (%v)", r, err)
+	}
+	var kind int
+	var data []byte
+	if err := row(db, []interface{}{&kind, &data}, "select kind, data from structs where name='forwarded' and typeof(kind)='integer'"); err != nil || kind != 7 || !bytes.Equal(data, []byte{0, 1, 2}) {
+		t.Errorf("unexpected row: %d %v (%v)", kind, data, err)
+	}
+	cancel()
+
+	// intents are applied once
+	if n, err := s.ApplyIntents(owner); err != nil || n != 0 {
+		t.Errorf("expected no intents to apply but got: %d (%v)", n, err)
+	}
+	// as are those whose results weren't recorded
+	lost, err := writer.Append("update structs set kind = kind + 1 where name='forwarded'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("update _intent_offsets set seq = ?", lost); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.ApplyIntents(owner); err != nil || n != 0 {
+		t.Errorf("expected no intents to apply but got: %d (%v)", n, err)
+	}
+	if r, applied, err := writer.Result(lost); err != nil || !applied || r.Err == nil || r.Err.Error() != errResultLost {
+		t.Errorf("unexpected result: %+v %v (%v)", r, applied, err)
+	}
+
+	if n, err := writer.Prune(time.Now().Add(time.Minute)); err != nil || n != 3 {
+		t.Errorf("expected 3 pruned intents but got: %d (%v)", n, err)
+	}
+	if _, _, err := writer.Result(seq); err == nil {
+		t.Error("expected the intent to be pruned")
+	}
+}
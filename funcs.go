@@ -0,0 +1,174 @@
+package sqlite
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// allowedTypes describes the Go types usable in the signature of a custom function
+const allowedTypes = "int, int8-64, uint, uint8-64, float32, float64, bool, string, []byte, or interface{} (arguments only)"
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// funcType reports whether t may be used as an argument or result of a custom function
+func funcType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// funcError describes an invalid signature for a custom function
+func funcError(name string, t reflect.Type, msg string, args ...interface{}) error {
+	return fmt.Errorf("function %q (%v): %s; allowed signatures are func(args...) T or func(args...) (T, error), where each type is one of: %s",
+		name, t, fmt.Sprintf(msg, args...), allowedTypes)
+}
+
+// checkSignature validates the arguments and results of a custom function's implementation
+func checkSignature(name string, t reflect.Type) error {
+	if t.Kind() != reflect.Func {
+		return funcError(name, t, "implementation is not a function")
+	}
+	if n := t.NumOut(); n != 1 && n != 2 {
+		return funcError(name, t, "must return 1 or 2 values, not %d", n)
+	}
+	if t.NumOut() == 2 && t.Out(1) != errorType {
+		return funcError(name, t, "second result must be an error")
+	}
+	// the driver can pass any value to an interface{} argument,
+	// but can't convert an interface{} result
+	if out := t.Out(0); !funcType(out) || out.Kind() == reflect.Interface {
+		return funcError(name, t, "unsupported result type %v", out)
+	}
+	for i := 0; i < t.NumIn(); i++ {
+		in := t.In(i)
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			in = in.Elem()
+		}
+		if !funcType(in) {
+			return funcError(name, t, "unsupported type %v for argument %d", in, i+1)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the function's name and implementation can be registered
+func (f FuncReg) Validate() error {
+	if strings.TrimSpace(f.Name) == "" {
+		return fmt.Errorf("function has no name")
+	}
+	if f.Impl == nil {
+		return fmt.Errorf("function %q has no implementation", f.Name)
+	}
+	return checkSignature(f.Name, reflect.TypeOf(f.Impl))
+}
+
+// Validate checks that the aggregate's name and constructor can be registered
+func (a AggReg) Validate() error {
+	if strings.TrimSpace(a.Name) == "" {
+		return fmt.Errorf("aggregate has no name")
+	}
+	if a.Impl == nil {
+		return fmt.Errorf("aggregate %q has no implementation", a.Name)
+	}
+	t := reflect.TypeOf(a.Impl)
+	if t.Kind() != reflect.Func || t.NumIn() != 0 || t.NumOut() < 1 || t.NumOut() > 2 {
+		return fmt.Errorf("aggregate %q (%v): implementation must be a constructor, func() *T or func() (*T, error)", a.Name, t)
+	}
+	if t.NumOut() == 2 && t.Out(1) != errorType {
+		return fmt.Errorf("aggregate %q (%v): second result must be an error", a.Name, t)
+	}
+	agg := t.Out(0)
+	step, ok := agg.MethodByName("Step")
+	if !ok {
+		return fmt.Errorf("aggregate %q: %v has no Step method", a.Name, agg)
+	}
+	done, ok := agg.MethodByName("Done")
+	if !ok {
+		return fmt.Errorf("aggregate %q: %v has no Done method", a.Name, agg)
+	}
+	// method types include the receiver, which isn't part of the signature
+	if step.Type.NumOut() > 1 || (step.Type.NumOut() == 1 && step.Type.Out(0) != errorType) {
+		return fmt.Errorf("aggregate %q: Step must return nothing or an error", a.Name)
+	}
+	for i := 1; i < step.Type.NumIn(); i++ {
+		in := step.Type.In(i)
+		if step.Type.IsVariadic() && i == step.Type.NumIn()-1 {
+			in = in.Elem()
+		}
+		if !funcType(in) {
+			return fmt.Errorf("aggregate %q: unsupported type %v for Step argument %d; allowed types are: %s", a.Name, in, i, allowedTypes)
+		}
+	}
+	if done.Type.NumIn() != 1 {
+		return fmt.Errorf("aggregate %q: Done must take no arguments", a.Name)
+	}
+	return checkSignature(a.Name+" Done", reflect.FuncOf(nil, outTypes(done.Type), false))
+}
+
+func outTypes(t reflect.Type) []reflect.Type {
+	out := make([]reflect.Type, t.NumOut())
+	for i := range out {
+		out[i] = t.Out(i)
+	}
+	return out
+}
+
+// Validate checks that the window function can be registered
+func (w WindowReg) Validate() error {
+	if strings.TrimSpace(w.Name) == "" {
+		return fmt.Errorf("window function has no name")
+	}
+	if w.New == nil {
+		return fmt.Errorf("window function %q has no constructor", w.Name)
+	}
+	if w.NArgs < -1 || w.NArgs > 127 {
+		return fmt.Errorf("window function %q: number of arguments must be -1 (any) or 0 to 127, not %d", w.Name, w.NArgs)
+	}
+	return nil
+}
+
+// validate checks the functions of a configuration before they are registered,
+// its creation settings, and with WithStrict, its pragmas
+func (c *Config) validate() error {
+	if n := c.pageSize; n != 0 && (n < 512 || n > 65536 || n&(n-1) != 0) {
+		return fmt.Errorf("invalid page size: %d", n)
+	}
+	if v := c.autoVacuum; v != nil && (*v < VacuumNone || *v > VacuumIncremental) {
+		return fmt.Errorf("invalid auto_vacuum mode: %d", *v)
+	}
+	for limit, value := range c.limits {
+		if err := limit.valid(value); err != nil {
+			return err
+		}
+	}
+	if c.strict {
+		if err := c.validateStrict(); err != nil {
+			return err
+		}
+	}
+	for _, f := range c.funcs {
+		if err := f.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, a := range c.aggs {
+		if err := a.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, w := range c.windows {
+		if err := w.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
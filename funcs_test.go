@@ -0,0 +1,82 @@
+package sqlite
+
+import (
+	"strings"
+	"testing"
+)
+
+type badStep struct{}
+
+func (b *badStep) Step(m map[string]int) {}
+func (b *badStep) Done() int             { return 0 }
+
+type goodStep struct{ n int64 }
+
+func (g *goodStep) Step(i int64) error { g.n += i; return nil }
+func (g *goodStep) Done() (int64, error) {
+	return g.n, nil
+}
+
+func TestFuncRegValidate(t *testing.T) {
+	good := []FuncReg{
+		{Name: "a", Impl: func(int, string) string { return "" }},
+		{Name: "b", Impl: func(...interface{}) (float64, error) { return 0, nil }},
+		{Name: "c", Impl: func([]byte, bool, uint8) []byte { return nil }},
+	}
+	for _, f := range good {
+		if err := f.Validate(); err != nil {
+			t.Errorf("%s: unexpected error: %v", f.Name, err)
+		}
+	}
+	bad := map[string]FuncReg{
+		"no name":        {Impl: func() int { return 0 }},
+		"not a function": {Name: "x", Impl: 42},
+		"no results":     {Name: "x", Impl: func(int) {}},
+		"not an error":   {Name: "x", Impl: func(int) (int, int) { return 0, 0 }},
+		"bad result":     {Name: "x", Impl: func() map[string]int { return nil }},
+		"any result":     {Name: "x", Impl: func(v interface{}) interface{} { return v }},
+		"bad argument":   {Name: "x", Impl: func(int, chan int) int { return 0 }},
+		"bad variadic":   {Name: "x", Impl: func(...struct{}) int { return 0 }},
+	}
+	for what, f := range bad {
+		err := f.Validate()
+		if err == nil {
+			t.Errorf("%s: expected error", what)
+			continue
+		}
+		t.Logf("%s: %v", what, err)
+	}
+	err := bad["bad argument"].Validate()
+	if !strings.Contains(err.Error(), "argument 2") || !strings.Contains(err.Error(), allowedTypes) {
+		t.Errorf("expected descriptive error but got: %v", err)
+	}
+}
+
+func TestAggRegValidate(t *testing.T) {
+	good := AggReg{Name: "sum", Impl: func() *goodStep { return new(goodStep) }}
+	if err := good.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []AggReg{
+		{Name: "x", Impl: func() *badStep { return nil }},
+		{Name: "x", Impl: func(int) *goodStep { return nil }},
+		{Name: "x", Impl: func() int { return 0 }},
+	} {
+		if err := a.Validate(); err == nil {
+			t.Errorf("expected error for %T", a.Impl)
+		}
+	}
+}
+
+func TestOpenBadFunction(t *testing.T) {
+	fn := FuncReg{Name: "broken", Impl: func(chan int) int { return 0 }}
+	if _, err := Open(":memory:", WithDriver("sqlite_broken"), WithFunctions(fn)); err == nil {
+		t.Fatal("expected error for invalid function")
+	} else {
+		t.Log(err)
+	}
+	db := memDB(t)
+	if err := RegisterFunctions(db, fn); err == nil {
+		t.Fatal("expected error for invalid function")
+	}
+}
@@ -0,0 +1,392 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// GeoFuncs are SQL functions for location data, for bucketing points by geohash or map tile,
+// and summarizing polygons, given as JSON arrays of [x,y] points, as returned by polygon,
+// or GeoJSON polygons, of which the outer ring is used:
+//
+//	polygon(lat1, lon1, lat2, lon2, ...)  a polygon of the points, as by ToPolygon
+//	geohash_encode(lat, lon[, precision]) the geohash of a point, of 12 characters by default
+//	geohash_decode(hash)                  the center of the geohash's cell, as [lat,lon]
+//	tile_xyz(lat, lon, zoom)              the map tile of a point, as "z/x/y"
+//	tile_bbox(z, x, y)                    the bounds of a map tile, as [west,south,east,north]
+//	poly_area(poly)                       the area of a polygon, in the units of its points
+//	poly_centroid(poly)                   the centroid of a polygon, as [x,y]
+//	poly_simplify(poly, tolerance)        a polygon with points within tolerance of its edges removed
+var GeoFuncs = []FuncReg{
+	{Name: "polygon", Impl: ToPolygon, Pure: true},
+	{Name: "geohash_encode", Impl: geohashEncode, Pure: true},
+	{Name: "geohash_decode", Impl: geohashDecode, Pure: true},
+	{Name: "tile_xyz", Impl: tileXYZ, Pure: true},
+	{Name: "tile_bbox", Impl: tileBBox, Pure: true},
+	{Name: "poly_area", Impl: polyArea, Pure: true},
+	{Name: "poly_centroid", Impl: polyCentroid, Pure: true},
+	{Name: "poly_simplify", Impl: polySimplify, Pure: true},
+}
+
+// GeoAggregates are SQL aggregates of polygons, given as for GeoFuncs:
+//
+//	poly_union_bbox(poly) the bounds of all the polygons, as [minx,miny,maxx,maxy], or null if none
+var GeoAggregates = []AggReg{
+	{Name: "poly_union_bbox", Impl: func() *unionBBox { return new(unionBBox) }, Pure: true},
+}
+
+// geohashAlphabet is the base32 alphabet of geohashes
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// maxTileLat is the latitude of the edges of web mercator maps
+const maxTileLat = 85.05112878
+
+// GeohashEncode returns the geohash of the point, of the given number of characters
+func GeohashEncode(lat, lon float64, precision int) string {
+	latRange, lonRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	var sb strings.Builder
+	bit, ch, even := 0, 0, true
+	for sb.Len() < precision {
+		r, v := &latRange, lat
+		if even {
+			r, v = &lonRange, lon
+		}
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if v >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+		if bit++; bit == 5 {
+			sb.WriteByte(geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return sb.String()
+}
+
+// GeohashDecode returns the center of the geohash's cell, and its half height and width
+func GeohashDecode(hash string) (lat, lon, latErr, lonErr float64, err error) {
+	latRange, lonRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	even := true
+	for _, c := range strings.ToLower(hash) {
+		i := strings.IndexRune(geohashAlphabet, c)
+		if i < 0 {
+			return 0, 0, 0, 0, fmt.Errorf("invalid geohash: %q", hash)
+		}
+		for bit := 4; bit >= 0; bit-- {
+			r := &latRange
+			if even {
+				r = &lonRange
+			}
+			mid := (r[0] + r[1]) / 2
+			if i&(1<<uint(bit)) != 0 {
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+	}
+	lat, lon = (latRange[0]+latRange[1])/2, (lonRange[0]+lonRange[1])/2
+	return lat, lon, (latRange[1] - latRange[0]) / 2, (lonRange[1] - lonRange[0]) / 2, nil
+}
+
+// TileXYZ returns the x and y of the web mercator map tile containing the point at the zoom
+func TileXYZ(lat, lon float64, zoom int) (x, y int) {
+	lat = math.Max(-maxTileLat, math.Min(maxTileLat, lat))
+	n := math.Exp2(float64(zoom))
+	rad := lat * math.Pi / 180
+	x = int(math.Floor((lon + 180) / 360 * n))
+	y = int(math.Floor((1 - math.Log(math.Tan(rad)+1/math.Cos(rad))/math.Pi) / 2 * n))
+	clamp := func(i int) int {
+		if i < 0 {
+			return 0
+		}
+		if max := int(n) - 1; i > max {
+			return max
+		}
+		return i
+	}
+	return clamp(x), clamp(y)
+}
+
+// TileBBox returns the bounds of the web mercator map tile
+func TileBBox(zoom, x, y int) (west, south, east, north float64) {
+	n := math.Exp2(float64(zoom))
+	lon := func(x int) float64 {
+		return float64(x)/n*360 - 180
+	}
+	lat := func(y int) float64 {
+		return math.Atan(math.Sinh(math.Pi*(1-2*float64(y)/n))) * 180 / math.Pi
+	}
+	return lon(x), lat(y + 1), lon(x + 1), lat(y)
+}
+
+// geoNumber returns an argument of a SQL function as a float, allowing integers
+func geoNumber(name string, v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	}
+	return 0, fmt.Errorf("%s must be a number, not: %v", name, v)
+}
+
+// geoInt returns an argument of a SQL function as an integer
+func geoInt(name string, v interface{}) (int, error) {
+	f, err := geoNumber(name, v)
+	if err == nil && f != math.Trunc(f) {
+		err = fmt.Errorf("%s must be an integer, not: %v", name, v)
+	}
+	return int(f), err
+}
+
+func geohashEncode(lat, lon interface{}, precision ...interface{}) (string, error) {
+	y, err := geoNumber("latitude", lat)
+	if err != nil {
+		return "", err
+	}
+	x, err := geoNumber("longitude", lon)
+	if err != nil {
+		return "", err
+	}
+	n := 12
+	if len(precision) > 0 {
+		if n, err = geoInt("precision", precision[0]); err != nil {
+			return "", err
+		}
+		if n < 1 || n > 22 {
+			return "", fmt.Errorf("precision must be from 1 to 22, not: %d", n)
+		}
+	}
+	return GeohashEncode(y, x, n), nil
+}
+
+func geohashDecode(hash string) (string, error) {
+	lat, lon, _, _, err := GeohashDecode(hash)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal([]float64{lat, lon})
+	return string(b), err
+}
+
+func tileXYZ(lat, lon, zoom interface{}) (string, error) {
+	y, err := geoNumber("latitude", lat)
+	if err != nil {
+		return "", err
+	}
+	x, err := geoNumber("longitude", lon)
+	if err != nil {
+		return "", err
+	}
+	z, err := geoInt("zoom", zoom)
+	if err != nil {
+		return "", err
+	}
+	if z < 0 || z > 30 {
+		return "", fmt.Errorf("zoom must be from 0 to 30, not: %d", z)
+	}
+	tx, ty := TileXYZ(y, x, z)
+	return fmt.Sprintf("%d/%d/%d", z, tx, ty), nil
+}
+
+func tileBBox(zoom, x, y interface{}) (string, error) {
+	var zxy [3]int
+	for i, v := range []interface{}{zoom, x, y} {
+		var err error
+		if zxy[i], err = geoInt([]string{"zoom", "x", "y"}[i], v); err != nil {
+			return "", err
+		}
+	}
+	if n := 1 << uint(zxy[0]); zxy[0] < 0 || zxy[0] > 30 || zxy[1] < 0 || zxy[1] >= n || zxy[2] < 0 || zxy[2] >= n {
+		return "", fmt.Errorf("invalid tile: %d/%d/%d", zxy[0], zxy[1], zxy[2])
+	}
+	west, south, east, north := TileBBox(zxy[0], zxy[1], zxy[2])
+	b, err := json.Marshal([]float64{west, south, east, north})
+	return string(b), err
+}
+
+// PolygonArea returns the area of the polygon, by the shoelace formula
+func PolygonArea(ring [][2]float64) float64 {
+	return math.Abs(signedArea(ring))
+}
+
+// signedArea returns the area of the polygon, positive if its points are counterclockwise
+func signedArea(ring [][2]float64) float64 {
+	var sum float64
+	for i := range ring {
+		p, q := ring[i], ring[(i+1)%len(ring)]
+		sum += p[0]*q[1] - q[0]*p[1]
+	}
+	return sum / 2
+}
+
+// PolygonCentroid returns the centroid of the polygon, or the mean of its points if it has no area
+func PolygonCentroid(ring [][2]float64) (x, y float64) {
+	if len(ring) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	area := signedArea(ring)
+	if area == 0 {
+		for _, p := range ring {
+			x += p[0]
+			y += p[1]
+		}
+		return x / float64(len(ring)), y / float64(len(ring))
+	}
+	for i := range ring {
+		p, q := ring[i], ring[(i+1)%len(ring)]
+		cross := p[0]*q[1] - q[0]*p[1]
+		x += (p[0] + q[0]) * cross
+		y += (p[1] + q[1]) * cross
+	}
+	return x / (6 * area), y / (6 * area)
+}
+
+// SimplifyPolygon returns the polygon with the points within the tolerance of the
+// edges between those kept removed, by the Douglas-Peucker algorithm
+func SimplifyPolygon(ring [][2]float64, tolerance float64) [][2]float64 {
+	if len(ring) < 3 {
+		return ring
+	}
+	keep := make([]bool, len(ring))
+	keep[0], keep[len(ring)-1] = true, true
+	var simplify func(first, last int)
+	simplify = func(first, last int) {
+		farthest, max := -1, tolerance
+		for i := first + 1; i < last; i++ {
+			if d := segmentDistance(ring[i], ring[first], ring[last]); d > max {
+				farthest, max = i, d
+			}
+		}
+		if farthest > 0 {
+			keep[farthest] = true
+			simplify(first, farthest)
+			simplify(farthest, last)
+		}
+	}
+	simplify(0, len(ring)-1)
+	var kept [][2]float64
+	for i, p := range ring {
+		if keep[i] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// segmentDistance returns the distance from the point to the segment from a to b
+func segmentDistance(p, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t := 0.0
+	if dx != 0 || dy != 0 {
+		t = ((p[0]-a[0])*dx + (p[1]-a[1])*dy) / (dx*dx + dy*dy)
+		t = math.Max(0, math.Min(1, t))
+	}
+	return math.Hypot(p[0]-(a[0]+t*dx), p[1]-(a[1]+t*dy))
+}
+
+// parsePolygon returns the points of a polygon given to a SQL function
+func parsePolygon(v interface{}) ([][2]float64, error) {
+	var text []byte
+	switch v := v.(type) {
+	case string:
+		text = []byte(v)
+	case []byte:
+		text = v
+	default:
+		return nil, fmt.Errorf("polygon must be text, not: %v", v)
+	}
+	text = bytes.Trim(bytes.TrimSpace(text), "'") // as quoted by the polygon function
+	var ring [][2]float64
+	if len(text) > 0 && text[0] == '{' {
+		var g struct {
+			Type        string
+			Coordinates [][][2]float64
+		}
+		if err := json.Unmarshal(text, &g); err != nil || g.Type != "Polygon" || len(g.Coordinates) == 0 {
+			return nil, fmt.Errorf("invalid polygon: %s", text)
+		}
+		ring = g.Coordinates[0]
+	} else if err := json.Unmarshal(text, &ring); err != nil {
+		return nil, fmt.Errorf("invalid polygon: %s", text)
+	}
+	return ring, nil
+}
+
+func polyArea(poly interface{}) (float64, error) {
+	ring, err := parsePolygon(poly)
+	if err != nil {
+		return 0, err
+	}
+	return PolygonArea(ring), nil
+}
+
+func polyCentroid(poly interface{}) (string, error) {
+	ring, err := parsePolygon(poly)
+	if err != nil {
+		return "", err
+	}
+	if len(ring) == 0 {
+		return "", fmt.Errorf("polygon has no points")
+	}
+	x, y := PolygonCentroid(ring)
+	b, err := json.Marshal([]float64{x, y})
+	return string(b), err
+}
+
+func polySimplify(poly, tolerance interface{}) (string, error) {
+	ring, err := parsePolygon(poly)
+	if err != nil {
+		return "", err
+	}
+	t, err := geoNumber("tolerance", tolerance)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(SimplifyPolygon(ring, t))
+	return string(b), err
+}
+
+// unionBBox is the poly_union_bbox aggregate
+type unionBBox struct {
+	bbox [4]float64
+	seen bool
+}
+
+func (u *unionBBox) Step(poly interface{}) error {
+	if b, ok := poly.([]byte); poly == nil || (ok && b == nil) {
+		return nil // the driver passes NULL as a nil slice
+	}
+	ring, err := parsePolygon(poly)
+	if err != nil {
+		return err
+	}
+	for _, p := range ring {
+		if !u.seen {
+			u.bbox = [4]float64{p[0], p[1], p[0], p[1]}
+			u.seen = true
+			continue
+		}
+		u.bbox[0], u.bbox[1] = math.Min(u.bbox[0], p[0]), math.Min(u.bbox[1], p[1])
+		u.bbox[2], u.bbox[3] = math.Max(u.bbox[2], p[0]), math.Max(u.bbox[3], p[1])
+	}
+	return nil
+}
+
+func (u *unionBBox) Done() (string, error) {
+	if !u.seen {
+		return "null", nil
+	}
+	b, err := json.Marshal(u.bbox)
+	return string(b), err
+}
@@ -0,0 +1,112 @@
+package sqlite
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGeohash(t *testing.T) {
+	if hash := GeohashEncode(57.64911, 10.40744, 11); hash != "u4pruydqqvj" {
+		t.Errorf("unexpected geohash: %s", hash)
+	}
+	lat, lon, latErr, lonErr, err := GeohashDecode("u4pruydqqvj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(lat-57.64911) > latErr || math.Abs(lon-10.40744) > lonErr {
+		t.Errorf("unexpected center: %v, %v", lat, lon)
+	}
+	if _, _, _, _, err := GeohashDecode("u4a"); err == nil {
+		t.Error("expected error for invalid geohash")
+	}
+}
+
+func TestTiles(t *testing.T) {
+	if x, y := TileXYZ(37.8, -122.4, 10); x != 163 || y != 395 {
+		t.Errorf("unexpected tile: %d/%d", x, y)
+	}
+	if x, y := TileXYZ(90, 180, 2); x != 3 || y != 0 {
+		t.Errorf("expected tile to be clamped but got: %d/%d", x, y)
+	}
+	west, south, east, north := TileBBox(1, 0, 0)
+	if west != -180 || east != 0 || south != 0 || math.Abs(north-maxTileLat) > 1e-6 {
+		t.Errorf("unexpected bounds: %v %v %v %v", west, south, east, north)
+	}
+}
+
+func TestGeoFuncs(t *testing.T) {
+	db, err := Open(":memory:", WithFunctions(GeoFuncs...), WithDriver("geo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var hash, center, tile, bbox string
+	const q = "select geohash_encode(57.64911, 10.40744, 5), geohash_decode('u4pru'), tile_xyz(37.8, -122.4, 10), tile_bbox(0, 0, 0)"
+	if err := row(db, []interface{}{&hash, &center, &tile, &bbox}, q); err != nil {
+		t.Fatal(err)
+	}
+	if hash != "u4pru" || center != "[57.63427734375,10.39306640625]" || tile != "10/163/395" {
+		t.Errorf("unexpected results: %s %s %s", hash, center, tile)
+	}
+	if bbox != "[-180,-85.05112877980659,180,85.05112877980659]" {
+		t.Errorf("unexpected bbox: %s", bbox)
+	}
+	// integers are accepted for coordinates
+	if err := row(db, []interface{}{&hash}, "select geohash_encode(0, 0)"); err != nil || hash != "s00000000000" {
+		t.Errorf("unexpected geohash: %s (%v)", hash, err)
+	}
+	if err := row(db, []interface{}{&bbox}, "select tile_bbox(1, 2, 0)"); err == nil {
+		t.Error("expected error for invalid tile")
+	}
+}
+
+func TestPolygons(t *testing.T) {
+	square := [][2]float64{{0, 0}, {2, 0}, {2, 2}, {0, 2}, {0, 0}}
+	if area := PolygonArea(square); area != 4 {
+		t.Errorf("unexpected area: %v", area)
+	}
+	if x, y := PolygonCentroid(square); x != 1 || y != 1 {
+		t.Errorf("unexpected centroid: %v, %v", x, y)
+	}
+	// points barely off the edges are dropped
+	noisy := [][2]float64{{0, 0}, {1, 0.01}, {2, 0}, {2.01, 1}, {2, 2}, {1, 1.99}, {0, 2}, {0, 0}}
+	if got := SimplifyPolygon(noisy, 0.1); len(got) != 5 {
+		t.Errorf("unexpected simplified polygon: %v", got)
+	}
+	if got := SimplifyPolygon(noisy, 0.001); len(got) != len(noisy) {
+		t.Errorf("expected nothing simplified but got: %v", got)
+	}
+
+	db, err := Open(":memory:", WithFunctions(GeoFuncs...), WithAggregates(GeoAggregates...), WithDriver("geo_polygons"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	const create = `
+create table shapes (name text, poly text);
+insert into shapes values('square', '[[0,0],[2,0],[2,2],[0,2],[0,0]]');
+insert into shapes values('triangle', '{"type":"Polygon","coordinates":[[[4,1],[6,1],[4,5],[4,1]]]}');
+insert into shapes values('none', null);
+`
+	if _, err := db.Exec(create); err != nil {
+		t.Fatal(err)
+	}
+	var area float64
+	var centroid, simplified, bbox string
+	q := "select poly_area(poly), poly_centroid(poly), poly_simplify(polygon(0, 0, 1, 0.01, 2, 0, 0, 0), 0.1) from shapes where name='triangle'"
+	if err := row(db, []interface{}{&area, &centroid, &simplified}, q); err != nil {
+		t.Fatal(err)
+	}
+	if area != 4 || centroid != "[4.666666666666667,2.3333333333333335]" || simplified != "[[0,0],[2,0],[0,0]]" {
+		t.Errorf("unexpected results: %v %s %s", area, centroid, simplified)
+	}
+	if err := row(db, []interface{}{&bbox}, "select poly_union_bbox(poly) from shapes"); err != nil || bbox != "[0,0,6,5]" {
+		t.Errorf("unexpected bbox: %s (%v)", bbox, err)
+	}
+	if err := row(db, []interface{}{&bbox}, "select poly_union_bbox(poly) from shapes where 0"); err != nil || bbox != "null" {
+		t.Errorf("unexpected bbox: %s (%v)", bbox, err)
+	}
+	if err := row(db, []interface{}{&area}, "select poly_area('nope')"); err == nil {
+		t.Error("expected error for invalid polygon")
+	}
+}
@@ -0,0 +1,569 @@
+package sqlite
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// GeoJSONOptions are the options for ImportGeoJSON
+type GeoJSONOptions struct {
+	Geometry string                // the column of the geometries, "geometry" if not set
+	WKB      bool                  // store geometries as well-known binary rather than GeoJSON text
+	Types    map[string]ColumnKind // kinds of columns, rather than inferring them
+}
+
+// geoNull stands for null values of imported features, so empty strings are kept
+const geoNull = "\x00null"
+
+// ImportGeoJSON imports the features of a GeoJSON FeatureCollection, or a single Feature,
+// into the table, which is created if it doesn't exist, with a column for the geometry,
+// one for the feature ids if they have them, named "id", and one for each of the properties.
+// The kinds of the columns are inferred as for ImportCSV, and properties that are objects
+// or arrays are kept as JSON text. Geometries in well-known binary are 2D
+func ImportGeoJSON(db *sql.DB, r io.Reader, table string, opts *GeoJSONOptions) (ImportResult, error) {
+	if opts == nil {
+		opts = &GeoJSONOptions{}
+	}
+	geomCol := opts.Geometry
+	if geomCol == "" {
+		geomCol = "geometry"
+	}
+	type feature struct {
+		Type       string          `json:"type"`
+		ID         json.RawMessage `json:"id"`
+		Geometry   json.RawMessage `json:"geometry"`
+		Properties json.RawMessage `json:"properties"`
+		Features   []json.RawMessage
+	}
+	var doc feature
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return ImportResult{}, fmt.Errorf("geojson: %w", err)
+	}
+	var features []feature
+	switch doc.Type {
+	case "FeatureCollection":
+		for i, raw := range doc.Features {
+			var f feature
+			if err := json.Unmarshal(raw, &f); err != nil || f.Type != "Feature" {
+				return ImportResult{}, fmt.Errorf("geojson: feature %d is invalid", i+1)
+			}
+			features = append(features, f)
+		}
+	case "Feature":
+		features = []feature{doc}
+	default:
+		return ImportResult{}, fmt.Errorf("geojson: expected a Feature or FeatureCollection but got: %q", doc.Type)
+	}
+
+	// the columns are the properties in the order they first appear
+	var keys []string
+	seen := make(map[string]bool)
+	props := make([]map[string]string, len(features))
+	ids := false
+	for i, f := range features {
+		var err error
+		var names []string
+		if names, props[i], err = geoProperties(f.Properties); err != nil {
+			return ImportResult{}, fmt.Errorf("geojson: feature %d: %w", i+1, err)
+		}
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				keys = append(keys, name)
+			}
+		}
+		ids = ids || (len(f.ID) > 0 && string(f.ID) != "null")
+	}
+	ids = ids && !seen["id"]
+	columns := []string{geomCol}
+	if ids {
+		columns = append(columns, "id")
+	}
+	columns = append(columns, keys...)
+
+	types := map[string]ColumnKind{geomCol: KindText}
+	if opts.WKB {
+		types[geomCol] = KindBlob
+	}
+	for name, k := range opts.Types {
+		types[name] = k
+	}
+	n := 0
+	next := func() ([]string, error) {
+		if n == len(features) {
+			return nil, io.EOF
+		}
+		f, values := features[n], make([]string, 0, len(columns))
+		n++
+		geom := geoNull
+		if g := bytes.TrimSpace(f.Geometry); len(g) > 0 && string(g) != "null" {
+			if opts.WKB {
+				b, err := geometryWKB(g)
+				if err != nil {
+					return nil, fmt.Errorf("geojson: feature %d: %w", n, err)
+				}
+				geom = string(b)
+			} else {
+				var buf bytes.Buffer
+				if err := json.Compact(&buf, g); err != nil {
+					return nil, err
+				}
+				geom = buf.String()
+			}
+		}
+		values = append(values, geom)
+		if ids {
+			values = append(values, geoValue(f.ID))
+		}
+		for _, key := range keys {
+			v, ok := props[n-1][key]
+			if !ok {
+				v = geoNull
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+	imp := importer{columns: columns, opts: ImportOptions{Types: types, Nulls: []string{geoNull}, Sample: len(features)}}
+	return imp.run(db, table, next)
+}
+
+// geoProperties returns the names of the properties of a feature, in order,
+// and their values as text
+func geoProperties(raw json.RawMessage) ([]string, map[string]string, error) {
+	values := make(map[string]string)
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, values, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, nil, err
+	}
+	// the keys in the order they appear, which the map loses
+	var names []string
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.Token() // the opening brace
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		name := t.(string)
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, nil, err
+		}
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+		values[name] = geoValue(fields[name])
+	}
+	return names, values, nil
+}
+
+// geoValue returns a JSON value as text
+func geoValue(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case len(raw) == 0 || string(raw) == "null":
+		return geoNull
+	case raw[0] == '"':
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			return s
+		}
+	case raw[0] == '{' || raw[0] == '[':
+		var buf bytes.Buffer
+		if json.Compact(&buf, raw) == nil {
+			return buf.String()
+		}
+	}
+	return string(raw)
+}
+
+// ExportGeoJSON writes the results of the query as a GeoJSON FeatureCollection, with
+// a feature for each row. The geometry is the column named "geometry" or "geom", as
+// GeoJSON text, well-known binary, or a polygon of the form returned by the polygon
+// function, e.g. [[0,0],[1,0],[1,1],[0,0]]. A column named "id" is the feature id,
+// and the others are its properties
+func ExportGeoJSON(db *sql.DB, w io.Writer, query string, args ...interface{}) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := getColumns(rows)
+	if err != nil {
+		return err
+	}
+	geomIdx, idIdx := -1, -1
+	for i, c := range columns {
+		switch strings.ToLower(c) {
+		case "geometry", "geom":
+			if geomIdx < 0 {
+				geomIdx = i
+			}
+		case "id":
+			idIdx = i
+		}
+	}
+	dest := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range dest {
+		ptrs[i] = &dest[i]
+	}
+
+	if _, err := io.WriteString(w, `{"type":"FeatureCollection","features":[`); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for count := 0; rows.Next(); count++ {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		buf.Reset()
+		if count > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString("\n{\"type\":\"Feature\"")
+		if idIdx >= 0 && dest[idIdx] != nil {
+			id, err := json.Marshal(geoProperty(dest[idIdx]))
+			if err != nil {
+				return err
+			}
+			buf.WriteString(`,"id":`)
+			buf.Write(id)
+		}
+		geom := json.RawMessage("null")
+		if geomIdx >= 0 {
+			if geom, err = geoJSONGeometry(dest[geomIdx]); err != nil {
+				return fmt.Errorf("row %d: %w", count+1, err)
+			}
+		}
+		buf.WriteString(`,"geometry":`)
+		buf.Write(geom)
+		buf.WriteString(`,"properties":{`)
+		first := true
+		for i, c := range columns {
+			if i == geomIdx || i == idIdx {
+				continue
+			}
+			name, _ := json.Marshal(c)
+			value, err := json.Marshal(geoProperty(dest[i]))
+			if err != nil {
+				return err
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			buf.Write(name)
+			buf.WriteByte(':')
+			buf.Write(value)
+		}
+		buf.WriteString("}}")
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n]}\n")
+	return err
+}
+
+// geoProperty returns a value of a column as a property of a feature
+func geoProperty(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+// geoJSONGeometry returns the GeoJSON of a geometry in any of the forms ExportGeoJSON reads
+func geoJSONGeometry(v interface{}) (json.RawMessage, error) {
+	var text []byte
+	switch v := v.(type) {
+	case nil:
+		return json.RawMessage("null"), nil
+	case string:
+		text = []byte(v)
+	case []byte:
+		if trimmed := bytes.TrimSpace(v); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			text = v
+			break
+		}
+		g, err := readWKB(bytes.NewReader(v))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(g)
+	default:
+		return nil, fmt.Errorf("invalid geometry: %v", v)
+	}
+	text = bytes.Trim(bytes.TrimSpace(text), "'") // as quoted by the polygon function
+	if len(text) > 0 && text[0] == '[' {
+		var ring [][]float64
+		if err := json.Unmarshal(text, &ring); err != nil {
+			return nil, fmt.Errorf("invalid polygon: %w", err)
+		}
+		return json.Marshal(geoJSON{Type: "Polygon", Coordinates: [][][]float64{ring}})
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, text); err != nil {
+		return nil, fmt.Errorf("invalid geometry: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// geoJSON is a GeoJSON geometry
+type geoJSON struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates,omitempty"`
+	Geometries  []geoJSON   `json:"geometries,omitempty"`
+}
+
+// wkbTypes are the well-known binary codes of the GeoJSON geometry types
+var wkbTypes = map[string]uint32{
+	"Point":              1,
+	"LineString":         2,
+	"Polygon":            3,
+	"MultiPoint":         4,
+	"MultiLineString":    5,
+	"MultiPolygon":       6,
+	"GeometryCollection": 7,
+}
+
+// geometryWKB returns a GeoJSON geometry as little-endian well-known binary
+func geometryWKB(raw []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeWKB(&buf, raw); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeWKB writes a GeoJSON geometry as well-known binary
+func writeWKB(buf *bytes.Buffer, raw []byte) error {
+	var g struct {
+		Type        string            `json:"type"`
+		Coordinates json.RawMessage   `json:"coordinates"`
+		Geometries  []json.RawMessage `json:"geometries"`
+	}
+	if err := json.Unmarshal(raw, &g); err != nil {
+		return err
+	}
+	code, ok := wkbTypes[g.Type]
+	if !ok {
+		return fmt.Errorf("unknown geometry type: %q", g.Type)
+	}
+	header := func(code uint32) {
+		buf.WriteByte(1)
+		binary.Write(buf, binary.LittleEndian, code)
+	}
+	count := func(n int) {
+		binary.Write(buf, binary.LittleEndian, uint32(n))
+	}
+	point := func(p []float64) error {
+		if len(p) < 2 {
+			return fmt.Errorf("invalid position: %v", p)
+		}
+		return binary.Write(buf, binary.LittleEndian, p[:2])
+	}
+	points := func(ps [][]float64) error {
+		count(len(ps))
+		for _, p := range ps {
+			if err := point(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	header(code)
+	var err error
+	switch g.Type {
+	case "Point":
+		var p []float64
+		if err = json.Unmarshal(g.Coordinates, &p); err == nil {
+			if len(p) == 0 {
+				p = []float64{math.NaN(), math.NaN()} // empty
+			}
+			err = point(p)
+		}
+	case "LineString":
+		var ps [][]float64
+		if err = json.Unmarshal(g.Coordinates, &ps); err == nil {
+			err = points(ps)
+		}
+	case "Polygon":
+		var rings [][][]float64
+		if err = json.Unmarshal(g.Coordinates, &rings); err == nil {
+			count(len(rings))
+			for _, ring := range rings {
+				if err = points(ring); err != nil {
+					break
+				}
+			}
+		}
+	case "MultiPoint":
+		var ps [][]float64
+		if err = json.Unmarshal(g.Coordinates, &ps); err == nil {
+			count(len(ps))
+			for _, p := range ps {
+				header(1)
+				if err = point(p); err != nil {
+					break
+				}
+			}
+		}
+	case "MultiLineString":
+		var lines [][][]float64
+		if err = json.Unmarshal(g.Coordinates, &lines); err == nil {
+			count(len(lines))
+			for _, line := range lines {
+				header(2)
+				if err = points(line); err != nil {
+					break
+				}
+			}
+		}
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		if err = json.Unmarshal(g.Coordinates, &polygons); err == nil {
+			count(len(polygons))
+			for _, rings := range polygons {
+				header(3)
+				count(len(rings))
+				for _, ring := range rings {
+					if err = points(ring); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	case "GeometryCollection":
+		count(len(g.Geometries))
+		for _, member := range g.Geometries {
+			if err = writeWKB(buf, member); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", g.Type, err)
+	}
+	return nil
+}
+
+// readWKB reads a geometry in well-known binary
+func readWKB(r *bytes.Reader) (geoJSON, error) {
+	var g geoJSON
+	order, err := r.ReadByte()
+	if err != nil {
+		return g, fmt.Errorf("invalid well-known binary: %w", err)
+	}
+	var bo binary.ByteOrder = binary.LittleEndian
+	if order == 0 {
+		bo = binary.BigEndian
+	}
+	var code uint32
+	if err := binary.Read(r, bo, &code); err != nil {
+		return g, fmt.Errorf("invalid well-known binary: %w", err)
+	}
+	count := func() (int, error) {
+		var n uint32
+		if err := binary.Read(r, bo, &n); err != nil {
+			return 0, err
+		}
+		if int64(n)*8 > int64(r.Len()) {
+			return 0, fmt.Errorf("count exceeds data: %d", n)
+		}
+		return int(n), nil
+	}
+	point := func() ([]float64, error) {
+		p := make([]float64, 2)
+		return p, binary.Read(r, bo, p)
+	}
+	points := func() ([][]float64, error) {
+		n, err := count()
+		if err != nil {
+			return nil, err
+		}
+		ps := make([][]float64, n)
+		for i := range ps {
+			if ps[i], err = point(); err != nil {
+				return nil, err
+			}
+		}
+		return ps, nil
+	}
+	members := func() ([]geoJSON, error) {
+		n, err := count()
+		if err != nil {
+			return nil, err
+		}
+		list := make([]geoJSON, n)
+		for i := range list {
+			if list[i], err = readWKB(r); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	for name, c := range wkbTypes {
+		if c == code {
+			g.Type = name
+		}
+	}
+	switch code {
+	case 1:
+		var p []float64
+		if p, err = point(); err == nil && math.IsNaN(p[0]) {
+			p = []float64{}
+		}
+		g.Coordinates = p
+	case 2:
+		g.Coordinates, err = points()
+	case 3:
+		var n int
+		if n, err = count(); err == nil {
+			rings := make([][][]float64, n)
+			for i := range rings {
+				if rings[i], err = points(); err != nil {
+					break
+				}
+			}
+			g.Coordinates = rings
+		}
+	case 4, 5, 6:
+		var list []geoJSON
+		if list, err = members(); err == nil {
+			coords := make([]interface{}, len(list))
+			for i, m := range list {
+				coords[i] = m.Coordinates
+			}
+			g.Coordinates = coords
+		}
+	case 7:
+		g.Geometries, err = members()
+	default:
+		return g, fmt.Errorf("unsupported well-known binary type: %d", code)
+	}
+	if err != nil {
+		return g, fmt.Errorf("invalid well-known binary %s: %w", g.Type, err)
+	}
+	return g, nil
+}
@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testGeoJSON = `{
+  "type": "FeatureCollection",
+  "features": [
+    {"type": "Feature", "id": 1,
+     "geometry": {"type": "Point", "coordinates": [-122.4, 37.8]},
+     "properties": {"name": "San Francisco", "pop": 873965, "tags": ["fog", "hills"], "note": ""}},
+    {"type": "Feature", "id": 2,
+     "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]},
+     "properties": {"name": "Square", "pop": null, "area": 0.5}},
+    {"type": "Feature", "id": 3, "geometry": null, "properties": {"name": "Nowhere"}}
+  ]
+}`
+
+func TestImportGeoJSON(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	result, err := ImportGeoJSON(db, strings.NewReader(testGeoJSON), "places", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(result.Columns, ","); got != "geometry,id,name,pop,tags,note,area" {
+		t.Errorf("unexpected columns: %s", got)
+	}
+	if result.Rows != 3 || result.Kinds[1] != KindInteger || result.Kinds[3] != KindInteger || result.Kinds[6] != KindReal {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	var geom, tags, note string
+	if err := row(db, []interface{}{&geom, &tags, &note}, "select geometry, tags, note from places where id=1"); err != nil {
+		t.Fatal(err)
+	}
+	if geom != `{"type":"Point","coordinates":[-122.4,37.8]}` || tags != `["fog","hills"]` || note != "" {
+		t.Errorf("unexpected row: %s %s %q", geom, tags, note)
+	}
+
+	var buf bytes.Buffer
+	if err := ExportGeoJSON(db, &buf, "select id, name, geometry from places order by id"); err != nil {
+		t.Fatal(err)
+	}
+	var fc struct {
+		Type     string
+		Features []struct {
+			ID         int
+			Geometry   *struct{ Type string }
+			Properties map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+		t.Fatalf("invalid geojson: %v\n%s", err, buf.String())
+	}
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 3 || fc.Features[1].ID != 2 || fc.Features[1].Geometry.Type != "Polygon" {
+		t.Errorf("unexpected export: %s", buf.String())
+	}
+	if fc.Features[2].Geometry != nil || fc.Features[0].Properties["name"] != "San Francisco" || len(fc.Features[0].Properties) != 1 {
+		t.Errorf("unexpected export: %s", buf.String())
+	}
+}
+
+func TestGeoJSONWKB(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	if _, err := ImportGeoJSON(db, strings.NewReader(testGeoJSON), "shapes", &GeoJSONOptions{WKB: true, Geometry: "geom"}); err != nil {
+		t.Fatal(err)
+	}
+	var wkb []byte
+	if err := row(db, []interface{}{&wkb}, "select geom from shapes where id=1"); err != nil {
+		t.Fatal(err)
+	}
+	// little-endian point: order, type, x, y
+	if len(wkb) != 21 || wkb[0] != 1 || wkb[1] != 1 {
+		t.Errorf("unexpected wkb: %x", wkb)
+	}
+
+	// the polygons of the polygon function are exported too
+	const create = `
+create table regions (name text, geom text);
+insert into regions values('tri', '[[0,0],[2,0],[0,2],[0,0]]');
+`
+	if _, err := db.Exec(create); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	q := "select name, geom from shapes where geom is not null union all select name, geom from regions"
+	if err := ExportGeoJSON(db, &buf, q); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"geometry":{"type":"Point","coordinates":[-122.4,37.8]}`,
+		`"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`,
+		`"geometry":{"type":"Polygon","coordinates":[[[0,0],[2,0],[0,2],[0,0]]]}`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %s in:\n%s", want, buf.String())
+		}
+	}
+
+	multi := `{"type":"GeometryCollection","geometries":[{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[0,1],[0,0]]]]},{"type":"MultiLineString","coordinates":[[[0,0],[1,1]]]}]}`
+	b, err := geometryWKB([]byte(multi))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := readWKB(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := json.Marshal(g); string(out) != multi {
+		t.Errorf("expected round trip of %s but got %s", multi, out)
+	}
+}
@@ -1,5 +1,11 @@
 module github.com/paulstuart/sqlite
 
-go 1.13
+go 1.18
 
-require github.com/mattn/go-sqlite3 v1.14.6
+require (
+	github.com/fsnotify/fsnotify v1.5.1
+	github.com/mattn/go-sqlite3 v1.14.6
+	golang.org/x/sys v0.7.0
+	golang.org/x/text v0.9.0
+	gopkg.in/yaml.v3 v3.0.1
+)
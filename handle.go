@@ -0,0 +1,94 @@
+package sqlite
+
+/*
+#include <stdint.h>
+
+// The sqlite3 symbols are provided by the go-sqlite3 driver, which is pinned by go.mod
+// (v1.14.6, bundling SQLite 3.34.0), or by the system's library with its libsqlite3 tag.
+// Only the stable C API of SQLite is used, declared here as the driver's header isn't
+// on the include path. Window functions need SQLite 3.25.0 or later
+typedef struct sqlite3 sqlite3;
+typedef struct sqlite3_context sqlite3_context;
+typedef struct sqlite3_value sqlite3_value;
+
+extern int sqlite3_auto_extension(void (*)(void));
+extern sqlite3 *sqlite3_context_db_handle(sqlite3_context*);
+extern void sqlite3_result_int64(sqlite3_context*, long long);
+extern int sqlite3_create_function(sqlite3*, const char*, int, int, void*,
+	void (*)(sqlite3_context*, int, sqlite3_value**),
+	void (*)(sqlite3_context*, int, sqlite3_value**),
+	void (*)(sqlite3_context*));
+
+#define SQLITE_UTF8        1
+#define SQLITE_DIRECTONLY  0x000080000
+
+static void handle_func(sqlite3_context *ctx, int argc, sqlite3_value **argv) {
+	sqlite3_result_int64(ctx, (long long)(uintptr_t)sqlite3_context_db_handle(ctx));
+}
+
+static int handle_init(sqlite3 *db, char **err, const void *api) {
+	return sqlite3_create_function(db, "sqlite_util_handle", 0, SQLITE_UTF8|SQLITE_DIRECTONLY, 0, handle_func, 0, 0);
+}
+
+static int register_handle(void) {
+	return sqlite3_auto_extension((void (*)(void))handle_init);
+}
+*/
+import "C"
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// handleQuery returns the sqlite3 handle of the connection, by the function
+// registered on each connection by handleInit
+const handleQuery = "SELECT sqlite_util_handle()"
+
+// handleInit is the error, if any, registering the function of handleQuery
+var handleInit error
+
+func init() {
+	// an auto extension is run for each connection opened, by any driver
+	if rc := C.register_handle(); rc != 0 {
+		handleInit = fmt.Errorf("failed to register the sqlite3 handle function: error code %d", int(rc))
+	}
+}
+
+// connHandle returns the sqlite3 handle of the connection, which the driver does not
+// expose, for the parts of the SQLite API it doesn't cover, e.g. window functions and
+// blobs. It is returned by a function of SQLite's own, rather than read from the driver
+func connHandle(conn *sqlite3.SQLiteConn) (uintptr, error) {
+	if handleInit != nil {
+		return 0, handleInit
+	}
+	if conn == nil {
+		return 0, fmt.Errorf("no sqlite3 connection")
+	}
+	rows, err := conn.Query(handleQuery, nil)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite3 handle: %w", err)
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, 1)
+	if err := rows.Next(dest); err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("no result")
+		}
+		return 0, fmt.Errorf("sqlite3 handle: %w", err)
+	}
+	handle, ok := dest[0].(int64)
+	if !ok || handle == 0 {
+		return 0, fmt.Errorf("sqlite3 handle: unexpected value: %v", dest[0])
+	}
+	return uintptr(handle), nil
+}
+
+// connID identifies the connection in traces, by its handle, 0 if it has none
+func connID(conn *sqlite3.SQLiteConn) uintptr {
+	handle, _ := connHandle(conn)
+	return handle
+}
@@ -0,0 +1,278 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HandlerOptions configure the HTTP query API
+type HandlerOptions struct {
+	ReadOnly    bool     // refuse statements that modify the database
+	Token       string   // bearer token required of clients, if set
+	Username    string   // basic auth credentials required of clients, if set
+	Password    string   //
+	CORSOrigins []string // origins allowed for cross-origin requests, "*" for any
+	MaxRows     int      // limit on rows returned by a query, unlimited if zero
+}
+
+// QueryRequest is the body of a query or exec request
+type QueryRequest struct {
+	SQL  string        `json:"sql"`
+	Args []interface{} `json:"args,omitempty"`
+}
+
+// QueryResponse is the result of a query request
+type QueryResponse struct {
+	Columns   []string        `json:"columns"`
+	Rows      [][]interface{} `json:"rows"`
+	Truncated bool            `json:"truncated,omitempty"`
+	Elapsed   string          `json:"elapsed"`
+}
+
+// ExecResponse is the result of an exec request
+type ExecResponse struct {
+	RowsAffected int64  `json:"rows_affected"`
+	LastInsertID int64  `json:"last_insert_id"`
+	Elapsed      string `json:"elapsed"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type httpHandler struct {
+	dbs  map[string]*sql.DB
+	opts HandlerOptions
+}
+
+// NewHandler returns an HTTP handler serving the named databases:
+//
+//	GET  /                 list the database names
+//	GET  /{db}/tables      list the tables of a database
+//	GET  /{db}/query?sql=  run a query, as does POST with a QueryRequest body
+//	POST /{db}/exec        execute a statement given as a QueryRequest body
+func NewHandler(dbs map[string]*sql.DB, opts HandlerOptions) http.Handler {
+	return &httpHandler{dbs: dbs, opts: opts}
+}
+
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.cors(w, r) && r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if !h.authorized(r) {
+		if h.opts.Username != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="sqlite"`)
+		}
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized"})
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] == "" {
+		names := make([]string, 0, len(h.dbs))
+		for name := range h.dbs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writeJSON(w, http.StatusOK, names)
+		return
+	}
+	db, ok := h.dbs[parts[0]]
+	if !ok || len(parts) != 2 {
+		writeJSON(w, http.StatusNotFound, errorResponse{"not found: " + r.URL.Path})
+		return
+	}
+
+	switch parts[1] {
+	case "tables":
+		tables, err := Tables(db)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errorResponse{err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, tables)
+	case "query":
+		req, err := parseRequest(r, http.MethodGet, http.MethodPost)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+			return
+		}
+		if status, err := h.check(req); err != nil {
+			writeJSON(w, status, errorResponse{err.Error()})
+			return
+		}
+		resp, err := h.query(r.Context(), db, req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case "exec":
+		if h.opts.ReadOnly {
+			writeJSON(w, http.StatusForbidden, errorResponse{"database is read-only"})
+			return
+		}
+		req, err := parseRequest(r, http.MethodPost)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+			return
+		}
+		start := time.Now()
+		result, err := db.ExecContext(r.Context(), req.SQL, req.Args...)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+			return
+		}
+		resp := ExecResponse{Elapsed: time.Since(start).String()}
+		resp.RowsAffected, _ = result.RowsAffected()
+		resp.LastInsertID, _ = result.LastInsertId()
+		writeJSON(w, http.StatusOK, resp)
+	default:
+		writeJSON(w, http.StatusNotFound, errorResponse{"not found: " + r.URL.Path})
+	}
+}
+
+// cors adds cross-origin headers for allowed origins, returning true if any were added
+func (h *httpHandler) cors(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range h.opts.CORSOrigins {
+		if allowed == "*" || allowed == origin {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Add("Vary", "Origin")
+			return true
+		}
+	}
+	return false
+}
+
+// authorized accepts either of the configured credentials, or anything if none are configured
+func (h *httpHandler) authorized(r *http.Request) bool {
+	if h.opts.Token == "" && h.opts.Username == "" {
+		return true
+	}
+	if h.opts.Token != "" {
+		auth := r.Header.Get("Authorization")
+		if strings.HasPrefix(auth, "Bearer ") && secureEqual(auth[7:], h.opts.Token) {
+			return true
+		}
+	}
+	if h.opts.Username != "" {
+		user, pass, ok := r.BasicAuth()
+		if ok && secureEqual(user, h.opts.Username) && secureEqual(pass, h.opts.Password) {
+			return true
+		}
+	}
+	return false
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func parseRequest(r *http.Request, methods ...string) (*QueryRequest, error) {
+	allowed := false
+	for _, m := range methods {
+		allowed = allowed || r.Method == m
+	}
+	if !allowed {
+		return nil, fmt.Errorf("method not allowed: %s", r.Method)
+	}
+	req := new(QueryRequest)
+	if r.Method == http.MethodGet {
+		req.SQL = r.URL.Query().Get("sql")
+	} else if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if strings.TrimSpace(req.SQL) == "" {
+		return nil, fmt.Errorf("no sql given")
+	}
+	return req, nil
+}
+
+// query runs the request, on a connection set to query_only if the handler is read-only
+// check refuses a query of more than one statement, as the driver runs them all, and
+// when read-only, a statement that isn't a query, returning the status of the refusal
+func (h *httpHandler) check(req *QueryRequest) (int, error) {
+	stmts, err := SplitStatements(req.SQL)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if len(stmts) != 1 || stmts[0].Dot {
+		return http.StatusBadRequest, fmt.Errorf("expected a single statement but got: %d", len(stmts))
+	}
+	if kind := Classify(stmts[0].SQL); h.opts.ReadOnly && kind != KindQuery {
+		return http.StatusForbidden, fmt.Errorf("database is read-only: %w: %s", ErrNotAllowed, kind)
+	}
+	return http.StatusOK, nil
+}
+
+func (h *httpHandler) query(ctx context.Context, db *sql.DB, req *QueryRequest) (resp *QueryResponse, err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if h.opts.ReadOnly {
+		if _, err := conn.ExecContext(ctx, "PRAGMA query_only=1"); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if _, rerr := conn.ExecContext(context.Background(), "PRAGMA query_only=0"); err == nil {
+				err = rerr
+			}
+		}()
+	}
+
+	start := time.Now()
+	rows, err := conn.QueryContext(ctx, req.SQL, req.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := getColumns(rows)
+	if err != nil {
+		return nil, err
+	}
+	resp = &QueryResponse{Columns: columns, Rows: [][]interface{}{}}
+	for rows.Next() {
+		if h.opts.MaxRows > 0 && len(resp.Rows) >= h.opts.MaxRows {
+			resp.Truncated = true
+			break
+		}
+		dest := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range dest {
+			ptrs[i] = &dest[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		resp.Rows = append(resp.Rows, dest)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	resp.Elapsed = time.Since(start).String()
+	return resp, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,156 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func testServer(t *testing.T, opts HandlerOptions) *httptest.Server {
+	t.Helper()
+	db := structDb(t)
+	srv := httptest.NewServer(NewHandler(map[string]*sql.DB{"test": db}, opts))
+	t.Cleanup(func() {
+		srv.Close()
+		db.Close()
+	})
+	return srv
+}
+
+func TestHandlerQuery(t *testing.T) {
+	srv := testServer(t, HandlerOptions{MaxRows: 3})
+
+	resp, err := http.Get(srv.URL + "/test/query?sql=" + url.QueryEscape("select id, name from structs order by id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %s", resp.Status)
+	}
+	var result QueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 3 || !result.Truncated {
+		t.Fatalf("expected 3 truncated rows but got: %+v", result)
+	}
+	if result.Rows[0][1] != "abc" {
+		t.Errorf("unexpected row: %v", result.Rows[0])
+	}
+
+	resp, err = http.Get(srv.URL + "/test/query?sql=" + url.QueryEscape("select 1; delete from structs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected bad request for several statements but got: %s", resp.Status)
+	}
+}
+
+func TestHandlerExec(t *testing.T) {
+	srv := testServer(t, HandlerOptions{})
+
+	body := `{"sql": "insert into structs(name, kind) values(?, ?)", "args": ["xyz", 7]}`
+	resp, err := http.Post(srv.URL+"/test/exec", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result ExecResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.RowsAffected != 1 || result.LastInsertID != 5 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestHandlerReadOnly(t *testing.T) {
+	srv := testServer(t, HandlerOptions{ReadOnly: true})
+
+	resp, err := http.Post(srv.URL+"/test/exec", "application/json", strings.NewReader(`{"sql": "delete from structs"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected forbidden but got: %s", resp.Status)
+	}
+
+	// writes disguised as queries must also be refused
+	resp, err = http.Post(srv.URL+"/test/query", "application/json", strings.NewReader(`{"sql": "delete from structs"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected forbidden but got: %s", resp.Status)
+	}
+
+	// nor may a query be followed by statements that undo read-only
+	for _, stmt := range []string{
+		"select 1; pragma query_only=0; delete from structs",
+		"pragma query_only=0",
+		"attach ':memory:' as other",
+	} {
+		resp, err = http.Get(srv.URL + "/test/query?sql=" + url.QueryEscape(stmt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Errorf("%q: expected refusal but got: %s", stmt, resp.Status)
+		}
+	}
+	resp, err = http.Get(srv.URL + "/test/query?sql=" + url.QueryEscape("select count(*) from structs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result QueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0] != float64(4) {
+		t.Errorf("expected the rows to remain but got: %v", result.Rows)
+	}
+}
+
+func TestHandlerAuth(t *testing.T) {
+	srv := testServer(t, HandlerOptions{Token: "secret", Username: "user", Password: "pass", CORSOrigins: []string{"*"}})
+
+	check := func(setup func(*http.Request), want int) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/test/tables", nil)
+		setup(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("expected status %d but got: %s", want, resp.Status)
+		}
+	}
+	check(func(r *http.Request) {}, http.StatusUnauthorized)
+	check(func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized)
+	check(func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, http.StatusOK)
+	check(func(r *http.Request) { r.SetBasicAuth("user", "pass") }, http.StatusOK)
+
+	req, _ := http.NewRequest(http.MethodOptions, srv.URL+"/test/query", nil)
+	req.Header.Set("Origin", "http://dashboard.example.com")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Access-Control-Allow-Origin") != "http://dashboard.example.com" {
+		t.Errorf("missing CORS header: %v", resp.Header)
+	}
+}
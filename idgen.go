@@ -0,0 +1,275 @@
+package sqlite
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SnowflakeEpoch is the start of the timestamps of snowflake ids, 2020-01-01 UTC
+var SnowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// The layout of snowflake ids, after the sign bit: 41 bits of milliseconds
+// since the epoch, 10 bits of node, and 12 bits of sequence
+const (
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	snowflakeMaxNode  = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSeq   = 1<<snowflakeSeqBits - 1
+)
+
+// Snowflake generates ids of a node that increase, and are unique among the
+// nodes, ordered by time: up to 4096 each millisecond, after which the ids run
+// ahead of the clock, as they do if the clock goes back
+type Snowflake struct {
+	mu   sync.Mutex
+	node int64
+	last int64 // the milliseconds of the last id
+	seq  int64
+}
+
+// NewSnowflake returns a generator of the node, from 0 to 1023
+func NewSnowflake(node int64) (*Snowflake, error) {
+	if node < 0 || node > snowflakeMaxNode {
+		return nil, fmt.Errorf("snowflake node must be from 0 to %d: %d", snowflakeMaxNode, node)
+	}
+	return &Snowflake{node: node}, nil
+}
+
+// Next returns the next id
+func (s *Snowflake) Next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, _ := s.next()
+	return id
+}
+
+// next returns the next id, and its milliseconds
+func (s *Snowflake) next() (int64, int64) {
+	now := time.Since(SnowflakeEpoch).Milliseconds()
+	if now > s.last {
+		s.last, s.seq = now, 0
+	} else if s.seq++; s.seq > snowflakeMaxSeq {
+		s.last, s.seq = s.last+1, 0
+	}
+	return s.last<<(snowflakeNodeBits+snowflakeSeqBits) | s.node<<snowflakeSeqBits | s.seq, s.last
+}
+
+// SnowflakeTime returns the time of a snowflake id, to the millisecond
+func SnowflakeTime(id int64) time.Time {
+	ms := id >> (snowflakeNodeBits + snowflakeSeqBits)
+	return SnowflakeEpoch.Add(time.Duration(ms) * time.Millisecond)
+}
+
+// SnowflakeNode returns the node of a snowflake id
+func SnowflakeNode(id int64) int64 {
+	return id >> snowflakeSeqBits & snowflakeMaxNode
+}
+
+// crockford is the alphabet of ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var (
+	umu      sync.Mutex
+	lastULID [16]byte
+)
+
+// ULID returns a universally unique lexicographically sortable identifier: 26
+// characters encoding 48 bits of milliseconds since the unix epoch and 80 random
+// bits, which for ids of the same millisecond are those of the last plus one,
+// so the ids of the process increase
+func ULID() string {
+	umu.Lock()
+	defer umu.Unlock()
+	var id [16]byte
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	binary.BigEndian.PutUint64(id[:8], ms<<16)
+	if binary.BigEndian.Uint64(lastULID[:8])>>16 >= ms {
+		// the same millisecond (or the clock went back): increment the last
+		id = lastULID
+		for i := 15; i >= 6; i-- {
+			if id[i]++; id[i] != 0 {
+				break
+			}
+		}
+	} else if _, err := rand.Read(id[6:]); err != nil {
+		panic(err)
+	}
+	lastULID = id
+	return encodeULID(id)
+}
+
+// encodeULID encodes the 128 bits as 26 characters of base 32, the first having 3 bits
+func encodeULID(id [16]byte) string {
+	var b strings.Builder
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	for i := 25; i >= 0; i-- {
+		shift := uint(i * 5)
+		var v uint64
+		switch {
+		case shift >= 64:
+			v = hi >> (shift - 64)
+		case shift > 59:
+			v = lo>>shift | hi<<(64-shift)
+		default:
+			v = lo >> shift
+		}
+		b.WriteByte(crockford[v&31])
+	}
+	return b.String()
+}
+
+// ULIDTime returns the time of a ULID, to the millisecond
+func ULIDTime(id string) (time.Time, error) {
+	if len(id) != 26 {
+		return time.Time{}, fmt.Errorf("invalid ULID: %q", id)
+	}
+	var ms uint64
+	for _, c := range strings.ToUpper(id[:10]) {
+		i := strings.IndexRune(crockford, c)
+		if i < 0 {
+			return time.Time{}, fmt.Errorf("invalid ULID: %q", id)
+		}
+		ms = ms<<5 | uint64(i)
+	}
+	if ms >= 1<<48 {
+		return time.Time{}, fmt.Errorf("invalid ULID: %q", id)
+	}
+	return time.Unix(0, int64(ms)*int64(time.Millisecond)), nil
+}
+
+var (
+	snowflakes = make(map[int64]*Snowflake)
+	idmu       sync.Mutex
+)
+
+// snowflakeFunc returns the next id of the node, for the snowflake SQL function
+func snowflakeFunc(node int64) (int64, error) {
+	idmu.Lock()
+	s, ok := snowflakes[node]
+	if !ok {
+		var err error
+		if s, err = NewSnowflake(node); err != nil {
+			idmu.Unlock()
+			return 0, err
+		}
+		snowflakes[node] = s
+	}
+	idmu.Unlock()
+	return s.Next(), nil
+}
+
+// IDFuncs are the SQL functions registered by WithIDGen
+var IDFuncs = []FuncReg{
+	{"ulid", ULID, false},
+	{"snowflake", snowflakeFunc, false},
+}
+
+// WithIDGen registers the SQL functions ulid(), returning a ULID, and snowflake(node),
+// returning a snowflake id of the node, e.g. for the defaults of keys:
+//
+//	CREATE TABLE events (id TEXT PRIMARY KEY DEFAULT (ulid()), ...)
+func WithIDGen() Optional {
+	return WithFunctions(IDFuncs...)
+}
+
+// idLease is how far ahead of the ids of NextID the state of their database is kept,
+// so the ids after a restart are later than those before, even if the clock went back
+const idLease = 10 * time.Second
+
+const idSchema = `CREATE TABLE IF NOT EXISTS _idgen (
+	id INTEGER PRIMARY KEY CHECK (id = 0),
+	node INTEGER NOT NULL,
+	lease INTEGER NOT NULL
+)`
+
+// dbSnowflake is the generator of NextID for a database
+type dbSnowflake struct {
+	*Snowflake
+	lease int64 // the milliseconds the ids are known to be within
+}
+
+var dbSnowflakes = make(map[*sql.DB]*dbSnowflake)
+
+// NextID returns the next snowflake id of the database, whose node is kept in the
+// table _idgen, chosen at random when the table is created, unless set by SetIDNode.
+// The database is only written to every few seconds of ids, to extend their lease
+func NextID(db *sql.DB) (int64, error) {
+	idmu.Lock()
+	defer idmu.Unlock()
+	s, ok := dbSnowflakes[db]
+	if !ok {
+		var err error
+		if s, err = loadSnowflake(db); err != nil {
+			return 0, err
+		}
+		dbSnowflakes[db] = s
+	}
+	s.mu.Lock()
+	id, ms := s.next()
+	s.mu.Unlock()
+	if ms >= s.lease {
+		lease := ms + idLease.Milliseconds()
+		if _, err := db.Exec("UPDATE _idgen SET lease=? WHERE id=0", lease); err != nil {
+			return 0, err
+		}
+		s.lease = lease
+	}
+	return id, nil
+}
+
+// SetIDNode sets the node of the ids of NextID for the database, e.g. so each of
+// the databases of a system has a different one
+func SetIDNode(db *sql.DB, node int64) error {
+	if node < 0 || node > snowflakeMaxNode {
+		return fmt.Errorf("snowflake node must be from 0 to %d: %d", snowflakeMaxNode, node)
+	}
+	idmu.Lock()
+	defer idmu.Unlock()
+	if _, err := loadSnowflake(db); err != nil {
+		return err
+	}
+	if _, err := db.Exec("UPDATE _idgen SET node=? WHERE id=0", node); err != nil {
+		return err
+	}
+	delete(dbSnowflakes, db)
+	return nil
+}
+
+// forgetNextID forgets the generator of the database, as it is being closed
+func forgetNextID(db *sql.DB) {
+	idmu.Lock()
+	delete(dbSnowflakes, db)
+	idmu.Unlock()
+}
+
+// loadSnowflake returns the generator of the database, with its state
+func loadSnowflake(db *sql.DB) (*dbSnowflake, error) {
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	node := int64(binary.BigEndian.Uint16(b[:])) & snowflakeMaxNode
+	if _, err := db.Exec(idSchema); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec("INSERT OR IGNORE INTO _idgen (id, node, lease) VALUES(0, ?, 0)", node); err != nil {
+		return nil, err
+	}
+	var lease int64
+	if err := row(db, []interface{}{&node, &lease}, "SELECT node, lease FROM _idgen WHERE id=0"); err != nil {
+		return nil, err
+	}
+	s, err := NewSnowflake(node)
+	if err != nil {
+		return nil, err
+	}
+	// ids start after those that could have been given out before
+	s.last = lease
+	s.seq = snowflakeMaxSeq
+	return &dbSnowflake{Snowflake: s}, nil
+}
@@ -0,0 +1,144 @@
+package sqlite
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestULID(t *testing.T) {
+	start := time.Now().Truncate(time.Millisecond)
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = ULID()
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Error("ULIDs are not sorted")
+	}
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if len(id) != 26 || seen[id] {
+			t.Fatalf("invalid or repeated ULID: %q", id)
+		}
+		seen[id] = true
+	}
+	when, err := ULIDTime(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if when.Before(start) || when.After(time.Now()) {
+		t.Errorf("ULID time %v is not from %v", when, start)
+	}
+	if _, err := ULIDTime("not a ulid"); err == nil {
+		t.Error("expected error for invalid ULID")
+	}
+}
+
+func TestSnowflake(t *testing.T) {
+	if _, err := NewSnowflake(1024); err == nil {
+		t.Error("expected error for invalid node")
+	}
+	s, err := NewSnowflake(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Truncate(time.Millisecond)
+	last := int64(0)
+	for i := 0; i < 10000; i++ {
+		id := s.Next()
+		if id <= last {
+			t.Fatalf("id %d is not after %d", id, last)
+		}
+		last = id
+	}
+	if n := SnowflakeNode(last); n != 7 {
+		t.Errorf("expected node 7 but got %d", n)
+	}
+	if when := SnowflakeTime(last); when.Before(start) {
+		t.Errorf("snowflake time %v is before %v", when, start)
+	}
+}
+
+func TestIDGenFuncs(t *testing.T) {
+	db, err := Open(":memory:", WithIDGen())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	const schema = `CREATE TABLE events (
+	id TEXT PRIMARY KEY DEFAULT (ulid()),
+	seq INTEGER NOT NULL DEFAULT (snowflake(3)),
+	name TEXT
+)`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := db.Exec("INSERT INTO events (name) VALUES(?)", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var names string
+	if err := row(db, []interface{}{&names}, "SELECT group_concat(name, '') FROM (SELECT name FROM events ORDER BY id)"); err != nil {
+		t.Fatal(err)
+	}
+	if names != "abc" {
+		t.Errorf("expected ids in order of insertion but got: %q", names)
+	}
+	var seq int64
+	if err := row(db, []interface{}{&seq}, "SELECT max(seq) FROM events"); err != nil {
+		t.Fatal(err)
+	}
+	if n := SnowflakeNode(seq); n != 3 {
+		t.Errorf("expected node 3 but got %d", n)
+	}
+	if _, err := db.Exec("SELECT snowflake(5000)"); err == nil {
+		t.Error("expected error for invalid node")
+	}
+}
+
+func TestNextID(t *testing.T) {
+	dir := t.TempDir()
+	db := fileDB(t, dir)
+	if err := SetIDNode(db, 42); err != nil {
+		t.Fatal(err)
+	}
+	first, err := NextID(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := SnowflakeNode(first); n != 42 {
+		t.Errorf("expected node 42 but got %d", n)
+	}
+	last := first
+	for i := 0; i < 100; i++ {
+		id, err := NextID(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id <= last {
+			t.Fatalf("id %d is not after %d", id, last)
+		}
+		last = id
+	}
+
+	// after a restart, ids are after the lease of those before
+	var lease int64
+	if err := row(db, []interface{}{&lease}, "SELECT lease FROM _idgen"); err != nil {
+		t.Fatal(err)
+	}
+	forgetNextID(db)
+	id, err := NextID(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id <= last || SnowflakeTime(id).Before(SnowflakeEpoch.Add(time.Duration(lease)*time.Millisecond)) {
+		t.Errorf("id %d after restart is within the lease %d", id, lease)
+	}
+	if SnowflakeNode(id) != 42 {
+		t.Errorf("expected node 42 after restart but got %d", SnowflakeNode(id))
+	}
+	if err := SetIDNode(db, -1); err == nil {
+		t.Error("expected error for invalid node")
+	}
+}
@@ -0,0 +1,459 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnKind is the type of the values of an imported column
+type ColumnKind string
+
+// Kinds of imported columns
+const (
+	KindText    ColumnKind = "text"
+	KindInteger ColumnKind = "integer"
+	KindReal    ColumnKind = "real"
+	KindBool    ColumnKind = "bool"
+	KindDate    ColumnKind = "date"
+	KindBlob    ColumnKind = "blob" // never inferred
+)
+
+// declType returns the declared type of a column of the kind, those of dates being
+// parsed as times by the driver
+func (k ColumnKind) declType() string {
+	switch k {
+	case KindInteger:
+		return "INTEGER"
+	case KindReal:
+		return "REAL"
+	case KindBool:
+		return "BOOLEAN"
+	case KindDate:
+		return "DATETIME"
+	case KindBlob:
+		return "BLOB"
+	}
+	return "TEXT"
+}
+
+// dateLayouts are the formats of the values inferred to be dates, the first being dates alone
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05.999999999-07:00",
+	time.RFC3339Nano,
+	"02/Jan/2006:15:04:05 -0700", // of access logs
+}
+
+// convert returns the value of the text as the kind
+func (k ColumnKind) convert(s string) (interface{}, error) {
+	switch k {
+	case KindInteger:
+		if leadingZero(s) {
+			return nil, fmt.Errorf("invalid integer: %q", s)
+		}
+		return strconv.ParseInt(s, 10, 64)
+	case KindReal:
+		f, err := strconv.ParseFloat(s, 64)
+		if err == nil && (leadingZero(s) || math.IsNaN(f) || math.IsInf(f, 0)) {
+			err = fmt.Errorf("invalid real: %q", s)
+		}
+		return f, err
+	case KindBool:
+		switch strings.ToLower(s) {
+		case "true", "t", "yes":
+			return 1, nil
+		case "false", "f", "no":
+			return 0, nil
+		}
+		return nil, fmt.Errorf("invalid bool: %q", s)
+	case KindDate:
+		for i, layout := range dateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				if i == 0 {
+					return t.Format(layout), nil
+				}
+				return t.UTC().Format("2006-01-02 15:04:05.999999999"), nil
+			}
+		}
+		return nil, fmt.Errorf("invalid date: %q", s)
+	case KindBlob:
+		return []byte(s), nil
+	}
+	return s, nil
+}
+
+// leadingZero reports whether the number has a leading zero, as codes such as zips do
+func leadingZero(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	return len(s) > 1 && s[0] == '0' && s[1] >= '0' && s[1] <= '9'
+}
+
+// inferKind returns the narrowest kind of all the values, which are not null.
+// Numbers with leading zeros, such as zip codes, are kept as text
+func inferKind(values []string) ColumnKind {
+	if len(values) == 0 {
+		return KindText
+	}
+	for _, k := range []ColumnKind{KindInteger, KindReal, KindBool, KindDate} {
+		ok := true
+		for _, v := range values {
+			if _, err := k.convert(v); err != nil {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return k
+		}
+	}
+	return KindText
+}
+
+// ImportOptions are the options for importing rows of any format
+type ImportOptions struct {
+	Types    map[string]ColumnKind // kinds of columns, rather than inferring them
+	Nulls    []string              // values imported as NULL, just the empty string if not set
+	Trim     bool                  // trim spaces around values
+	Encoding string                // of the input, see DetectEncoding, detected if not set
+	Sample   int                   // the rows read to infer the kinds of columns, 1000 if not set
+	Rejects  io.Writer             // optional, where rows that can't be imported are written
+
+	// Checkpoint names the import, to commit its rows in batches, with its progress kept
+	// in the table _import_state, so an import that is interrupted resumes after the last
+	// row it committed when run again with the same name and input, and one that completed
+	// imports only rows added to the end of its input since
+	Checkpoint     string
+	CheckpointRows int // the rows of input read between checkpoints, 10000 if not set
+}
+
+// CSVOptions are the options for ImportCSV
+type CSVOptions struct {
+	ImportOptions
+	Comma   rune     // the field separator, ',' if not set
+	Columns []string // the names of the columns, when the input has no header row
+}
+
+// ImportResult is what was imported
+type ImportResult struct {
+	Columns  []string
+	Kinds    []ColumnKind
+	Rows     int64 // rows imported
+	Rejected int64 // rows written to the rejects
+}
+
+// ImportCSV imports CSV into the table, which is created if it doesn't exist, with columns
+// named by the header row and typed by the values of the first rows. Integers, reals,
+// bools (true/false, t/f, yes/no), and ISO 8601 dates and times are recognized, and
+// imported as integers, reals, 1 or 0, and ISO 8601 text in UTC. Input is converted to
+// UTF-8 from its encoding, which is detected if not given, as by DetectEncoding.
+// Rows that can't be read or converted are written to the rejects as CSV, prefixed by
+// their row number and the error, or fail the import if there are no rejects.
+// The rows are imported in a single transaction, unless the import is checkpointed
+func ImportCSV(db *sql.DB, table string, r io.Reader, opts *CSVOptions) (ImportResult, error) {
+	if opts == nil {
+		opts = &CSVOptions{}
+	}
+	dr, err := decodeReader(r, opts.Encoding)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	cr := csv.NewReader(dr)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	cr.FieldsPerRecord = -1
+	columns := opts.Columns
+	if len(columns) == 0 {
+		if columns, err = cr.Read(); err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("no header row")
+			}
+			return ImportResult{}, err
+		}
+	}
+	next := func() ([]string, error) {
+		record, err := cr.Read()
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			return nil, rejectError{err}
+		}
+		return record, err
+	}
+	imp := importer{columns: columns, opts: opts.ImportOptions}
+	return imp.run(db, table, next)
+}
+
+const importStateSchema = `CREATE TABLE IF NOT EXISTS _import_state (
+  name TEXT PRIMARY KEY,
+  tbl TEXT NOT NULL,
+  row INTEGER NOT NULL,
+  rows INTEGER NOT NULL,
+  rejected INTEGER NOT NULL,
+  updated TEXT NOT NULL
+)`
+
+// importState returns the number of the last row of input committed by the import of
+// the name, with the rows imported and rejected so far
+func importState(db *sql.DB, name, table string) (int, int64, int64, error) {
+	if _, err := db.Exec(importStateSchema); err != nil {
+		return 0, 0, 0, err
+	}
+	var last int
+	var rows, rejected int64
+	var tbl string
+	err := row(db, []interface{}{&last, &rows, &rejected, &tbl}, "SELECT row, rows, rejected, tbl FROM _import_state WHERE name=?", name)
+	switch {
+	case err == sql.ErrNoRows:
+		return 0, 0, 0, nil
+	case err != nil:
+		return 0, 0, 0, err
+	case tbl != table:
+		return 0, 0, 0, fmt.Errorf("import: %s is of table: %s", name, tbl)
+	}
+	return last, rows, rejected, nil
+}
+
+// ResetImport forgets the progress of the import of the name, so it is imported anew
+func ResetImport(db *sql.DB, name string) error {
+	if _, err := db.Exec(importStateSchema); err != nil {
+		return err
+	}
+	_, err := db.Exec("DELETE FROM _import_state WHERE name=?", name)
+	return err
+}
+
+// rejectError is the error of a row that can't be read, which is rejected rather than
+// ending the import
+type rejectError struct {
+	error
+}
+
+// importer imports rows of text into a table, for the importers of each format
+type importer struct {
+	columns []string
+	opts    ImportOptions
+}
+
+// run imports the rows returned by next, until it returns io.EOF
+func (imp *importer) run(db *sql.DB, table string, next func() ([]string, error)) (ImportResult, error) {
+	result := ImportResult{Columns: make([]string, len(imp.columns))}
+	for i, c := range imp.columns {
+		if c = strings.TrimSpace(c); c == "" {
+			c = fmt.Sprintf("c%d", i+1)
+		}
+		result.Columns[i] = c
+	}
+	if len(result.Columns) == 0 {
+		return result, fmt.Errorf("no columns")
+	}
+	nulls := map[string]bool{"": true}
+	if len(imp.opts.Nulls) > 0 {
+		nulls = make(map[string]bool, len(imp.opts.Nulls))
+		for _, null := range imp.opts.Nulls {
+			nulls[null] = true
+		}
+	}
+	sample := imp.opts.Sample
+	if sample <= 0 {
+		sample = 1000
+	}
+	var rejects *csv.Writer
+	if imp.opts.Rejects != nil {
+		rejects = csv.NewWriter(imp.opts.Rejects)
+	}
+	// the rows up to resume were committed by an earlier run, and are read again only
+	// to infer the kinds of the columns as it did
+	resume := 0
+	if imp.opts.Checkpoint != "" {
+		var err error
+		if resume, result.Rows, result.Rejected, err = importState(db, imp.opts.Checkpoint, table); err != nil {
+			return result, err
+		}
+	}
+	batch := imp.opts.CheckpointRows
+	if batch <= 0 {
+		batch = 10000
+	}
+	number := 0
+	reject := func(n int, fields []string, err error) error {
+		if rejects == nil {
+			return fmt.Errorf("row %d: %w", n, err)
+		}
+		result.Rejected++
+		rejects.Write(append([]string{strconv.Itoa(n), err.Error()}, fields...))
+		return nil
+	}
+	// read returns the next row that has the right number of fields, and its number
+	read := func() ([]string, int, error) {
+		for {
+			fields, err := next()
+			if err == io.EOF {
+				return nil, 0, err
+			}
+			number++
+			var rerr rejectError
+			if errors.As(err, &rerr) {
+				if number <= resume {
+					continue
+				}
+				if err := reject(number, fields, rerr.error); err != nil {
+					return nil, 0, err
+				}
+				continue
+			}
+			if err != nil {
+				return nil, 0, err
+			}
+			if len(fields) != len(result.Columns) {
+				if number <= resume {
+					continue
+				}
+				if err := reject(number, fields, fmt.Errorf("expected %d fields but got %d", len(result.Columns), len(fields))); err != nil {
+					return nil, 0, err
+				}
+				continue
+			}
+			if imp.opts.Trim {
+				for i := range fields {
+					fields[i] = strings.TrimSpace(fields[i])
+				}
+			}
+			return fields, number, nil
+		}
+	}
+
+	// the first rows decide the kinds of the columns
+	type pending struct {
+		fields []string
+		number int
+	}
+	var buffered []pending
+	for len(buffered) < sample {
+		fields, n, err := read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+		buffered = append(buffered, pending{fields, n})
+	}
+	result.Kinds = make([]ColumnKind, len(result.Columns))
+	defs := make([][2]string, len(result.Columns))
+	for i, c := range result.Columns {
+		if k, ok := imp.opts.Types[c]; ok {
+			result.Kinds[i] = k
+		} else {
+			var values []string
+			for _, p := range buffered {
+				if v := p.fields[i]; !nulls[v] {
+					values = append(values, v)
+				}
+			}
+			result.Kinds[i] = inferKind(values)
+		}
+		defs[i] = [2]string{quoteIdent(c), result.Kinds[i].declType()}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer func() { tx.Rollback() }()
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s %s", quoteIdent(table), tableDef(db, defs))
+	if _, err := tx.Exec(create); err != nil {
+		return result, err
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdent(table), quotedList(result.Columns), placeholders(len(result.Columns)))
+	stmt, err := tx.Prepare(insert)
+	if err != nil {
+		return result, err
+	}
+	// commit commits the rows of input up to n, with the progress of the import if it is checkpointed
+	commit := func(n int) error {
+		if imp.opts.Checkpoint != "" {
+			const save = `INSERT OR REPLACE INTO _import_state (name, tbl, row, rows, rejected, updated)
+VALUES(?, ?, ?, ?, ?, strftime('%Y-%m-%d %H:%M:%f', 'now'))`
+			if _, err := tx.Exec(save, imp.opts.Checkpoint, table, n, result.Rows, result.Rejected); err != nil {
+				return err
+			}
+		}
+		if rejects != nil {
+			if rejects.Flush(); rejects.Error() != nil {
+				return rejects.Error()
+			}
+		}
+		return tx.Commit()
+	}
+	committed := resume
+	args := make([]interface{}, len(result.Columns))
+	add := func(fields []string, n int) error {
+		if n <= resume {
+			return nil
+		}
+		for i, v := range fields {
+			if nulls[v] {
+				args[i] = nil
+				continue
+			}
+			value, err := result.Kinds[i].convert(v)
+			if err != nil {
+				return reject(n, fields, fmt.Errorf("column %s: %w", result.Columns[i], err))
+			}
+			args[i] = value
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return fmt.Errorf("row %d: %w", n, err)
+		}
+		result.Rows++
+		return nil
+	}
+	for _, p := range buffered {
+		if err := add(p.fields, p.number); err != nil {
+			return result, err
+		}
+	}
+	for {
+		fields, n, err := read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+		if err := add(fields, n); err != nil {
+			return result, err
+		}
+		// the rows buffered, read ahead of those added, are committed with the first batch
+		if imp.opts.Checkpoint != "" && n-committed >= batch {
+			if err := commit(n); err != nil {
+				return result, err
+			}
+			committed = n
+			if tx, err = db.Begin(); err != nil {
+				return result, err
+			}
+			if stmt, err = tx.Prepare(insert); err != nil {
+				return result, err
+			}
+		}
+	}
+	if number < resume {
+		return result, fmt.Errorf("import: %s has %d rows of input, but %d were imported", imp.opts.Checkpoint, number, resume)
+	}
+	return result, commit(number)
+}
+
+// placeholders returns n comma separated parameters
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
@@ -0,0 +1,189 @@
+package sqlite
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportCSV(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	const input = "\xEF\xBB\xBFid, name ,price,active,joined,zip\n" +
+		"1, ann ,1.5,true,2021-03-04,02134\n" +
+		"2,bob,N/A,no,2021-03-05T10:00:00Z,90210\n" +
+		"3,cal,2,yes\n" +
+		"4,dee,3,f,2021-03-06,10001\n"
+	var rejects bytes.Buffer
+	opts := &CSVOptions{ImportOptions: ImportOptions{Nulls: []string{"", "N/A"}, Trim: true, Sample: 2, Rejects: &rejects}}
+	result, err := ImportCSV(db, "people", strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 3 || result.Rejected != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	want := []ColumnKind{KindInteger, KindText, KindReal, KindBool, KindDate, KindText}
+	for i, k := range want {
+		if result.Kinds[i] != k {
+			t.Errorf("column %s: expected %s but got %s", result.Columns[i], k, result.Kinds[i])
+		}
+	}
+	if !strings.HasPrefix(rejects.String(), "3,expected 6 fields but got 4,3,cal,2,yes") {
+		t.Errorf("unexpected rejects: %q", rejects.String())
+	}
+
+	var name, zip string
+	var price *float64
+	var active bool
+	var joined time.Time
+	if err := row(db, []interface{}{&name, &price, &active, &joined, &zip}, "select name, price, active, joined, zip from people where id=2"); err != nil {
+		t.Fatal(err)
+	}
+	if name != "bob" || price != nil || active || joined.Hour() != 10 || zip != "90210" {
+		t.Errorf("unexpected row: %s %v %v %v %s", name, price, active, joined, zip)
+	}
+	if err := row(db, []interface{}{&name, &zip}, "select name, zip from people where id=1"); err != nil || name != "ann" || zip != "02134" {
+		t.Errorf("unexpected row: %s %s (%v)", name, zip, err)
+	}
+
+	// rows after the sample that don't convert are rejected too
+	rejects.Reset()
+	input2 := "a;b\n1;x\n2;y\nthree;z\n"
+	result, err = ImportCSV(db, "semi", strings.NewReader(input2), &CSVOptions{Comma: ';', ImportOptions: ImportOptions{Sample: 1, Rejects: &rejects}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 2 || result.Rejected != 1 || !strings.Contains(rejects.String(), "column a") {
+		t.Errorf("unexpected result: %+v, rejects: %q", result, rejects.String())
+	}
+	// or fail the import without a rejects file
+	if _, err := ImportCSV(db, "semi2", strings.NewReader(input2), &CSVOptions{Comma: ';', ImportOptions: ImportOptions{Sample: 1}}); err == nil || !strings.Contains(err.Error(), "row 3") {
+		t.Errorf("expected error for row 3 but got: %v", err)
+	}
+	var count int
+	if err := row(db, []interface{}{&count}, "select count(*) from sqlite_master where name='semi2'"); err != nil || count != 0 {
+		t.Errorf("expected failed import to be rolled back: %d %v", count, err)
+	}
+
+	// Latin-1 is detected
+	latin := "city\nS\xE3o Paulo\n"
+	if _, err := ImportCSV(db, "cities", strings.NewReader(latin), nil); err != nil {
+		t.Fatal(err)
+	}
+	var city string
+	if err := row(db, []interface{}{&city}, "select city from cities"); err != nil || city != "São Paulo" {
+		t.Errorf("unexpected city: %q (%v)", city, err)
+	}
+
+	named := &CSVOptions{Columns: []string{"x", "y"}, ImportOptions: ImportOptions{Types: map[string]ColumnKind{"x": KindText}}}
+	result, err = ImportCSV(db, "points", strings.NewReader("1,2\n3,4\n"), named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 2 || result.Kinds[0] != KindText || result.Kinds[1] != KindInteger {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+// failingReader returns the error once its input is read
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, f.err
+	}
+	return n, err
+}
+
+func TestImportCheckpoint(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "import.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	lines := []string{"id,name"}
+	for i := 1; i <= 25; i++ {
+		if i == 7 {
+			lines = append(lines, "bad")
+			continue
+		}
+		// long enough for the input to be read after its encoding is detected
+		lines = append(lines, fmt.Sprintf("%d,%s%d", i, strings.Repeat("x", 3000), i))
+	}
+	input := func(rows int) io.Reader {
+		return strings.NewReader(strings.Join(lines[:rows+1], "\n") + "\n")
+	}
+	var rejects bytes.Buffer
+	opts := &CSVOptions{ImportOptions: ImportOptions{Sample: 5, Rejects: &rejects, Checkpoint: "people.csv", CheckpointRows: 10}}
+	count := func() int {
+		var n int
+		if err := row(db, []interface{}{&n}, "select count(*) from people"); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// interrupted after the rows of the second checkpoint were read
+	interrupted := failingReader{input(23), errors.New("connection reset")}
+	if _, err := ImportCSV(db, "people", interrupted, opts); err == nil {
+		t.Fatal("expected the import to fail")
+	}
+	if n := count(); n != 19 {
+		t.Errorf("expected the 19 rows checkpointed but got: %d", n)
+	}
+
+	// resumed after the last row committed
+	rejects.Reset()
+	result, err := ImportCSV(db, "people", input(25), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 24 || result.Rejected != 1 || rejects.Len() != 0 {
+		t.Errorf("unexpected result: %+v, rejects: %q", result, rejects.String())
+	}
+	var dups int
+	if err := row(db, []interface{}{&dups}, "select count(*) - count(distinct id) from people"); err != nil || dups != 0 {
+		t.Errorf("expected no duplicate rows but got: %d (%v)", dups, err)
+	}
+	if n := count(); n != 24 {
+		t.Errorf("expected 24 rows but got: %d", n)
+	}
+
+	// run again, only rows added since are imported
+	if _, err := ImportCSV(db, "people", input(25), opts); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 24 {
+		t.Errorf("expected the import to be idempotent but got: %d rows", n)
+	}
+	lines = append(lines, "26,name26")
+	if result, err = ImportCSV(db, "people", input(26), opts); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 25 || result.Rows != 25 {
+		t.Errorf("expected the added row to be imported but got: %d rows, result: %+v", n, result)
+	}
+
+	if _, err := ImportCSV(db, "people", input(20), opts); err == nil {
+		t.Error("expected error for input shorter than imported")
+	}
+	if _, err := ImportCSV(db, "others", input(5), opts); err == nil {
+		t.Error("expected error for the import of another table")
+	}
+	if err := ResetImport(db, "people.csv"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportCSV(db, "others", input(5), opts); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,189 @@
+package sqlite
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// CombinedLog matches lines of the combined access log format of Apache and nginx, and
+// of the common log format, which lacks the referer and agent
+var CombinedLog = regexp.MustCompile(`^(?P<host>\S+) (?P<ident>\S+) (?P<user>\S+) \[(?P<time>[^\]]+)\] "(?P<method>\S+)(?: (?P<path>\S+))?(?: (?P<protocol>[^"]*))?" (?P<status>\d{3}) (?P<size>\S+)(?: "(?P<referer>[^"]*)" "(?P<agent>[^"]*)")?`)
+
+// FixedField is a field of a fixed-width file, in the characters from Start to End
+// of each line, counted from 0
+type FixedField struct {
+	Name       string
+	Start, End int
+}
+
+// logOptions returns the options for importing a log, where "-" is null by default
+func logOptions(opts *ImportOptions) ImportOptions {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+	o := *opts
+	if len(o.Nulls) == 0 {
+		o.Nulls = []string{"", "-"}
+	}
+	return o
+}
+
+// lineReader returns the non-blank lines of the text of r, decoded per the options
+func lineReader(r io.Reader, opts ImportOptions) (func() (string, error), error) {
+	dr, err := decodeReader(r, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(dr)
+	scanner.Buffer(nil, 1<<20)
+	return func() (string, error) {
+		for scanner.Scan() {
+			if line := strings.TrimRight(scanner.Text(), "\r"); strings.TrimSpace(line) != "" {
+				return line, nil
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}, nil
+}
+
+// ImportFixedWidth imports lines of fixed-width fields into the table, as ImportCSV does
+// rows of CSV. Values are trimmed of spaces, and fields beyond the end of a line are empty
+func ImportFixedWidth(db *sql.DB, table string, r io.Reader, fields []FixedField, opts *ImportOptions) (ImportResult, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		if f.Start < 0 || f.End <= f.Start {
+			return ImportResult{}, fmt.Errorf("field %s has invalid range: %d-%d", f.Name, f.Start, f.End)
+		}
+		columns[i] = f.Name
+	}
+	line, err := lineReader(r, *opts)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	next := func() ([]string, error) {
+		text, err := line()
+		if err != nil {
+			return nil, err
+		}
+		chars := []rune(text)
+		values := make([]string, len(fields))
+		for i, f := range fields {
+			if f.Start < len(chars) {
+				end := f.End
+				if end > len(chars) {
+					end = len(chars)
+				}
+				values[i] = strings.TrimSpace(string(chars[f.Start:end]))
+			}
+		}
+		return values, nil
+	}
+	imp := importer{columns: columns, opts: *opts}
+	return imp.run(db, table, next)
+}
+
+// ImportLog imports the lines of a log into the table, as ImportCSV does rows of CSV,
+// with a column for each named capture of the pattern, e.g. CombinedLog. Lines that
+// don't match are rejected, and "-" is null if the options don't give the nulls
+func ImportLog(db *sql.DB, table string, r io.Reader, pattern *regexp.Regexp, opts *ImportOptions) (ImportResult, error) {
+	var columns []string
+	var index []int
+	for i, name := range pattern.SubexpNames() {
+		if name != "" {
+			columns = append(columns, name)
+			index = append(index, i)
+		}
+	}
+	if len(columns) == 0 {
+		return ImportResult{}, fmt.Errorf("pattern has no named captures: %s", pattern)
+	}
+	o := logOptions(opts)
+	line, err := lineReader(r, o)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	next := func() ([]string, error) {
+		text, err := line()
+		if err != nil {
+			return nil, err
+		}
+		m := pattern.FindStringSubmatch(text)
+		if m == nil {
+			return []string{text}, rejectError{fmt.Errorf("line doesn't match the pattern")}
+		}
+		values := make([]string, len(index))
+		for i, j := range index {
+			values[i] = m[j]
+		}
+		return values, nil
+	}
+	imp := importer{columns: columns, opts: o}
+	return imp.run(db, table, next)
+}
+
+// ImportLTSV imports a log of labeled tab-separated values (http://ltsv.org), lines of
+// "label:value" fields separated by tabs, into the table, as ImportLog does, with a
+// column for each of the labels, or for those of the first line if none are given.
+// Fields with other labels are ignored
+func ImportLTSV(db *sql.DB, table string, r io.Reader, labels []string, opts *ImportOptions) (ImportResult, error) {
+	o := logOptions(opts)
+	line, err := lineReader(r, o)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	parse := func(text string) map[string]string {
+		fields := make(map[string]string)
+		for _, field := range strings.Split(text, "\t") {
+			if colon := strings.Index(field, ":"); colon > 0 {
+				fields[field[:colon]] = field[colon+1:]
+			}
+		}
+		return fields
+	}
+	var first map[string]string
+	if len(labels) == 0 {
+		text, err := line()
+		if err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("no labels")
+			}
+			return ImportResult{}, err
+		}
+		first = parse(text)
+		seen := make(map[string]bool)
+		for _, field := range strings.Split(text, "\t") {
+			if colon := strings.Index(field, ":"); colon > 0 && !seen[field[:colon]] {
+				seen[field[:colon]] = true
+				labels = append(labels, field[:colon])
+			}
+		}
+	}
+	next := func() ([]string, error) {
+		fields := first
+		first = nil
+		if fields == nil {
+			text, err := line()
+			if err != nil {
+				return nil, err
+			}
+			fields = parse(text)
+		}
+		values := make([]string, len(labels))
+		for i, label := range labels {
+			values[i] = fields[label]
+		}
+		return values, nil
+	}
+	imp := importer{columns: labels, opts: o}
+	return imp.run(db, table, next)
+}
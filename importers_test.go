@@ -0,0 +1,96 @@
+package sqlite
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportFixedWidth(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	const input = "" +
+		"0001Ann       Zürich    42.5\n" +
+		"0002Bob       Lima\n" +
+		"\n" +
+		"0003Cal                 17\n"
+	fields := []FixedField{{"id", 0, 4}, {"name", 4, 14}, {"city", 14, 24}, {"score", 24, 30}}
+	result, err := ImportFixedWidth(db, "people", strings.NewReader(input), fields, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 3 || result.Kinds[0] != KindText || result.Kinds[3] != KindReal {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	var id, name, city string
+	var score *float64
+	if err := row(db, []interface{}{&id, &name, &city, &score}, "select * from people where name='Ann'"); err != nil {
+		t.Fatal(err)
+	}
+	if id != "0001" || city != "Zürich" || score == nil || *score != 42.5 {
+		t.Errorf("unexpected row: %s %s %s %v", id, name, city, score)
+	}
+	var nulls int
+	if err := row(db, []interface{}{&nulls}, "select count(*) from people where city is null or score is null"); err != nil || nulls != 2 {
+		t.Errorf("expected 2 rows with nulls but got: %d (%v)", nulls, err)
+	}
+	if _, err := ImportFixedWidth(db, "bad", strings.NewReader(input), []FixedField{{"x", 4, 2}}, nil); err == nil {
+		t.Error("expected error for invalid range")
+	}
+}
+
+func TestImportLog(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	const input = `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"
+10.0.0.2 - - [10/Oct/2000:13:56:01 -0700] "POST /login HTTP/1.1" 302 -
+garbage
+10.0.0.3 - - [10/Oct/2000:13:57:12 -0700] "-" 408 0 "-" "-"
+`
+	var rejects bytes.Buffer
+	result, err := ImportLog(db, "access", strings.NewReader(input), CombinedLog, &ImportOptions{Rejects: &rejects})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 3 || result.Rejected != 1 || !strings.HasPrefix(rejects.String(), "3,") {
+		t.Errorf("unexpected result: %+v, rejects: %q", result, rejects.String())
+	}
+	var user, path string
+	var status int
+	var size *int64
+	var at time.Time
+	if err := row(db, []interface{}{&user, &path, &status, &size, &at}, "select user, path, status, size, time from access where host='127.0.0.1'"); err != nil {
+		t.Fatal(err)
+	}
+	if user != "frank" || path != "/apache_pb.gif" || status != 200 || size == nil || *size != 2326 || at.UTC().Hour() != 20 {
+		t.Errorf("unexpected row: %s %s %d %v %v", user, path, status, size, at)
+	}
+	var missing int
+	if err := row(db, []interface{}{&missing}, "select count(*) from access where user is null and method is not 'GET'"); err != nil || missing != 2 {
+		t.Errorf("expected 2 anonymous requests but got: %d (%v)", missing, err)
+	}
+}
+
+func TestImportLTSV(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	const input = "host:127.0.0.1\tstatus:200\treqtime:0.03\n" +
+		"host:10.0.0.2\tstatus:404\textra:x\n"
+	result, err := ImportLTSV(db, "hits", strings.NewReader(input), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 2 || strings.Join(result.Columns, ",") != "host,status,reqtime" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	var status int
+	var reqtime *float64
+	if err := row(db, []interface{}{&status, &reqtime}, "select status, reqtime from hits where host='10.0.0.2'"); err != nil || status != 404 || reqtime != nil {
+		t.Errorf("unexpected row: %d %v (%v)", status, reqtime, err)
+	}
+	result, err = ImportLTSV(db, "extras", strings.NewReader(input), []string{"extra"}, nil)
+	if err != nil || result.Rows != 2 {
+		t.Errorf("unexpected result: %+v (%v)", result, err)
+	}
+}
@@ -0,0 +1,127 @@
+package sqlite
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// IndexSpec declares an index. Either Columns or Expr must be given
+type IndexSpec struct {
+	Name    string // defaults to a name derived from the rest of the spec
+	Table   string
+	Columns []string // indexed columns, optionally followed by COLLATE or ASC/DESC
+	Expr    string   // indexed expression, e.g. "lower(email)"
+	Unique  bool
+	Partial string // WHERE clause of a partial index, e.g. "deleted IS NULL"
+}
+
+// IndexName returns the name of the index, which for unnamed specs is derived
+// from the table and columns, with a hash of any expression or partial clause
+func (s IndexSpec) IndexName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	parts := []string{"idx", s.Table}
+	if s.Unique {
+		parts[0] = "uidx"
+	}
+	for _, c := range s.Columns {
+		parts = append(parts, strings.Fields(c)[0])
+	}
+	if s.Expr != "" || s.Partial != "" {
+		sum := sha1.Sum([]byte(s.Expr + "\x00" + s.Partial))
+		parts = append(parts, hex.EncodeToString(sum[:4]))
+	}
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, strings.Join(parts, "_"))
+	return strings.ToLower(name)
+}
+
+// SQL returns the statement that creates the index
+func (s IndexSpec) SQL() (string, error) {
+	if s.Table == "" {
+		return "", fmt.Errorf("index has no table")
+	}
+	var on []string
+	for _, c := range s.Columns {
+		fields := strings.Fields(c)
+		if len(fields) == 0 {
+			return "", fmt.Errorf("index on %s has an empty column", s.Table)
+		}
+		on = append(on, strings.Join(append([]string{quoteIdent(fields[0])}, fields[1:]...), " "))
+	}
+	if s.Expr != "" {
+		on = append(on, s.Expr)
+	}
+	if len(on) == 0 {
+		return "", fmt.Errorf("index on %s has no columns or expression", s.Table)
+	}
+	create := "CREATE INDEX"
+	if s.Unique {
+		create = "CREATE UNIQUE INDEX"
+	}
+	stmt := fmt.Sprintf("%s %s ON %s (%s)", create, quoteIdent(s.IndexName()), quoteIdent(s.Table), strings.Join(on, ", "))
+	if s.Partial != "" {
+		stmt += " WHERE " + s.Partial
+	}
+	return stmt, nil
+}
+
+// EnsureIndex creates the index if it doesn't exist. An index of the same name
+// with a different definition is replaced, so changing a spec updates the index
+func EnsureIndex(db *sql.DB, spec IndexSpec) error {
+	stmt, err := spec.SQL()
+	if err != nil {
+		return err
+	}
+	name := spec.IndexName()
+	var existing string
+	switch err := row(db, []interface{}{&existing}, "SELECT sql FROM sqlite_master WHERE type='index' AND name=?", name); err {
+	case nil:
+		if existing == stmt {
+			return nil
+		}
+	case sql.ErrNoRows:
+	default:
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if existing != "" {
+		if _, err := tx.Exec("DROP INDEX " + quoteIdent(name)); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(stmt); err != nil {
+		return fmt.Errorf("index: %s, error: %w", name, err)
+	}
+	return tx.Commit()
+}
+
+// Analyze gathers statistics for the query planner on the given tables,
+// or the whole database if none are given. The statistics include
+// sample rows (sqlite_stat4) when the driver is built with the sqlite_stat4 tag
+func Analyze(db *sql.DB, tables ...string) error {
+	if len(tables) == 0 {
+		_, err := db.Exec("ANALYZE")
+		return err
+	}
+	for _, table := range tables {
+		if _, err := db.Exec("ANALYZE " + quoteIdent(table)); err != nil {
+			return fmt.Errorf("analyze table: %s, error: %w", table, err)
+		}
+	}
+	return nil
+}
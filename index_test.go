@@ -0,0 +1,54 @@
+package sqlite
+
+import (
+	"testing"
+)
+
+func TestEnsureIndex(t *testing.T) {
+	db := memDB(t)
+	if _, err := db.Exec("create table users (id integer primary key, email text, deleted int)"); err != nil {
+		t.Fatal(err)
+	}
+	spec := IndexSpec{Table: "users", Expr: "lower(email)", Unique: true, Partial: "deleted IS NULL"}
+	name := spec.IndexName()
+	if name != spec.IndexName() || name[:11] != "uidx_users_" {
+		t.Fatalf("unexpected index name: %s", name)
+	}
+	for i := 0; i < 2; i++ {
+		if err := EnsureIndex(db, spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec("insert into users (email) values('A@b.com'), ('a@B.com')"); err == nil {
+		t.Fatal("expected unique index to reject duplicate")
+	}
+
+	byEmail := IndexSpec{Name: "users_email", Table: "users", Columns: []string{"email COLLATE NOCASE"}}
+	if err := EnsureIndex(db, byEmail); err != nil {
+		t.Fatal(err)
+	}
+	// changing the spec replaces the index
+	byEmail.Columns = []string{"email", "id DESC"}
+	if err := EnsureIndex(db, byEmail); err != nil {
+		t.Fatal(err)
+	}
+	var create string
+	if err := row(db, []interface{}{&create}, "select sql from sqlite_master where name='users_email'"); err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := byEmail.SQL(); create != want {
+		t.Fatalf("expected %s but got: %s", want, create)
+	}
+	if err := EnsureIndex(db, IndexSpec{Table: "users"}); err == nil {
+		t.Fatal("expected error for index without columns")
+	}
+	if err := Analyze(db, "users"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Analyze(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := Analyze(db, "nosuch"); err == nil {
+		t.Fatal("expected error for unknown table")
+	}
+}
@@ -0,0 +1,95 @@
+// Command pragmagen generates the typed pragma accessors in pragma_gen.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"strings"
+	"text/template"
+)
+
+// pragmas maps each pragma to the Go type of its value.
+// compile_options (a list) and data_version (see DataVersion) are handled elsewhere
+var pragmas = []struct{ Name, Type string }{
+	{"application_id", "int"},
+	{"auto_vacuum", "Vacuum"},
+	{"automatic_index", "bool"},
+	{"busy_timeout", "int"},
+	{"cache_size", "int"},
+	{"cache_spill", "int"},
+	{"cell_size_check", "bool"},
+	{"checkpoint_fullfsync", "bool"},
+	{"defer_foreign_keys", "bool"},
+	{"encoding", "string"},
+	{"foreign_keys", "bool"},
+	{"freelist_count", "int64"},
+	{"fullfsync", "bool"},
+	{"journal_mode", "Journal"},
+	{"journal_size_limit", "int64"},
+	{"locking_mode", "Locking"},
+	{"max_page_count", "int64"},
+	{"mmap_size", "int64"},
+	{"page_count", "int64"},
+	{"page_size", "int"},
+	{"query_only", "bool"},
+	{"read_uncommitted", "bool"},
+	{"recursive_triggers", "bool"},
+	{"reverse_unordered_selects", "bool"},
+	{"schema_version", "int"},
+	{"secure_delete", "int"},
+	{"soft_heap_limit", "int64"},
+	{"synchronous", "Sync"},
+	{"temp_store", "TempStorage"},
+	{"threads", "int"},
+	{"user_version", "int"},
+	{"wal_autocheckpoint", "int"},
+}
+
+var tmpl = template.Must(template.New("pragmas").Funcs(template.FuncMap{"camel": camel}).Parse(`// Code generated by pragmagen; DO NOT EDIT.
+
+package sqlite
+
+import "database/sql"
+{{range .}}
+// {{camel .Name}} returns the value of "PRAGMA {{.Name}}"
+func {{camel .Name}}(db *sql.DB) ({{.Type}}, error) {
+	var v {{.Type}}
+	return v, row(db, []interface{}{&v}, "PRAGMA {{.Name}}")
+}
+{{end}}`))
+
+// initialisms are words that are upper cased in Go names
+var initialisms = map[string]bool{"id": true, "wal": true}
+
+// camel converts a pragma name to its Go name, e.g., "wal_autocheckpoint" to "WALAutocheckpoint"
+func camel(name string) string {
+	var b strings.Builder
+	for _, word := range strings.Split(name, "_") {
+		if initialisms[word] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+func main() {
+	output := flag.String("o", "pragma_gen.go", "file to generate")
+	flag.Parse()
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, pragmas); err != nil {
+		log.Fatal(err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,109 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// Limit is a run-time limit of SQLite connections, see https://sqlite.org/limits.html
+type Limit int
+
+// Run-time limits, as set by SetLimit or WithLimits
+const (
+	LimitLength            Limit = sqlite3.SQLITE_LIMIT_LENGTH              // size of a string or blob, or a row
+	LimitSQLLength         Limit = sqlite3.SQLITE_LIMIT_SQL_LENGTH          // length of a statement
+	LimitColumn            Limit = sqlite3.SQLITE_LIMIT_COLUMN              // columns of a table, index, or result
+	LimitExprDepth         Limit = sqlite3.SQLITE_LIMIT_EXPR_DEPTH          // depth of the parse tree of an expression
+	LimitCompoundSelect    Limit = sqlite3.SQLITE_LIMIT_COMPOUND_SELECT     // terms of a compound select
+	LimitVDBEOp            Limit = sqlite3.SQLITE_LIMIT_VDBE_OP             // instructions of a prepared statement
+	LimitFunctionArg       Limit = sqlite3.SQLITE_LIMIT_FUNCTION_ARG        // arguments of a function
+	LimitAttached          Limit = sqlite3.SQLITE_LIMIT_ATTACHED            // attached databases
+	LimitLikePatternLength Limit = sqlite3.SQLITE_LIMIT_LIKE_PATTERN_LENGTH // length of a LIKE or GLOB pattern
+	LimitVariableNumber    Limit = sqlite3.SQLITE_LIMIT_VARIABLE_NUMBER     // index of a statement parameter
+	LimitTriggerDepth      Limit = sqlite3.SQLITE_LIMIT_TRIGGER_DEPTH       // depth of recursive triggers
+	LimitWorkerThreads     Limit = sqlite3.SQLITE_LIMIT_WORKER_THREADS      // auxiliary threads of a statement
+)
+
+func (l Limit) String() string {
+	return enumString(int(l), "length", "sql_length", "column", "expr_depth", "compound_select", "vdbe_op",
+		"function_arg", "attached", "like_pattern_length", "variable_number", "trigger_depth", "worker_threads")
+}
+
+// valid reports whether l and the value can be set
+func (l Limit) valid(value int) error {
+	if l < LimitLength || l > LimitWorkerThreads {
+		return fmt.Errorf("unknown limit: %d", int(l))
+	}
+	if value < 0 {
+		return fmt.Errorf("invalid %s limit: %d", l, value)
+	}
+	return nil
+}
+
+// WithLimits sets limits of each connection, e.g. to bound the memory and complexity of
+// statements from untrusted input. SQLite lowers values above its compiled maximums
+func WithLimits(limits map[Limit]int) Optional {
+	return func(c *Config) {
+		if c.limits == nil {
+			c.limits = make(map[Limit]int)
+		}
+		for limit, value := range limits {
+			c.limits[limit] = value
+		}
+	}
+}
+
+// GetLimit returns the value of a limit of the connections of the database
+func GetLimit(db *sql.DB, limit Limit) (int, error) {
+	if err := limit.valid(0); err != nil {
+		return 0, err
+	}
+	var value int
+	return value, WithRawConn(db, func(conn *sqlite3.SQLiteConn) error {
+		value = conn.GetLimit(int(limit))
+		return nil
+	})
+}
+
+// SetLimit sets a limit of each connection of the database, both those open and
+// those opened later, returning its prior value
+func SetLimit(db *sql.DB, limit Limit, value int) (int, error) {
+	if err := limit.valid(value); err != nil {
+		return 0, err
+	}
+	d, ok := db.Driver().(*liteDriver)
+	if !ok {
+		return 0, fmt.Errorf("database not opened by this package")
+	}
+	prior, err := GetLimit(db, limit)
+	if err != nil {
+		return 0, err
+	}
+	d.c.Lock()
+	defer d.c.Unlock()
+	limits := make(map[Limit]int, len(d.c.limits)+1)
+	for l, v := range d.c.limits {
+		limits[l] = v
+	}
+	limits[limit] = value
+	d.c.limits = limits
+	for conn := range d.c.open {
+		conn.SetLimit(int(limit), value)
+	}
+	return prior, nil
+}
+
+// sameLimits reports whether the limits are the same
+func sameLimits(a, b map[Limit]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for limit, value := range a {
+		if v, ok := b[limit]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	db, err := Open(":memory:", WithLimits(map[Limit]int{LimitSQLLength: 100}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if n, err := GetLimit(db, LimitSQLLength); err != nil || n != 100 {
+		t.Fatalf("got limit %d, %v", n, err)
+	}
+	if _, err := db.Exec("select 1 -- " + strings.Repeat("x", 100)); err == nil {
+		t.Error("expected error for statement over the limit")
+	}
+
+	// applies to open connections, and those opened later
+	ctx := context.Background()
+	conn1, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn1.Close()
+	prior, err := SetLimit(db, LimitLength, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prior < 10 {
+		t.Errorf("unexpected prior limit: %d", prior)
+	}
+	var s string
+	if err := conn1.QueryRowContext(ctx, "select zeroblob(20)").Scan(&s); err == nil {
+		t.Error("expected error for blob over the limit on open connection")
+	}
+	conn2, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+	if err := conn2.QueryRowContext(ctx, "select zeroblob(20)").Scan(&s); err == nil {
+		t.Error("expected error for blob over the limit on new connection")
+	}
+
+	if _, err := SetLimit(db, Limit(99), 1); err == nil {
+		t.Error("expected error for unknown limit")
+	}
+	if _, err := Open(":memory:", WithLimits(map[Limit]int{LimitColumn: -1})); err == nil {
+		t.Error("expected error for invalid limit")
+	}
+	if LimitVariableNumber.String() != "variable_number" {
+		t.Errorf("unexpected name: %s", LimitVariableNumber)
+	}
+}
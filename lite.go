@@ -1,25 +1,26 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"log"
 	"os"
 	"path"
 	"path/filepath"
-	"regexp"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	sqlite3 "github.com/mattn/go-sqlite3"
 )
 
 var (
-	rmu, imu sync.Mutex
+	rmu, imu, smu sync.Mutex
 )
 
 // N/A, impacts db, or multi-column -- ignore for now
@@ -74,34 +75,87 @@ const (
 )
 
 var (
-	pragmas    = strings.Fields(pragmaList)
-	commentC   = regexp.MustCompile(`(?s)/\*.*?\*/`)
-	commentSQL = regexp.MustCompile(`\s*--.*`)
+	pragmas = strings.Fields(pragmaList)
 
-	registry    = make(map[string]*sqlite3.SQLiteConn)
-	initialized = make(map[string]struct{})
+	registry    = make(map[*sqlite3.SQLiteConn]ConnInfo)
+	initialized = make(map[string]*connector)
+	resets      = make(map[string]bool)
+
+	// opening serializes opening each file, and shared holds the handles opened WithShared
+	opening = make(map[string]*sync.Mutex)
+	shared  = make(map[string]*sharedDB)
+
+	// ErrDriverSettings is returned when opening a database with the name
+	// of a registered driver, but with different settings
+	ErrDriverSettings = errors.New("driver already registered with different settings")
+
+	// ErrSharedSettings is returned when opening a file WithShared that is already
+	// open WithShared, but with different settings
+	ErrSharedSettings = errors.New("shared database already open with different settings")
+
+	// ErrDriverRegistered is returned when opening a database WithStrict with the name
+	// of a driver that is already registered, even with the same settings
+	ErrDriverRegistered = errors.New("driver already registered")
 
 	// Debug enables debugging  output
 	Debug = false
+
+	// EchoComments includes the comments preceding and within a statement when Commands echoes it
+	EchoComments = false
 )
 
 // Hook is an SQLite connection hook
 type Hook func(*sqlite3.SQLiteConn) error
 
-func register(file string, conn *sqlite3.SQLiteConn) {
-	file, _ = filepath.Abs(file)
-	if len(file) > 0 {
-		rmu.Lock()
-		registry[file] = conn
-		rmu.Unlock()
-	}
+// ConnInfo describes an open connection
+type ConnInfo struct {
+	DSN  string // as given to the driver, e.g. "file:test.db?_busy_timeout=5000"
+	File string // the file of the main database, empty if it is in memory
+}
+
+// Memory reports whether the main database of the connection is in memory
+func (c ConnInfo) Memory() bool {
+	return c.File == ""
+}
+
+// register records an open connection, which is known by its identity,
+// as in-memory databases don't have a filename to tell them apart
+func register(conn *sqlite3.SQLiteConn, info ConnInfo) {
+	rmu.Lock()
+	registry[conn] = info
+	rmu.Unlock()
 }
 
-func registered(file string) *sqlite3.SQLiteConn {
+func unregister(conn *sqlite3.SQLiteConn) {
 	rmu.Lock()
-	conn := registry[file]
+	delete(registry, conn)
 	rmu.Unlock()
-	return conn
+}
+
+// Connection returns the description of one of the connections of db
+func Connection(db *sql.DB) (ConnInfo, error) {
+	var info ConnInfo
+	err := WithRawConn(db, func(conn *sqlite3.SQLiteConn) error {
+		rmu.Lock()
+		defer rmu.Unlock()
+		var ok bool
+		if info, ok = registry[conn]; !ok {
+			return fmt.Errorf("connection not opened by this package")
+		}
+		return nil
+	})
+	return info, err
+}
+
+// Connections returns the descriptions of all the connections open
+func Connections() []ConnInfo {
+	rmu.Lock()
+	defer rmu.Unlock()
+	list := make([]ConnInfo, 0, len(registry))
+	for _, info := range registry {
+		list = append(list, info)
+	}
+	return list
 }
 
 func toIPv4(ip int64) string {
@@ -132,6 +186,17 @@ type FuncReg struct {
 	Pure bool
 }
 
+// AggReg contains the fields necessary to register a custom Sqlite aggregate function.
+// Impl is a constructor returning a pointer to a type with Step and Done methods,
+// as described by sqlite3.SQLiteConn.RegisterAggregator.
+//
+// To use an aggregate with an OVER clause, register it as a WindowReg instead
+type AggReg struct {
+	Name string
+	Impl interface{}
+	Pure bool
+}
+
 // ipFuncs have example functions to convert ipv4 to and from int32
 var ipFuncs = []FuncReg{
 	{"iptoa", toIPv4, true},
@@ -139,126 +204,489 @@ var ipFuncs = []FuncReg{
 	{"polygon", ToPolygon, true},
 }
 
-// The only way to get access to the sqliteconn, which is needed to be able to generate
-// a backup from the database while it is open. This is a less than satisfactory approach
-// because there's no way to have multiple instances open associate the connection with the DSN
-//
-// Since our use case is to normally have one instance open this should be workable for now
-func sqlInit(driverName, query string, hook Hook, funcs ...FuncReg) {
-	if Debug {
-		log.Println("registering driver:", driverName)
+// connector holds the settings applied to each new connection of a registered driver
+type connector struct {
+	sync.Mutex
+	query   string
+	hook    Hook
+	funcs   []FuncReg
+	aggs    []AggReg
+	windows []WindowReg
+	events  ConnEvents
+	trace   TraceSink
+	record  *Recorder
+	stats   *QueryStats
+	logger  Logger
+	strict  bool
+	tables  bool // STRICT tables, see WithStrictTables
+	capture *ChangeCapture
+	configs *ConfigStore
+	limits  map[Limit]int
+
+	open map[*sqlite3.SQLiteConn]bool // the open connections, for settings changed after they're opened
+}
+
+// connect is the connection hook of a registered driver
+func (c *connector) connect(conn *sqlite3.SQLiteConn) error {
+	c.Lock()
+	query, hook, trace := c.query, c.hook, c.trace
+	var watchers []rowWatcher
+	if c.capture != nil {
+		watchers = append(watchers, c.capture)
 	}
-	imu.Lock()
-	defer imu.Unlock()
+	if c.configs != nil {
+		watchers = append(watchers, c.configs)
+	}
+	funcs, aggs, windows := c.funcs, c.aggs, c.windows
+	for limit, value := range c.limits {
+		conn.SetLimit(int(limit), value)
+	}
+	c.Unlock()
+	if trace != nil && nativeTrace != nil {
+		if err := nativeTrace(conn, trace); err != nil {
+			return err
+		}
+	}
+	if err := registerFuncs(conn, funcs...); err != nil {
+		return err
+	}
+	if err := registerAggs(conn, aggs...); err != nil {
+		return err
+	}
+	if err := registerWindows(conn, windows...); err != nil {
+		return err
+	}
+	if query != "" {
+		if _, err := conn.Exec(query, nil); err != nil {
+			return fmt.Errorf("connection query failed: %s -- %w", query, err)
+		}
+	}
+	watchRows(conn, watchers...)
 
-	if _, ok := initialized[driverName]; ok {
-		return
+	if hook != nil {
+		return hook(conn)
 	}
-	initialized[driverName] = struct{}{}
+	return nil
+}
 
-	drvr := &sqlite3.SQLiteDriver{
-		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
-			for _, fn := range funcs {
-				if err := conn.RegisterFunc(fn.Name, fn.Impl, fn.Pure); err != nil {
-					return fmt.Errorf("failed to register %q: %w", fn.Name, err)
-				}
-				if Debug {
-					log.Println("registered function:", fn.Name)
-				}
-			}
-			if filename, err := connFilename(conn); err == nil {
-				register(filename, conn)
-			} else {
-				return fmt.Errorf("couldn't get filename for connection: %+v, error: %w", conn, err)
-			}
+// funcID identifies a func value by its code, as reflect does. Go has no supported way
+// to tell closures of the same func literal apart, so those capturing different state
+// are the same func, and settings that differ only by what a closure captures can't
+// be detected, see WithDriver
+func funcID(fn interface{}) uintptr {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return 0
+	}
+	return v.Pointer()
+}
 
-			if query != "" {
-				if _, err := conn.Exec(query, nil); err != nil {
-					return fmt.Errorf("connection query failed: %s -- %w", query, err)
-				}
-			}
+// same reports whether c and other prepare connections the same way
+func (c *connector) same(other *connector) bool {
+	c.Lock()
+	defer c.Unlock()
+	switch {
+	case c.query != other.query:
+	case funcID(c.hook) != funcID(other.hook):
+	case !c.events.same(other.events):
+	case !sameValue(c.trace, other.trace):
+	case !sameValue(c.logger, other.logger):
+	case c.record != other.record:
+	case c.stats != other.stats:
+	case c.strict != other.strict:
+	case c.tables != other.tables:
+	case c.capture != other.capture:
+	case c.configs != other.configs:
+	case !sameLimits(c.limits, other.limits):
+	case !sameFuncs(c.funcs, other.funcs):
+	case !sameAggs(c.aggs, other.aggs):
+	case !sameWindows(c.windows, other.windows):
+	default:
+		return true
+	}
+	return false
+}
 
-			if hook != nil {
-				return hook(conn)
-			}
-			return nil
-		},
+// sameFuncs reports whether the functions are the same registrations
+func sameFuncs(a, b []FuncReg) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, fn := range a {
+		o := b[i]
+		if fn.Name != o.Name || fn.Pure != o.Pure || funcID(fn.Impl) != funcID(o.Impl) {
+			return false
+		}
 	}
-	sql.Register(driverName, drvr)
+	return true
 }
 
-// Filename returns the filename of the DB
-func Filename(db *sql.DB) string {
-	var seq, name, file string
-	_ = row(db, []interface{}{&seq, &name, &file}, "PRAGMA database_list")
-	return file
+// sameAggs reports whether the aggregates are the same registrations
+func sameAggs(a, b []AggReg) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, agg := range a {
+		o := b[i]
+		if agg.Name != o.Name || agg.Pure != o.Pure || funcID(agg.Impl) != funcID(o.Impl) {
+			return false
+		}
+	}
+	return true
 }
 
-// connFilename returns the filename of the connection
-func connFilename(conn *sqlite3.SQLiteConn) (string, error) {
-	var filename string
-	fn := func(cols []string, row int, values []driver.Value) error {
-		if len(values) < 3 {
-			return fmt.Errorf("only got %d values", len(values))
+// sameWindows reports whether the window functions are the same registrations
+func sameWindows(a, b []WindowReg) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, w := range a {
+		o := b[i]
+		if w.Name != o.Name || w.Pure != o.Pure || w.NArgs != o.NArgs || funcID(w.New) != funcID(o.New) {
+			return false
 		}
-		if values[2] == nil {
-			return fmt.Errorf("nil values")
+	}
+	return true
+}
+
+// update replaces the settings of c with those of other
+func (c *connector) update(other *connector) {
+	c.Lock()
+	c.query, c.hook = other.query, other.hook
+	c.funcs, c.aggs, c.windows = other.funcs, other.aggs, other.windows
+	c.events, c.trace = other.events, other.trace
+	c.record, c.stats, c.logger = other.record, other.stats, other.logger
+	c.strict, c.capture, c.configs, c.limits = other.strict, other.capture, other.configs, other.limits
+	c.tables = other.tables
+	c.Unlock()
+}
+
+func registerFuncs(conn *sqlite3.SQLiteConn, funcs ...FuncReg) error {
+	for _, fn := range funcs {
+		if err := conn.RegisterFunc(fn.Name, fn.Impl, fn.Pure); err != nil {
+			return fmt.Errorf("failed to register %q: %w", fn.Name, err)
 		}
-		filename = string(values[2].(string))
-		return nil
+		defaultLogger().Debug("registered function", "name", fn.Name)
 	}
-	return filename, connQuery(conn, fn, "PRAGMA database_list")
+	return nil
 }
 
-// Close cleans up the database before closing (checkpoints WAL)
-func Close(db *sql.DB) {
-	if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
-		log.Printf("error executing WAL checkpoint: %v\n", err)
+func registerAggs(conn *sqlite3.SQLiteConn, aggs ...AggReg) error {
+	for _, agg := range aggs {
+		if err := conn.RegisterAggregator(agg.Name, agg.Impl, agg.Pure); err != nil {
+			return fmt.Errorf("failed to register aggregate %q: %w", agg.Name, err)
+		}
 	}
-	if err := db.Close(); err != nil {
-		log.Printf("error closing database: %v\n", err)
+	return nil
+}
+
+// sqlInit registers a driver that prepares each new connection with the query, hook, and funcs
+func sqlInit(driverName, query string, hook Hook, funcs ...FuncReg) error {
+	return initDriver(driverName, &connector{query: query, hook: hook, funcs: funcs})
+}
+
+// initDriver registers a driver that prepares each new connection with c
+// Once registered, a driver's settings can't be changed unless it is reset by ResetDriver
+func initDriver(driverName string, c *connector) error {
+	defaultLogger().Debug("registering driver", "driver", driverName)
+	imu.Lock()
+	defer imu.Unlock()
+
+	if prev, ok := initialized[driverName]; ok {
+		if resets[driverName] {
+			delete(resets, driverName)
+			prev.update(c)
+			return nil
+		}
+		if c.strict {
+			return fmt.Errorf("%w: %q", ErrDriverRegistered, driverName)
+		}
+		if !prev.same(c) {
+			return fmt.Errorf("%w: %q", ErrDriverSettings, driverName)
+		}
+		return nil
+	}
+	initialized[driverName] = c
+	sql.Register(driverName, newDriver(c))
+	return nil
+}
+
+// ResetDriver allows the driver registered by WithDriver(name) to be opened again
+// with different settings. Drivers can't be unregistered, so the new settings also
+// apply to new connections of databases already open with the driver.
+// It is intended for tests
+func ResetDriver(name string) {
+	imu.Lock()
+	if _, ok := initialized[name]; ok {
+		resets[name] = true
 	}
+	imu.Unlock()
 }
 
-// Backup backs up the open database
-func Backup(db *sql.DB, dest string) error {
-	return backup(db, dest, 1024, ioutil.Discard)
+// liteDriver is an sqlite3 driver that keeps its connection settings at hand
+type liteDriver struct {
+	*sqlite3.SQLiteDriver
+	c *connector
 }
 
-func backup(db *sql.DB, dest string, step int, w io.Writer) error {
-	os.Remove(dest)
+// newDriver returns a driver that prepares each new connection with c
+func newDriver(c *connector) *liteDriver {
+	return &liteDriver{SQLiteDriver: &sqlite3.SQLiteDriver{ConnectHook: c.connect}, c: c}
+}
 
-	destDb, err := Open(dest)
+// Open implements driver.Driver, reporting the connection's events
+func (d *liteDriver) Open(dsn string) (driver.Conn, error) {
+	d.c.Lock()
+	events, trace, record, stats := d.c.events, d.c.trace, d.c.record, d.c.stats
+	d.c.Unlock()
+	conn, err := d.SQLiteDriver.Open(dsn)
 	if err != nil {
-		return err
+		if events.OnError != nil {
+			events.OnError(dsn, err)
+		}
+		return nil, err
+	}
+	if events.OnOpen != nil {
+		events.OnOpen(dsn)
+	}
+	if nativeTrace != nil {
+		trace = nil // traced by SQLite itself
+	}
+	if record != nil {
+		trace = teeSink(trace, record)
+	}
+	if stats != nil {
+		trace = teeSink(trace, stats)
 	}
-	defer destDb.Close()
+	sc := conn.(*sqlite3.SQLiteConn)
+	register(sc, ConnInfo{DSN: dsn, File: sc.GetFilename("main")})
+	d.c.Lock()
+	if d.c.open == nil {
+		d.c.open = make(map[*sqlite3.SQLiteConn]bool)
+	}
+	d.c.open[sc] = true
+	d.c.Unlock()
+	lc := &liteConn{SQLiteConn: sc, owner: d.c, dsn: dsn, onClose: events.OnClose, trace: trace}
+	if trace != nil {
+		lc.id = connID(sc)
+	}
+	return lc, nil
+}
 
-	if err = destDb.Ping(); err != nil {
-		return err
+// liteConn is a registered connection that reports when it is closed, and traces its
+// statements when SQLite's tracing is unavailable or they are recorded or measured
+type liteConn struct {
+	*sqlite3.SQLiteConn
+	owner   *connector
+	dsn     string
+	onClose func(dsn string, err error)
+	trace   TraceSink
+	id      uintptr // of the connection in traces
+}
+
+// Close implements driver.Conn
+func (c *liteConn) Close() error {
+	if c.trace != nil {
+		c.trace.Trace(TraceEvent{Kind: TraceClose, Time: time.Now(), Conn: c.id})
+	}
+	unregister(c.SQLiteConn)
+	c.owner.Lock()
+	delete(c.owner.open, c.SQLiteConn)
+	c.owner.Unlock()
+	err := c.SQLiteConn.Close()
+	if c.onClose != nil {
+		c.onClose(c.dsn, err)
+	}
+	return err
+}
+
+// rawConn returns the sqlite3 connection of a driver connection obtained by sql.Conn.Raw
+func rawConn(dc interface{}) *sqlite3.SQLiteConn {
+	if lc, ok := dc.(*liteConn); ok {
+		return lc.SQLiteConn
 	}
+	return dc.(*sqlite3.SQLiteConn)
+}
 
-	from := registered(Filename(db))
-	to := registered(Filename(destDb))
-	bk, err := to.Backup("main", from, "main")
+// WithRawConn calls fn with the sqlite3 connection of one of the connections of db,
+// for features of the driver not exposed by database/sql, e.g. hooks, limits, or backups.
+// The connection is reserved for the duration of the call, and must not be kept after it
+func WithRawConn(db *sql.DB, fn func(*sqlite3.SQLiteConn) error) error {
+	conn, err := db.Conn(context.Background())
 	if err != nil {
 		return err
 	}
+	defer conn.Close()
+	return conn.Raw(func(dc interface{}) error {
+		switch c := dc.(type) {
+		case *liteConn:
+			return fn(c.SQLiteConn)
+		case *sqlite3.SQLiteConn:
+			return fn(c)
+		}
+		return fmt.Errorf("not an sqlite3 connection: %T", dc)
+	})
+}
 
+// dsnConnector opens connections with a driver of its own, rather than one registered by name,
+// so each database opened without WithDriver has its own functions and settings
+type dsnConnector struct {
+	dsn    string
+	driver *liteDriver
+}
+
+// Connect implements the driver.Connector interface
+func (d dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return d.driver.Open(d.dsn)
+}
+
+// Driver implements the driver.Connector interface
+func (d dsnConnector) Driver() driver.Driver {
+	return d.driver
+}
+
+// RegisterFunctions registers funcs on the idle connections of db and on every
+// connection it opens afterwards. Connections in use by other goroutines at the
+// time of the call are not updated, so call it before db is shared
+func RegisterFunctions(db *sql.DB, funcs ...FuncReg) error {
+	d, ok := db.Driver().(*liteDriver)
+	if !ok {
+		return fmt.Errorf("database was not opened by this package")
+	}
+	for _, fn := range funcs {
+		if err := fn.Validate(); err != nil {
+			return err
+		}
+	}
+	c := d.c
+	c.Lock()
+	c.funcs = append(c.funcs[:len(c.funcs):len(c.funcs)], funcs...)
+	c.Unlock()
+
+	// take hold of every idle connection, so each one is updated once
+	ctx := context.Background()
+	idle := db.Stats().Idle
+	conns := make([]*sql.Conn, 0, idle)
 	defer func() {
-		berr := bk.Finish()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < idle; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		conns = append(conns, conn)
+		err = conn.Raw(func(dc interface{}) error {
+			return registerFuncs(rawConn(dc), funcs...)
+		})
 		if err != nil {
-			err = berr
+			return err
+		}
+	}
+	return nil
+}
+
+// WarmUp opens n connections of the pool ahead of use and verifies each one by reading
+// the schema, which waits out the busy timeout if the database is locked. This runs
+// the connection hook, pragmas, and function registrations up front, so their failures
+// are reported at startup rather than by the first queries to need a new connection.
+//
+// Connections beyond the pool's idle limit (see sql.DB.SetMaxIdleConns, 2 by default)
+// are closed once returned, so raise it to keep them all
+func WarmUp(db *sql.DB, n int) error {
+	if max := db.Stats().MaxOpenConnections; max > 0 && n > max {
+		n = max
+	}
+	ctx := context.Background()
+	conns := make([]*sql.Conn, 0, n)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
 		}
 	}()
+	for i := 0; i < n; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return fmt.Errorf("warm up connection %d: %w", i+1, err)
+		}
+		conns = append(conns, conn)
+		var count int
+		if err := conn.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_master").Scan(&count); err != nil {
+			return fmt.Errorf("warm up connection %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
 
-	for {
-		fmt.Fprintf(w, "pagecount: %d remaining: %d\n", bk.PageCount(), bk.Remaining())
-		var done bool
-		done, err = bk.Step(step)
-		if done || err != nil {
-			break
+// AttachedDB is a database of a connection, as listed by PRAGMA database_list
+type AttachedDB struct {
+	Seq  int
+	Name string // the schema name, e.g. "main", "temp", or the name given to ATTACH
+	File string // empty for in-memory and temporary databases
+}
+
+// Databases returns the databases of a connection of db: main, temp if used, and those attached.
+// Each connection of a pool has its own attachments, so use a single connection (e.g. with
+// SetMaxOpenConns(1)) when attaching databases to be listed here
+func Databases(db *sql.DB) ([]AttachedDB, error) {
+	var list []AttachedDB
+	rows, err := db.Query("PRAGMA database_list")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a AttachedDB
+		if err := rows.Scan(&a.Seq, &a.Name, &a.File); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// Filename returns the filename of the main database of db, which is empty if it is in memory
+func Filename(db *sql.DB) (string, error) {
+	var file string
+	err := row(db, []interface{}{&file}, "SELECT file FROM pragma_database_list WHERE name='main'")
+	return file, err
+}
+
+// logName returns the filename of the database, or why it's unknown, for logging
+func logName(db *sql.DB) string {
+	file, err := Filename(db)
+	if err != nil {
+		return fmt.Sprintf("(%v)", err)
+	}
+	return file
+}
+
+// Close cleans up the database before closing (checkpoints WAL), returning the error closing it.
+// Failures to checkpoint are logged, unless the database was opened WithStrict,
+// in which case they are returned (the database is closed regardless)
+func Close(db *sql.DB) error {
+	dropQueryCache(db)
+	forgetReadOnly(db)
+	forgetNextID(db)
+	if isStrict(db) {
+		_, err := Filename(db)
+		if err == nil {
+			_, err = db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+		}
+		if cerr := db.Close(); err == nil {
+			err = cerr
 		}
+		return err
+	}
+	logger, file := loggerOf(db), logName(db)
+	if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		logger.Error("error executing WAL checkpoint", "db", file, "op", "checkpoint", "error", err)
+	}
+	err := db.Close()
+	if err != nil {
+		logger.Error("error closing database", "db", file, "op", "close", "error", err)
 	}
 	return err
 }
@@ -277,134 +705,20 @@ func Pragmas(db *sql.DB, w io.Writer) {
 func CompileOptions(db *sql.DB, w io.Writer) {
 	rows, err := db.Query("PRAGMA compile_options")
 	if err != nil {
-		log.Println("can't get compiled options:", err)
+		loggerOf(db).Error("can't get compiled options", "db", logName(db), "op", "compile_options", "error", err)
 		return
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var option string
 		if err := rows.Scan(&option); err != nil {
-			log.Println("can't scan row:", err)
+			loggerOf(db).Error("can't scan row", "db", logName(db), "op", "compile_options", "error", err)
 			return
 		}
 		fmt.Fprintln(w, option)
 	}
 }
 
-// File emulates ".read FILENAME"
-func File(db *sql.DB, file string, echo bool, w io.Writer) error {
-	out, err := ioutil.ReadFile(file)
-	if err != nil {
-		return err
-	}
-	return Commands(db, string(out), echo, w)
-}
-
-func startsWith(data, sub string) bool {
-	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(data)), strings.ToUpper(sub))
-}
-
-func listTables(db *sql.DB, w io.Writer) error {
-	q := `
-SELECT name FROM sqlite_master
-WHERE type='table'
-ORDER BY name
-`
-	fn := func(_ []string, row []interface{}) {
-		if len(row) > 0 {
-			fmt.Fprintln(w, row[0])
-		}
-	}
-	return query(db, fn, q)
-}
-
-// showRow is a handler for the query func
-func showRow(columns []string, row []interface{}) {
-	if columns != nil {
-		fmt.Println(strings.Join(columns, "\t"))
-	}
-	for i, r := range row {
-		if i > 0 {
-			fmt.Print("\t")
-		}
-		fmt.Print(r)
-	}
-	fmt.Print("\n")
-}
-
-// Commands emulates the client reading a series of commands
-func Commands(db *sql.DB, buffer string, echo bool, w io.Writer) error {
-	if w == nil {
-		w = os.Stdout
-	}
-	// strip comments
-	clean := commentC.ReplaceAll([]byte(buffer), []byte{})
-	clean = commentSQL.ReplaceAll(clean, []byte{})
-
-	lines := strings.Split(string(clean), ";\n")
-	multiline := "" // triggers are multiple lines
-	trigger := false
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		switch {
-		case strings.HasPrefix(line, ".echo "):
-			echo, _ = strconv.ParseBool(line[6:])
-			continue
-		case strings.HasPrefix(line, ".read "):
-			name := strings.TrimSpace(line[6:])
-			if err := File(db, name, echo, w); err != nil {
-				return fmt.Errorf("read file: %s, error: %w", name, err)
-			}
-			continue
-		case strings.HasPrefix(line, ".print "):
-			str := strings.TrimSpace(line[7:])
-			str = strings.Trim(str, `"`)
-			str = strings.Trim(str, "'")
-			fmt.Fprintln(w, str)
-			continue
-		case strings.HasPrefix(line, ".tables"):
-			if err := listTables(db, w); err != nil {
-				return fmt.Errorf("table error: %w", err)
-			}
-			continue
-		case startsWith(line, "CREATE TRIGGER"):
-			multiline = line
-			trigger = true
-			continue
-		case startsWith(line, "END;"):
-			line = multiline + "\n" + line
-			multiline = ""
-			trigger = false
-		case trigger:
-			multiline += "\n" + line // restore our 'split' transaction
-			continue
-		}
-		if len(multiline) > 0 {
-			multiline += "\n" + line // restore our 'split' transaction
-		} else {
-			multiline = line
-		}
-		if strings.Contains(line, ";") {
-			continue
-		}
-		if echo {
-			fmt.Println("CMD> ", multiline)
-		}
-		if startsWith(multiline, "SELECT") {
-			if err := query(db, showRow, multiline); err != nil {
-				return fmt.Errorf("SELECT QUERY: %s FILE: %s ERROR: %w", line, Filename(db), err)
-			}
-		} else if _, err := db.Exec(multiline); err != nil {
-			return fmt.Errorf("EXEC QUERY: %s FILE: %s ERROR: %w", line, Filename(db), err)
-		}
-		multiline = ""
-	}
-	return nil
-}
-
 // connQuery executes a query on a driver connection
 func connQuery(conn *sqlite3.SQLiteConn, fn func([]string, int, []driver.Value) error, query string, args ...driver.Value) error {
 	rows, err := conn.Query(query, args)
@@ -445,11 +759,29 @@ func Version() (string, int, string) {
 
 // Config represents the sqlite configuration options
 type Config struct {
-	fail   bool
-	query  string
-	driver string
-	hook   Hook
-	funcs  []FuncReg
+	fail    bool
+	shared  bool
+	query   string
+	driver  string
+	hook    Hook
+	funcs   []FuncReg
+	aggs    []AggReg
+	windows []WindowReg
+	pragmas []string
+	events  ConnEvents
+	trace   TraceSink
+	record  *Recorder
+	stats   *QueryStats
+	logger  Logger
+	strict  bool
+	tables  bool
+	capture *ChangeCapture
+	configs *ConfigStore
+	limits  map[Limit]int
+
+	fallback   bool
+	pageSize   int
+	autoVacuum *Vacuum
 }
 
 type Optional func(*Config)
@@ -461,6 +793,39 @@ func WithExists(fail bool) Optional {
 	}
 }
 
+// WithShared makes opening a file that is already open with WithShared
+// return the same handle, rather than an independent one. Opening it with
+// settings other than those it was first opened with fails with ErrSharedSettings.
+// The handle is closed once Release has been called for each time it was opened;
+// Close closes it for every user at once, and the next open WithShared opens it anew
+func WithShared(share bool) Optional {
+	return func(c *Config) {
+		c.shared = share
+	}
+}
+
+// ConnEvents are callbacks for the events in the life of a connection,
+// e.g. to log or meter connection churn. Each is optional
+type ConnEvents struct {
+	OnOpen  func(dsn string)            // a connection has been opened and prepared
+	OnClose func(dsn string, err error) // a connection has been closed
+	OnError func(dsn string, err error) // a connection failed to open, or its preparation (hook, query, or functions) failed
+}
+
+// same reports whether the callbacks are the same funcs
+func (e ConnEvents) same(other ConnEvents) bool {
+	return funcID(e.OnOpen) == funcID(other.OnOpen) &&
+		funcID(e.OnClose) == funcID(other.OnClose) &&
+		funcID(e.OnError) == funcID(other.OnError)
+}
+
+// WithConnEvents reports the events of each connection to the given callbacks
+func WithConnEvents(events ConnEvents) Optional {
+	return func(c *Config) {
+		c.events = events
+	}
+}
+
 // WithQuery adds an sql query to execute for each new connection
 func WithQuery(query string) Optional {
 	return func(c *Config) {
@@ -468,6 +833,82 @@ func WithQuery(query string) Optional {
 	}
 }
 
+// WithPragmas sets pragmas (e.g., "journal_mode=WAL") for each new connection,
+// applied before any query given by WithQuery
+func WithPragmas(pragmas ...string) Optional {
+	return func(c *Config) {
+		c.pragmas = append(c.pragmas, pragmas...)
+	}
+}
+
+// WithPageSize sets the page size of a database created by opening it, which must
+// be a power of two from 512 to 65536. The page size of an existing database can
+// only be changed by VACUUM, so opening one with a different page size fails
+func WithPageSize(n int) Optional {
+	return func(c *Config) {
+		c.pageSize = n
+	}
+}
+
+// WithAutoVacuum sets the auto-vacuum mode of a database created by opening it.
+// As with WithPageSize, opening an existing database with a different mode fails
+func WithAutoVacuum(mode Vacuum) Optional {
+	return func(c *Config) {
+		c.autoVacuum = &mode
+	}
+}
+
+// creationPragmas returns the pragmas that only take effect when the database is created.
+// They come before all others, as some (e.g. "journal_mode=WAL") create the database
+func (c *Config) creationPragmas() []string {
+	var pragmas []string
+	if c.pageSize != 0 {
+		pragmas = append(pragmas, fmt.Sprintf("page_size=%d", c.pageSize))
+	}
+	if c.autoVacuum != nil {
+		pragmas = append(pragmas, fmt.Sprintf("auto_vacuum=%d", *c.autoVacuum))
+	}
+	return pragmas
+}
+
+// checkCreation verifies that the database has the settings it was to be created with,
+// as SQLite ignores them for existing databases
+func (c *Config) checkCreation(db *sql.DB) error {
+	if c.pageSize != 0 {
+		size, err := PageSize(db)
+		if err != nil {
+			return err
+		}
+		if size != c.pageSize {
+			return fmt.Errorf("database exists with page size %d, not %d, which requires VACUUM to change", size, c.pageSize)
+		}
+	}
+	if c.autoVacuum != nil {
+		mode, err := AutoVacuum(db)
+		if err != nil {
+			return err
+		}
+		if mode != *c.autoVacuum {
+			return fmt.Errorf("database exists with auto_vacuum %s, not %s, which requires VACUUM to change", mode, *c.autoVacuum)
+		}
+	}
+	return nil
+}
+
+// connQuery returns the query to execute for each new connection
+func (c *Config) connQuery() string {
+	pragmas := append(c.creationPragmas(), c.pragmas...)
+	if len(pragmas) == 0 {
+		return c.query
+	}
+	var sb strings.Builder
+	for _, pragma := range pragmas {
+		fmt.Fprintf(&sb, "PRAGMA %s;\n", pragma)
+	}
+	sb.WriteString(c.query)
+	return sb.String()
+}
+
 // WithHook adds an sql query to execute for each new connection
 func WithHook(hook Hook) Optional {
 	return func(c *Config) {
@@ -475,7 +916,12 @@ func WithHook(hook Hook) Optional {
 	}
 }
 
-//WithDriver sets the driver name used
+// WithDriver registers the database's driver with the given name, so it can be used
+// with sql.Open. Opening another database with the same name but different settings
+// fails with ErrDriverSettings; without WithDriver each database has a driver of its own.
+// Funcs, such as hooks and SQL functions, are compared by their code, so closures of the
+// same func literal that capture different state are not told apart: register those
+// with a name of their own, or reset the driver with ResetDriver, to change them
 func WithDriver(driver string) Optional {
 	return func(c *Config) {
 		c.driver = driver
@@ -489,41 +935,204 @@ func WithFunctions(functions ...FuncReg) Optional {
 	}
 }
 
+// WithAggregates registers custom aggregate functions
+func WithAggregates(aggregates ...AggReg) Optional {
+	return func(c *Config) {
+		c.aggs = append(c.aggs, aggregates...)
+	}
+}
+
 // open returns a db handler for the given file
 func open(file string, config *Config) (*sql.DB, error) {
 	if config == nil {
 		config = &Config{driver: DefaultDriver}
 	}
-	sqlInit(config.driver, config.query, config.hook, config.funcs...)
-	if !strings.Contains(file, ":memory:") {
-		filename := file
-		filename = strings.TrimPrefix(filename, "file:")
-		filename = strings.TrimPrefix(filename, "//")
-		if i := strings.Index(filename, "?"); i > 0 {
-			filename = filename[:i]
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	c := &connector{
+		query:   config.connQuery(),
+		hook:    config.hook,
+		funcs:   config.funcs,
+		aggs:    config.aggs,
+		windows: config.windows,
+		events:  config.events,
+		trace:   config.trace,
+		record:  config.record,
+		stats:   config.stats,
+		logger:  config.logger,
+		strict:  config.strict,
+		tables:  config.tables,
+		capture: config.capture,
+		configs: config.configs,
+		limits:  config.limits,
+	}
+	if config.driver != "" {
+		if err := initDriver(config.driver, c); err != nil {
+			return nil, err
 		}
+	}
+	if strings.Contains(file, ":memory:") {
+		return openDB(file, config, c)
+	}
+	filename := file
+	filename = strings.TrimPrefix(filename, "file:")
+	filename = strings.TrimPrefix(filename, "//")
+	if i := strings.Index(filename, "?"); i > 0 {
+		filename = filename[:i]
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
 
-		// create directory if necessary
-		dirName := path.Dir(filename)
-		if _, err := os.Stat(dirName); os.IsNotExist(err) {
-			if err := os.Mkdir(dirName, 0777); err != nil {
-				return nil, err
+	// concurrent opens of the same file are taken in turn
+	smu.Lock()
+	lock, ok := opening[abs]
+	if !ok {
+		lock = new(sync.Mutex)
+		opening[abs] = lock
+	}
+	smu.Unlock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	if config.shared {
+		smu.Lock()
+		s, ok := shared[abs]
+		smu.Unlock()
+		if ok && s.db.Ping() != nil {
+			// closed by Close rather than Release
+			smu.Lock()
+			delete(shared, abs)
+			smu.Unlock()
+			ok = false
+		}
+		if ok {
+			if !s.same(file, config, c) {
+				return nil, fmt.Errorf("%w: %s", ErrSharedSettings, file)
 			}
+			smu.Lock()
+			s.refs++
+			smu.Unlock()
+			return s.db, nil
 		}
+	}
 
-		if !config.fail {
-			if _, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0666); err != nil {
+	// create directory if necessary
+	if err := os.Mkdir(path.Dir(filename), 0777); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+	writable := true
+	if !config.fail {
+		f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0666)
+		if err != nil {
+			if !config.fallback || !readOnlyFile(filename, err) {
 				return nil, fmt.Errorf("os file: %s, error: %w", file, err)
 			}
-		} else if _, err := os.Stat(filename); os.IsNotExist(err) {
+			writable = false
+		} else {
+			f.Close()
+		}
+	} else if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil, err
+	}
+	var db *sql.DB
+	if writable {
+		db, err = openDB(file, config, c)
+	}
+	if config.fallback && err == nil {
+		db, err = fallbackReadOnly(db, file, config, c)
+	}
+	if err == nil && config.shared {
+		smu.Lock()
+		shared[abs] = &sharedDB{
+			db:       db,
+			refs:     1,
+			params:   dsnParams(file),
+			driver:   config.driver,
+			fallback: config.fallback,
+			conn:     c,
+		}
+		smu.Unlock()
+	}
+	return db, err
+}
+
+// openDB opens the database once its file is in place
+func openDB(file string, config *Config, c *connector) (*sql.DB, error) {
+	var db *sql.DB
+	if config.driver == "" {
+		db = sql.OpenDB(dsnConnector{dsn: file, driver: newDriver(c)})
+	} else {
+		var err error
+		if db, err = sql.Open(config.driver, file); err != nil {
+			return db, fmt.Errorf("sql file: %s, error: %w", file, err)
+		}
+	}
+	if err := db.Ping(); err != nil {
+		return db, err
+	}
+	if err := config.checkCreation(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if config.configs != nil {
+		if err := config.configs.bind(db); err != nil {
+			db.Close()
 			return nil, err
 		}
 	}
-	db, err := sql.Open(config.driver, file)
-	if err != nil {
-		return db, fmt.Errorf("sql file: %s, error: %w", file, err)
+	return db, nil
+}
+
+// sharedDB is a handle opened WithShared, with the settings it was opened with
+type sharedDB struct {
+	db       *sql.DB
+	refs     int
+	params   string
+	driver   string
+	fallback bool
+	conn     *connector
+}
+
+// same reports whether opening file with config and c would give a handle like s
+func (s *sharedDB) same(file string, config *Config, c *connector) bool {
+	return s.params == dsnParams(file) &&
+		s.driver == config.driver &&
+		s.fallback == config.fallback &&
+		s.conn.same(c)
+}
+
+// dsnParams returns the parameters of the file name, if any
+func dsnParams(file string) string {
+	if i := strings.Index(file, "?"); i >= 0 {
+		return file[i+1:]
 	}
-	return db, db.Ping()
+	return ""
+}
+
+// Release closes db, unless it was opened WithShared and
+// there are other users of it that have yet to release it.
+// Handles opened WithShared should be released rather than closed
+func Release(db *sql.DB) error {
+	smu.Lock()
+	for file, s := range shared {
+		if s.db != db {
+			continue
+		}
+		if s.refs--; s.refs > 0 {
+			smu.Unlock()
+			return nil
+		}
+		delete(shared, file)
+		break
+	}
+	smu.Unlock()
+	dropQueryCache(db)
+	forgetReadOnly(db)
+	forgetNextID(db)
+	return db.Close()
 }
 
 // Open returns a db handler for the given file
@@ -566,8 +1175,8 @@ func getColumns(row *sql.Rows) ([]string, error) {
 	return columns, nil
 }
 
-func query(db *sql.DB, fn handler, query string, args ...interface{}) error {
-	rows, err := db.Query(query, args...)
+func query(db Queryer, fn handler, query string, args ...interface{}) error {
+	rows, err := db.QueryContext(context.Background(), query, args...)
 	if err != nil {
 		return err
 	}
@@ -602,9 +1211,7 @@ LOOP:
 	for i, pt := range pts {
 		switch pt := pt.(type) {
 		case float64:
-			if Debug {
-				log.Printf("polygon %d (%T): %v\n", i, pt, pt)
-			}
+			defaultLogger().Debug("polygon", "index", i, "type", fmt.Sprintf("%T", pt), "value", pt)
 			if i%2 != 0 {
 				if i > 2 {
 					sb.WriteByte(',')
@@ -614,9 +1221,7 @@ LOOP:
 				fLat = pt
 			}
 		case int64:
-			if Debug {
-				log.Printf("polygon %d (%T): %v\n", i, pt, pt)
-			}
+			defaultLogger().Debug("polygon", "index", i, "type", fmt.Sprintf("%T", pt), "value", pt)
 			if i%2 != 0 {
 				if i > 2 {
 					sb.WriteByte(',')
@@ -626,9 +1231,7 @@ LOOP:
 				iLat = pt
 			}
 		default:
-			if Debug {
-				log.Printf("polygon %d (%T): %v\n", i, pt, pt)
-			}
+			defaultLogger().Debug("polygon", "index", i, "type", fmt.Sprintf("%T", pt), "value", pt)
 			break LOOP
 		}
 	}
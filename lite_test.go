@@ -1,12 +1,17 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	sqlite3 "github.com/mattn/go-sqlite3"
@@ -120,6 +125,22 @@ func TestSqliteBadHook(t *testing.T) {
 	}
 }
 
+func TestWithPragmas(t *testing.T) {
+	db, err := Open(":memory:", WithDriver("pragmatic"), WithPragmas("cache_size=1234", "user_version=7"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var size, version int
+	if err := row(db, []interface{}{&size, &version}, "select * from pragma_cache_size, pragma_user_version"); err != nil {
+		t.Fatal(err)
+	}
+	if size != 1234 || version != 7 {
+		t.Fatalf("expected pragmas 1234 and 7 but got: %d and %d", size, version)
+	}
+}
+
 func simpleQuery(db *sql.DB) error {
 	var one int
 	dest := []interface{}{&one}
@@ -293,6 +314,39 @@ END;
 	}
 }
 
+func TestCommandsOneLine(t *testing.T) {
+	db := memDB(t)
+	const query = `create table one (s text); insert into one values('a;b'); insert into one values('c')`
+	if err := Commands(db, query, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := row(db, []interface{}{&count}, "select count(*) from one"); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 rows but got: %d", count)
+	}
+}
+
+func TestCommandsCommentsInStrings(t *testing.T) {
+	db := memDB(t)
+	const query = `
+create table urls (url text); -- where to go
+insert into urls values('http://example.com/a--b/*c*/'); /* just one */
+`
+	if err := Commands(db, query, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	var url string
+	if err := row(db, []interface{}{&url}, "select url from urls"); err != nil {
+		t.Fatal(err)
+	}
+	if url != "http://example.com/a--b/*c*/" {
+		t.Fatalf("string was mangled: %q", url)
+	}
+}
+
 func TestDataVersion(t *testing.T) {
 	db := structDb(t)
 
@@ -539,3 +593,569 @@ func queryer(t *testing.T, db *sql.DB, query string, fields ...interface{}) {
 	}
 	rows.Close()
 }
+
+func TestRegisterFunctions(t *testing.T) {
+	db, err := Open(":memory:", WithDriver("sqlite_register"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxIdleConns(4)
+
+	// open several connections, leaving them idle
+	ctx := context.Background()
+	var conns []*sql.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	double := func(i int64) int64 { return i * 2 }
+	if err := RegisterFunctions(db, FuncReg{Name: "double", Impl: double, Pure: true}); err != nil {
+		t.Fatal(err)
+	}
+	// use existing and new connections at once
+	for i := 0; i < 5; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		var got int64
+		if err := conn.QueryRowContext(ctx, "select double(21)").Scan(&got); err != nil {
+			t.Fatalf("connection %d: %v", i, err)
+		}
+		if got != 42 {
+			t.Fatalf("expected 42 but got: %d", got)
+		}
+	}
+
+	other, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if err := RegisterFunctions(other); err == nil {
+		t.Fatal("expected error for database opened elsewhere")
+	}
+}
+
+// ewma is an exponentially weighted moving average
+type ewma struct {
+	avg  float64
+	seen bool
+}
+
+func (e *ewma) Step(x float64) {
+	if !e.seen {
+		e.avg, e.seen = x, true
+		return
+	}
+	e.avg = 0.5*x + 0.5*e.avg
+}
+
+func (e *ewma) Done() float64 {
+	return e.avg
+}
+
+func TestAggregate(t *testing.T) {
+	agg := AggReg{Name: "ewma", Impl: func() *ewma { return new(ewma) }, Pure: true}
+	db, err := Open(":memory:", WithDriver("sqlite_aggregate"), WithAggregates(agg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	const setup = `
+create table samples (i int, x real);
+insert into samples values(1, 2), (2, 4), (3, 8), (4, 16);
+`
+	if _, err := db.Exec(setup); err != nil {
+		t.Fatal(err)
+	}
+	var total float64
+	if err := row(db, []interface{}{&total}, "select ewma(x) from samples"); err != nil {
+		t.Fatal(err)
+	}
+	if total != 10.75 {
+		t.Errorf("expected 10.75 but got: %v", total)
+	}
+}
+
+// mean is a rolling average
+type mean struct {
+	sum   float64
+	count int
+}
+
+func (m *mean) Step(args ...interface{}) {
+	m.sum += args[0].(float64)
+	m.count++
+}
+
+func (m *mean) Inverse(args ...interface{}) {
+	m.sum -= args[0].(float64)
+	m.count--
+}
+
+func (m *mean) Value() interface{} {
+	if m.count == 0 {
+		return nil
+	}
+	return m.sum / float64(m.count)
+}
+
+// TestConnHandle fails if the sqlite3 handles of connections, which window functions,
+// blobs, and traces depend on, can't be had, or if the driver's SQLite is too old
+func TestConnHandle(t *testing.T) {
+	db := fileDB(t, t.TempDir())
+	defer db.Close()
+	var version string
+	if err := row(db, []interface{}{&version}, "select sqlite_version()"); err != nil {
+		t.Fatal(err)
+	}
+	var major, minor int
+	fmt.Sscanf(version, "%d.%d", &major, &minor)
+	if major < 3 || major == 3 && minor < 25 {
+		t.Fatalf("window functions need SQLite 3.25.0 or later, the driver has: %s", version)
+	}
+
+	handles := make(map[uintptr]bool)
+	for i := 0; i < 2; i++ {
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		err = conn.Raw(func(dc interface{}) error {
+			handle, err := connHandle(rawConn(dc))
+			if err != nil {
+				return err
+			}
+			if again, err := connHandle(rawConn(dc)); err != nil || again != handle {
+				return fmt.Errorf("handle changed: %x %x (%v)", handle, again, err)
+			}
+			handles[handle] = true
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("no sqlite3 handle for the connection: %v", err)
+		}
+	}
+	if len(handles) != 2 || handles[0] {
+		t.Errorf("expected a handle of each connection but got: %v", handles)
+	}
+	if _, err := connHandle(nil); err == nil {
+		t.Error("expected error for missing connection")
+	}
+}
+
+func TestWindowFunction(t *testing.T) {
+	win := WindowReg{Name: "mean", NArgs: 1, New: func() WindowFunc { return new(mean) }, Pure: true}
+	db, err := Open(":memory:", WithDriver("sqlite_window"), WithWindows(win))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	const setup = `
+create table samples (i int, x real);
+insert into samples values(1, 2), (2, 4), (3, 8), (4, 16);
+`
+	if _, err := db.Exec(setup); err != nil {
+		t.Fatal(err)
+	}
+
+	var avg float64
+	if err := row(db, []interface{}{&avg}, "select mean(x) from samples"); err != nil {
+		t.Fatal(err)
+	}
+	if avg != 7.5 {
+		t.Errorf("expected 7.5 but got: %v", avg)
+	}
+	var none interface{}
+	if err := row(db, []interface{}{&none}, "select mean(x) from samples where i > 10"); err != nil || none != nil {
+		t.Errorf("expected null but got: %v (%v)", none, err)
+	}
+
+	// rolling over the current and previous row
+	rows, err := db.Query("select mean(x) over (order by i rows between 1 preceding and current row) from samples")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []float64
+	for rows.Next() {
+		var f float64
+		if err := rows.Scan(&f); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, f)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{2, 3, 6, 12}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %v but got: %v", want, got)
+	}
+	if _, err := db.Exec("select mean(1, 2) from samples"); err == nil {
+		t.Error("expected error for wrong number of arguments")
+	}
+}
+
+func TestFunctionNamespaces(t *testing.T) {
+	open := func(answer int64) *sql.DB {
+		fn := FuncReg{Name: "answer", Impl: func() int64 { return answer }, Pure: true}
+		db, err := Open(":memory:", WithFunctions(fn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	first, second := open(1), open(2)
+	defer first.Close()
+	defer second.Close()
+	for want, db := range map[int64]*sql.DB{1: first, 2: second} {
+		var got int64
+		if err := row(db, []interface{}{&got}, "select answer()"); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("expected %d but got: %d", want, got)
+		}
+	}
+}
+
+func TestDriverSettings(t *testing.T) {
+	const name = "sqlite_settings"
+	db, err := Open(":memory:", WithDriver(name), WithPragmas("user_version=1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// the same settings may be used again
+	if db, err = Open(":memory:", WithDriver(name), WithPragmas("user_version=1")); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if _, err = Open(":memory:", WithDriver(name), WithPragmas("user_version=2")); !errors.Is(err, ErrDriverSettings) {
+		t.Fatalf("expected ErrDriverSettings but got: %v", err)
+	}
+
+	// funcs of other code are different settings
+	const funcs = "sqlite_settings_funcs"
+	reg := FuncReg{Name: "answer", Impl: func() int64 { return 42 }, Pure: true}
+	if db, err = Open(":memory:", WithDriver(funcs), WithFunctions(reg)); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if db, err = Open(":memory:", WithDriver(funcs), WithFunctions(reg)); err != nil {
+		t.Fatalf("expected the identical registration to be the same settings: %v", err)
+	}
+	db.Close()
+	other := FuncReg{Name: "answer", Impl: func() int64 { return 43 }, Pure: true}
+	if _, err = Open(":memory:", WithDriver(funcs), WithFunctions(other)); !errors.Is(err, ErrDriverSettings) {
+		t.Fatalf("expected ErrDriverSettings for another func but got: %v", err)
+	}
+	const hooks = "sqlite_settings_hooks"
+	if db, err = Open(":memory:", WithDriver(hooks), WithHook(func(*sqlite3.SQLiteConn) error { return nil })); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if _, err = Open(":memory:", WithDriver(hooks), WithHook(func(*sqlite3.SQLiteConn) error { return errors.New("other") })); !errors.Is(err, ErrDriverSettings) {
+		t.Fatalf("expected ErrDriverSettings for another hook but got: %v", err)
+	}
+
+	ResetDriver(name)
+	if db, err = Open(":memory:", WithDriver(name), WithPragmas("user_version=2")); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	version, err := UserVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Fatalf("expected user version 2 but got: %d", version)
+	}
+}
+
+func TestOpenConcurrent(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "new", "concurrent.db")
+	const n = 10
+	dbs := make([]*sql.DB, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dbs[i], errs[i] = Open(file, WithShared(i%2 == 0))
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("open %d: %v", i, err)
+		}
+	}
+	// shared opens get the same handle, the others their own
+	if dbs[0] != dbs[2] || dbs[1] == dbs[3] || dbs[0] == dbs[1] {
+		t.Fatal("unexpected handles")
+	}
+	for i := 2; i < n; i += 2 {
+		if err := Release(dbs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dbs[0].Ping(); err != nil {
+		t.Fatalf("expected shared handle to remain open: %v", err)
+	}
+	if err := Release(dbs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbs[0].Ping(); err == nil {
+		t.Fatal("expected shared handle to be closed")
+	}
+	for i := 1; i < n; i += 2 {
+		if err := Release(dbs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOpenSharedSettings(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "shared.db")
+	db, err := Open(file, WithShared(true), WithPragmas("cache_size=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(file, WithShared(true), WithPragmas("cache_size=200")); !errors.Is(err, ErrSharedSettings) {
+		t.Fatalf("expected ErrSharedSettings for other pragmas but got: %v", err)
+	}
+	if _, err := Open(file+"?_busy_timeout=100", WithShared(true), WithPragmas("cache_size=100")); !errors.Is(err, ErrSharedSettings) {
+		t.Fatalf("expected ErrSharedSettings for other parameters but got: %v", err)
+	}
+	other, err := Open(file, WithShared(true), WithPragmas("cache_size=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other != db {
+		t.Fatal("expected the shared handle")
+	}
+
+	// Close bypasses the count, and the next open gets a handle of its own
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := Open(file, WithShared(true), WithPragmas("cache_size=200"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == db {
+		t.Fatal("expected a new handle after Close")
+	}
+	if err := again.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	// releasing the closed handle leaves the new one open
+	for _, closed := range []*sql.DB{db, other} {
+		if err := Release(closed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := again.Ping(); err != nil {
+		t.Fatalf("expected new handle to remain open: %v", err)
+	}
+	if err := Release(again); err != nil {
+		t.Fatal(err)
+	}
+	if err := again.Ping(); err == nil {
+		t.Fatal("expected shared handle to be closed")
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	var opened int32
+	hook := func(conn *sqlite3.SQLiteConn) error {
+		if atomic.AddInt32(&opened, 1) > 3 {
+			return errors.New("too many connections")
+		}
+		return nil
+	}
+	db, err := Open(filepath.Join(t.TempDir(), "warm.db"), WithHook(hook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxIdleConns(3)
+	if err := WarmUp(db, 3); err != nil {
+		t.Fatal(err)
+	}
+	if idle := db.Stats().Idle; idle != 3 {
+		t.Fatalf("expected 3 idle connections but got: %d", idle)
+	}
+	// a failing hook is reported by the warm up
+	if err := WarmUp(db, 5); err == nil {
+		t.Fatal("expected hook error")
+	} else {
+		t.Log(err)
+	}
+}
+
+func TestConnEvents(t *testing.T) {
+	var opened, closed, failed int32
+	events := ConnEvents{
+		OnOpen:  func(string) { atomic.AddInt32(&opened, 1) },
+		OnClose: func(string, error) { atomic.AddInt32(&closed, 1) },
+		OnError: func(dsn string, err error) {
+			atomic.AddInt32(&failed, 1)
+			t.Logf("%s: %v", dsn, err)
+		},
+	}
+	db, err := Open(filepath.Join(t.TempDir(), "events.db"), WithConnEvents(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxIdleConns(2)
+	if err := WarmUp(db, 2); err != nil {
+		t.Fatal(err)
+	}
+	// connections are still usable as sqlite3 connections
+	if err := RegisterFunctions(db, FuncReg{Name: "twice", Impl: func(i int64) int64 { return 2 * i }}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if opened != 2 || closed != 2 || failed != 0 {
+		t.Fatalf("expected 2 opened and closed but got: %d, %d (%d failed)", opened, closed, failed)
+	}
+
+	bad := func(*sqlite3.SQLiteConn) error { return errors.New("hook failed") }
+	if _, err := Open(":memory:", WithConnEvents(events), WithHook(bad)); err == nil {
+		t.Fatal("expected hook error")
+	}
+	if failed != 1 {
+		t.Fatalf("expected a failed connection but got: %d", failed)
+	}
+}
+
+func TestDatabases(t *testing.T) {
+	dir, err := ioutil.TempDir("", "databases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	main := filepath.Join(dir, "main.db")
+	db, err := Open(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	other := filepath.Join(dir, "other.db")
+	if _, err := db.Exec("ATTACH DATABASE ? AS other", other); err != nil {
+		t.Fatal(err)
+	}
+	list, err := Databases(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "main" || list[0].File != main || list[1].Name != "other" || list[1].File != other {
+		t.Fatalf("unexpected databases: %+v", list)
+	}
+	if file, err := Filename(db); err != nil || file != main {
+		t.Fatalf("expected filename %q but got %q (%v)", main, file, err)
+	}
+
+	if file, err := Filename(memDB(t)); err != nil || file != "" {
+		t.Fatalf("expected no filename but got %q (%v)", file, err)
+	}
+	closed := memDB(t)
+	closed.Close()
+	if _, err := Filename(closed); err == nil {
+		t.Fatal("expected an error for a closed database")
+	}
+}
+
+func TestWithRawConn(t *testing.T) {
+	db, err := Open(testFile, WithConnEvents(ConnEvents{OnClose: func(string, error) {}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var file string
+	err = WithRawConn(db, func(conn *sqlite3.SQLiteConn) error {
+		file = conn.GetFilename("main")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := filepath.Abs(testFile); file != want {
+		t.Errorf("expected filename %q but got %q", want, file)
+	}
+	if err := WithRawConn(db, func(*sqlite3.SQLiteConn) error { return errors.New("failed") }); err == nil || err.Error() != "failed" {
+		t.Errorf("expected the callback's error but got: %v", err)
+	}
+
+	other, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if err := WithRawConn(other, func(*sqlite3.SQLiteConn) error { return nil }); err != nil {
+		t.Errorf("expected a plain sqlite3 handle to work but got: %v", err)
+	}
+}
+
+func TestCreationSettings(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "created.db")
+	opts := []Optional{WithPageSize(8192), WithAutoVacuum(VacuumIncremental), WithPragmas("journal_mode=WAL")}
+	db, err := Open(file, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("create table cs (x)"); err != nil {
+		t.Fatal(err)
+	}
+	if size, err := PageSize(db); err != nil || size != 8192 {
+		t.Fatalf("expected page size 8192 but got %d (%v)", size, err)
+	}
+	if mode, err := AutoVacuum(db); err != nil || mode != VacuumIncremental {
+		t.Fatalf("expected incremental auto_vacuum but got %s (%v)", mode, err)
+	}
+	db.Close()
+
+	// reopening with the same settings is fine, but they can't be changed
+	db, err = Open(file, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if _, err := Open(file, WithPageSize(4096)); err == nil {
+		t.Fatal("expected an error changing the page size")
+	}
+	if _, err := Open(file, WithAutoVacuum(VacuumNone)); err == nil {
+		t.Fatal("expected an error changing auto_vacuum")
+	}
+	if _, err := Open(":memory:", WithPageSize(1000)); err == nil {
+		t.Fatal("expected an error for an invalid page size")
+	}
+
+	mem, err := Open(":memory:", WithPageSize(16384))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	if size, err := PageSize(mem); err != nil || size != 16384 {
+		t.Fatalf("expected page size 16384 but got %d (%v)", size, err)
+	}
+}
@@ -0,0 +1,85 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// ErrLocked is returned by TryExclusive when another process holds the lock
+var ErrLocked = errors.New("database is locked by another process")
+
+// FileLock is an exclusive lock on a database file, for tools that must be the only
+// process doing maintenance (e.g., vacuum or migration) on it at a time
+type FileLock struct {
+	// Tx is the exclusive transaction holding SQLite's lock on the file,
+	// nil if the file did not exist when it was locked. Changes made with it
+	// are committed by Unlock
+	Tx *sql.Tx
+
+	db   *sql.DB
+	file *os.File
+}
+
+// TryExclusive locks the database file at path without waiting, returning ErrLocked
+// if another process holds it. A lock (flock, or LockFileEx on Windows) is taken on a sidecar
+// file (path + ".lock"), which also covers a database that has yet to be created,
+// and then SQLite's own lock with an exclusive transaction.
+//
+// In WAL mode the exclusive transaction only keeps out other writers,
+// so processes reading the database are unaffected
+func TryExclusive(path string) (*FileLock, error) {
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	l := &FileLock{file: f}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return l, nil
+	}
+
+	db, err := Open(path+"?_busy_timeout=0&_txlock=exclusive", WithExists(true))
+	if err != nil {
+		l.Unlock()
+		return nil, err
+	}
+	l.db = db
+	if l.Tx, err = db.Begin(); err != nil {
+		l.Unlock()
+		var serr sqlite3.Error
+		if errors.As(err, &serr) && (serr.Code == sqlite3.ErrBusy || serr.Code == sqlite3.ErrLocked) {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+	return l, nil
+}
+
+// Unlock commits the lock's transaction and releases the lock
+func (l *FileLock) Unlock() error {
+	var err error
+	if l.Tx != nil {
+		err = l.Tx.Commit()
+		l.Tx = nil
+	}
+	if l.db != nil {
+		if cerr := l.db.Close(); err == nil {
+			err = cerr
+		}
+		l.db = nil
+	}
+	if l.file != nil {
+		if uerr := funlock(l.file); err == nil {
+			err = uerr
+		}
+		l.file.Close()
+		l.file = nil
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTryExclusive(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "locked.db")
+
+	// a file that doesn't exist yet is locked with the advisory lock alone
+	l, err := TryExclusive(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Tx != nil {
+		t.Fatal("expected no transaction for a missing database")
+	}
+	if _, err := TryExclusive(file); err != ErrLocked {
+		t.Fatalf("expected ErrLocked but got: %v", err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("create table maintenance (done int)"); err != nil {
+		t.Fatal(err)
+	}
+	if l, err = TryExclusive(file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Tx.Exec("insert into maintenance values(1)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	var done int
+	if err := row(db, []interface{}{&done}, "select done from maintenance"); err != nil || done != 1 {
+		t.Fatalf("expected committed change but got: %d (%v)", done, err)
+	}
+
+	// another writer holding SQLite's lock keeps it from being taken
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("insert into maintenance values(2)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TryExclusive(file); err != ErrLocked {
+		t.Fatalf("expected ErrLocked but got: %v", err)
+	}
+	tx.Rollback()
+	if l, err = TryExclusive(file); err != nil {
+		t.Fatalf("expected lock to be released after failing: %v", err)
+	}
+	l.Unlock()
+}
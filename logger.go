@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Logger receives the messages of the package, at levels, with context as
+// alternating keys and values. It is satisfied by *slog.Logger
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// StdLogger adapts a log.Logger (the standard logger if nil) to Logger,
+// writing the context as key=value pairs. Debug messages are only written if Debug is set
+func StdLogger(l *log.Logger) Logger {
+	if l == nil {
+		l = log.New(log.Writer(), log.Prefix(), log.Flags())
+	}
+	return stdLogger{l}
+}
+
+type stdLogger struct {
+	*log.Logger
+}
+
+func (l stdLogger) output(level, msg string, args []interface{}) {
+	var b strings.Builder
+	b.WriteString(level)
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			fmt.Fprintf(&b, " %v", args[i])
+			break
+		}
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+	l.Println(b.String())
+}
+
+// Debug implements Logger
+func (l stdLogger) Debug(msg string, args ...interface{}) {
+	if Debug {
+		l.output("DEBUG", msg, args)
+	}
+}
+
+// Info implements Logger
+func (l stdLogger) Info(msg string, args ...interface{}) { l.output("INFO", msg, args) }
+
+// Warn implements Logger
+func (l stdLogger) Warn(msg string, args ...interface{}) { l.output("WARN", msg, args) }
+
+// Error implements Logger
+func (l stdLogger) Error(msg string, args ...interface{}) { l.output("ERROR", msg, args) }
+
+var (
+	lmu           sync.Mutex
+	packageLogger Logger
+)
+
+// SetLogger sets the logger of databases opened without WithLogger,
+// and of messages not about a database. If nil, the standard logger is used
+func SetLogger(logger Logger) {
+	lmu.Lock()
+	packageLogger = logger
+	lmu.Unlock()
+}
+
+// defaultLogger returns the logger set by SetLogger, or the standard logger
+func defaultLogger() Logger {
+	lmu.Lock()
+	defer lmu.Unlock()
+	if packageLogger == nil {
+		return stdLogger{log.New(log.Writer(), log.Prefix(), log.Flags())}
+	}
+	return packageLogger
+}
+
+// WithLogger sends the messages about the database to the logger, rather than the default logger
+func WithLogger(logger Logger) Optional {
+	return func(c *Config) {
+		c.logger = logger
+	}
+}
+
+// loggerOf returns the logger of the database
+func loggerOf(db *sql.DB) Logger {
+	if d, ok := db.Driver().(*liteDriver); ok {
+		d.c.Lock()
+		logger := d.c.logger
+		d.c.Unlock()
+		if logger != nil {
+			return logger
+		}
+	}
+	return defaultLogger()
+}
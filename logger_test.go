@@ -0,0 +1,61 @@
+package sqlite
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testLogger records the messages logged
+type testLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) log(level, msg string, args []interface{}) {
+	l.mu.Lock()
+	l.messages = append(l.messages, fmt.Sprint(level, " ", msg, " ", args))
+	l.mu.Unlock()
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) { l.log("DEBUG", msg, args) }
+func (l *testLogger) Info(msg string, args ...interface{})  { l.log("INFO", msg, args) }
+func (l *testLogger) Warn(msg string, args ...interface{})  { l.log("WARN", msg, args) }
+func (l *testLogger) Error(msg string, args ...interface{}) { l.log("ERROR", msg, args) }
+
+func TestWithLogger(t *testing.T) {
+	logger := &testLogger{}
+	db, err := Open(":memory:", WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	Close(db) // fails, as it's already closed
+	if len(logger.messages) != 1 || !strings.HasPrefix(logger.messages[0], "ERROR error executing WAL checkpoint") ||
+		!strings.Contains(logger.messages[0], "op checkpoint") {
+		t.Fatalf("unexpected messages: %q", logger.messages)
+	}
+
+	pkg := &testLogger{}
+	SetLogger(pkg)
+	defer SetLogger(nil)
+	other := memDB(t)
+	other.Close()
+	Close(other)
+	if len(pkg.messages) != 1 || len(logger.messages) != 1 {
+		t.Fatalf("expected the default logger to be used, but got: %q", pkg.messages)
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := StdLogger(log.New(&buf, "", 0))
+	logger.Warn("something odd", "db", "test.db", "op", "close", "extra")
+	logger.Debug("not shown")
+	if got, want := buf.String(), "WARN something odd db=test.db op=close extra\n"; got != want {
+		t.Errorf("expected %q but got %q", want, got)
+	}
+}
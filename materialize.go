@@ -0,0 +1,73 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// TempTable is the result of a query materialized in a temporary table. Temporary
+// tables are only seen by the connection that creates them, so the table holds on
+// to a connection of its database, which is used for the queries that join it
+type TempTable struct {
+	Name string
+	conn *sql.Conn
+}
+
+// Materialize creates a temporary table of the results of a query, e.g. an expensive
+// subquery that is joined repeatedly. The table is dropped, and its connection
+// returned to the pool, by Close
+func Materialize(db *sql.DB, name, query string, args ...interface{}) (*TempTable, error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	create := fmt.Sprintf("CREATE TEMP TABLE %s AS %s", quoteIdent(name), query)
+	if _, err := conn.ExecContext(ctx, create, args...); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("materialize: %s, error: %w", name, err)
+	}
+	return &TempTable{Name: name, conn: conn}, nil
+}
+
+// Index indexes the table on the given columns
+func (t *TempTable) Index(columns ...string) error {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdent(c)
+	}
+	name := quoteIdent(t.Name + "_" + strings.Join(columns, "_"))
+	_, err := t.Exec(fmt.Sprintf("CREATE INDEX temp.%s ON %s (%s)", name, quoteIdent(t.Name), strings.Join(quoted, ",")))
+	return err
+}
+
+// Conn returns the connection that sees the table
+func (t *TempTable) Conn() *sql.Conn {
+	return t.conn
+}
+
+// Exec runs a statement on the connection that sees the table
+func (t *TempTable) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.conn.ExecContext(context.Background(), query, args...)
+}
+
+// Query runs a query on the connection that sees the table
+func (t *TempTable) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.conn.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRow runs a query returning a single row on the connection that sees the table
+func (t *TempTable) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.conn.QueryRowContext(context.Background(), query, args...)
+}
+
+// Close drops the table and returns its connection to the pool
+func (t *TempTable) Close() error {
+	_, err := t.Exec("DROP TABLE IF EXISTS temp." + quoteIdent(t.Name))
+	if cerr := t.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"testing"
+)
+
+func TestMaterialize(t *testing.T) {
+	db := structDb(t)
+	defer db.Close()
+	tt, err := Materialize(db, "big_kinds", "select name, kind from structs where kind > ?", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tt.Index("kind"); err != nil {
+		t.Fatal(err)
+	}
+	AssertPlan(t, tt.Conn(), "select * from big_kinds where kind = 42", PlanExpectations{UsesIndex: "big_kinds_kind", NoFullScan: true})
+
+	var count int
+	const join = "select count(*) from structs s join big_kinds b on s.name = b.name"
+	if err := tt.QueryRow(join).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 joined rows but got: %d", count)
+	}
+	if err := tt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1) // the connection that had the table
+	if _, err := db.Exec("select * from big_kinds"); err == nil {
+		t.Fatal("expected the table to be dropped")
+	}
+	if _, err := Materialize(db, "bad", "select * from nosuch"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
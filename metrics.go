@@ -0,0 +1,203 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Kinds of metrics
+const (
+	MetricCounter = "counter"
+	MetricGauge   = "gauge"
+)
+
+const metricsSchema = `CREATE TABLE IF NOT EXISTS _metrics (
+	name TEXT PRIMARY KEY,
+	kind TEXT NOT NULL,
+	value REAL NOT NULL,
+	updated INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS _metrics_samples (
+	name TEXT NOT NULL,
+	bucket INTEGER NOT NULL,
+	delta REAL NOT NULL,
+	last REAL NOT NULL,
+	min REAL NOT NULL,
+	max REAL NOT NULL,
+	sum REAL NOT NULL,
+	n INTEGER NOT NULL,
+	PRIMARY KEY (name, bucket)
+) WITHOUT ROWID;`
+
+// Metrics are counters and gauges kept in the database, in the tables _metrics,
+// of their current values, and _metrics_samples, of their changes in each period
+// of the resolution, from which rollups over recent windows are made
+type Metrics struct {
+	Resolution time.Duration // the period of the samples, a minute if not set
+	Keep       time.Duration // how long samples are kept by Prune, a day if not set
+	db         *sql.DB
+}
+
+// Metric is the current value of a counter or gauge
+type Metric struct {
+	Name    string
+	Kind    string
+	Value   float64
+	Updated time.Time
+}
+
+// Rollup summarizes the changes of a metric over a window
+type Rollup struct {
+	Name     string
+	Window   time.Duration
+	Updates  int64   // the increments of a counter, or settings of a gauge
+	Delta    float64 // the total of the increments of a counter
+	Rate     float64 // the increments per second
+	Min, Max float64 // of the values after each update
+	Avg      float64
+	Last     float64 // the current value
+}
+
+// Counters returns the metrics kept in the database, creating their tables if needed
+func Counters(db *sql.DB) (*Metrics, error) {
+	if _, err := db.Exec(metricsSchema); err != nil {
+		return nil, err
+	}
+	return &Metrics{db: db}, nil
+}
+
+// bucket returns the start of the sample period of the time, in unix seconds
+func (m *Metrics) bucket(t time.Time) int64 {
+	res := m.Resolution
+	if res < time.Second {
+		res = time.Minute
+	}
+	secs := int64(res / time.Second)
+	return t.Unix() / secs * secs
+}
+
+// Incr adds delta to the counter, creating it if needed, and returns its new value
+func (m *Metrics) Incr(name string, delta float64) (float64, error) {
+	const upsert = `INSERT INTO _metrics (name, kind, value, updated) VALUES(?, 'counter', ?, ?)
+ON CONFLICT(name) DO UPDATE SET value = value + excluded.value, updated = excluded.updated
+WHERE kind = 'counter'`
+	return m.update(name, MetricCounter, upsert, delta)
+}
+
+// Set sets the gauge, creating it if needed
+func (m *Metrics) Set(name string, value float64) error {
+	const upsert = `INSERT INTO _metrics (name, kind, value, updated) VALUES(?, 'gauge', ?, ?)
+ON CONFLICT(name) DO UPDATE SET value = excluded.value, updated = excluded.updated
+WHERE kind = 'gauge'`
+	_, err := m.update(name, MetricGauge, upsert, value)
+	return err
+}
+
+// update changes the metric by the upsert, and adds the change to its samples, atomically
+func (m *Metrics) update(name, kind, upsert string, v float64) (float64, error) {
+	now := time.Now()
+	tx, err := m.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(upsert, name, v, now.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, fmt.Errorf("metric %q is not a %s", name, kind)
+	}
+	var value float64
+	if err := tx.QueryRow("SELECT value FROM _metrics WHERE name=?", name).Scan(&value); err != nil {
+		return 0, err
+	}
+	delta := v
+	if kind == MetricGauge {
+		delta = 0
+	}
+	const sample = `INSERT INTO _metrics_samples (name, bucket, delta, last, min, max, sum, n) VALUES(?1, ?2, ?3, ?4, ?4, ?4, ?4, 1)
+ON CONFLICT(name, bucket) DO UPDATE SET delta = delta + excluded.delta, last = excluded.last,
+min = min(min, excluded.last), max = max(max, excluded.last), sum = sum + excluded.last, n = n + 1`
+	if _, err := tx.Exec(sample, name, m.bucket(now), delta, value); err != nil {
+		return 0, err
+	}
+	return value, tx.Commit()
+}
+
+// Get returns the metric, with a Kind of "" if there is none by the name
+func (m *Metrics) Get(name string) (Metric, error) {
+	metric := Metric{Name: name}
+	var updated int64
+	err := row(m.db, []interface{}{&metric.Kind, &metric.Value, &updated}, "SELECT kind, value, updated FROM _metrics WHERE name=?", name)
+	if err == sql.ErrNoRows {
+		return metric, nil
+	}
+	metric.Updated = time.Unix(0, updated)
+	return metric, err
+}
+
+// All returns all the metrics, by name
+func (m *Metrics) All() ([]Metric, error) {
+	var metrics []Metric
+	rows, err := m.db.Query("SELECT name, kind, value, updated FROM _metrics ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var metric Metric
+		var updated int64
+		if err := rows.Scan(&metric.Name, &metric.Kind, &metric.Value, &updated); err != nil {
+			return nil, err
+		}
+		metric.Updated = time.Unix(0, updated)
+		metrics = append(metrics, metric)
+	}
+	return metrics, rows.Err()
+}
+
+// Rollup summarizes the updates of the metric in the window before now, which
+// is rounded out to whole periods of the resolution
+func (m *Metrics) Rollup(name string, window time.Duration) (Rollup, error) {
+	r := Rollup{Name: name, Window: window}
+	metric, err := m.Get(name)
+	if err != nil {
+		return r, err
+	}
+	if metric.Kind == "" {
+		return r, fmt.Errorf("no metric named: %q", name)
+	}
+	r.Last = metric.Value
+	var min, max, sum sql.NullFloat64
+	const q = `SELECT coalesce(sum(n), 0), coalesce(sum(delta), 0), min(min), max(max), sum(sum)
+FROM _metrics_samples WHERE name=? AND bucket >= ?`
+	dest := []interface{}{&r.Updates, &r.Delta, &min, &max, &sum}
+	if err := row(m.db, dest, q, name, m.bucket(time.Now().Add(-window))); err != nil {
+		return r, err
+	}
+	r.Min, r.Max = min.Float64, max.Float64
+	if r.Updates > 0 {
+		r.Avg = sum.Float64 / float64(r.Updates)
+	}
+	if window > 0 {
+		r.Rate = r.Delta / window.Seconds()
+	}
+	return r, nil
+}
+
+// Prune deletes the samples older than the metrics keep, returning the number deleted
+func (m *Metrics) Prune() (int64, error) {
+	keep := m.Keep
+	if keep <= 0 {
+		keep = 24 * time.Hour
+	}
+	result, err := m.db.Exec("DELETE FROM _metrics_samples WHERE bucket < ?", m.bucket(time.Now().Add(-keep)))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCounters(t *testing.T) {
+	// a file, as the updates are concurrent, and connections to memory have their own databases
+	db := fileDB(t, t.TempDir())
+	metrics, err := Counters(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the schema is only created once
+	if _, err := Counters(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := metrics.Incr("requests", 1); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if value, err := metrics.Incr("requests", 5); err != nil || value != 15 {
+		t.Errorf("expected 15 requests but got: %v (%v)", value, err)
+	}
+
+	for _, v := range []float64{3, 9, 6} {
+		if err := metrics.Set("queue", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	queue, err := metrics.Get("queue")
+	if err != nil || queue.Kind != MetricGauge || queue.Value != 6 || queue.Updated.IsZero() {
+		t.Errorf("unexpected gauge: %+v (%v)", queue, err)
+	}
+	if missing, err := metrics.Get("missing"); err != nil || missing.Kind != "" {
+		t.Errorf("unexpected metric: %+v (%v)", missing, err)
+	}
+	if _, err := metrics.Incr("queue", 1); err == nil {
+		t.Error("expected error incrementing a gauge")
+	}
+	if err := metrics.Set("requests", 1); err == nil {
+		t.Error("expected error setting a counter")
+	}
+
+	r, err := metrics.Rollup("requests", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Updates != 11 || r.Delta != 15 || r.Last != 15 || r.Rate != 0.25 {
+		t.Errorf("unexpected counter rollup: %+v", r)
+	}
+	r, err = metrics.Rollup("queue", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Updates != 3 || r.Delta != 0 || r.Min != 3 || r.Max != 9 || r.Avg != 6 || r.Last != 6 {
+		t.Errorf("unexpected gauge rollup: %+v", r)
+	}
+	if _, err := metrics.Rollup("missing", time.Minute); err == nil {
+		t.Error("expected error for missing metric")
+	}
+
+	all, err := metrics.All()
+	if err != nil || len(all) != 2 || all[0].Name != "queue" || all[1].Value != 15 {
+		t.Errorf("unexpected metrics: %+v (%v)", all, err)
+	}
+
+	// old samples are pruned, but not the current values
+	if _, err := db.Exec("update _metrics_samples set bucket = bucket - 86400 * 2"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := metrics.Prune(); err != nil || n < 2 {
+		t.Errorf("expected pruned samples but got: %d (%v)", n, err)
+	}
+	if r, err := metrics.Rollup("requests", time.Hour); err != nil || r.Updates != 0 || r.Last != 15 {
+		t.Errorf("unexpected rollup after prune: %+v (%v)", r, err)
+	}
+}
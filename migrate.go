@@ -0,0 +1,248 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MigrationTable records the migrations applied to a database
+const MigrationTable = "schema_migrations"
+
+// migration files are named VERSION_NAME.up.sql and VERSION_NAME.down.sql,
+// with VERSION_NAME.sql accepted as an up migration without a rollback
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+?)(\.up|\.down)?\.sql$`)
+
+// Migration is a versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Missing   bool // applied to the database but not found in the migrations
+}
+
+// LoadMigrationsDir reads the migration files in the directory, sorted by version
+func LoadMigrationsDir(dir string) ([]Migration, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return loadMigrations(names, func(name string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(dir, name))
+	})
+}
+
+// loadMigrations returns the migrations of the files named, read by readFile,
+// sorted by version. Files not named as migrations are ignored
+func loadMigrations(names []string, readFile func(name string) ([]byte, error)) ([]Migration, error) {
+	byVersion := make(map[int64]*Migration)
+	for _, name := range names {
+		m := migrationFile.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration: %s, error: %w", name, err)
+		}
+		body, err := readFile(name)
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration version %d has conflicting names: %q and %q", version, mig.Name, m[2])
+		}
+		if m[3] == ".down" {
+			mig.Down = string(body)
+		} else if mig.Up != "" {
+			return nil, fmt.Errorf("migration version %d has multiple up files", version)
+		} else {
+			mig.Up = string(body)
+		}
+	}
+	list := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration version %d has no up file", mig.Version)
+		}
+		list = append(list, *mig)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+func migrationInit(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + MigrationTable + ` (
+	version integer not null primary key,
+	name text,
+	applied_at timestamp DEFAULT CURRENT_TIMESTAMP
+)`)
+	return err
+}
+
+// MigrationsStatus returns the status of each migration, in version order
+func MigrationsStatus(db *sql.DB, migrations []Migration) ([]MigrationStatus, error) {
+	applied, err := migrationsApplied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []MigrationStatus
+	for _, m := range migrations {
+		s := applied[m.Version]
+		s.Migration = m
+		list = append(list, s)
+		delete(applied, m.Version)
+	}
+	for _, s := range applied {
+		s.Missing = true
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// migrationsApplied returns the applied migrations, without creating the
+// migration table so that status checks and dry runs leave the database untouched
+func migrationsApplied(db *sql.DB) (map[int64]MigrationStatus, error) {
+	applied := make(map[int64]MigrationStatus)
+	var count int
+	const q = "SELECT count(*) FROM sqlite_master WHERE type='table' AND name=?"
+	if err := row(db, []interface{}{&count}, q, MigrationTable); err != nil || count == 0 {
+		return applied, err
+	}
+
+	rows, err := db.Query("SELECT version, name, applied_at FROM " + MigrationTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s MigrationStatus
+		if err := rows.Scan(&s.Version, &s.Name, &s.AppliedAt); err != nil {
+			return nil, err
+		}
+		s.Applied = true
+		applied[s.Version] = s
+	}
+	return applied, rows.Err()
+}
+
+// PlanUp returns the pending migrations up to and including the target version,
+// or all pending migrations if target is zero
+func PlanUp(db *sql.DB, migrations []Migration, target int64) ([]Migration, error) {
+	status, err := MigrationsStatus(db, migrations)
+	if err != nil {
+		return nil, err
+	}
+	var plan []Migration
+	for _, s := range status {
+		if target > 0 && s.Version > target {
+			break
+		}
+		if !s.Applied {
+			plan = append(plan, s.Migration)
+		}
+	}
+	return plan, nil
+}
+
+// PlanDown returns the applied migrations to roll back, most recent first
+func PlanDown(db *sql.DB, migrations []Migration, steps int) ([]Migration, error) {
+	status, err := MigrationsStatus(db, migrations)
+	if err != nil {
+		return nil, err
+	}
+	var plan []Migration
+	for i := len(status) - 1; i >= 0 && len(plan) < steps; i-- {
+		s := status[i]
+		if !s.Applied {
+			continue
+		}
+		if s.Missing {
+			return nil, fmt.Errorf("migration %d_%s was applied but is missing", s.Version, s.Name)
+		}
+		if s.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s has no down file", s.Version, s.Name)
+		}
+		plan = append(plan, s.Migration)
+	}
+	return plan, nil
+}
+
+// MigrateUp applies the pending migrations up to the target version (all if zero),
+// each in its own transaction, returning those applied
+func MigrateUp(db *sql.DB, migrations []Migration, target int64) ([]Migration, error) {
+	if err := migrationInit(db); err != nil {
+		return nil, err
+	}
+	plan, err := PlanUp(db, migrations, target)
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range plan {
+		err := migrationApply(db, m.Up,
+			"INSERT INTO "+MigrationTable+" (version, name) VALUES(?,?)", m.Version, m.Name)
+		if err != nil {
+			return plan[:i], fmt.Errorf("migration %d_%s up: %w", m.Version, m.Name, err)
+		}
+	}
+	return plan, nil
+}
+
+// MigrateDown rolls back the given number of applied migrations, returning those rolled back
+func MigrateDown(db *sql.DB, migrations []Migration, steps int) ([]Migration, error) {
+	plan, err := PlanDown(db, migrations, steps)
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range plan {
+		err := migrationApply(db, m.Down,
+			"DELETE FROM "+MigrationTable+" WHERE version=?", m.Version)
+		if err != nil {
+			return plan[:i], fmt.Errorf("migration %d_%s down: %w", m.Version, m.Name, err)
+		}
+	}
+	return plan, nil
+}
+
+// migrationApply runs the script and records the change in one transaction
+func migrationApply(db *sql.DB, script, record string, args ...interface{}) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err = tx.Exec(record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,22 @@
+package sqlite
+
+import (
+	"io/fs"
+)
+
+// LoadMigrations reads the migration files in the top level of fsys, sorted by version
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return loadMigrations(names, func(name string) ([]byte, error) {
+		return fs.ReadFile(fsys, name)
+	})
+}
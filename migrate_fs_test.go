@@ -0,0 +1,29 @@
+package sqlite
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for name, body := range testMigrations {
+		fsys[name] = &fstest.MapFile{Data: []byte(body)}
+	}
+	list, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMigrations(t, list)
+}
+
+func TestLoadMigrationsMissingUp(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_users.down.sql": {Data: []byte("drop table users;")},
+	}
+	if _, err := LoadMigrations(fsys); err == nil {
+		t.Fatal("expected error for missing up migration")
+	} else {
+		t.Log(err)
+	}
+}
@@ -0,0 +1,148 @@
+package sqlite
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var testMigrations = map[string]string{
+	"001_users.up.sql":   "create table users (id integer primary key, name text);",
+	"001_users.down.sql": "drop table users;",
+	"002_tags.up.sql":    "create table tags (user int, tag text);",
+	"002_tags.down.sql":  "drop table tags;",
+	"003_index.sql":      "create index users_name on users(name);",
+	"README.md":          "not a migration",
+}
+
+// migrationsDir returns a directory of the migration files
+func migrationsDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, body := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// checkMigrations checks the migrations loaded from testMigrations
+func checkMigrations(t *testing.T, list []Migration) {
+	t.Helper()
+	if len(list) != 3 {
+		t.Fatalf("expected 3 migrations but got: %d", len(list))
+	}
+	for i, m := range list {
+		if m.Version != int64(i+1) {
+			t.Errorf("expected version %d but got: %d", i+1, m.Version)
+		}
+	}
+	if list[2].Name != "index" || list[2].Down != "" {
+		t.Errorf("bad migration: %+v", list[2])
+	}
+}
+
+func TestLoadMigrationsDir(t *testing.T) {
+	list, err := LoadMigrationsDir(migrationsDir(t, testMigrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMigrations(t, list)
+
+	missing := migrationsDir(t, map[string]string{"001_users.down.sql": "drop table users;"})
+	if _, err := LoadMigrationsDir(missing); err == nil {
+		t.Fatal("expected error for missing up migration")
+	} else {
+		t.Log(err)
+	}
+	if _, err := LoadMigrationsDir(filepath.Join(missing, "nosuch")); err == nil {
+		t.Fatal("expected error for missing directory")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+
+	list, err := LoadMigrationsDir(migrationsDir(t, testMigrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := MigrateUp(db, list, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 {
+		t.Fatalf("expected 2 applied but got: %d", len(applied))
+	}
+	if _, err := db.Exec("insert into tags (user, tag) values(1, 'admin')"); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := MigrationsStatus(db, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status[0].Applied || !status[1].Applied || status[2].Applied {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	if applied, err = MigrateUp(db, list, 0); err != nil {
+		t.Fatal(err)
+	} else if len(applied) != 1 || applied[0].Version != 3 {
+		t.Fatalf("expected migration 3 to be applied but got: %+v", applied)
+	}
+
+	// the latest migration has no rollback
+	if _, err := MigrateDown(db, list, 1); err == nil {
+		t.Fatal("expected error rolling back migration without down file")
+	} else {
+		t.Log(err)
+	}
+}
+
+func TestMigrateDown(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+
+	list, err := LoadMigrationsDir(migrationsDir(t, testMigrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	list = list[:2]
+	if _, err := MigrateUp(db, list, 0); err != nil {
+		t.Fatal(err)
+	}
+	rolled, err := MigrateDown(db, list, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rolled) != 2 || rolled[0].Version != 2 {
+		t.Fatalf("unexpected rollback: %+v", rolled)
+	}
+	if pending, err := PlanUp(db, list, 0); err != nil {
+		t.Fatal(err)
+	} else if len(pending) != 2 {
+		t.Fatalf("expected 2 pending but got: %d", len(pending))
+	}
+}
+
+func TestMigrateBadSQL(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+
+	list := []Migration{
+		{Version: 1, Name: "good", Up: "create table a (id int);"},
+		{Version: 2, Name: "bad", Up: queryBad},
+	}
+	applied, err := MigrateUp(db, list, 0)
+	if err == nil {
+		t.Fatal("expected error for bad migration")
+	}
+	t.Log(err)
+	if len(applied) != 1 {
+		t.Fatalf("expected 1 applied but got: %d", len(applied))
+	}
+}
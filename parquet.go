@@ -0,0 +1,396 @@
+package sqlite
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// parquet physical types, converted types, and encodings, as in parquet.thrift
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMicros = 10
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+// parquetMagic begins and ends a parquet file
+var parquetMagic = []byte("PAR1")
+
+// parquetColumn is a column of the results, with its values, nil for NULL
+type parquetColumn struct {
+	name      string
+	values    []interface{}
+	kind      int // physical type
+	converted int // converted type, -1 for none
+}
+
+// ExportParquet writes the results of the query as a parquet file, of one row group of
+// uncompressed, PLAIN encoded, optional columns. The results are held in memory until
+// they are written, as the type of a column is that of all its values: INT64 for
+// integers, DOUBLE for numbers, INT64 timestamps for times, byte arrays for blobs,
+// and UTF-8 strings otherwise
+func ExportParquet(db *sql.DB, w io.Writer, query string, args ...interface{}) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	names, err := getColumns(rows)
+	if err != nil {
+		return err
+	}
+	columns := make([]*parquetColumn, len(names))
+	for i, name := range names {
+		columns[i] = &parquetColumn{name: name}
+	}
+	dest := make([]interface{}, len(names))
+	ptrs := make([]interface{}, len(names))
+	for i := range dest {
+		ptrs[i] = &dest[i]
+	}
+	var numRows int64
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		for i, value := range dest {
+			if b, ok := value.([]byte); ok {
+				// the buffer is reused by the next row
+				value = append([]byte(nil), b...)
+			}
+			columns[i].values = append(columns[i].values, value)
+		}
+		numRows++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	pw := &parquetWriter{w: w}
+	pw.write(parquetMagic)
+	chunks := make([]parquetChunk, len(columns))
+	for i, col := range columns {
+		col.infer()
+		chunks[i] = pw.column(col)
+	}
+	if pw.err != nil {
+		return pw.err
+	}
+	footer := parquetFooter(columns, chunks, numRows)
+	pw.write(footer)
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(footer)))
+	pw.write(size[:])
+	pw.write(parquetMagic)
+	return pw.err
+}
+
+// ExportTableParquet writes the contents of the table as parquet, optionally filtered by the where clause
+func ExportTableParquet(db *sql.DB, w io.Writer, table, where string) error {
+	clause, args := whereClause("", W().Raw(where))
+	return ExportParquet(db, w, "SELECT * FROM "+quoteIdent(table)+clause, args...)
+}
+
+// infer sets the parquet type of the column from its values
+func (c *parquetColumn) infer() {
+	ints, floats, times, blobs, all := 0, 0, 0, 0, 0
+	for _, value := range c.values {
+		switch value.(type) {
+		case nil:
+			continue
+		case int64, bool:
+			ints++
+		case float64:
+			floats++
+		case time.Time:
+			times++
+		case []byte:
+			blobs++
+		}
+		all++
+	}
+	c.kind, c.converted = parquetByteArray, parquetUTF8
+	switch {
+	case all == 0:
+	case ints == all:
+		c.kind, c.converted = parquetInt64, -1
+	case ints+floats == all:
+		c.kind, c.converted = parquetDouble, -1
+	case times == all:
+		c.kind, c.converted = parquetInt64, parquetTimestampMicros
+	case blobs == all:
+		c.kind, c.converted = parquetByteArray, -1
+	}
+}
+
+// parquetChunk is where a column was written
+type parquetChunk struct {
+	offset int64 // of its page
+	size   int64 // of its page, with the page header
+}
+
+// parquetWriter writes to w, keeping the offset and the first error
+type parquetWriter struct {
+	w      io.Writer
+	offset int64
+	err    error
+}
+
+func (pw *parquetWriter) write(b []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	pw.err = err
+}
+
+// column writes the column as a single data page
+func (pw *parquetWriter) column(c *parquetColumn) parquetChunk {
+	// definition levels: 1 for values, 0 for NULL, as runs of the RLE hybrid encoding
+	var levels, data bytes.Buffer
+	for i := 0; i < len(c.values); {
+		defined := c.values[i] != nil
+		j := i + 1
+		for j < len(c.values) && (c.values[j] != nil) == defined {
+			j++
+		}
+		writeUvarint(&levels, uint64(j-i)<<1)
+		if defined {
+			levels.WriteByte(1)
+		} else {
+			levels.WriteByte(0)
+		}
+		i = j
+	}
+	binary.Write(&data, binary.LittleEndian, uint32(levels.Len()))
+	data.Write(levels.Bytes())
+
+	var b8 [8]byte
+	for _, value := range c.values {
+		if value == nil {
+			continue
+		}
+		switch c.kind {
+		case parquetInt64:
+			var n int64
+			switch value := value.(type) {
+			case int64:
+				n = value
+			case bool:
+				if value {
+					n = 1
+				}
+			case time.Time:
+				n = value.UnixNano() / int64(time.Microsecond)
+			}
+			binary.LittleEndian.PutUint64(b8[:], uint64(n))
+			data.Write(b8[:])
+		case parquetDouble:
+			f, ok := value.(float64)
+			if !ok {
+				f = float64(parquetInt(value))
+			}
+			binary.LittleEndian.PutUint64(b8[:], math.Float64bits(f))
+			data.Write(b8[:])
+		default:
+			var text []byte
+			switch value := value.(type) {
+			case []byte:
+				text = value
+			case string:
+				text = []byte(value)
+			case time.Time:
+				text = []byte(value.Format(time.RFC3339Nano))
+			default:
+				text = []byte(fmt.Sprint(value))
+			}
+			binary.Write(&data, binary.LittleEndian, uint32(len(text)))
+			data.Write(text)
+		}
+	}
+
+	var header thriftWriter
+	header.i32(1, 0) // DATA_PAGE
+	header.i32(2, int32(data.Len()))
+	header.i32(3, int32(data.Len()))
+	header.structBegin(5)
+	header.i32(1, int32(len(c.values)))
+	header.i32(2, parquetPlain)
+	header.i32(3, parquetRLE)
+	header.i32(4, parquetRLE)
+	header.structEnd()
+	header.stop()
+
+	chunk := parquetChunk{offset: pw.offset}
+	pw.write(header.Bytes())
+	pw.write(data.Bytes())
+	chunk.size = pw.offset - chunk.offset
+	return chunk
+}
+
+// parquetInt returns the integer value, for a column of numbers
+func parquetInt(value interface{}) int64 {
+	switch value := value.(type) {
+	case int64:
+		return value
+	case bool:
+		if value {
+			return 1
+		}
+	}
+	return 0
+}
+
+// parquetFooter returns the file metadata of the columns written as the chunks
+func parquetFooter(columns []*parquetColumn, chunks []parquetChunk, numRows int64) []byte {
+	var meta thriftWriter
+	meta.i32(1, 1) // version
+	meta.listBegin(2, thriftStruct, len(columns)+1)
+	meta.elemBegin()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(columns)))
+	meta.elemEnd()
+	for _, c := range columns {
+		meta.elemBegin()
+		meta.i32(1, int32(c.kind))
+		meta.i32(3, 1) // OPTIONAL
+		meta.binary(4, c.name)
+		if c.converted >= 0 {
+			meta.i32(6, int32(c.converted))
+		}
+		meta.elemEnd()
+	}
+	meta.i64(3, numRows)
+	meta.listBegin(4, thriftStruct, 1)
+	meta.elemBegin()
+	meta.listBegin(1, thriftStruct, len(columns))
+	var total int64
+	for i, c := range columns {
+		chunk := chunks[i]
+		total += chunk.size
+		meta.elemBegin()
+		meta.i64(2, chunk.offset)
+		meta.structBegin(3)
+		meta.i32(1, int32(c.kind))
+		meta.listBegin(2, thriftI32, 2)
+		meta.zigzag(parquetPlain)
+		meta.zigzag(parquetRLE)
+		meta.listBegin(3, thriftBinary, 1)
+		meta.bytes(c.name)
+		meta.i32(4, 0) // UNCOMPRESSED
+		meta.i64(5, int64(len(c.values)))
+		meta.i64(6, chunk.size)
+		meta.i64(7, chunk.size)
+		meta.i64(9, chunk.offset)
+		meta.structEnd()
+		meta.elemEnd()
+	}
+	meta.i64(2, total)
+	meta.i64(3, numRows)
+	meta.elemEnd()
+	meta.binary(6, "github.com/paulstuart/sqlite")
+	meta.stop()
+	return meta.Bytes()
+}
+
+// thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the structs of the parquet metadata in the thrift compact protocol.
+// Fields are written in the order of their ids, and structs within lists are begun
+// and ended by elemBegin and elemEnd
+type thriftWriter struct {
+	bytes.Buffer
+	last  int16   // id of the last field of the struct being written
+	outer []int16 // of the structs containing it
+}
+
+func (t *thriftWriter) field(id int16, kind byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		t.WriteByte(kind)
+		t.zigzag(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) zigzag(n int64) {
+	writeUvarint(&t.Buffer, uint64(n<<1^n>>63))
+}
+
+func (t *thriftWriter) bytes(s string) {
+	writeUvarint(&t.Buffer, uint64(len(s)))
+	t.WriteString(s)
+}
+
+func (t *thriftWriter) i32(id int16, n int32) {
+	t.field(id, thriftI32)
+	t.zigzag(int64(n))
+}
+
+func (t *thriftWriter) i64(id int16, n int64) {
+	t.field(id, thriftI64)
+	t.zigzag(n)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.bytes(s)
+}
+
+func (t *thriftWriter) listBegin(id int16, kind byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.WriteByte(byte(size)<<4 | kind)
+	} else {
+		t.WriteByte(0xF0 | kind)
+		writeUvarint(&t.Buffer, uint64(size))
+	}
+}
+
+func (t *thriftWriter) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.elemBegin()
+}
+
+func (t *thriftWriter) structEnd() {
+	t.elemEnd()
+}
+
+func (t *thriftWriter) elemBegin() {
+	t.outer = append(t.outer, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) elemEnd() {
+	t.stop()
+	t.last = t.outer[len(t.outer)-1]
+	t.outer = t.outer[:len(t.outer)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.WriteByte(0)
+}
+
+func writeUvarint(b *bytes.Buffer, n uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	b.Write(buf[:binary.PutUvarint(buf[:], n)])
+}
@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestExportParquet(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	const schema = `
+create table readings (id integer, value real, note text, raw blob);
+insert into readings values (1, 1.5, 'one', x'01'), (2, null, null, null), (3, 3, 'three', x'0203');
+`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := ExportTableParquet(db, &buf, "readings", ""); err != nil {
+		t.Fatal(err)
+	}
+	file := buf.Bytes()
+	if !bytes.HasPrefix(file, parquetMagic) || !bytes.HasSuffix(file, parquetMagic) {
+		t.Fatalf("missing magic: %q", file)
+	}
+	size := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	if size <= 0 || size > len(file)-12 {
+		t.Fatalf("bad footer size: %d", size)
+	}
+	footer := file[len(file)-8-size : len(file)-8]
+	for _, name := range []string{"schema", "id", "value", "note", "raw"} {
+		if !bytes.Contains(footer, []byte(name)) {
+			t.Errorf("missing column %q in footer", name)
+		}
+	}
+	// the values of the integer column follow their definition levels, a run of 3
+	ids := []byte{2, 0, 0, 0, 3 << 1, 1}
+	for _, id := range []uint64{1, 2, 3} {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], id)
+		ids = append(ids, b[:]...)
+	}
+	if !bytes.Contains(file, ids) {
+		t.Errorf("missing integer values in: %q", file)
+	}
+	// the text column is of the strings that aren't NULL, after runs of 1, 1, and 1
+	if !bytes.Contains(file, []byte("\x06\x00\x00\x00\x02\x01\x02\x00\x02\x01\x03\x00\x00\x00one\x05\x00\x00\x00three")) {
+		t.Errorf("missing text values in: %q", file)
+	}
+
+	if err := ExportParquet(db, failWriter{}, "select * from readings"); err == nil {
+		t.Error("expected error from writer")
+	}
+	if err := ExportParquet(db, &bytes.Buffer{}, "select * from nosuch"); err == nil {
+		t.Error("expected error for missing table")
+	}
+}
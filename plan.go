@@ -0,0 +1,96 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PlanStep is a step of a query plan, as reported by EXPLAIN QUERY PLAN
+type PlanStep struct {
+	ID     int
+	Parent int
+	Detail string // e.g. "SEARCH TABLE t USING INDEX idx_t_a (a=?)"
+}
+
+// QueryPlan returns the plan SQLite has chosen for the query
+func QueryPlan(db Queryer, query string, args ...interface{}) ([]PlanStep, error) {
+	rows, err := db.QueryContext(context.Background(), "EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var plan []PlanStep
+	for rows.Next() {
+		var step PlanStep
+		var notUsed int
+		if err := rows.Scan(&step.ID, &step.Parent, &notUsed, &step.Detail); err != nil {
+			return nil, err
+		}
+		plan = append(plan, step)
+	}
+	return plan, rows.Err()
+}
+
+// PlanExpectations are the properties expected of a query plan
+type PlanExpectations struct {
+	UsesIndex  string // the plan uses the named index
+	NoFullScan bool   // no table is scanned without an index
+}
+
+// fullScan matches the steps scanning a table in its entirety, which differs by version
+// of SQLite: "SCAN TABLE t" until 3.36, "SCAN t" since
+var fullScan = regexp.MustCompile(`^SCAN (?:TABLE )?[^ ]+(?: AS [^ ]+)?$`)
+
+// CheckPlan returns an error if the plan of the query doesn't meet the expectations
+func CheckPlan(db Queryer, query string, expect PlanExpectations, args ...interface{}) error {
+	plan, err := QueryPlan(db, query, args...)
+	if err != nil {
+		return err
+	}
+	var details []string
+	for _, step := range plan {
+		details = append(details, step.Detail)
+	}
+	var problems []string
+	if expect.UsesIndex != "" {
+		uses := regexp.MustCompile(`\bINDEX ` + regexp.QuoteMeta(expect.UsesIndex) + `\b`)
+		found := false
+		for _, d := range details {
+			if uses.MatchString(d) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			problems = append(problems, "does not use index "+expect.UsesIndex)
+		}
+	}
+	if expect.NoFullScan {
+		for _, d := range details {
+			if fullScan.MatchString(d) {
+				problems = append(problems, "full scan: "+d)
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("query plan %s (plan: %s)", strings.Join(problems, ", "), strings.Join(details, "; "))
+	}
+	return nil
+}
+
+// TestingT is the part of testing.TB used by AssertPlan
+type TestingT interface {
+	Helper()
+	Fatalf(format string, args ...interface{})
+}
+
+// AssertPlan fails the test if the plan of the query doesn't meet the expectations,
+// so a schema or query change that degrades the plan, e.g. to a table scan, is caught
+func AssertPlan(t TestingT, db Queryer, query string, expect PlanExpectations, args ...interface{}) {
+	t.Helper()
+	if err := CheckPlan(db, query, expect, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeT records the failure of an assertion
+type fakeT struct {
+	failed string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Fatalf(format string, args ...interface{}) {
+	t.failed = fmt.Sprintf(format, args...)
+}
+
+func TestAssertPlan(t *testing.T) {
+	db := memDB(t)
+	for _, stmt := range []string{
+		"create table tp (id integer primary key, a text, b int)",
+		"create index idx_tp_a on tp(a)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plan, err := QueryPlan(db, "select * from tp where a=?", "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 1 || !strings.Contains(plan[0].Detail, "idx_tp_a") {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+
+	AssertPlan(t, db, "select * from tp where a=?", PlanExpectations{UsesIndex: "idx_tp_a", NoFullScan: true}, "x")
+	AssertPlan(t, db, "select * from tp where id=1", PlanExpectations{NoFullScan: true})
+
+	ft := &fakeT{}
+	AssertPlan(ft, db, "select * from tp where b=1", PlanExpectations{NoFullScan: true})
+	if !strings.Contains(ft.failed, "full scan") {
+		t.Errorf("expected a full scan failure but got: %q", ft.failed)
+	}
+	ft = &fakeT{}
+	AssertPlan(ft, db, "select * from tp where id=1", PlanExpectations{UsesIndex: "idx_tp_a"})
+	if !strings.Contains(ft.failed, "does not use index idx_tp_a") {
+		t.Errorf("expected an index failure but got: %q", ft.failed)
+	}
+	ft = &fakeT{}
+	AssertPlan(ft, db, "select * from nosuch", PlanExpectations{})
+	if !strings.Contains(ft.failed, "no such table") {
+		t.Errorf("expected a query error but got: %q", ft.failed)
+	}
+}
@@ -0,0 +1,156 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+//go:generate go run ./internal/pragmagen -o pragma_gen.go
+
+// Journal is the value of "PRAGMA journal_mode"
+type Journal string
+
+// Journal modes
+const (
+	JournalDelete   Journal = "delete"
+	JournalTruncate Journal = "truncate"
+	JournalPersist  Journal = "persist"
+	JournalMemory   Journal = "memory"
+	JournalWAL      Journal = "wal"
+	JournalOff      Journal = "off"
+)
+
+// Locking is the value of "PRAGMA locking_mode"
+type Locking string
+
+// Locking modes
+const (
+	LockingNormal    Locking = "normal"
+	LockingExclusive Locking = "exclusive"
+)
+
+// Sync is the value of "PRAGMA synchronous"
+type Sync int
+
+// Synchronous settings
+const (
+	SyncOff Sync = iota
+	SyncNormal
+	SyncFull
+	SyncExtra
+)
+
+func (s Sync) String() string {
+	return enumString(int(s), "off", "normal", "full", "extra")
+}
+
+// TempStorage is the value of "PRAGMA temp_store"
+type TempStorage int
+
+// Temporary storage locations
+const (
+	TempStorageDefault TempStorage = iota
+	TempStorageFile
+	TempStorageMemory
+)
+
+func (t TempStorage) String() string {
+	return enumString(int(t), "default", "file", "memory")
+}
+
+// Vacuum is the value of "PRAGMA auto_vacuum"
+type Vacuum int
+
+// Auto-vacuum settings
+const (
+	VacuumNone Vacuum = iota
+	VacuumFull
+	VacuumIncremental
+)
+
+func (v Vacuum) String() string {
+	return enumString(int(v), "none", "full", "incremental")
+}
+
+func enumString(i int, names ...string) string {
+	if i < 0 || i >= len(names) {
+		return "unknown"
+	}
+	return names[i]
+}
+
+// volatilePragmas reflect the state of a database rather than its configuration,
+// so they are ignored by ComparePragmas
+var volatilePragmas = map[string]bool{
+	"data_version":   true,
+	"freelist_count": true,
+	"page_count":     true,
+	"schema_version": true,
+}
+
+// PragmaValues maps pragma names to their values
+type PragmaValues map[string]string
+
+// PragmaChange is a pragma whose value differs between two snapshots
+type PragmaChange struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// PragmaSnapshot returns the values of all relevant pragmas (see Pragmas).
+// Pragmas unsupported by the library are omitted, and compile options are comma separated
+func PragmaSnapshot(db *sql.DB) (PragmaValues, error) {
+	values := make(PragmaValues)
+	for _, pragma := range pragmas {
+		var list []string
+		fn := func(_ []string, row []interface{}) {
+			if len(row) > 0 {
+				list = append(list, fmt.Sprint(row[0]))
+			}
+		}
+		if err := query(db, fn, "PRAGMA "+pragma); err != nil {
+			return nil, fmt.Errorf("pragma %s: %w", pragma, err)
+		}
+		if len(list) > 0 {
+			values[pragma] = strings.Join(list, ",")
+		}
+	}
+	return values, nil
+}
+
+// ComparePragmas returns the pragmas that differ between snapshots a and b,
+// ignoring those that reflect the state of the database (e.g., page_count)
+func ComparePragmas(a, b PragmaValues) []PragmaChange {
+	names := make(map[string]struct{})
+	for name := range a {
+		names[name] = struct{}{}
+	}
+	for name := range b {
+		names[name] = struct{}{}
+	}
+	var changes []PragmaChange
+	for name := range names {
+		if volatilePragmas[name] || a[name] == b[name] {
+			continue
+		}
+		changes = append(changes, PragmaChange{Name: name, From: a[name], To: b[name]})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// WritePragmas writes a snapshot of db's pragmas to w as JSON
+func WritePragmas(db *sql.DB, w io.Writer) error {
+	values, err := PragmaSnapshot(db)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(values)
+}
@@ -0,0 +1,197 @@
+// Code generated by pragmagen; DO NOT EDIT.
+
+package sqlite
+
+import "database/sql"
+
+// ApplicationID returns the value of "PRAGMA application_id"
+func ApplicationID(db *sql.DB) (int, error) {
+	var v int
+	return v, row(db, []interface{}{&v}, "PRAGMA application_id")
+}
+
+// AutoVacuum returns the value of "PRAGMA auto_vacuum"
+func AutoVacuum(db *sql.DB) (Vacuum, error) {
+	var v Vacuum
+	return v, row(db, []interface{}{&v}, "PRAGMA auto_vacuum")
+}
+
+// AutomaticIndex returns the value of "PRAGMA automatic_index"
+func AutomaticIndex(db *sql.DB) (bool, error) {
+	var v bool
+	return v, row(db, []interface{}{&v}, "PRAGMA automatic_index")
+}
+
+// BusyTimeout returns the value of "PRAGMA busy_timeout"
+func BusyTimeout(db *sql.DB) (int, error) {
+	var v int
+	return v, row(db, []interface{}{&v}, "PRAGMA busy_timeout")
+}
+
+// CacheSize returns the value of "PRAGMA cache_size"
+func CacheSize(db *sql.DB) (int, error) {
+	var v int
+	return v, row(db, []interface{}{&v}, "PRAGMA cache_size")
+}
+
+// CacheSpill returns the value of "PRAGMA cache_spill"
+func CacheSpill(db *sql.DB) (int, error) {
+	var v int
+	return v, row(db, []interface{}{&v}, "PRAGMA cache_spill")
+}
+
+// CellSizeCheck returns the value of "PRAGMA cell_size_check"
+func CellSizeCheck(db *sql.DB) (bool, error) {
+	var v bool
+	return v, row(db, []interface{}{&v}, "PRAGMA cell_size_check")
+}
+
+// CheckpointFullfsync returns the value of "PRAGMA checkpoint_fullfsync"
+func CheckpointFullfsync(db *sql.DB) (bool, error) {
+	var v bool
+	return v, row(db, []interface{}{&v}, "PRAGMA checkpoint_fullfsync")
+}
+
+// DeferForeignKeys returns the value of "PRAGMA defer_foreign_keys"
+func DeferForeignKeys(db *sql.DB) (bool, error) {
+	var v bool
+	return v, row(db, []interface{}{&v}, "PRAGMA defer_foreign_keys")
+}
+
+// Encoding returns the value of "PRAGMA encoding"
+func Encoding(db *sql.DB) (string, error) {
+	var v string
+	return v, row(db, []interface{}{&v}, "PRAGMA encoding")
+}
+
+// ForeignKeys returns the value of "PRAGMA foreign_keys"
+func ForeignKeys(db *sql.DB) (bool, error) {
+	var v bool
+	return v, row(db, []interface{}{&v}, "PRAGMA foreign_keys")
+}
+
+// FreelistCount returns the value of "PRAGMA freelist_count"
+func FreelistCount(db *sql.DB) (int64, error) {
+	var v int64
+	return v, row(db, []interface{}{&v}, "PRAGMA freelist_count")
+}
+
+// Fullfsync returns the value of "PRAGMA fullfsync"
+func Fullfsync(db *sql.DB) (bool, error) {
+	var v bool
+	return v, row(db, []interface{}{&v}, "PRAGMA fullfsync")
+}
+
+// JournalMode returns the value of "PRAGMA journal_mode"
+func JournalMode(db *sql.DB) (Journal, error) {
+	var v Journal
+	return v, row(db, []interface{}{&v}, "PRAGMA journal_mode")
+}
+
+// JournalSizeLimit returns the value of "PRAGMA journal_size_limit"
+func JournalSizeLimit(db *sql.DB) (int64, error) {
+	var v int64
+	return v, row(db, []interface{}{&v}, "PRAGMA journal_size_limit")
+}
+
+// LockingMode returns the value of "PRAGMA locking_mode"
+func LockingMode(db *sql.DB) (Locking, error) {
+	var v Locking
+	return v, row(db, []interface{}{&v}, "PRAGMA locking_mode")
+}
+
+// MaxPageCount returns the value of "PRAGMA max_page_count"
+func MaxPageCount(db *sql.DB) (int64, error) {
+	var v int64
+	return v, row(db, []interface{}{&v}, "PRAGMA max_page_count")
+}
+
+// MmapSize returns the value of "PRAGMA mmap_size"
+func MmapSize(db *sql.DB) (int64, error) {
+	var v int64
+	return v, row(db, []interface{}{&v}, "PRAGMA mmap_size")
+}
+
+// PageCount returns the value of "PRAGMA page_count"
+func PageCount(db *sql.DB) (int64, error) {
+	var v int64
+	return v, row(db, []interface{}{&v}, "PRAGMA page_count")
+}
+
+// PageSize returns the value of "PRAGMA page_size"
+func PageSize(db *sql.DB) (int, error) {
+	var v int
+	return v, row(db, []interface{}{&v}, "PRAGMA page_size")
+}
+
+// QueryOnly returns the value of "PRAGMA query_only"
+func QueryOnly(db *sql.DB) (bool, error) {
+	var v bool
+	return v, row(db, []interface{}{&v}, "PRAGMA query_only")
+}
+
+// ReadUncommitted returns the value of "PRAGMA read_uncommitted"
+func ReadUncommitted(db *sql.DB) (bool, error) {
+	var v bool
+	return v, row(db, []interface{}{&v}, "PRAGMA read_uncommitted")
+}
+
+// RecursiveTriggers returns the value of "PRAGMA recursive_triggers"
+func RecursiveTriggers(db *sql.DB) (bool, error) {
+	var v bool
+	return v, row(db, []interface{}{&v}, "PRAGMA recursive_triggers")
+}
+
+// ReverseUnorderedSelects returns the value of "PRAGMA reverse_unordered_selects"
+func ReverseUnorderedSelects(db *sql.DB) (bool, error) {
+	var v bool
+	return v, row(db, []interface{}{&v}, "PRAGMA reverse_unordered_selects")
+}
+
+// SchemaVersion returns the value of "PRAGMA schema_version"
+func SchemaVersion(db *sql.DB) (int, error) {
+	var v int
+	return v, row(db, []interface{}{&v}, "PRAGMA schema_version")
+}
+
+// SecureDelete returns the value of "PRAGMA secure_delete"
+func SecureDelete(db *sql.DB) (int, error) {
+	var v int
+	return v, row(db, []interface{}{&v}, "PRAGMA secure_delete")
+}
+
+// SoftHeapLimit returns the value of "PRAGMA soft_heap_limit"
+func SoftHeapLimit(db *sql.DB) (int64, error) {
+	var v int64
+	return v, row(db, []interface{}{&v}, "PRAGMA soft_heap_limit")
+}
+
+// Synchronous returns the value of "PRAGMA synchronous"
+func Synchronous(db *sql.DB) (Sync, error) {
+	var v Sync
+	return v, row(db, []interface{}{&v}, "PRAGMA synchronous")
+}
+
+// TempStore returns the value of "PRAGMA temp_store"
+func TempStore(db *sql.DB) (TempStorage, error) {
+	var v TempStorage
+	return v, row(db, []interface{}{&v}, "PRAGMA temp_store")
+}
+
+// Threads returns the value of "PRAGMA threads"
+func Threads(db *sql.DB) (int, error) {
+	var v int
+	return v, row(db, []interface{}{&v}, "PRAGMA threads")
+}
+
+// UserVersion returns the value of "PRAGMA user_version"
+func UserVersion(db *sql.DB) (int, error) {
+	var v int
+	return v, row(db, []interface{}{&v}, "PRAGMA user_version")
+}
+
+// WALAutocheckpoint returns the value of "PRAGMA wal_autocheckpoint"
+func WALAutocheckpoint(db *sql.DB) (int, error) {
+	var v int
+	return v, row(db, []interface{}{&v}, "PRAGMA wal_autocheckpoint")
+}
@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestTypedPragmas(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "pragma.db")
+	db, err := Open(file, WithDriver("sqlite_pragma"), WithPragmas("journal_mode=WAL", "synchronous=NORMAL", "foreign_keys=on"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mode, err := JournalMode(db)
+	if err != nil || mode != JournalWAL {
+		t.Errorf("expected journal mode %q but got: %q (%v)", JournalWAL, mode, err)
+	}
+	sync, err := Synchronous(db)
+	if err != nil || sync != SyncNormal {
+		t.Errorf("expected synchronous %v but got: %v (%v)", SyncNormal, sync, err)
+	}
+	fk, err := ForeignKeys(db)
+	if err != nil || !fk {
+		t.Errorf("expected foreign keys on but got: %v (%v)", fk, err)
+	}
+	size, err := PageSize(db)
+	if err != nil || size <= 0 {
+		t.Errorf("unexpected page size: %d (%v)", size, err)
+	}
+	if _, err := FreelistCount(db); err != nil {
+		t.Error(err)
+	}
+	if store, err := TempStore(db); err != nil || store.String() != "default" {
+		t.Errorf("unexpected temp store: %v (%v)", store, err)
+	}
+	if s := Sync(9).String(); s != "unknown" {
+		t.Errorf("expected unknown but got: %q", s)
+	}
+}
+
+func TestComparePragmas(t *testing.T) {
+	a, err := PragmaSnapshot(memDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a["journal_mode"] != "memory" || a["compile_options"] == "" {
+		t.Fatalf("unexpected snapshot: %v", a)
+	}
+	db := memDB(t)
+	if _, err := db.Exec("PRAGMA user_version=7; create table t (x)"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := PragmaSnapshot(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := ComparePragmas(a, b)
+	if len(changes) != 1 || changes[0] != (PragmaChange{Name: "user_version", From: "0", To: "7"}) {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+
+	var buf bytes.Buffer
+	if err := WritePragmas(db, &buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded PragmaValues
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded["user_version"] != "7" {
+		t.Fatalf("unexpected JSON: %s (%v)", buf.String(), err)
+	}
+}
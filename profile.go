@@ -0,0 +1,152 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// TableProfile is the profile of the columns of a table
+type TableProfile struct {
+	Table   string
+	Rows    int64 // rows in the table
+	Sampled int64 // rows examined, a random sample if fewer than Rows
+	Columns []ColumnProfile
+}
+
+// ColumnProfile summarizes the values of a column in the rows examined
+type ColumnProfile struct {
+	Name        string
+	DeclType    string
+	Types       map[string]int64 // values of each storage class, e.g. "text" or "null"
+	Nulls       int64
+	NullPercent float64
+	Distinct    int64
+	MinLength   int64 // of the non-null values as text, or bytes of blobs
+	MaxLength   int64
+	AvgLength   float64
+	Examples    []interface{} // a few of the distinct values
+}
+
+// Profile describes the columns of the tables, or of every table if none are given, for
+// understanding an unfamiliar database. Tables of more than 100,000 rows are sampled by
+// taking every nth rowid, so tables without a rowid must be smaller
+func Profile(db *sql.DB, tables ...string) ([]TableProfile, error) {
+	if len(tables) == 0 {
+		var err error
+		if tables, err = Tables(db); err != nil {
+			return nil, err
+		}
+	}
+	profiles := make([]TableProfile, 0, len(tables))
+	for _, table := range tables {
+		p, err := profileTable(db, table)
+		if err != nil {
+			return nil, fmt.Errorf("profile: %s, error: %w", table, err)
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// profileTable profiles each column of the table
+func profileTable(db *sql.DB, table string) (TableProfile, error) {
+	p := TableProfile{Table: table}
+	fn := func(_ []string, row []interface{}) {
+		p.Columns = append(p.Columns, ColumnProfile{Name: fmt.Sprint(row[1]), DeclType: fmt.Sprint(row[2])})
+	}
+	if err := query(db, fn, "PRAGMA table_info("+quoteIdent(table)+")"); err != nil {
+		return p, err
+	}
+	if len(p.Columns) == 0 {
+		return p, fmt.Errorf("no such table: %s", table)
+	}
+	if err := row(db, []interface{}{&p.Rows}, "SELECT count(*) FROM "+quoteIdent(table)); err != nil {
+		return p, err
+	}
+	p.Sampled = p.Rows
+	src := quoteIdent(table)
+	if p.Rows > statsSample {
+		// every nth row, so each column is profiled from the same rows
+		src = fmt.Sprintf("(SELECT * FROM %s WHERE rowid %% %d = 0)", src, p.Rows/statsSample+1)
+		if err := row(db, []interface{}{&p.Sampled}, "SELECT count(*) FROM "+src); err != nil {
+			return p, err
+		}
+	}
+
+	ctx := context.Background()
+	for i := range p.Columns {
+		c := &p.Columns[i]
+		col := quoteIdent(c.Name)
+		var minLen, maxLen, avgLen sql.NullFloat64
+		dest := []interface{}{&c.Distinct, &minLen, &maxLen, &avgLen}
+		q := fmt.Sprintf("SELECT count(DISTINCT %[1]s), min(length(%[1]s)), max(length(%[1]s)), avg(length(%[1]s)) FROM %[2]s", col, src)
+		if err := row(db, dest, q); err != nil {
+			return p, err
+		}
+		c.MinLength, c.MaxLength, c.AvgLength = int64(minLen.Float64), int64(maxLen.Float64), avgLen.Float64
+
+		c.Types = make(map[string]int64)
+		rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT typeof(%s), count(*) FROM %s GROUP BY 1", col, src))
+		if err != nil {
+			return p, err
+		}
+		for rows.Next() {
+			var kind string
+			var n int64
+			if err := rows.Scan(&kind, &n); err != nil {
+				rows.Close()
+				return p, err
+			}
+			c.Types[kind] = n
+		}
+		rows.Close()
+		c.Nulls = c.Types["null"]
+		if p.Sampled > 0 {
+			c.NullPercent = 100 * float64(c.Nulls) / float64(p.Sampled)
+		}
+
+		examples := func(_ []string, row []interface{}) {
+			c.Examples = append(c.Examples, row[0])
+		}
+		if err := query(db, examples, fmt.Sprintf("SELECT DISTINCT %[1]s FROM %[2]s WHERE %[1]s IS NOT NULL LIMIT 3", col, src)); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// WriteProfile renders the profiles as a report
+func WriteProfile(w io.Writer, profiles []TableProfile) {
+	for _, p := range profiles {
+		fmt.Fprintf(w, "%s: %d rows", p.Table, p.Rows)
+		if p.Sampled < p.Rows {
+			fmt.Fprintf(w, " (%d sampled)", p.Sampled)
+		}
+		fmt.Fprintln(w)
+		for _, c := range p.Columns {
+			kinds := make([]string, 0, len(c.Types))
+			for kind := range c.Types {
+				kinds = append(kinds, kind)
+			}
+			sort.Strings(kinds)
+			types := make([]string, len(kinds))
+			for i, kind := range kinds {
+				types[i] = fmt.Sprintf("%s:%d", kind, c.Types[kind])
+			}
+			examples := make([]string, len(c.Examples))
+			for i, v := range c.Examples {
+				examples[i] = sqlLiteral(v)
+			}
+			fmt.Fprintf(w, "  %s %s\n", c.Name, c.DeclType)
+			fmt.Fprintf(w, "    types: %s\n", strings.Join(types, " "))
+			fmt.Fprintf(w, "    nulls: %.1f%%  distinct: %d  length: %d-%d (avg %.1f)\n", c.NullPercent, c.Distinct, c.MinLength, c.MaxLength, c.AvgLength)
+			if len(examples) > 0 {
+				fmt.Fprintf(w, "    examples: %s\n", strings.Join(examples, ", "))
+			}
+		}
+	}
+}
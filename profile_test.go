@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	const create = `
+create table items (id integer primary key, name text, price real, code);
+insert into items (name, price, code) values('apple', 1.5, 10);
+insert into items (name, price, code) values('banana', null, 'B-2');
+insert into items (name, price, code) values('kiwi', 2, null);
+insert into items (name, price, code) values('kiwi', null, x'0102');
+create table empty (a int);
+`
+	if _, err := db.Exec(create); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := Profile(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 || profiles[0].Table != "empty" || profiles[1].Table != "items" {
+		t.Fatalf("unexpected profiles: %+v", profiles)
+	}
+	items := profiles[1]
+	if items.Rows != 4 || items.Sampled != 4 || len(items.Columns) != 4 {
+		t.Fatalf("unexpected profile: %+v", items)
+	}
+	name := items.Columns[1]
+	if name.Distinct != 3 || name.MinLength != 4 || name.MaxLength != 6 || name.AvgLength != 4.75 || len(name.Examples) != 3 {
+		t.Errorf("unexpected name profile: %+v", name)
+	}
+	price := items.Columns[2]
+	if price.DeclType != "real" || price.Nulls != 2 || price.NullPercent != 50 || price.Types["real"] != 2 {
+		t.Errorf("unexpected price profile: %+v", price)
+	}
+	code := items.Columns[3]
+	if len(code.Types) != 4 || code.Types["integer"] != 1 || code.Types["text"] != 1 || code.Types["blob"] != 1 {
+		t.Errorf("unexpected code types: %v", code.Types)
+	}
+
+	var buf bytes.Buffer
+	WriteProfile(&buf, profiles)
+	for _, want := range []string{"items: 4 rows", "types: blob:1 integer:1 null:1 text:1", "nulls: 50.0%", "'apple'"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, buf.String())
+		}
+	}
+	if _, err := Profile(db, "nope"); err == nil {
+		t.Error("expected error for missing table")
+	}
+}
@@ -0,0 +1,140 @@
+package sqlite
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// queryName matches the annotation naming the query that follows it, e.g. "-- name: insert-user"
+var queryName = regexp.MustCompile(`^--\s*name:\s*(\S+)\s*$`)
+
+// Queries is a catalog of named queries, kept in files of SQL rather than in Go strings.
+// Each query follows a line naming it, as in:
+//
+//	-- name: top-customers
+//	-- customers by their total spend
+//	SELECT name, sum(total) AS spent FROM orders GROUP BY name ORDER BY spent DESC LIMIT ?;
+type Queries struct {
+	queries map[string]string
+	sources map[string]string // the file of each query, for reporting duplicates
+}
+
+// NewQueries returns an empty catalog
+func NewQueries() *Queries {
+	return &Queries{queries: make(map[string]string), sources: make(map[string]string)}
+}
+
+// Parse adds the queries read from r, from the named file. Text before the first name
+// is ignored, and a name already in the catalog is an error
+func (q *Queries) Parse(file string, r io.Reader) error {
+	var name string
+	var body strings.Builder
+	line := 0
+	add := func() error {
+		if name == "" {
+			return nil
+		}
+		text := strings.TrimSpace(body.String())
+		if text == "" {
+			return fmt.Errorf("%s:%d: query %q is empty", file, line, name)
+		}
+		if prev, ok := q.sources[name]; ok {
+			return fmt.Errorf("%s:%d: query %q is already in %s", file, line, name, prev)
+		}
+		q.queries[name] = text
+		q.sources[name] = file
+		return nil
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if m := queryName.FindStringSubmatch(strings.TrimSpace(text)); m != nil {
+			if err := add(); err != nil {
+				return err
+			}
+			name = m[1]
+			body.Reset()
+			continue
+		}
+		if name != "" {
+			body.WriteString(text)
+			body.WriteByte('\n')
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return add()
+}
+
+// Names returns the names of the queries, sorted
+func (q *Queries) Names() []string {
+	names := make([]string, 0, len(q.queries))
+	for name := range q.queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SQL returns the named query
+func (q *Queries) SQL(name string) (string, error) {
+	text, ok := q.queries[name]
+	if !ok {
+		return "", fmt.Errorf("no query named: %q", name)
+	}
+	return text, nil
+}
+
+// Exec runs the named query
+func (q *Queries) Exec(db Queryer, name string, args ...interface{}) (sql.Result, error) {
+	text, err := q.SQL(name)
+	if err != nil {
+		return nil, err
+	}
+	return db.ExecContext(context.Background(), text, args...)
+}
+
+// Query runs the named query, returning its rows
+func (q *Queries) Query(db Queryer, name string, args ...interface{}) (*sql.Rows, error) {
+	text, err := q.SQL(name)
+	if err != nil {
+		return nil, err
+	}
+	return db.QueryContext(context.Background(), text, args...)
+}
+
+// Stream runs the named query, calling fn with each of its rows, until fn fails
+func (q *Queries) Stream(db Queryer, name string, fn func(columns []string, row []interface{}) error, args ...interface{}) error {
+	rows, err := q.Query(db, name, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	row := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range row {
+		ptrs[i] = &row[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		if err := fn(columns, row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
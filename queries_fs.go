@@ -0,0 +1,30 @@
+package sqlite
+
+import (
+	"io/fs"
+)
+
+// LoadQueries returns the catalog of the queries in the files of fsys matching the
+// pattern, e.g. "queries/*.sql", or all ".sql" files at its root if it's empty
+func LoadQueries(fsys fs.FS, pattern string) (*Queries, error) {
+	if pattern == "" {
+		pattern = "*.sql"
+	}
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	q := NewQueries()
+	for _, file := range files {
+		f, err := fsys.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		err = q.Parse(file, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestQueries(t *testing.T) {
+	fsys := fstest.MapFS{
+		"users.sql": {Data: []byte(`
+-- queries of users
+
+-- name: create-users
+CREATE TABLE users (id integer primary key, name text, spent real);
+
+-- name: insert-user
+-- a user and what they spent
+INSERT INTO users (name, spent) VALUES (?, ?);
+`)},
+		"reports.sql": {Data: []byte(`-- name: top-customers
+SELECT name, spent FROM users ORDER BY spent DESC LIMIT ?;
+`)},
+		"notes.txt": {Data: []byte("-- name: ignored\nSELECT 1;\n")},
+	}
+	q, err := LoadQueries(fsys, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(q.Names(), ","); got != "create-users,insert-user,top-customers" {
+		t.Fatalf("unexpected names: %s", got)
+	}
+
+	db := memDB(t)
+	if _, err := q.Exec(db, "create-users"); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"ann", "bob", "cal"} {
+		if _, err := q.Exec(db, "insert-user", name, float64(i)*10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var names []string
+	fn := func(columns []string, row []interface{}) error {
+		if columns[0] != "name" {
+			t.Errorf("unexpected columns: %v", columns)
+		}
+		names = append(names, row[0].(string))
+		return nil
+	}
+	if err := q.Stream(db, "top-customers", fn, 2); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "cal,bob" {
+		t.Errorf("unexpected results: %v", names)
+	}
+	if _, err := q.Exec(db, "nope"); err == nil {
+		t.Error("expected error for unknown query")
+	}
+
+	dup := fstest.MapFS{
+		"a.sql": {Data: []byte("-- name: same\nSELECT 1;\n")},
+		"b.sql": {Data: []byte("-- name: same\nSELECT 2;\n")},
+	}
+	if _, err := LoadQueries(dup, ""); err == nil || !strings.Contains(err.Error(), "a.sql") {
+		t.Errorf("expected duplicate error but got: %v", err)
+	}
+	if err := NewQueries().Parse("empty.sql", strings.NewReader("-- name: empty\n\n-- name: next\nSELECT 1;")); err == nil {
+		t.Error("expected error for empty query")
+	}
+}
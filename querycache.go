@@ -0,0 +1,133 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// QueryResult is the result of a query, as cached by CachedQuery
+type QueryResult struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// queryCache holds the cached results of a database
+type queryCache struct {
+	mu      sync.Mutex
+	gen     uint64                              // incremented when the database may have changed
+	seen    map[*sqlite3.SQLiteConn]connVersion // the version of the data last seen by each connection
+	entries map[string]cachedResult
+}
+
+// connVersion tells whether the data seen by a connection has changed: data_version changes
+// when other connections commit changes, and total_changes when the connection makes them
+type connVersion struct {
+	data, changes int64
+}
+
+type cachedResult struct {
+	result  *QueryResult
+	gen     uint64
+	expires time.Time
+}
+
+var (
+	qmu     sync.Mutex
+	qcaches = make(map[*sql.DB]*queryCache)
+)
+
+const qVersion = "SELECT (SELECT data_version FROM pragma_data_version), total_changes()"
+
+// CachedQuery returns the result of the query, from the cache if it was stored under the key
+// less than ttl ago (or ever, if ttl is 0) and the database hasn't changed since. Changes are
+// detected with PRAGMA data_version, so they include those made by other processes.
+// Results are shared by callers, and must not be modified. The cache is dropped by Close
+func CachedQuery(db *sql.DB, key string, ttl time.Duration, q string, args ...interface{}) (*QueryResult, error) {
+	qmu.Lock()
+	c, ok := qcaches[db]
+	if !ok {
+		c = &queryCache{seen: make(map[*sqlite3.SQLiteConn]connVersion), entries: make(map[string]cachedResult)}
+		qcaches[db] = c
+	}
+	qmu.Unlock()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := c.check(ctx, conn); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	gen := c.gen
+	c.mu.Unlock()
+	if ok && entry.gen == gen && (entry.expires.IsZero() || time.Now().Before(entry.expires)) {
+		return entry.result, nil
+	}
+
+	result := &QueryResult{Rows: [][]interface{}{}}
+	fn := func(columns []string, row []interface{}) {
+		if columns != nil {
+			result.Columns = columns
+		}
+		result.Rows = append(result.Rows, append([]interface{}(nil), row...))
+	}
+	if err := query(conn, fn, q, args...); err != nil {
+		return nil, err
+	}
+	entry = cachedResult{result: result, gen: gen}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	c.mu.Lock()
+	c.entries[key] = entry
+	c.mu.Unlock()
+	return result, nil
+}
+
+// check invalidates the cache if the data seen by the connection has changed,
+// or it hasn't been seen before, and so what it has seen is unknown
+func (c *queryCache) check(ctx context.Context, conn *sql.Conn) error {
+	var sc *sqlite3.SQLiteConn
+	if err := conn.Raw(func(dc interface{}) error {
+		sc = rawConn(dc)
+		return nil
+	}); err != nil {
+		return err
+	}
+	var v connVersion
+	if err := conn.QueryRowContext(ctx, qVersion).Scan(&v.data, &v.changes); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if last, ok := c.seen[sc]; !ok || last != v {
+		c.gen++
+		c.seen[sc] = v
+	}
+	// forget closed connections
+	if len(c.seen) > 16 {
+		rmu.Lock()
+		for conn := range c.seen {
+			if _, ok := registry[conn]; !ok {
+				delete(c.seen, conn)
+			}
+		}
+		rmu.Unlock()
+	}
+	return nil
+}
+
+// dropQueryCache discards the cached results of the database
+func dropQueryCache(db *sql.DB) {
+	qmu.Lock()
+	delete(qcaches, db)
+	qmu.Unlock()
+}
@@ -0,0 +1,70 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCachedQuery(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cached.db")
+	db, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(db)
+	if _, err := db.Exec("create table cq (x int); insert into cq values (1), (2)"); err != nil {
+		t.Fatal(err)
+	}
+	const q = "select sum(x) as total from cq where x > ?"
+	total := func() int64 {
+		t.Helper()
+		r, err := CachedQuery(db, "total", 0, q, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(r.Columns) != 1 || r.Columns[0] != "total" || len(r.Rows) != 1 {
+			t.Fatalf("unexpected result: %+v", r)
+		}
+		return r.Rows[0][0].(int64)
+	}
+	first, err := CachedQuery(db, "total", 0, q, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := CachedQuery(db, "total", 0, q, 0); again != first {
+		t.Fatal("expected the cached result")
+	}
+
+	// changes by the handle itself, and by another process (here another handle)
+	if _, err := db.Exec("insert into cq values (3)"); err != nil {
+		t.Fatal(err)
+	}
+	if n := total(); n != 6 {
+		t.Fatalf("expected the cache to be invalidated by a write, but got: %d", n)
+	}
+	other, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := other.Exec("insert into cq values (4)"); err != nil {
+		t.Fatal(err)
+	}
+	if n := total(); n != 10 {
+		t.Fatalf("expected the cache to be invalidated by another writer, but got: %d", n)
+	}
+
+	// the ttl expires results even without changes
+	r1, err := CachedQuery(db, "ttl", time.Millisecond, q, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if r2, _ := CachedQuery(db, "ttl", time.Millisecond, q, 0); r2 == r1 {
+		t.Fatal("expected the result to expire")
+	}
+	if _, err := CachedQuery(db, "bad", 0, "select * from nosuch"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
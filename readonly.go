@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// readOnlyDBs are the databases opened read-only by WithFallbackReadOnly
+var readOnlyDBs = make(map[*sql.DB]bool)
+
+// WithFallbackReadOnly opens a database that can't be written, as its file or
+// filesystem is read-only, or another process holds its write lock, in read-only
+// mode, with a warning, rather than failing, e.g. for tools that browse databases.
+// Whether it can be written is checked by a write that is rolled back, which waits
+// for the busy timeout of the connection if the database is locked
+func WithFallbackReadOnly() Optional {
+	return func(c *Config) {
+		c.fallback = true
+	}
+}
+
+// ReadOnly reports whether the database was opened read-only by WithFallbackReadOnly
+func ReadOnly(db *sql.DB) bool {
+	smu.Lock()
+	defer smu.Unlock()
+	return readOnlyDBs[db]
+}
+
+// forgetReadOnly forgets the database is read-only, as it is being closed
+func forgetReadOnly(db *sql.DB) {
+	smu.Lock()
+	delete(readOnlyDBs, db)
+	smu.Unlock()
+}
+
+// readOnlyFile reports whether the file exists, but couldn't be opened for writing
+// because of its permissions or those of its filesystem
+func readOnlyFile(filename string, err error) bool {
+	if _, serr := os.Stat(filename); serr != nil {
+		return false
+	}
+	return os.IsPermission(err) || errors.Is(err, syscall.EROFS)
+}
+
+// readOnlyDSN returns the file as a URI to open it read-only
+func readOnlyDSN(file string) string {
+	if !strings.HasPrefix(file, "file:") {
+		return "file:" + file + "?mode=ro"
+	}
+	if strings.Contains(file, "?") {
+		return file + "&mode=ro"
+	}
+	return file + "?mode=ro"
+}
+
+// probeWrite writes to the database in a transaction that is rolled back
+func probeWrite(db *sql.DB) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "ROLLBACK")
+	var version int64
+	if err := conn.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	// setting the version writes the header, as any change would
+	_, err = conn.ExecContext(ctx, "PRAGMA user_version = "+strconv.FormatInt(version, 10))
+	return err
+}
+
+// fallbackReadOnly returns the database if it can be written, and otherwise reopens
+// it read-only. A nil database is one whose file couldn't be opened for writing
+func fallbackReadOnly(db *sql.DB, file string, config *Config, c *connector) (*sql.DB, error) {
+	var reason error
+	if db != nil {
+		if reason = probeWrite(db); reason == nil {
+			return db, nil
+		}
+		db.Close()
+	}
+	db, err := openDB(readOnlyDSN(file), config, c)
+	if err != nil {
+		return nil, err
+	}
+	args := []interface{}{"db", file}
+	if reason != nil {
+		args = append(args, "error", reason)
+	}
+	loggerOf(db).Warn("database opened read-only, as it can't be written", args...)
+	smu.Lock()
+	readOnlyDBs[db] = true
+	smu.Unlock()
+	return db, nil
+}
@@ -0,0 +1,61 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFallbackReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	db := fileDB(t, dir)
+	file := filepath.Join(dir, "source.db")
+
+	// a writable database is opened as usual
+	rw, err := Open(file, WithFallbackReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ReadOnly(rw) {
+		t.Error("expected a writable database")
+	}
+	rw.Close()
+
+	// another handle holds the write lock
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("insert into structs(name) values('pending')"); err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := Open(file, WithFallbackReadOnly(), WithPragmas("busy_timeout=50"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(ro)
+	if !ReadOnly(ro) {
+		t.Fatal("expected a read-only database")
+	}
+	var count int
+	if err := row(ro, []interface{}{&count}, "select count(*) from structs"); err != nil || count != 4 {
+		t.Errorf("expected 4 rows but got: %d (%v)", count, err)
+	}
+	tx.Rollback()
+	if _, err := ro.Exec("insert into structs(name) values('nope')"); err == nil {
+		t.Error("expected writes to fail")
+	}
+}
+
+func TestReadOnlyDSN(t *testing.T) {
+	for file, want := range map[string]string{
+		"a.db":                    "file:a.db?mode=ro",
+		"file:a.db":               "file:a.db?mode=ro",
+		"file:a.db?cache=private": "file:a.db?cache=private&mode=ro",
+	} {
+		if got := readOnlyDSN(file); got != want {
+			t.Errorf("expected %s but got: %s", want, got)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// RowTransform changes a row of a table as it is copied into the rebuilt database.
+// The row is keyed by the columns of the old table, and the result by those of the new,
+// where missing columns take their default. Returning a nil row skips it
+type RowTransform func(row map[string]interface{}) (map[string]interface{}, error)
+
+// RebuildSpec is the schema and settings of a database rebuilt by RebuildWithSchema
+type RebuildSpec struct {
+	SQL        string                  // statements creating the tables, indexes, views, and triggers
+	PageSize   int                     // page size of the new file, if not the default
+	AutoVacuum *Vacuum                 // auto-vacuum mode of the new file, if not the default
+	Transforms map[string]RowTransform // optional, keyed by table name
+	Progress   io.Writer               // receives progress reports
+}
+
+// RebuildWithSchema rebuilds a database file with a new schema and page settings, which
+// is a VACUUM that can also change the schema. A new file is created with the schema,
+// the rows of the tables it shares with the old are copied (through any transforms),
+// and the row counts, and the contents of tables copied as they are, are verified
+// before the new file replaces the old by renaming it.
+//
+// Writes are locked out while the rows are copied, though reads continue. The connections
+// of db refer to the replaced file, so db is closed, and the rebuilt database opened with
+// opts is returned. Other handles on the file must be reopened too
+func RebuildWithSchema(db *sql.DB, spec RebuildSpec, opts ...Optional) (*sql.DB, error) {
+	w := spec.Progress
+	if w == nil {
+		w = ioutil.Discard
+	}
+	file, err := Filename(db)
+	if err != nil {
+		return nil, err
+	}
+	if file == "" {
+		return nil, fmt.Errorf("can't rebuild an in-memory database")
+	}
+	tmp := file + ".rebuild"
+	for _, f := range []string{tmp, tmp + "-journal", tmp + "-wal", tmp + "-shm"} {
+		os.Remove(f)
+	}
+	built := false
+	defer func() {
+		if !built {
+			os.Remove(tmp)
+		}
+	}()
+
+	var create []Optional
+	if spec.PageSize != 0 {
+		create = append(create, WithPageSize(spec.PageSize))
+	}
+	if spec.AutoVacuum != nil {
+		create = append(create, WithAutoVacuum(*spec.AutoVacuum))
+	}
+	newDB, err := Open(tmp, create...)
+	if err != nil {
+		return nil, err
+	}
+	defer newDB.Close()
+	if _, err := newDB.Exec(spec.SQL); err != nil {
+		return nil, fmt.Errorf("new schema: %w", err)
+	}
+	// triggers are created once the rows are copied, so they don't act on them
+	var names, triggers []string
+	fn := func(_ []string, row []interface{}) {
+		names = append(names, fmt.Sprint(row[0]))
+		triggers = append(triggers, fmt.Sprint(row[1]))
+	}
+	if err := query(newDB, fn, "SELECT name, sql FROM sqlite_master WHERE type='trigger'"); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if _, err := newDB.Exec("DROP TRIGGER " + quoteIdent(name)); err != nil {
+			return nil, err
+		}
+	}
+
+	// a write transaction keeps the old database as it is until it is replaced
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(ctx, "ROLLBACK")
+
+	tables, err := Tables(newDB)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		if err := rebuildCopy(conn, newDB, table, spec.Transforms[table], w); err != nil {
+			return nil, fmt.Errorf("rebuild table: %s, error: %w", table, err)
+		}
+	}
+	for _, trigger := range triggers {
+		if _, err := newDB.Exec(trigger); err != nil {
+			return nil, err
+		}
+	}
+	if err := IntegrityCheck(newDB); err != nil {
+		return nil, err
+	}
+	if err := Close(newDB); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tmp, file); err != nil {
+		return nil, err
+	}
+	built = true
+	fmt.Fprintf(w, "replaced: %s\n", file)
+	conn.ExecContext(ctx, "ROLLBACK")
+	conn.Close()
+	db.Close()
+	return Open(file, opts...)
+}
+
+// rebuildCopy copies the rows of a table of the old database, if it has it, into the new
+func rebuildCopy(old *sql.Conn, newDB *sql.DB, table string, transform RowTransform, w io.Writer) error {
+	oldCols, err := tableColumns(old, table)
+	if err != nil {
+		fmt.Fprintf(w, "table: %s is new\n", table)
+		return nil
+	}
+	newCols, err := tableColumns(newDB, table)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool)
+	for _, c := range newCols {
+		keep[c] = true
+	}
+	var common []string
+	for _, c := range oldCols {
+		if keep[c] {
+			common = append(common, c)
+		}
+	}
+	if transform == nil && len(common) == 0 {
+		fmt.Fprintf(w, "table: %s has no columns in common\n", table)
+		return nil
+	}
+
+	tx, err := newDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmts := make(map[string]*sql.Stmt)
+	defer func() {
+		for _, stmt := range stmts {
+			stmt.Close()
+		}
+	}()
+	insert := func(columns []string, values []interface{}) error {
+		key := strings.Join(columns, "\x00")
+		stmt, ok := stmts[key]
+		if !ok {
+			q := fmt.Sprintf("INSERT INTO %s (%s) VALUES(%s)", quoteIdent(table), quotedList(columns),
+				strings.TrimSuffix(strings.Repeat("?,", len(columns)), ","))
+			if len(columns) == 0 {
+				q = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", quoteIdent(table))
+			}
+			if stmt, err = tx.Prepare(q); err != nil {
+				return err
+			}
+			stmts[key] = stmt
+		}
+		_, err := stmt.Exec(values...)
+		return err
+	}
+
+	read := common
+	if transform != nil {
+		read = oldCols
+	}
+	var copied, skipped int64
+	var ferr error
+	fn := func(_ []string, row []interface{}) {
+		if ferr != nil {
+			return
+		}
+		columns, values := read, row
+		if transform != nil {
+			in := make(map[string]interface{}, len(read))
+			for i, c := range read {
+				in[c] = row[i]
+			}
+			out, err := transform(in)
+			if err != nil {
+				ferr = err
+				return
+			}
+			if out == nil {
+				skipped++
+				return
+			}
+			columns, values = nil, nil
+			for _, c := range newCols {
+				if v, ok := out[c]; ok {
+					columns = append(columns, c)
+					values = append(values, v)
+				}
+			}
+		}
+		if ferr = insert(columns, values); ferr == nil {
+			copied++
+		}
+	}
+	if err := query(old, fn, fmt.Sprintf("SELECT %s FROM %s", quotedList(read), quoteIdent(table))); err != nil {
+		return err
+	}
+	if ferr != nil {
+		return ferr
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	var oldCount, newCount int64
+	countSQL := "SELECT count(*) FROM " + quoteIdent(table)
+	if err := old.QueryRowContext(context.Background(), countSQL).Scan(&oldCount); err != nil {
+		return err
+	}
+	if err := newDB.QueryRow(countSQL).Scan(&newCount); err != nil {
+		return err
+	}
+	if newCount != oldCount-skipped {
+		return fmt.Errorf("copied %d of %d rows (%d skipped)", newCount, oldCount, skipped)
+	}
+	if transform == nil {
+		oldSum, err := tableChecksum(old, table, common)
+		if err != nil {
+			return err
+		}
+		newSum, err := tableChecksum(newDB, table, common)
+		if err != nil {
+			return err
+		}
+		if oldSum != newSum {
+			return fmt.Errorf("checksums of the copied columns differ")
+		}
+	}
+	fmt.Fprintf(w, "table: %s rows: %d skipped: %d\n", table, copied, skipped)
+	return nil
+}
+
+// quotedList returns the quoted names of the columns, separated by commas
+func quotedList(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdent(c)
+	}
+	return strings.Join(quoted, ",")
+}
+
+// tableChecksum returns a checksum of the values of the columns of a table, regardless of row order
+func tableChecksum(db Queryer, table string, columns []string) (string, error) {
+	var sums []string
+	fn := func(_ []string, row []interface{}) {
+		h := sha256.New()
+		for _, v := range row {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			fmt.Fprintf(h, "%v\x00", v)
+		}
+		sums = append(sums, string(h.Sum(nil)))
+	}
+	if err := query(db, fn, fmt.Sprintf("SELECT %s FROM %s", quotedList(columns), quoteIdent(table))); err != nil {
+		return "", err
+	}
+	sort.Strings(sums)
+	h := sha256.New()
+	for _, s := range sums {
+		io.WriteString(h, s)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRebuildWithSchema(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rebuild.db")
+	db, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const old = `
+	create table people (id integer primary key, name text, age int);
+	create table notes (id integer primary key, note text);
+	create table dropped (x);
+	insert into people (name, age) values ('alice', 30), ('bob', 17), ('carol', 45);
+	insert into notes (note) values ('one'), ('two');
+	insert into dropped values (1);
+	`
+	if _, err := db.Exec(old); err != nil {
+		t.Fatal(err)
+	}
+	const schema = `
+	create table people (id integer primary key, first text, age int, adult bool default 0);
+	create table notes (id integer primary key, note text not null, created text default 'never');
+	create table added (y);
+	create table audit (msg text);
+	create trigger notes_audit after insert on notes begin insert into audit values (new.note); end;
+	`
+	var progress bytes.Buffer
+	vacuum := VacuumFull
+	spec := RebuildSpec{
+		SQL:        schema,
+		PageSize:   8192,
+		AutoVacuum: &vacuum,
+		Progress:   &progress,
+		Transforms: map[string]RowTransform{
+			"people": func(row map[string]interface{}) (map[string]interface{}, error) {
+				if row["name"] == "bob" {
+					return nil, nil
+				}
+				return map[string]interface{}{"id": row["id"], "first": row["name"], "age": row["age"], "adult": true}, nil
+			},
+		},
+	}
+	db, err = RebuildWithSchema(db, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	t.Log(progress.String())
+
+	if size, err := PageSize(db); err != nil || size != 8192 {
+		t.Fatalf("expected page size 8192 but got %d (%v)", size, err)
+	}
+	tables, err := Tables(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(tables, ",") != "added,audit,notes,people" {
+		t.Fatalf("unexpected tables: %v", tables)
+	}
+	var names string
+	if err := row(db, []interface{}{&names}, "select group_concat(first) from people where adult order by id"); err != nil {
+		t.Fatal(err)
+	}
+	if names != "alice,carol" {
+		t.Fatalf("unexpected people: %q", names)
+	}
+	var notes, audits int
+	if err := row(db, []interface{}{&notes}, "select count(*) from notes where created='never'"); err != nil {
+		t.Fatal(err)
+	}
+	if err := row(db, []interface{}{&audits}, "select count(*) from audit"); err != nil {
+		t.Fatal(err)
+	}
+	if notes != 2 || audits != 0 {
+		t.Fatalf("expected 2 notes and no audits for the copied rows, but got %d and %d", notes, audits)
+	}
+	if _, err := db.Exec("insert into notes (note) values ('three')"); err != nil {
+		t.Fatal(err)
+	}
+	if err := row(db, []interface{}{&audits}, "select count(*) from audit"); err != nil || audits != 1 {
+		t.Fatalf("expected the trigger to be recreated, but got %d audits (%v)", audits, err)
+	}
+
+	if _, err := RebuildWithSchema(memDB(t), RebuildSpec{SQL: schema}); err == nil {
+		t.Fatal("expected an error rebuilding a memory database")
+	}
+}
+
+func TestRebuildBadSchema(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rebuild.db")
+	db, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("create table t (x); insert into t values (1)"); err != nil {
+		t.Fatal(err)
+	}
+	// the copy fails the new constraint, leaving the old database in place
+	if _, err := RebuildWithSchema(db, RebuildSpec{SQL: "create table t (x check (x > 1))"}); err == nil {
+		t.Fatal("expected the copy to fail")
+	}
+	var count int
+	if err := row(db, []interface{}{&count}, "select count(*) from t"); err != nil || count != 1 {
+		t.Fatalf("expected the old database to be intact, but got %d rows (%v)", count, err)
+	}
+	if _, err := db.Exec("insert into t values (2)"); err != nil {
+		t.Fatalf("expected writes to be unlocked: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Recorder is a flight recorder, keeping the most recent statements run
+// by a database, for post-mortem debugging without the overhead of full tracing
+type Recorder struct {
+	mu     sync.Mutex
+	events []TraceEvent
+	next   int
+	full   bool
+}
+
+// NewRecorder returns a Recorder keeping the last n statements
+func NewRecorder(n int) *Recorder {
+	if n < 1 {
+		n = 1
+	}
+	return &Recorder{events: make([]TraceEvent, n)}
+}
+
+// WithFlightRecorder records the last n statements run by the database,
+// which are available from FlightRecorder
+func WithFlightRecorder(n int) Optional {
+	return func(c *Config) {
+		c.record = NewRecorder(n)
+	}
+}
+
+// FlightRecorder returns the recorder of a database opened WithFlightRecorder, or nil
+func FlightRecorder(db *sql.DB) *Recorder {
+	d, ok := db.Driver().(*liteDriver)
+	if !ok {
+		return nil
+	}
+	d.c.Lock()
+	defer d.c.Unlock()
+	return d.c.record
+}
+
+// Trace implements TraceSink, recording statements
+func (r *Recorder) Trace(ev TraceEvent) {
+	if ev.Kind != TraceStatement {
+		return
+	}
+	r.mu.Lock()
+	r.events[r.next] = ev
+	if r.next++; r.next == len(r.events) {
+		r.next, r.full = 0, true
+	}
+	r.mu.Unlock()
+}
+
+// Recent returns up to n of the most recent statements, oldest first, or all of them if n is 0
+func (r *Recorder) Recent(n int) []TraceEvent {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := append([]TraceEvent(nil), r.events[:r.next]...)
+	if r.full {
+		events = append(append([]TraceEvent(nil), r.events[r.next:]...), events...)
+	}
+	if n > 0 && n < len(events) {
+		events = events[len(events)-n:]
+	}
+	return events
+}
+
+// Dump writes the recorded statements, oldest first
+func (r *Recorder) Dump(w io.Writer) error {
+	return dumpEvents(w, r.Recent(0))
+}
+
+func dumpEvents(w io.Writer, events []TraceEvent) error {
+	for _, ev := range events {
+		line := fmt.Sprintf("%s %v {%q}", ev.Time.Format("15:04:05.000"), ev.Duration, ev.SQL)
+		if len(ev.Args) > 0 {
+			line += fmt.Sprintf(" args %v", ev.Args)
+		}
+		if ev.Err != nil {
+			line += fmt.Sprintf(" error: %v", ev.Err)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// teeSink returns a sink sending events to both sinks, either of which may be nil
+func teeSink(a, b TraceSink) TraceSink {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	return TraceFunc(func(ev TraceEvent) {
+		a.Trace(ev)
+		b.Trace(ev)
+	})
+}
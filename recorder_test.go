@@ -0,0 +1,52 @@
+package sqlite
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	db, err := Open(":memory:", WithFlightRecorder(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, q := range []string{"create table fr (x)", "insert into fr values(1)", "insert into fr values(2)"} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec("insert into nosuch values(?)", 3); err == nil {
+		t.Fatal("expected error")
+	}
+
+	r := FlightRecorder(db)
+	recent := r.Recent(0)
+	if len(recent) != 3 {
+		t.Fatalf("expected 3 statements but got: %+v", recent)
+	}
+	if recent[0].SQL != "insert into fr values(1)" || recent[2].Err == nil {
+		t.Fatalf("unexpected statements: %+v", recent)
+	}
+	if last := r.Recent(1); len(last) != 1 || last[0].Args[0] != int64(3) {
+		t.Fatalf("unexpected last statement: %+v", last)
+	}
+
+	var results bytes.Buffer
+	sh := NewShell(db, ShellIO{Results: &results})
+	if err := sh.Run(".recent 2"); err != nil {
+		t.Fatal(err)
+	}
+	out := results.String()
+	if strings.Count(out, "\n") != 2 || !strings.Contains(out, "no such table") {
+		t.Fatalf("unexpected dump: %q", out)
+	}
+
+	if FlightRecorder(memDB(t)) != nil {
+		t.Fatal("expected no recorder")
+	}
+	if err := NewShell(memDB(t), ShellIO{}).Run(".recent"); err == nil {
+		t.Fatal("expected error without a recorder")
+	}
+}
@@ -0,0 +1,245 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replica is a local copy of the primary of a ReplicaSet
+type replica struct {
+	file       string
+	db         *sql.DB
+	refreshing int32 // out of rotation while being refreshed
+}
+
+// ReplicaSet is a primary database with local copies for reading, refreshed from the
+// primary with the backup API, so that heavy reads are spread over several files rather
+// than contending for the primary's, e.g. when it can't use WAL. The copies lag the
+// primary by up to the refresh interval, so reads that must see the latest writes
+// should use the primary, or write with the Server of the set and wait for its Token
+type ReplicaSet struct {
+	Primary  *sql.DB
+	OnError  func(error) // called for failed refreshes, which are logged if nil
+	replicas []*replica
+	next     uint64
+	mu       sync.Mutex // refreshes are taken in turn
+	updated  time.Time
+	cancel   context.CancelFunc
+	done     chan struct{}
+	kick     chan struct{} // refreshes the copies without waiting for the interval
+
+	tmu     sync.Mutex
+	server  *Server
+	applied Token         // of the last write of the server the copies include
+	err     error         // of the last refresh
+	notify  chan struct{} // closed by each refresh
+}
+
+// Replicas opens the primary database file, with the options, and makes the number of
+// copies of it, as files beside it named by their number, e.g. "app.db.replica1", opened
+// with the same options. The copies are refreshed at every interval, if it is positive,
+// until the set is closed
+func Replicas(primary string, copies int, refreshInterval time.Duration, opts ...Optional) (*ReplicaSet, error) {
+	if copies < 1 {
+		return nil, fmt.Errorf("invalid number of replicas: %d", copies)
+	}
+	db, err := Open(primary, opts...)
+	if err != nil {
+		return nil, err
+	}
+	rs := &ReplicaSet{Primary: db, done: make(chan struct{}), kick: make(chan struct{}, 1), notify: make(chan struct{})}
+	for i := 1; i <= copies; i++ {
+		r := &replica{file: fmt.Sprintf("%s.replica%d", primary, i)}
+		if r.db, err = Open(r.file, opts...); err != nil {
+			rs.Close()
+			return nil, err
+		}
+		rs.replicas = append(rs.replicas, r)
+	}
+	if err := rs.Refresh(); err != nil {
+		rs.Close()
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	rs.cancel = cancel
+	go rs.run(ctx, refreshInterval)
+	return rs, nil
+}
+
+// run refreshes the copies at every interval, and when waited for, until the context is done
+func (rs *ReplicaSet) run(ctx context.Context, interval time.Duration) {
+	defer close(rs.done)
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-rs.kick:
+		}
+		if err := rs.Refresh(); err != nil {
+			if rs.OnError != nil {
+				rs.OnError(err)
+			} else {
+				loggerOf(rs.Primary).Error("replica refresh failed", "db", logName(rs.Primary), "op", "replicate", "error", err)
+			}
+		}
+	}
+}
+
+// Refresh copies the primary into each copy in turn, taking it out of rotation while
+// it is copied. Queries already reading a copy delay its refresh until they are done,
+// up to the busy timeout of its connections
+func (rs *ReplicaSet) Refresh() error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	// the writes done before the copies start are in them
+	token := rs.token()
+	err := rs.refresh()
+	if err == nil {
+		rs.updated = time.Now()
+	}
+	rs.tmu.Lock()
+	if err == nil {
+		rs.applied = token
+	}
+	rs.err = err
+	close(rs.notify)
+	rs.notify = make(chan struct{})
+	rs.tmu.Unlock()
+	return err
+}
+
+// refresh copies the primary into each copy
+func (rs *ReplicaSet) refresh() error {
+	for _, r := range rs.replicas {
+		atomic.StoreInt32(&r.refreshing, 1)
+		err := copyDB(rs.Primary, r.db, 1024, ioutil.Discard)
+		atomic.StoreInt32(&r.refreshing, 0)
+		if err != nil {
+			return fmt.Errorf("replica: %s, error: %w", r.file, err)
+		}
+	}
+	return nil
+}
+
+// token returns the Token of the last write of the server, if it has one
+func (rs *ReplicaSet) token() Token {
+	rs.tmu.Lock()
+	defer rs.tmu.Unlock()
+	if rs.server == nil {
+		return 0
+	}
+	return rs.server.Token()
+}
+
+// Server returns the Server of the writes to the primary, started when first used,
+// whose tokens the copies are tracked by, so readers can wait for their writes:
+//
+//	r, err := rs.Server().Exec("UPDATE ...", ...)
+//	...
+//	err = rs.WaitFor(ctx, r.Token)
+//	row := rs.QueryRow("SELECT ...", ...)
+func (rs *ReplicaSet) Server() *Server {
+	rs.tmu.Lock()
+	defer rs.tmu.Unlock()
+	if rs.server == nil {
+		rs.server = NewServer(rs.Primary)
+	}
+	return rs.server
+}
+
+// WaitFor waits until the copies include the write of the token, from the Server of the
+// set, refreshing them without waiting for the interval. It returns the error of the
+// refresh if it failed, or of the context if it is done first
+func (rs *ReplicaSet) WaitFor(ctx context.Context, token Token) error {
+	for {
+		rs.tmu.Lock()
+		applied, notify := rs.applied, rs.notify
+		rs.tmu.Unlock()
+		if token <= applied {
+			return nil
+		}
+		select {
+		case rs.kick <- struct{}{}:
+		default:
+			// a refresh is already due
+		}
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		rs.tmu.Lock()
+		applied, err := rs.applied, rs.err
+		rs.tmu.Unlock()
+		if err != nil && token > applied {
+			return err
+		}
+	}
+}
+
+// Refreshed returns when the copies were last refreshed
+func (rs *ReplicaSet) Refreshed() time.Time {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.updated
+}
+
+// Reader returns the next copy in rotation, skipping one being refreshed
+func (rs *ReplicaSet) Reader() *sql.DB {
+	n := uint64(len(rs.replicas))
+	start := atomic.AddUint64(&rs.next, 1)
+	for i := uint64(0); i < n; i++ {
+		r := rs.replicas[(start+i)%n]
+		if atomic.LoadInt32(&r.refreshing) == 0 {
+			return r.db
+		}
+	}
+	// all are being refreshed, as with a single copy
+	return rs.replicas[start%n].db
+}
+
+// Query runs a query on the next copy in rotation
+func (rs *ReplicaSet) Query(q string, args ...interface{}) (*sql.Rows, error) {
+	return rs.Reader().Query(q, args...)
+}
+
+// QueryRow runs a query returning a single row on the next copy in rotation
+func (rs *ReplicaSet) QueryRow(q string, args ...interface{}) *sql.Row {
+	return rs.Reader().QueryRow(q, args...)
+}
+
+// Close stops the refreshes, closes the databases, and removes the copies
+func (rs *ReplicaSet) Close() error {
+	if rs.cancel != nil {
+		rs.cancel()
+		<-rs.done
+	}
+	if rs.server != nil {
+		rs.server.Close()
+	}
+	err := rs.Primary.Close()
+	for _, r := range rs.replicas {
+		if cerr := r.db.Close(); err == nil {
+			err = cerr
+		}
+		for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+			if rerr := os.Remove(r.file + suffix); rerr != nil && !os.IsNotExist(rerr) && err == nil {
+				err = rerr
+			}
+		}
+	}
+	return err
+}
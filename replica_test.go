@@ -0,0 +1,178 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReplicas(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "primary.db")
+	db, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prepare(db)
+	db.Close()
+
+	rs, err := Replicas(file, 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readers := make(map[*sql.DB]bool)
+	for i := 0; i < 3; i++ {
+		readers[rs.Reader()] = true
+	}
+	if len(readers) != 3 || readers[rs.Primary] {
+		t.Errorf("expected reads spread over the copies but got %d readers", len(readers))
+	}
+
+	count := func() int {
+		var n int
+		if err := rs.QueryRow("select count(*) from structs").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := count(); n != 4 {
+		t.Errorf("expected 4 rows but got: %d", n)
+	}
+	if _, err := rs.Primary.Exec("insert into structs(name, kind, data) values('nop', 1, 'new')"); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 4 {
+		t.Errorf("expected the copies to lag but got: %d", n)
+	}
+
+	// reads continue while the copies are refreshed
+	before := rs.Refreshed()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			if n := count(); n != 4 && n != 5 {
+				t.Errorf("unexpected count: %d", n)
+			}
+		}
+	}()
+	if err := rs.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if !rs.Refreshed().After(before) {
+		t.Error("expected the refresh time to advance")
+	}
+	for i := 0; i < 3; i++ {
+		if n := count(); n != 5 {
+			t.Errorf("expected 5 rows but got: %d", n)
+		}
+	}
+
+	if err := rs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file + ".replica1"); !os.IsNotExist(err) {
+		t.Errorf("expected copy to be removed: %v", err)
+	}
+	if _, err := Replicas(file, 0, 0); err == nil {
+		t.Error("expected error for no copies")
+	}
+}
+
+func TestReplicasRefreshInterval(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "primary.db")
+	rs, err := Replicas(file, 1, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	if _, err := rs.Primary.Exec("create table t (x)"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var n int
+		err := rs.QueryRow("select count(*) from t").Scan(&n)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("copy was not refreshed: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReplicasWaitFor(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "primary.db")
+	rs, err := Replicas(file, 2, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	ctx := context.Background()
+	if err := rs.WaitFor(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	s := rs.Server()
+	if s != rs.Server() {
+		t.Fatal("expected the same server")
+	}
+	r, err := s.Exec("create table t (x)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Token != 1 {
+		t.Errorf("expected token 1 but got: %d", r.Token)
+	}
+	if err := rs.WaitFor(ctx, r.Token); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		var n int
+		if err := rs.QueryRow("select count(*) from t").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = s.Transact(func(tx Tx) error {
+		_, err := tx.ExecContext(ctx, "insert into t values(1),(2)")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token := s.Token(); token != 2 {
+		t.Errorf("expected token 2 but got: %d", token)
+	}
+	if err := rs.WaitFor(ctx, s.Token()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		var n int
+		if err := rs.QueryRow("select count(*) from t").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Errorf("expected 2 rows but got: %d", n)
+		}
+	}
+
+	if _, err := s.Exec("insert into nosuch values(1)"); err == nil {
+		t.Fatal("expected error")
+	}
+	if token := s.Token(); token != 2 {
+		t.Errorf("expected failed writes to have no token but got: %d", token)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := rs.WaitFor(ctx, 99); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline for a token not written but got: %v", err)
+	}
+}
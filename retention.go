@@ -0,0 +1,155 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RetentionPolicy is how long the rows of a table are kept, by the time in one of its columns
+type RetentionPolicy struct {
+	Table     string
+	Column    string        // the time of each row, as text SQLite can parse, or unix seconds if Unix
+	Unix      bool          // the column holds unix seconds
+	MaxAge    time.Duration // rows older than this are removed
+	Archive   string        // optional table the rows are copied to before being deleted, created if needed
+	BatchSize int           // the most rows removed per transaction, 1000 if not set
+	Pause     time.Duration // sleep between batches, so writers aren't locked out for long
+	Filter    *Where        // optional, limits the rows removed to those it matches
+}
+
+// RetentionResult is the rows removed from a table by a policy
+type RetentionResult struct {
+	Table    string
+	Deleted  int64
+	Archived int64
+}
+
+// where returns the conditions selecting the expired rows
+func (p RetentionPolicy) where(now time.Time) *Where {
+	cutoff := now.Add(-p.MaxAge).UTC()
+	w := W()
+	if p.Unix {
+		w.Raw(quoteIdent(p.Column)+" < ?", cutoff.Unix())
+	} else {
+		w.Raw("julianday("+quoteIdent(p.Column)+") < julianday(?)", cutoff.Format("2006-01-02 15:04:05.000"))
+	}
+	return w.And(p.Filter)
+}
+
+// Retention deletes the rows older than each policy allows, copying them to its archive
+// table if it has one. Rows are removed in batches by rowid, each in its own transaction,
+// so tables without a rowid aren't supported
+func Retention(db *sql.DB, policies []RetentionPolicy) ([]RetentionResult, error) {
+	results := make([]RetentionResult, 0, len(policies))
+	for _, p := range policies {
+		r, err := retain(db, p)
+		results = append(results, r)
+		if err != nil {
+			return results, fmt.Errorf("retention: %s, error: %w", p.Table, err)
+		}
+	}
+	return results, nil
+}
+
+// retain applies a single policy
+func retain(db *sql.DB, p RetentionPolicy) (RetentionResult, error) {
+	r := RetentionResult{Table: p.Table}
+	switch {
+	case p.Table == "" || p.Column == "":
+		return r, fmt.Errorf("policy needs a table and column")
+	case p.MaxAge <= 0:
+		return r, fmt.Errorf("invalid max age: %v", p.MaxAge)
+	}
+	if p.BatchSize <= 0 {
+		p.BatchSize = 1000
+	}
+	table := quoteIdent(p.Table)
+	if p.Archive != "" {
+		create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s AS SELECT * FROM %s WHERE 0", quoteIdent(p.Archive), table)
+		if _, err := db.Exec(create); err != nil {
+			return r, err
+		}
+	}
+	clause, args := whereClause("", p.where(time.Now()))
+	batch := fmt.Sprintf("SELECT rowid FROM %s%s LIMIT %d", table, clause, p.BatchSize)
+	remove := fmt.Sprintf("DELETE FROM %s WHERE rowid IN (%s)", table, batch)
+	archive := fmt.Sprintf("INSERT INTO %s SELECT * FROM %s WHERE rowid IN (%s)", quoteIdent(p.Archive), table, batch)
+	for {
+		tx, err := db.Begin()
+		if err != nil {
+			return r, err
+		}
+		var archived int64
+		if p.Archive != "" {
+			result, err := tx.Exec(archive, args...)
+			if err != nil {
+				tx.Rollback()
+				return r, err
+			}
+			archived, _ = result.RowsAffected()
+		}
+		result, err := tx.Exec(remove, args...)
+		if err != nil {
+			tx.Rollback()
+			return r, err
+		}
+		deleted, _ := result.RowsAffected()
+		if err := tx.Commit(); err != nil {
+			return r, err
+		}
+		r.Deleted += deleted
+		r.Archived += archived
+		if deleted < int64(p.BatchSize) {
+			return r, nil
+		}
+		if p.Pause > 0 {
+			time.Sleep(p.Pause)
+		}
+	}
+}
+
+// RetentionScheduler periodically applies retention policies to a database
+type RetentionScheduler struct {
+	DB       *sql.DB
+	Policies []RetentionPolicy
+	Interval time.Duration
+	OnError  func(error)             // called for failed runs, which are logged if nil
+	OnRun    func([]RetentionResult) // optional, called with the results of each completed run
+}
+
+// RunOnce applies the policies once
+func (s *RetentionScheduler) RunOnce() ([]RetentionResult, error) {
+	results, err := Retention(s.DB, s.Policies)
+	if err != nil {
+		return results, err
+	}
+	if s.OnRun != nil {
+		s.OnRun(results)
+	}
+	return results, nil
+}
+
+// Run applies the policies immediately and then at every interval until the context is done
+func (s *RetentionScheduler) Run(ctx context.Context) error {
+	if s.Interval <= 0 {
+		return fmt.Errorf("invalid retention interval: %v", s.Interval)
+	}
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RunOnce(); err != nil {
+			if s.OnError != nil {
+				s.OnError(err)
+			} else {
+				loggerOf(s.DB).Error("retention failed", "db", logName(s.DB), "op", "retention", "error", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
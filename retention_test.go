@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRetention(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	const create = `
+create table events (id integer primary key, name text, created timestamp);
+create table hits (id integer primary key, at integer);
+`
+	if _, err := db.Exec(create); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i := 0; i < 25; i++ {
+		age := time.Duration(i) * time.Hour
+		if _, err := db.Exec("insert into events (name, created) values(?,?)", "event", now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("insert into hits (at) values(?)", now.Add(-age).Unix()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	policies := []RetentionPolicy{
+		{Table: "events", Column: "created", MaxAge: 10*time.Hour - time.Minute, Archive: "events_archive", BatchSize: 4, Pause: time.Millisecond},
+		{Table: "hits", Column: "at", Unix: true, MaxAge: 20*time.Hour - time.Minute},
+	}
+	results, err := Retention(db, policies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Deleted != 15 || results[0].Archived != 15 {
+		t.Errorf("unexpected events results: %+v", results[0])
+	}
+	if results[1].Deleted != 5 || results[1].Archived != 0 {
+		t.Errorf("unexpected hits results: %+v", results[1])
+	}
+	var kept, archived int
+	if err := row(db, []interface{}{&kept}, "select count(*) from events"); err != nil || kept != 10 {
+		t.Errorf("expected 10 events kept but got: %d (%v)", kept, err)
+	}
+	if err := row(db, []interface{}{&archived}, "select count(*) from events_archive where name='event'"); err != nil || archived != 15 {
+		t.Errorf("expected 15 events archived but got: %d (%v)", archived, err)
+	}
+
+	if _, err := Retention(db, []RetentionPolicy{{Table: "events", Column: "created"}}); err == nil {
+		t.Error("expected error for missing max age")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &RetentionScheduler{
+		DB:       db,
+		Policies: policies,
+		Interval: time.Hour,
+		OnRun: func(results []RetentionResult) {
+			if results[0].Deleted != 0 {
+				t.Errorf("expected nothing left to delete but got: %+v", results[0])
+			}
+			cancel()
+		},
+	}
+	if err := s.Run(ctx); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// SampleOptions controls SampleTables
+type SampleOptions struct {
+	Tables      []string  // tables to sample, all tables when empty
+	Fraction    float64   // fraction of each table's rows to sample, all rows when 0
+	Limit       int       // maximum rows sampled per table, no limit when 0
+	PreserveFKs bool      // also copy the rows referenced by foreign keys of sampled rows
+	Progress    io.Writer // receives progress reports
+}
+
+// foreignKey is a foreign key of a table
+type foreignKey struct {
+	parent string
+	from   []string // columns of the child table
+	to     []string // columns of the parent table
+}
+
+// foreignKeys returns the foreign keys of the table
+func foreignKeys(db *sql.DB, table string) ([]foreignKey, error) {
+	var keys []foreignKey
+	ids := make(map[int64]int)
+	fn := func(_ []string, row []interface{}) {
+		// id, seq, table, from, to, on_update, on_delete, match
+		id, _ := row[0].(int64)
+		i, ok := ids[id]
+		if !ok {
+			i = len(keys)
+			ids[id] = i
+			keys = append(keys, foreignKey{parent: fmt.Sprint(row[2])})
+		}
+		keys[i].from = append(keys[i].from, fmt.Sprint(row[3]))
+		if row[4] != nil {
+			keys[i].to = append(keys[i].to, fmt.Sprint(row[4]))
+		}
+	}
+	if err := query(db, fn, "PRAGMA foreign_key_list("+quoteIdent(table)+")"); err != nil {
+		return nil, err
+	}
+	// a key without parent columns refers to the parent's primary key
+	for i, key := range keys {
+		if len(key.to) == 0 {
+			pk, err := primaryKey(db, key.parent)
+			if err != nil {
+				return nil, err
+			}
+			keys[i].to = pk
+		}
+	}
+	return keys, nil
+}
+
+// SampleTables copies a random sample of the rows of tables in src to dst,
+// creating the tables (and their indexes) in dst as needed. With PreserveFKs,
+// the rows referenced by the sample are copied too, so the sample remains
+// referentially intact
+func SampleTables(src, dst *sql.DB, opts SampleOptions) error {
+	w := opts.Progress
+	if w == nil {
+		w = ioutil.Discard
+	}
+	if opts.Fraction < 0 || opts.Fraction > 1 {
+		return fmt.Errorf("sample fraction must be between 0 and 1, not %v", opts.Fraction)
+	}
+	tables := opts.Tables
+	if len(tables) == 0 {
+		var err error
+		if tables, err = Tables(src); err != nil {
+			return err
+		}
+	}
+	s := &sampler{src: src, dst: dst, w: w, columns: make(map[string][]string)}
+	for _, table := range tables {
+		q := "SELECT %s FROM " + quoteIdent(table)
+		var args []interface{}
+		if opts.Fraction > 0 && opts.Fraction < 1 {
+			q += " WHERE abs(random() %% 1000000) < ?"
+			args = append(args, int(opts.Fraction*1000000))
+		}
+		if opts.Limit > 0 {
+			q += " ORDER BY random() LIMIT ?"
+			args = append(args, opts.Limit)
+		}
+		n, err := s.copyRows(table, q, args...)
+		if err != nil {
+			return fmt.Errorf("sample table: %s, error: %w", table, err)
+		}
+		fmt.Fprintf(w, "table: %s sampled: %d rows\n", table, n)
+	}
+	if !opts.PreserveFKs {
+		return nil
+	}
+
+	// copy missing parent rows until every reference is satisfied,
+	// as parents may have parents of their own
+	for {
+		added := 0
+		for _, table := range s.created {
+			n, err := s.copyParents(table)
+			if err != nil {
+				return fmt.Errorf("sample parents of table: %s, error: %w", table, err)
+			}
+			added += n
+		}
+		if added == 0 {
+			return nil
+		}
+	}
+}
+
+// sampler copies rows from src to dst
+type sampler struct {
+	src, dst *sql.DB
+	w        io.Writer
+	columns  map[string][]string // columns of the tables created in dst
+	created  []string
+}
+
+// create creates the table in dst, if it hasn't been already, returning its columns
+func (s *sampler) create(table string) ([]string, error) {
+	if columns, ok := s.columns[table]; ok {
+		return columns, nil
+	}
+	columns, err := tableColumns(s.src, table)
+	if err != nil {
+		return nil, err
+	}
+	var exists int
+	if err := row(s.dst, []interface{}{&exists}, "SELECT count(*) FROM sqlite_master WHERE type='table' AND name=?", table); err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		schema, err := schemaSQL(s.src, "table", table)
+		if err != nil {
+			return nil, err
+		}
+		indexes, err := schemaSQL(s.src, "index", table)
+		if err != nil {
+			return nil, err
+		}
+		for _, stmt := range append(schema, indexes...) {
+			if _, err := s.dst.Exec(stmt); err != nil {
+				return nil, err
+			}
+		}
+	}
+	s.columns[table] = columns
+	s.created = append(s.created, table)
+	return columns, nil
+}
+
+// copyRows copies the rows selected by query, a format with a verb for the column list,
+// skipping rows already in dst. It returns the number of rows added
+func (s *sampler) copyRows(table, query string, args ...interface{}) (int, error) {
+	columns, err := s.create(table)
+	if err != nil {
+		return 0, err
+	}
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdent(c)
+	}
+	list := strings.Join(quoted, ",")
+	rows, err := s.src.Query(fmt.Sprintf(query, list), args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	tx, err := s.dst.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	insert := fmt.Sprintf("INSERT OR IGNORE INTO %s (%s) VALUES(%s)", quoteIdent(table), list,
+		strings.TrimSuffix(strings.Repeat("?,", len(columns)), ","))
+	stmt, err := tx.Prepare(insert)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	values := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	added := 0
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return 0, err
+		}
+		result, err := stmt.Exec(values...)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			added++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	return added, tx.Commit()
+}
+
+// copyParents copies the rows referenced by the table's rows in dst that are missing from dst
+func (s *sampler) copyParents(table string) (int, error) {
+	keys, err := foreignKeys(s.src, table)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, key := range keys {
+		if len(key.from) != len(key.to) {
+			return 0, fmt.Errorf("foreign key to %s has mismatched columns", key.parent)
+		}
+		if _, err := s.create(key.parent); err != nil {
+			return 0, err
+		}
+		missing, err := s.missingRefs(table, key)
+		if err != nil {
+			return 0, err
+		}
+		match := make([]string, len(key.to))
+		for i, c := range key.to {
+			match[i] = quoteIdent(c) + "=?"
+		}
+		q := "SELECT %s FROM " + quoteIdent(key.parent) + " WHERE " + strings.Join(match, " AND ")
+		for _, ref := range missing {
+			n, err := s.copyRows(key.parent, q, ref...)
+			if err != nil {
+				return 0, err
+			}
+			added += n
+		}
+		if len(missing) > 0 {
+			fmt.Fprintf(s.w, "table: %s referenced by %s: %d rows\n", key.parent, table, len(missing))
+		}
+	}
+	return added, nil
+}
+
+// missingRefs returns the distinct non-null references of the table's rows in dst
+// that have no matching parent row in dst
+func (s *sampler) missingRefs(table string, key foreignKey) ([][]interface{}, error) {
+	from := make([]string, len(key.from))
+	notNull := make([]string, len(key.from))
+	match := make([]string, len(key.from))
+	for i := range key.from {
+		from[i] = "c." + quoteIdent(key.from[i])
+		notNull[i] = from[i] + " IS NOT NULL"
+		match[i] = "p." + quoteIdent(key.to[i]) + "=" + from[i]
+	}
+	q := fmt.Sprintf("SELECT DISTINCT %s FROM %s c WHERE %s AND NOT EXISTS (SELECT 1 FROM %s p WHERE %s)",
+		strings.Join(from, ","), quoteIdent(table), strings.Join(notNull, " AND "),
+		quoteIdent(key.parent), strings.Join(match, " AND "))
+	var refs [][]interface{}
+	fn := func(_ []string, row []interface{}) {
+		refs = append(refs, append([]interface{}(nil), row...))
+	}
+	return refs, query(s.dst, fn, q)
+}
@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const sampleSetup = `
+create table regions (id integer primary key, name text);
+create table customers (id integer primary key, name text, region integer references regions);
+create table orders (id integer primary key, customer integer references customers(id), total real);
+create index orders_customer on orders(customer);
+`
+
+func sampleSource(t *testing.T) *sql.DB {
+	t.Helper()
+	db := memDB(t)
+	var b strings.Builder
+	b.WriteString(sampleSetup)
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(&b, "insert into regions values(%d, 'region %d');\n", i, i)
+	}
+	for i := 1; i <= 50; i++ {
+		fmt.Fprintf(&b, "insert into customers values(%d, 'customer %d', %d);\n", i, i, i%5+1)
+	}
+	for i := 1; i <= 500; i++ {
+		fmt.Fprintf(&b, "insert into orders values(%d, %d, %d.5);\n", i, i%50+1, i)
+	}
+	if _, err := db.Exec(b.String()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSampleTables(t *testing.T) {
+	src := sampleSource(t)
+	dst := memDB(t)
+	opts := SampleOptions{Tables: []string{"orders"}, Limit: 20, PreserveFKs: true, Progress: testout}
+	if err := SampleTables(src, dst, opts); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := row(dst, []interface{}{&count}, "select count(*) from orders"); err != nil || count != 20 {
+		t.Fatalf("expected 20 orders but got: %d (%v)", count, err)
+	}
+	// every reference must resolve within the sample
+	for _, q := range []string{
+		"select count(*) from orders where customer not in (select id from customers)",
+		"select count(*) from customers where region not in (select id from regions)",
+	} {
+		if err := row(dst, []interface{}{&count}, q); err != nil || count != 0 {
+			t.Fatalf("expected no dangling references but got: %d (%v)", count, err)
+		}
+	}
+	if err := row(dst, []interface{}{&count}, "select count(*) from customers"); err != nil || count == 0 || count > 20 {
+		t.Fatalf("expected only referenced customers but got: %d (%v)", count, err)
+	}
+	if err := row(dst, []interface{}{&count}, "select count(*) from sqlite_master where name='orders_customer'"); err != nil || count != 1 {
+		t.Fatalf("expected index to be created but got: %d (%v)", count, err)
+	}
+}
+
+func TestSampleFraction(t *testing.T) {
+	src := sampleSource(t)
+	dst := memDB(t)
+	if err := SampleTables(src, dst, SampleOptions{Fraction: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := row(dst, []interface{}{&count}, "select count(*) from orders"); err != nil || count < 150 || count > 350 {
+		t.Fatalf("expected about half the orders but got: %d (%v)", count, err)
+	}
+	if err := SampleTables(src, memDB(t), SampleOptions{Fraction: 2}); err == nil {
+		t.Fatal("expected error for invalid fraction")
+	}
+}
@@ -0,0 +1,201 @@
+package sqlite
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// fieldCache maps a struct type to its fields, keyed by lower case column name
+var fieldCache sync.Map
+
+// structFields returns the column names of a struct's fields and their indexes.
+// Columns are named by the field's "sql" tag, or the field's name otherwise;
+// fields tagged "-" are ignored
+func structFields(t reflect.Type) map[string][]int {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.(map[string][]int)
+	}
+	fields := make(map[string][]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		name := f.Tag.Get("sql")
+		if name == "-" {
+			continue
+		}
+		if i := strings.IndexByte(name, ','); i >= 0 {
+			name = name[:i]
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = f.Index
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// ScanRow scans the current row into the struct pointed to by dest.
+// Columns without a matching field are discarded, and a NULL sets
+// a field to its zero value unless the field is a pointer or a sql.Scanner
+func ScanRow(rows *sql.Rows, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("destination must be a pointer to a struct, not %T", dest)
+	}
+	v = v.Elem()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	fields := structFields(v.Type())
+	targets := make([]interface{}, len(columns))
+	after := make([]func(), 0, len(columns))
+	for i, column := range columns {
+		index, ok := fields[strings.ToLower(column)]
+		if !ok {
+			targets[i] = new(interface{})
+			continue
+		}
+		field := v.FieldByIndex(index)
+		target, fn := scanTarget(field)
+		targets[i] = target
+		if fn != nil {
+			after = append(after, fn)
+		}
+	}
+	if err := rows.Scan(targets...); err != nil {
+		return err
+	}
+	for _, fn := range after {
+		fn()
+	}
+	return nil
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// scanTarget returns what to scan a field into, and a func to assign the field afterwards if required
+func scanTarget(field reflect.Value) (interface{}, func()) {
+	addr := field.Addr()
+	switch {
+	case field.Kind() == reflect.Ptr, addr.Type().Implements(scannerType):
+		return addr.Interface(), nil
+	case field.Type() == timeType:
+		var t NullTime
+		return &t, func() { field.Set(reflect.ValueOf(t.Time)) }
+	}
+	// scanning into a pointer to the field's type leaves it nil for NULL
+	ptr := reflect.New(reflect.PtrTo(field.Type()))
+	return ptr.Interface(), func() {
+		if p := ptr.Elem(); p.IsNil() {
+			field.Set(reflect.Zero(field.Type()))
+		} else {
+			field.Set(p.Elem())
+		}
+	}
+}
+
+// NullTime is a time.Time that may be NULL, scanned from any of SQLite's time formats
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+// Scan implements the sql.Scanner interface
+func (n *NullTime) Scan(value interface{}) error {
+	n.Time, n.Valid = time.Time{}, false
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		n.Time = v
+	case int64:
+		n.Time = time.Unix(v, 0).UTC()
+	case float64:
+		// julian day number
+		n.Time = time.Unix(int64((v-2440587.5)*86400), 0).UTC()
+	case []byte:
+		return n.parse(string(v))
+	case string:
+		return n.parse(v)
+	default:
+		return fmt.Errorf("can't scan %T into NullTime", value)
+	}
+	n.Valid = true
+	return nil
+}
+
+func (n *NullTime) parse(s string) error {
+	s = strings.TrimSuffix(s, "Z")
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(format, s, time.UTC); err == nil {
+			n.Time, n.Valid = t, true
+			return nil
+		}
+	}
+	return fmt.Errorf("can't parse time: %q", s)
+}
+
+// Value implements the driver.Valuer interface
+func (n NullTime) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Time, nil
+}
+
+// NullJSON is a JSON document that may be NULL
+type NullJSON struct {
+	JSON  json.RawMessage
+	Valid bool
+}
+
+// Scan implements the sql.Scanner interface
+func (n *NullJSON) Scan(value interface{}) error {
+	n.JSON, n.Valid = nil, false
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		n.JSON = append(json.RawMessage(nil), v...)
+	case string:
+		n.JSON = json.RawMessage(v)
+	default:
+		return fmt.Errorf("can't scan %T into NullJSON", value)
+	}
+	if !json.Valid(n.JSON) {
+		return fmt.Errorf("invalid JSON: %q", n.JSON)
+	}
+	n.Valid = true
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (n NullJSON) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return string(n.JSON), nil
+}
+
+// Unmarshal decodes the document into v, leaving v unchanged if it is NULL
+func (n NullJSON) Unmarshal(v interface{}) error {
+	if !n.Valid {
+		return nil
+	}
+	return json.Unmarshal(n.JSON, v)
+}
@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScanRow(t *testing.T) {
+	db := memDB(t)
+	const setup = `
+create table people (id integer primary key, name text, age int, born text, seen int, prefs text, extra text);
+insert into people values(1, 'alice', 30, '1990-04-01 12:30:00', 1600000000, '{"theme":"dark"}', 'x');
+insert into people values(2, null, null, null, null, null, null);
+`
+	if _, err := db.Exec(setup); err != nil {
+		t.Fatal(err)
+	}
+	type person struct {
+		ID      int64
+		Name    string
+		Age     *int
+		Born    time.Time
+		Seen    NullTime
+		Prefs   NullJSON
+		Ignored string `sql:"-"`
+		hidden  string
+	}
+	rows, err := db.Query("select * from people order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var people []person
+	for rows.Next() {
+		var p person
+		if err := ScanRow(rows, &p); err != nil {
+			t.Fatal(err)
+		}
+		people = append(people, p)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(people) != 2 {
+		t.Fatalf("expected 2 people but got: %d", len(people))
+	}
+
+	alice := people[0]
+	if alice.Name != "alice" || alice.Age == nil || *alice.Age != 30 {
+		t.Errorf("unexpected person: %+v", alice)
+	}
+	if want := time.Date(1990, 4, 1, 12, 30, 0, 0, time.UTC); !alice.Born.Equal(want) {
+		t.Errorf("expected born %v but got: %v", want, alice.Born)
+	}
+	if !alice.Seen.Valid || alice.Seen.Time.Unix() != 1600000000 {
+		t.Errorf("unexpected seen: %+v", alice.Seen)
+	}
+	var prefs struct{ Theme string }
+	if err := alice.Prefs.Unmarshal(&prefs); err != nil || prefs.Theme != "dark" {
+		t.Errorf("unexpected prefs: %+v (%v)", prefs, err)
+	}
+
+	nobody := people[1]
+	if nobody.Name != "" || nobody.Age != nil || !nobody.Born.IsZero() || nobody.Seen.Valid || nobody.Prefs.Valid {
+		t.Errorf("expected zero values but got: %+v", nobody)
+	}
+}
+
+func TestScanRowBadDest(t *testing.T) {
+	db := memDB(t)
+	rows, err := db.Query("select 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	rows.Next()
+	var i int
+	if err := ScanRow(rows, &i); err == nil {
+		t.Fatal("expected error for non-struct destination")
+	}
+}
+
+func TestNullTimeValue(t *testing.T) {
+	db := memDB(t)
+	if _, err := db.Exec("create table times (t timestamp)"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, v := range []NullTime{{}, {Time: now, Valid: true}} {
+		if _, err := db.Exec("insert into times values(?)", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var count int
+	if err := row(db, []interface{}{&count}, "select count(*) from times where t is null"); err != nil || count != 1 {
+		t.Fatalf("expected one null but got: %d (%v)", count, err)
+	}
+	var got NullTime
+	if err := row(db, []interface{}{&got}, "select t from times where t is not null"); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Valid || !got.Time.Equal(now) {
+		t.Fatalf("expected %v but got: %+v", now, got)
+	}
+}
@@ -0,0 +1,234 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// SchemaSpec is the desired schema of a database, for EnsureSchema
+type SchemaSpec struct {
+	SQL              string   // statements creating the tables, indexes, views, and triggers
+	AllowDestructive bool     // allow dropping objects and rebuilding changed tables
+	Ignore           []string // patterns (as in path.Match) of tables managed elsewhere, which are left alone
+}
+
+// ErrDestructive is returned by EnsureSchema when the changes needed
+// to reach the desired schema would lose data, and they are not allowed
+type ErrDestructive struct {
+	Changes []SchemaChange
+}
+
+func (e *ErrDestructive) Error() string {
+	list := make([]string, len(e.Changes))
+	for i, c := range e.Changes {
+		list[i] = c.String()
+	}
+	return "destructive schema changes not allowed: " + strings.Join(list, ", ")
+}
+
+func (s SchemaSpec) ignored(table string) bool {
+	for _, pattern := range s.Ignore {
+		if ok, _ := path.Match(pattern, table); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsureSchema changes the schema of db to match the desired schema, returning the changes made.
+// Missing objects are created, and columns added to the end of a table are added
+// with ALTER TABLE. Changed indexes, views, and triggers are recreated.
+//
+// Dropping objects, and rebuilding tables that changed in other ways, are destructive,
+// and unless allowed by the spec, EnsureSchema returns an *ErrDestructive without
+// making any changes. Rebuilt tables keep the rows of the columns they still have
+func EnsureSchema(db *sql.DB, desired SchemaSpec) ([]SchemaChange, error) {
+	want, err := Open(":memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer want.Close()
+	want.SetMaxOpenConns(1) // each connection would have its own database
+	if _, err := want.Exec(desired.SQL); err != nil {
+		return nil, fmt.Errorf("desired schema: %w", err)
+	}
+
+	changes, err := schemaChanges(db, want, desired)
+	if err != nil {
+		return nil, err
+	}
+	var rebuild, destructive []SchemaChange
+	for _, c := range changes {
+		switch {
+		case c.Action == DiffRemoved:
+			destructive = append(destructive, c)
+		case c.Action == DiffChanged && c.Type == "table":
+			if _, ok := addedColumns(db, want, c.Name); !ok {
+				destructive = append(destructive, c)
+				rebuild = append(rebuild, c)
+			}
+		}
+	}
+	if len(destructive) > 0 && !desired.AllowDestructive {
+		return nil, &ErrDestructive{Changes: destructive}
+	}
+
+	// tables are rebuilt first, as dropping the old table drops its indexes and triggers
+	for _, c := range rebuild {
+		if err := rebuildTable(db, want, c.Name, c.To); err != nil {
+			return nil, fmt.Errorf("rebuild table: %s, error: %w", c.Name, err)
+		}
+	}
+	applied := rebuild
+	if len(rebuild) > 0 {
+		if changes, err = schemaChanges(db, want, desired); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range changes {
+		if _, err := db.Exec(c.SQL(db, want)); err != nil {
+			return applied, fmt.Errorf("%s: %w", c, err)
+		}
+		applied = append(applied, c)
+	}
+	return applied, nil
+}
+
+// schemaChanges returns the differences that matter between the schemas
+func schemaChanges(db, want *sql.DB, desired SchemaSpec) ([]SchemaChange, error) {
+	diff, err := SchemaDiff(db, want)
+	if err != nil {
+		return nil, err
+	}
+	var changes []SchemaChange
+	for _, c := range diff {
+		if desired.ignored(c.Table) {
+			continue
+		}
+		// columns added by ALTER TABLE leave their own formatting in the schema
+		if c.Action == DiffChanged && canonicalSQL(c.From) == canonicalSQL(c.To) {
+			continue
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+var (
+	canonicalToken = regexp.MustCompile(`'(?:[^']|'')*'|"[A-Za-z_][A-Za-z0-9_]*"|\s+|.`)
+	canonicalPunct = "(),;=<>+-*/"
+)
+
+// canonicalSQL reduces create statements to a form that ignores differences
+// in case, whitespace, and quoting of plain identifiers
+func canonicalSQL(s string) string {
+	var b strings.Builder
+	space := false
+	for _, tok := range canonicalToken.FindAllString(s, -1) {
+		switch {
+		case strings.TrimSpace(tok) == "":
+			space = true
+			continue
+		case tok[0] == '\'':
+			// string literals are kept as they are
+		case tok[0] == '"':
+			tok = strings.ToLower(tok[1 : len(tok)-1])
+		default:
+			tok = strings.ToLower(tok)
+		}
+		if space && b.Len() > 0 && !strings.ContainsAny(tok[:1], canonicalPunct) {
+			if last := b.String()[b.Len()-1:]; !strings.ContainsAny(last, canonicalPunct) {
+				b.WriteByte(' ')
+			}
+		}
+		space = false
+		b.WriteString(tok)
+	}
+	return b.String()
+}
+
+// rebuildTable replaces a table with one created by the given statement,
+// copying the values of the columns common to both. The old table is renamed
+// out of the way with legacy_alter_table so references to it by other
+// tables, views, and triggers are kept for the new table. The statements
+// after are run once the old table is dropped, in the same transaction
+func rebuildTable(db, want *sql.DB, table, create string, after ...string) error {
+	oldCols, err := tableColumns(db, table)
+	if err != nil {
+		return err
+	}
+	newCols, err := tableColumns(want, table)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool)
+	for _, c := range newCols {
+		keep[strings.ToLower(c)] = true
+	}
+	var common []string
+	for _, c := range oldCols {
+		if keep[strings.ToLower(c)] {
+			common = append(common, quoteIdent(c))
+		}
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var fks bool
+	if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&fks); err != nil {
+		return err
+	}
+	// foreign keys can only be switched off outside of a transaction
+	for _, pragma := range []string{"PRAGMA foreign_keys=OFF", "PRAGMA legacy_alter_table=ON"} {
+		if _, err := conn.ExecContext(ctx, pragma); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		conn.ExecContext(ctx, "PRAGMA legacy_alter_table=OFF")
+		if fks {
+			conn.ExecContext(ctx, "PRAGMA foreign_keys=ON")
+		}
+	}()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	old := quoteIdent("_old_" + table)
+	stmts := []string{
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteIdent(table), old),
+		create,
+	}
+	if len(common) > 0 {
+		list := strings.Join(common, ",")
+		stmts = append(stmts, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", quoteIdent(table), list, list, old))
+	}
+	stmts = append(stmts, "DROP TABLE "+old)
+	for _, stmt := range append(stmts, after...) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if fks {
+		rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+		if err != nil {
+			return err
+		}
+		violation := rows.Next()
+		rows.Close()
+		if violation {
+			return fmt.Errorf("rebuilt table violates foreign keys")
+		}
+	}
+	return tx.Commit()
+}
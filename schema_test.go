@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"strings"
+	"testing"
+)
+
+const schemaV1 = `
+CREATE TABLE users (
+	id integer primary key,
+	name text
+);
+CREATE TABLE posts (id integer primary key, user integer references users, body text);
+`
+
+const schemaV2 = schemaV1 + `
+CREATE TABLE tags (name text primary key);
+CREATE INDEX posts_user ON posts(user);
+`
+
+func TestEnsureSchema(t *testing.T) {
+	db := memDB(t)
+	if _, err := db.Exec("create table cache (key text)"); err != nil {
+		t.Fatal(err)
+	}
+	spec := SchemaSpec{SQL: schemaV1, Ignore: []string{"cache"}}
+	changes, err := EnsureSchema(db, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes but got: %v", changes)
+	}
+	if _, err := db.Exec("insert into users values(1, 'bob'); insert into posts values(1, 1, 'hello')"); err != nil {
+		t.Fatal(err)
+	}
+
+	spec.SQL = schemaV2
+	if changes, err = EnsureSchema(db, spec); err != nil || len(changes) != 2 {
+		t.Fatalf("expected 2 changes but got: %v (%v)", changes, err)
+	}
+
+	// columns added to the end of a table don't need a rebuild, and are then up to date
+	spec.SQL = schemaV2 + "\n" + `CREATE TABLE extra (id integer primary key);`
+	spec.SQL = strings.Replace(spec.SQL, "name text\n", "name text,\n\temail text default ''\n", 1)
+	for i, want := range []int{2, 0} {
+		if changes, err = EnsureSchema(db, spec); err != nil || len(changes) != want {
+			t.Fatalf("run %d: expected %d changes but got: %v (%v)", i, want, changes, err)
+		}
+	}
+
+	// dropping a column requires a rebuild, which is destructive
+	rebuilt := strings.Replace(spec.SQL, "body text", "title text", 1)
+	if _, err = EnsureSchema(db, SchemaSpec{SQL: rebuilt, Ignore: spec.Ignore}); err == nil {
+		t.Fatal("expected destructive changes to be rejected")
+	} else if _, ok := err.(*ErrDestructive); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changes, err = EnsureSchema(db, SchemaSpec{SQL: rebuilt, AllowDestructive: true, Ignore: spec.Ignore}); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := row(db, []interface{}{&count}, "select count(*) from posts where user=1 and title is null"); err != nil || count != 1 {
+		t.Fatalf("expected rebuilt table to keep its rows but got: %d (%v)", count, err)
+	}
+	if err := row(db, []interface{}{&count}, "select count(*) from sqlite_master where name='posts_user'"); err != nil || count != 1 {
+		t.Fatalf("expected index of rebuilt table to be recreated but got: %d (%v)", count, err)
+	}
+	if changes, err = EnsureSchema(db, SchemaSpec{SQL: rebuilt, Ignore: spec.Ignore}); err != nil || len(changes) != 0 {
+		t.Fatalf("expected no changes but got: %v (%v)", changes, err)
+	}
+}
+
+func TestCanonicalSQL(t *testing.T) {
+	a := "CREATE TABLE t (\n  id integer primary key,\n  name text DEFAULT 'X  Y'\n, \"email\" text)"
+	b := `create table t(id INTEGER primary key, name text default 'X  Y', email text)`
+	if canonicalSQL(a) != canonicalSQL(b) {
+		t.Fatalf("expected equal forms:\n%s\n%s", canonicalSQL(a), canonicalSQL(b))
+	}
+	if canonicalSQL(`create table t (x default 'a')`) == canonicalSQL(`create table t (x default 'A')`) {
+		t.Fatal("expected string literals to differ")
+	}
+}
@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StatementResult is the outcome of a statement executed by ExecScript
+type StatementResult struct {
+	Statement
+	RowsAffected int64
+	LastInsertID int64
+	Duration     time.Duration
+}
+
+// ExecScript executes each statement of the script in turn, returning the result of each.
+// Unlike passing the script to Exec, where the driver only reports the result of
+// the last statement, every statement's changes and timing are visible.
+// If a statement fails, the results of the statements before it are returned
+// along with a *ScriptError
+func ExecScript(db Queryer, script string) ([]StatementResult, error) {
+	statements, err := SplitStatements(script)
+	if err != nil {
+		serr := &ScriptError{Err: err}
+		if split, ok := err.(*SplitError); ok {
+			serr.Line = split.Line
+		}
+		return nil, serr
+	}
+	results := make([]StatementResult, 0, len(statements))
+	for i, stmt := range statements {
+		fail := func(err error) error {
+			return &ScriptError{Line: stmt.Line, Index: i + 1, SQL: snippet(stmt.SQL), Err: err}
+		}
+		if stmt.Dot {
+			return results, fail(fmt.Errorf("dot-commands are not supported"))
+		}
+		start := time.Now()
+		result, err := db.ExecContext(context.Background(), stmt.SQL)
+		if err != nil {
+			return results, fail(err)
+		}
+		r := StatementResult{Statement: stmt, Duration: time.Since(start)}
+		r.RowsAffected, _ = result.RowsAffected()
+		r.LastInsertID, _ = result.LastInsertId()
+		results = append(results, r)
+	}
+	return results, nil
+}
@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExecScript(t *testing.T) {
+	db := memDB(t)
+	const script = `
+create table items (id integer primary key, name text);
+insert into items (name) values ('a'), ('b'), ('c');
+update items set name = upper(name) where id > 1;
+delete from items where id = 3;
+`
+	results, err := ExecScript(db, script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results but got: %d", len(results))
+	}
+	for i, want := range []int64{0, 3, 2, 1} {
+		if results[i].RowsAffected != want {
+			t.Errorf("statement %d: expected %d rows affected but got: %d", i+1, want, results[i].RowsAffected)
+		}
+	}
+	if results[1].LastInsertID != 3 || results[1].Line != 3 {
+		t.Errorf("unexpected insert result: %+v", results[1])
+	}
+
+	results, err = ExecScript(db, "insert into items (name) values ('d');\ninsert into nosuch values (1);")
+	var serr *ScriptError
+	if !errors.As(err, &serr) || serr.Index != 2 || serr.Line != 2 {
+		t.Fatalf("expected script error for statement 2 but got: %v", err)
+	}
+	if len(results) != 1 || results[0].RowsAffected != 1 {
+		t.Fatalf("expected the result of the first statement but got: %+v", results)
+	}
+	if _, err := ExecScript(db, ".tables"); err == nil {
+		t.Fatal("expected error for dot-command")
+	}
+}
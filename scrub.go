@@ -0,0 +1,194 @@
+package sqlite
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// ScrubAction is how the values of a column are scrubbed
+type ScrubAction string
+
+// Scrub actions
+const (
+	ScrubHash ScrubAction = "hash" // replace with a salted hash, consistent across tables so joins still work
+	ScrubMask ScrubAction = "mask" // keep the first character of text, masking the rest
+	ScrubFake ScrubAction = "fake" // replace with a plausible fake name or email address
+	ScrubNull ScrubAction = "null" // replace with NULL
+)
+
+// ScrubRule scrubs a column, in a single table or in all tables if Table is empty or "*"
+type ScrubRule struct {
+	Table  string      `json:"table"`
+	Column string      `json:"column"`
+	Action ScrubAction `json:"action"`
+}
+
+// Scrubber replaces sensitive values when exporting data with Dump or CopyTables
+type Scrubber struct {
+	Rules []ScrubRule `json:"rules"`
+	Salt  string      `json:"salt"` // salt for hashed and fake values, so they can't be reversed by guessing
+}
+
+// Validate checks that each rule has a column and a known action
+func (s *Scrubber) Validate() error {
+	for _, r := range s.Rules {
+		if r.Column == "" {
+			return fmt.Errorf("scrub rule for table %q has no column", r.Table)
+		}
+		switch r.Action {
+		case ScrubHash, ScrubMask, ScrubFake, ScrubNull:
+		default:
+			return fmt.Errorf("scrub rule for column %q has unknown action %q", r.Column, r.Action)
+		}
+	}
+	return nil
+}
+
+// actions returns the scrub action for each column index of the table that has one
+func (s *Scrubber) actions(table string, columns []string) map[int]ScrubAction {
+	if s == nil {
+		return nil
+	}
+	var actions map[int]ScrubAction
+	for _, r := range s.Rules {
+		if r.Table != "" && r.Table != "*" && !strings.EqualFold(r.Table, table) {
+			continue
+		}
+		for i, column := range columns {
+			if strings.EqualFold(r.Column, column) {
+				if actions == nil {
+					actions = make(map[int]ScrubAction)
+				}
+				actions[i] = r.Action
+			}
+		}
+	}
+	return actions
+}
+
+// hash returns a salted hash of the value
+func (s *Scrubber) hash(v interface{}) []byte {
+	sum := sha256.Sum256([]byte(s.Salt + fmt.Sprint(v)))
+	return sum[:]
+}
+
+// Value returns the scrubbed replacement for value v
+func (s *Scrubber) Value(action ScrubAction, v interface{}) interface{} {
+	if v == nil || action == ScrubNull {
+		return nil
+	}
+	switch action {
+	case ScrubHash:
+		sum := s.hash(v)
+		switch v.(type) {
+		case int64, float64:
+			return int64(binary.BigEndian.Uint64(sum) >> 1)
+		}
+		return hex.EncodeToString(sum[:8])
+	case ScrubMask:
+		switch v := v.(type) {
+		case string:
+			return maskText(v)
+		case []byte:
+			return []byte(maskText(string(v)))
+		}
+		return int64(0)
+	case ScrubFake:
+		sum := s.hash(v)
+		switch v := v.(type) {
+		case string:
+			return fakeText(v, sum)
+		case []byte:
+			return []byte(fakeText(string(v), sum))
+		}
+		return int64(binary.BigEndian.Uint64(sum) >> 1)
+	}
+	return v
+}
+
+// scrub replaces the values of a row in place
+func (s *Scrubber) scrub(actions map[int]ScrubAction, values []interface{}) {
+	for i, action := range actions {
+		values[i] = s.Value(action, values[i])
+	}
+}
+
+func maskText(s string) string {
+	var b strings.Builder
+	first := true
+	for _, r := range s {
+		switch {
+		case unicode.IsSpace(r) || unicode.IsPunct(r):
+			b.WriteRune(r)
+		case first:
+			b.WriteRune(r)
+			first = false
+		default:
+			b.WriteByte('*')
+		}
+	}
+	return b.String()
+}
+
+var (
+	fakeFirst = []string{"Alex", "Blair", "Casey", "Dana", "Eden", "Finley", "Gray", "Harper", "Indy", "Jordan", "Kai", "Logan", "Morgan", "Noel", "Oakley", "Parker", "Quinn", "Riley", "Sage", "Taylor"}
+	fakeLast  = []string{"Adams", "Brooks", "Carter", "Dixon", "Ellis", "Foster", "Garcia", "Hayes", "Irwin", "Jensen", "Keller", "Lopez", "Miller", "Nolan", "Owens", "Perry", "Reyes", "Shaw", "Turner", "Walsh"}
+)
+
+// fakeText returns a fake replacement shaped like s: an email address, a full name, or a single name
+func fakeText(s string, sum []byte) string {
+	first := fakeFirst[int(sum[0])%len(fakeFirst)]
+	last := fakeLast[int(sum[1])%len(fakeLast)]
+	switch {
+	case strings.Contains(s, "@"):
+		return strings.ToLower(first+"."+last) + strconv.Itoa(int(sum[2])) + "@example.com"
+	case strings.Contains(strings.TrimSpace(s), " "):
+		return first + " " + last
+	}
+	return first
+}
+
+// sqlLiteral formats a value as an SQL literal
+func sqlLiteral(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NULL"
+		case math.IsInf(v, 1):
+			return "9.0e+999"
+		case math.IsInf(v, -1):
+			return "-9.0e+999"
+		}
+		f := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(f, ".e") {
+			f += ".0"
+		}
+		return f
+	case time.Time:
+		return "'" + v.Format(sqlite3.SQLiteTimestampFormats[0]) + "'"
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case []byte:
+		return "X'" + strings.ToUpper(hex.EncodeToString(v)) + "'"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+	return "'" + strings.ReplaceAll(fmt.Sprint(v), "'", "''") + "'"
+}
@@ -0,0 +1,256 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Generator returns the value of a column for row i (counting from 0) of a seeded table
+type Generator func(r *rand.Rand, i int) interface{}
+
+// TableSeed describes the rows to generate for a table.
+// Columns without a generator that are foreign keys are given the keys
+// of random rows of the parent table, and other columns get their defaults
+type TableSeed struct {
+	Table   string
+	Rows    int
+	Columns map[string]Generator
+}
+
+// SeedSpec describes the rows to generate for a set of tables
+type SeedSpec struct {
+	Tables []TableSeed
+	Seed   int64 // seed for the random values, the same spec and seed produce the same rows
+}
+
+// Seed generates rows for the tables of the spec. Tables are filled
+// parents first, so foreign keys can refer to the rows generated for them
+func Seed(db *sql.DB, spec SeedSpec) error {
+	r := rand.New(rand.NewSource(spec.Seed))
+	keys := make(map[string][]foreignKey)
+	for _, t := range spec.Tables {
+		fks, err := foreignKeys(db, t.Table)
+		if err != nil {
+			return err
+		}
+		keys[t.Table] = fks
+	}
+	tables, err := seedOrder(spec.Tables, keys)
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		if err := seedTable(db, r, t, keys[t.Table]); err != nil {
+			return fmt.Errorf("seed table: %s, error: %w", t.Table, err)
+		}
+	}
+	return nil
+}
+
+// seedOrder sorts the tables so parents come before their children
+func seedOrder(tables []TableSeed, keys map[string][]foreignKey) ([]TableSeed, error) {
+	index := make(map[string]int)
+	for i, t := range tables {
+		index[t.Table] = i
+	}
+	var (
+		sorted []TableSeed
+		state  = make(map[string]int) // 1 while visiting, 2 once sorted
+		visit  func(t TableSeed) error
+	)
+	visit = func(t TableSeed) error {
+		switch state[t.Table] {
+		case 1:
+			return fmt.Errorf("seed tables have circular foreign keys: %s", t.Table)
+		case 2:
+			return nil
+		}
+		state[t.Table] = 1
+		for _, fk := range keys[t.Table] {
+			if i, ok := index[fk.parent]; ok && fk.parent != t.Table {
+				if err := visit(tables[i]); err != nil {
+					return err
+				}
+			}
+		}
+		state[t.Table] = 2
+		sorted = append(sorted, t)
+		return nil
+	}
+	for _, t := range tables {
+		if err := visit(t); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// seedRef supplies the columns of a foreign key from the keys of the parent's rows
+type seedRef struct {
+	columns []string
+	values  [][]interface{}
+}
+
+func seedTable(db *sql.DB, r *rand.Rand, t TableSeed, fks []foreignKey) error {
+	columns, err := tableColumns(db, t.Table)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	for _, c := range columns {
+		known[strings.ToLower(c)] = true
+	}
+	var names []string
+	for name := range t.Columns {
+		if !known[strings.ToLower(name)] {
+			return fmt.Errorf("no such column: %s", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// foreign keys without generators take their values from the parent's rows
+	var refs []seedRef
+	for _, fk := range fks {
+		generated := false
+		for _, c := range fk.from {
+			if _, ok := t.Columns[c]; ok {
+				generated = true
+			}
+		}
+		if generated || len(fk.from) != len(fk.to) {
+			continue
+		}
+		ref := seedRef{columns: fk.from}
+		quoted := make([]string, len(fk.to))
+		for i, c := range fk.to {
+			quoted[i] = quoteIdent(c)
+		}
+		q := fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ","), quoteIdent(fk.parent))
+		fn := func(_ []string, row []interface{}) {
+			ref.values = append(ref.values, append([]interface{}(nil), row...))
+		}
+		if err := query(db, fn, q); err != nil {
+			return err
+		}
+		if len(ref.values) == 0 && fk.parent != t.Table {
+			return fmt.Errorf("no rows in %s for foreign key %s", fk.parent, strings.Join(fk.from, ","))
+		}
+		refs = append(refs, ref)
+		names = append(names, fk.from...)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no columns to generate")
+	}
+
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteIdent(name)
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES(%s)", quoteIdent(t.Table), strings.Join(quoted, ","),
+		strings.TrimSuffix(strings.Repeat("?,", len(names)), ","))
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(insert)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	values := make([]interface{}, len(names))
+	for i := 0; i < t.Rows; i++ {
+		n := 0
+		for _, name := range names[:len(t.Columns)] {
+			values[n] = t.Columns[name](r, i)
+			n++
+		}
+		for _, ref := range refs {
+			var parent []interface{}
+			if len(ref.values) > 0 {
+				parent = ref.values[r.Intn(len(ref.values))]
+			}
+			for j := range ref.columns {
+				if parent == nil {
+					values[n] = nil // a table referring to itself starts without parents
+				} else {
+					values[n] = parent[j]
+				}
+				n++
+			}
+		}
+		if _, err := stmt.Exec(values...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Sequence generates start, start+step, start+2*step, ...
+func Sequence(start, step int64) Generator {
+	return func(_ *rand.Rand, i int) interface{} {
+		return start + int64(i)*step
+	}
+}
+
+// OneOf generates values chosen at random from the given values
+func OneOf(values ...interface{}) Generator {
+	return func(r *rand.Rand, _ int) interface{} {
+		return values[r.Intn(len(values))]
+	}
+}
+
+// IntRange generates random integers from min to max inclusive
+func IntRange(min, max int64) Generator {
+	return func(r *rand.Rand, _ int) interface{} {
+		return min + r.Int63n(max-min+1)
+	}
+}
+
+// FloatRange generates random numbers from min up to max
+func FloatRange(min, max float64) Generator {
+	return func(r *rand.Rand, _ int) interface{} {
+		return min + r.Float64()*(max-min)
+	}
+}
+
+// DateRange generates random times from start up to end, in SQLite's datetime format
+func DateRange(start, end time.Time) Generator {
+	span := int64(end.Sub(start) / time.Second)
+	return func(r *rand.Rand, _ int) interface{} {
+		t := start.Add(time.Duration(r.Int63n(span+1)) * time.Second)
+		return t.UTC().Format("2006-01-02 15:04:05")
+	}
+}
+
+// FakeName generates random full names
+func FakeName() Generator {
+	return func(r *rand.Rand, _ int) interface{} {
+		return fakeFirst[r.Intn(len(fakeFirst))] + " " + fakeLast[r.Intn(len(fakeLast))]
+	}
+}
+
+// FakeEmail generates email addresses that are unique within the table
+func FakeEmail() Generator {
+	return func(r *rand.Rand, i int) interface{} {
+		first := fakeFirst[r.Intn(len(fakeFirst))]
+		last := fakeLast[r.Intn(len(fakeLast))]
+		return fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), i+1)
+	}
+}
+
+// Nullable wraps a generator to generate NULL for the given fraction of rows
+func Nullable(g Generator, fraction float64) Generator {
+	return func(r *rand.Rand, i int) interface{} {
+		if r.Float64() < fraction {
+			return nil
+		}
+		return g(r, i)
+	}
+}
@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeed(t *testing.T) {
+	db := memDB(t)
+	if _, err := db.Exec(sampleSetup); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	spec := SeedSpec{
+		Seed: 42,
+		Tables: []TableSeed{
+			// children are listed first, but seeded after their parents
+			{Table: "orders", Rows: 200, Columns: map[string]Generator{"total": FloatRange(1, 100)}},
+			{Table: "customers", Rows: 20, Columns: map[string]Generator{"name": FakeName()}},
+			{Table: "regions", Rows: 3, Columns: map[string]Generator{
+				"id":   Sequence(10, 10),
+				"name": OneOf("north", "south", "east", "west"),
+			}},
+		},
+	}
+	if err := Seed(db, spec); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	for table, want := range map[string]int{"regions": 3, "customers": 20, "orders": 200} {
+		if err := row(db, []interface{}{&count}, "select count(*) from "+table); err != nil || count != want {
+			t.Fatalf("expected %d %s but got: %d (%v)", want, table, count, err)
+		}
+	}
+	for _, q := range []string{
+		"select count(*) from orders where customer not in (select id from customers)",
+		"select count(*) from customers where region not in (10, 20, 30)",
+		"select count(*) from orders where total < 1 or total >= 100",
+	} {
+		if err := row(db, []interface{}{&count}, q); err != nil || count != 0 {
+			t.Fatalf("%s: expected 0 but got: %d (%v)", q, count, err)
+		}
+	}
+
+	// the same seed generates the same rows
+	again := memDB(t)
+	if _, err := again.Exec(sampleSetup); err != nil {
+		t.Fatal(err)
+	}
+	if err := Seed(again, spec); err != nil {
+		t.Fatal(err)
+	}
+	var a, b string
+	const names = "select group_concat(name) from customers"
+	if err := row(db, []interface{}{&a}, names); err != nil {
+		t.Fatal(err)
+	}
+	if err := row(again, []interface{}{&b}, names); err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Fatalf("expected repeatable names but got: %s and %s", a, b)
+	}
+
+	dates := SeedSpec{Tables: []TableSeed{{Table: "regions", Rows: 1, Columns: map[string]Generator{
+		"name": DateRange(start, start.Add(24*time.Hour)),
+	}}}}
+	if err := Seed(db, dates); err != nil {
+		t.Fatal(err)
+	}
+	bad := SeedSpec{Tables: []TableSeed{{Table: "regions", Rows: 1, Columns: map[string]Generator{"nosuch": Sequence(1, 1)}}}}
+	if err := Seed(db, bad); err == nil {
+		t.Fatal("expected error for unknown column")
+	}
+}
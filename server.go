@@ -0,0 +1,329 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// ErrServerClosed is returned for writes to a Server that has been closed
+var ErrServerClosed = errors.New("server closed")
+
+// ServerMaxRows is the most rows Server.Query returns, as its results are held in memory
+var ServerMaxRows = 10000
+
+// Token is the sequence of a write run by a Server, increasing with each write, so readers
+// of copies of its database can wait for those to include the write, see ReplicaSet.WaitFor.
+// The zero Token precedes all writes
+type Token uint64
+
+// ExecResult is the result of a statement executed by a Server
+type ExecResult struct {
+	LastInsertID int64
+	RowsAffected int64
+	Token        Token                 // of the statement, if it succeeded
+	Duration     time.Duration         // of the statement, once its turn came
+	Kind         Kind                  // of the statement, as classified by Classify
+	Code         sqlite3.ErrNoExtended // the extended result code of SQLite, if the statement failed
+}
+
+// ServerStats are the operations of a Server
+type ServerStats struct {
+	Queued        int           `json:"queued"`                  // operations waiting for their turn
+	InFlight      string        `json:"in_flight,omitempty"`     // the operation running, if any
+	InFlightFor   time.Duration `json:"in_flight_for,omitempty"` // how long it has been running
+	Execs         int64         `json:"execs"`                   // operations run
+	Failed        int64         `json:"failed"`                  // operations that failed
+	LockWait      time.Duration `json:"lock_wait"`               // the total time operations waited for their turn
+	MaxLockWait   time.Duration `json:"max_lock_wait"`           // the longest time an operation waited
+	LastError     string        `json:"last_error,omitempty"`    // of the last operation that failed
+	LastErrorTime time.Time     `json:"last_error_time"`
+}
+
+// Priority is the lane of a Server an operation is queued in
+type Priority int
+
+// Priorities of operations, from the highest
+const (
+	PriorityInteractive Priority = iota // e.g. the writes of users waiting for them
+	PriorityNormal                      // the default
+	PriorityBatch                       // e.g. bulk imports and maintenance
+	priorities
+)
+
+// ServerWeights are the operations run from each lane of a Server, from PriorityInteractive
+// to PriorityBatch, in each round of its turns, so those of lower priority get some turns,
+// rather than waiting for all of higher priority. Servers use the weights when created
+var ServerWeights = [priorities]int{8, 4, 1}
+
+// serverOp is a write run by a Server
+type serverOp struct {
+	desc   string // the statement, or what the operation is
+	fn     func(db *sql.DB) error
+	done   chan error
+	queued time.Time
+}
+
+// Server serializes the writes to a database through a goroutine of its own, so
+// writers in the process queue for their turn, rather than contend for SQLite's
+// write lock and retry on SQLITE_BUSY. Reads don't need the server, and can use
+// the database directly. Operations are queued in lanes by priority, see Lane
+type Server struct {
+	DB      *sql.DB
+	qmu     sync.Mutex
+	ready   *sync.Cond // signalled when operations are queued, or the server is closed
+	lanes   [priorities][]serverOp
+	weights [priorities]int
+	credits [priorities]int // the turns left to each lane in the current round
+	closed  bool
+	seq     uint64 // the Token of the last write
+	done    chan struct{}
+
+	statsMu sync.Mutex
+	stats   ServerStats
+	started time.Time // of the operation in flight
+}
+
+// NewServer returns a server of the writes to the database
+func NewServer(db *sql.DB) *Server {
+	s := &Server{DB: db, weights: ServerWeights, done: make(chan struct{})}
+	for p, w := range s.weights {
+		if w < 1 {
+			s.weights[p] = 1
+		}
+	}
+	s.ready = sync.NewCond(&s.qmu)
+	go s.run()
+	return s
+}
+
+// next returns the operation with the next turn, waiting for one to be queued, and
+// false once the server is closed and the operations queued have been run. Turns
+// are taken by weighted round robin: each lane gets as many turns in a round as its
+// weight, while it has operations queued, and a round ends when no lane with
+// operations queued has turns left
+func (s *Server) next() (serverOp, bool) {
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+	for {
+		queued := false
+		for p := range s.lanes {
+			if len(s.lanes[p]) == 0 {
+				continue
+			}
+			queued = true
+			if s.credits[p] > 0 {
+				s.credits[p]--
+				op := s.lanes[p][0]
+				s.lanes[p] = s.lanes[p][1:]
+				return op, true
+			}
+		}
+		switch {
+		case queued:
+			s.credits = s.weights
+		case s.closed:
+			return serverOp{}, false
+		default:
+			s.ready.Wait()
+		}
+	}
+}
+
+// run runs the writes in turn until the server is closed
+func (s *Server) run() {
+	defer close(s.done)
+	for {
+		op, ok := s.next()
+		if !ok {
+			return
+		}
+		now := time.Now()
+		wait := now.Sub(op.queued)
+		s.statsMu.Lock()
+		s.stats.Queued--
+		s.stats.InFlight, s.started = op.desc, now
+		s.stats.LockWait += wait
+		if wait > s.stats.MaxLockWait {
+			s.stats.MaxLockWait = wait
+		}
+		s.statsMu.Unlock()
+
+		err := op.fn(s.DB)
+
+		s.statsMu.Lock()
+		s.stats.InFlight = ""
+		s.stats.Execs++
+		if err != nil {
+			s.stats.Failed++
+			s.stats.LastError, s.stats.LastErrorTime = err.Error(), time.Now()
+		}
+		s.statsMu.Unlock()
+		op.done <- err
+	}
+}
+
+// do runs fn with the database in its turn in the lane, and returns its error
+func (s *Server) do(p Priority, desc string, fn func(db *sql.DB) error) error {
+	if p < 0 || p >= priorities {
+		return fmt.Errorf("invalid priority: %d", p)
+	}
+	op := serverOp{desc: desc, fn: fn, done: make(chan error, 1), queued: time.Now()}
+	s.qmu.Lock()
+	if s.closed {
+		s.qmu.Unlock()
+		return ErrServerClosed
+	}
+	s.lanes[p] = append(s.lanes[p], op)
+	s.statsMu.Lock()
+	s.stats.Queued++
+	s.statsMu.Unlock()
+	s.ready.Signal()
+	s.qmu.Unlock()
+	return <-op.done
+}
+
+// wrote returns the Token of a write, once it is done
+func (s *Server) wrote() Token {
+	return Token(atomic.AddUint64(&s.seq, 1))
+}
+
+// Token returns the Token of the last write done, which follows those of the writes
+// done before it, e.g. of a transaction
+func (s *Server) Token() Token {
+	return Token(atomic.LoadUint64(&s.seq))
+}
+
+// Stats returns the operations of the server so far
+func (s *Server) Stats() ServerStats {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	stats := s.stats
+	if stats.InFlight != "" {
+		stats.InFlightFor = time.Since(s.started)
+	}
+	return stats
+}
+
+// Publish publishes the stats of the server with expvar, under the name, e.g. so they
+// are served by /debug/vars. As with expvar.Publish, the name can be used only once
+func (s *Server) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return s.Stats()
+	}))
+}
+
+// ServerLane queues the operations of a Server with a priority
+type ServerLane struct {
+	s *Server
+	p Priority
+}
+
+// Lane returns the lane of the priority, e.g. so a bulk import doesn't hold up
+// the writes of users:
+//
+//	s.Lane(PriorityBatch).Exec("INSERT INTO ...", ...)
+func (s *Server) Lane(p Priority) ServerLane {
+	return ServerLane{s: s, p: p}
+}
+
+// Exec executes the statement in its turn, with PriorityNormal
+func (s *Server) Exec(q string, args ...interface{}) (ExecResult, error) {
+	return s.Lane(PriorityNormal).Exec(q, args...)
+}
+
+// Query runs the query in its turn, with PriorityNormal, see ServerLane.Query
+func (s *Server) Query(q string, args ...interface{}) (*QueryResult, error) {
+	return s.Lane(PriorityNormal).Query(q, args...)
+}
+
+// Transact runs fn in an immediate transaction, in its turn, with PriorityNormal,
+// as does the package's Transact
+func (s *Server) Transact(fn func(tx Tx) error) error {
+	return s.Lane(PriorityNormal).Transact(fn)
+}
+
+// Exec executes the statement in its turn
+func (l ServerLane) Exec(q string, args ...interface{}) (ExecResult, error) {
+	r := ExecResult{Kind: Classify(q)}
+	err := l.s.do(l.p, q, func(db *sql.DB) error {
+		start := time.Now()
+		result, err := db.Exec(q, args...)
+		r.Duration = time.Since(start)
+		if err != nil {
+			return err
+		}
+		r.LastInsertID, _ = result.LastInsertId()
+		r.RowsAffected, _ = result.RowsAffected()
+		r.Token = l.s.wrote()
+		return nil
+	})
+	var serr sqlite3.Error
+	if errors.As(err, &serr) {
+		r.Code = serr.ExtendedCode
+	}
+	return r, err
+}
+
+// Query runs the query in its turn, so it sees the writes queued before it, returning
+// its rows, of which there can be no more than ServerMaxRows
+func (l ServerLane) Query(q string, args ...interface{}) (*QueryResult, error) {
+	result := &QueryResult{Rows: [][]interface{}{}}
+	err := l.s.do(l.p, q, func(db *sql.DB) error {
+		rows, err := db.Query(q, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		if result.Columns, err = rows.Columns(); err != nil {
+			return err
+		}
+		for rows.Next() {
+			if len(result.Rows) == ServerMaxRows {
+				return fmt.Errorf("query has more than %d rows", ServerMaxRows)
+			}
+			row := make([]interface{}, len(result.Columns))
+			ptrs := make([]interface{}, len(row))
+			for i := range row {
+				ptrs[i] = &row[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				return err
+			}
+			result.Rows = append(result.Rows, row)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Transact runs fn in an immediate transaction, in its turn, as does the package's Transact.
+// The Token of the server once it returns follows that of the transaction
+func (l ServerLane) Transact(fn func(tx Tx) error) error {
+	return l.s.do(l.p, "transaction", func(db *sql.DB) error {
+		if err := Transact(db, fn, TxOptions{Immediate: true}); err != nil {
+			return err
+		}
+		l.s.wrote()
+		return nil
+	})
+}
+
+// Close stops the server once the writes queued have been run. It doesn't close the database
+func (s *Server) Close() error {
+	s.qmu.Lock()
+	s.closed = true
+	s.ready.Signal()
+	s.qmu.Unlock()
+	<-s.done
+	return nil
+}
@@ -0,0 +1,170 @@
+package sqlite
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// publishRuns counts the runs of TestServer, which publishes its stats
+var publishRuns int
+
+func TestServer(t *testing.T) {
+	db := fileDB(t, t.TempDir())
+	s := NewServer(db)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := s.Exec("insert into structs(name, kind) values(?, ?)", fmt.Sprint("served", i), i); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	r, err := s.Exec("update structs set kind = kind + 1 where name like 'served%'")
+	if err != nil || r.RowsAffected != 20 || r.LastInsertID == 0 || r.Kind != KindWrite || r.Duration == 0 {
+		t.Errorf("unexpected result: %+v (%v)", r, err)
+	}
+	r, err = s.Exec("insert into structs(id, name) values(1, 'taken')")
+	if err == nil || r.Code != sqlite3.ErrConstraintPrimaryKey {
+		t.Errorf("expected primary key violation but got: %+v (%v)", r, err)
+	}
+
+	result, err := s.Query("select name, kind from structs where name like 'served%' order by kind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 20 || len(result.Columns) != 2 || result.Rows[0][0] != "served0" || result.Rows[0][1] != int64(1) {
+		t.Errorf("unexpected query result: %v %v", result.Columns, result.Rows)
+	}
+	saved := ServerMaxRows
+	ServerMaxRows = 10
+	if _, err := s.Query("select * from structs"); err == nil {
+		t.Error("expected error for too many rows")
+	}
+	ServerMaxRows = saved
+
+	err = s.Transact(func(tx Tx) error {
+		if _, err := tx.ExecContext(context.Background(), "delete from structs where name like 'served%'"); err != nil {
+			return err
+		}
+		return fmt.Errorf("changed my mind")
+	})
+	if err == nil {
+		t.Error("expected the transaction to fail")
+	}
+	var count int
+	if err := row(db, []interface{}{&count}, "select count(*) from structs where name like 'served%'"); err != nil || count != 20 {
+		t.Errorf("expected the transaction to be rolled back but got: %d (%v)", count, err)
+	}
+
+	// an operation in flight, and one queued behind it
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go s.Transact(func(tx Tx) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	queued := make(chan error)
+	go func() {
+		_, err := s.Exec("delete from structs where name = 'nobody'")
+		queued <- err
+	}()
+	for s.Stats().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	stats := s.Stats()
+	if stats.InFlight != "transaction" || stats.InFlightFor == 0 || stats.Queued != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	close(release)
+	if err := <-queued; err != nil {
+		t.Fatal(err)
+	}
+	stats = s.Stats()
+	if stats.Execs != 27 || stats.Failed != 3 || stats.InFlight != "" || stats.Queued != 0 ||
+		stats.LockWait == 0 || stats.MaxLockWait == 0 || !strings.Contains(stats.LastError, "changed my mind") {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	// expvar names can only be published once, so each run has its own
+	publishRuns++
+	name := fmt.Sprintf("sqlite_server_test_%d", publishRuns)
+	s.Publish(name)
+	if v := expvar.Get(name); v == nil || !strings.Contains(v.String(), `"execs":27`) {
+		t.Errorf("unexpected published stats: %v", v)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Exec("delete from structs"); err != ErrServerClosed {
+		t.Errorf("expected server closed but got: %v", err)
+	}
+	s.Close()
+}
+
+func TestServerLanes(t *testing.T) {
+	db := fileDB(t, t.TempDir())
+	if _, err := db.Exec("create table turns (lane text)"); err != nil {
+		t.Fatal(err)
+	}
+	saved := ServerWeights
+	ServerWeights = [priorities]int{2, 1, 1}
+	s := NewServer(db)
+	ServerWeights = saved
+	defer s.Close()
+
+	// queue the batch writes first, while the server is busy
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go s.Transact(func(tx Tx) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	var wg sync.WaitGroup
+	total := 0
+	queue := func(p Priority, lane string, n int) {
+		total += n
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := s.Lane(p).Exec("insert into turns values(?)", lane); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		for s.Stats().Queued < total {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	queue(PriorityBatch, "b", 4)
+	queue(PriorityInteractive, "i", 6)
+	close(release)
+	wg.Wait()
+
+	var order string
+	if err := row(db, []interface{}{&order}, "select group_concat(lane, '') from (select lane from turns order by rowid)"); err != nil {
+		t.Fatal(err)
+	}
+	// the interactive lane gets two turns to each of the batch lane, which isn't starved
+	if order != "iibiibiibb" {
+		t.Errorf("unexpected order of turns: %s", order)
+	}
+	if _, err := s.Lane(Priority(7)).Exec("delete from turns"); err == nil {
+		t.Error("expected error for invalid priority")
+	}
+}
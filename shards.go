@@ -0,0 +1,299 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ShardFunc picks which of n shards holds a key
+type ShardFunc func(key string, n int) int
+
+// HashShard spreads keys across the shards by their hash
+func HashShard(key string, n int) int {
+	h := fnv.New32a()
+	io.WriteString(h, key)
+	return int(h.Sum32() % uint32(n))
+}
+
+// RangeShard returns a ShardFunc for keys split into ranges by the sorted bounds:
+// keys less than bounds[0] are in shard 0, those less than bounds[1] in shard 1,
+// and so on, with the rest in the shard after the last bound. Keys are compared as
+// strings, so numbers should be formatted with a fixed width
+func RangeShard(bounds ...string) ShardFunc {
+	return func(key string, n int) int {
+		i := sort.SearchStrings(bounds, key)
+		if i < len(bounds) && bounds[i] == key {
+			i++
+		}
+		if i >= n {
+			i = n - 1
+		}
+		return i
+	}
+}
+
+// Shards routes statements to one of a number of database files by a shard key
+type Shards struct {
+	mu    sync.RWMutex
+	files []string
+	dbs   []*sql.DB
+	pick  ShardFunc
+	opts  []Optional
+}
+
+// OpenShards opens the files as shards, with keys placed by pick (HashShard if nil).
+// Each file is opened with opts
+func OpenShards(files []string, pick ShardFunc, opts ...Optional) (*Shards, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no shards")
+	}
+	if pick == nil {
+		pick = HashShard
+	}
+	s := &Shards{pick: pick, opts: opts}
+	for _, file := range files {
+		db, err := Open(file, opts...)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("shard: %s, error: %w", file, err)
+		}
+		s.files = append(s.files, file)
+		s.dbs = append(s.dbs, db)
+	}
+	return s, nil
+}
+
+// Len returns the number of shards
+func (s *Shards) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.dbs)
+}
+
+// Shard returns the index of the shard holding the key
+func (s *Shards) Shard(key string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pick(key, len(s.dbs))
+}
+
+// DB returns the database of the shard holding the key
+func (s *Shards) DB(key string) *sql.DB {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dbs[s.pick(key, len(s.dbs))]
+}
+
+// Exec runs a statement on the shard holding the key
+func (s *Shards) Exec(key, q string, args ...interface{}) (sql.Result, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dbs[s.pick(key, len(s.dbs))].Exec(q, args...)
+}
+
+// Query runs a query on the shard holding the key
+func (s *Shards) Query(key, q string, args ...interface{}) (*sql.Rows, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dbs[s.pick(key, len(s.dbs))].Query(q, args...)
+}
+
+// ExecAll runs a statement, e.g. one changing the schema, on every shard
+func (s *Shards) ExecAll(q string, args ...interface{}) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i, db := range s.dbs {
+		if _, err := db.Exec(q, args...); err != nil {
+			return fmt.Errorf("shard: %s, error: %w", s.files[i], err)
+		}
+	}
+	return nil
+}
+
+// QueryAll runs a query on every shard at once, merging the rows of each, in shard order
+func (s *Shards) QueryAll(q string, args ...interface{}) (*QueryResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	results := make([]*QueryResult, len(s.dbs))
+	errs := make([]error, len(s.dbs))
+	var wg sync.WaitGroup
+	for i, db := range s.dbs {
+		wg.Add(1)
+		go func(i int, db *sql.DB) {
+			defer wg.Done()
+			r := &QueryResult{}
+			errs[i] = query(db, func(columns []string, row []interface{}) {
+				if columns != nil {
+					r.Columns = columns
+				}
+				r.Rows = append(r.Rows, append([]interface{}(nil), row...))
+			}, q, args...)
+			results[i] = r
+		}(i, db)
+	}
+	wg.Wait()
+	merged := &QueryResult{Rows: [][]interface{}{}}
+	for i, r := range results {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("shard: %s, error: %w", s.files[i], errs[i])
+		}
+		if merged.Columns == nil {
+			merged.Columns = r.Columns
+		}
+		merged.Rows = append(merged.Rows, r.Rows...)
+	}
+	return merged, nil
+}
+
+// Close closes the shards
+func (s *Shards) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for _, db := range s.dbs {
+		if cerr := db.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// ReshardOptions controls the copy job of AddShard
+type ReshardOptions struct {
+	Keys     map[string]string // the shard key column of each table to reshard, keyed by table
+	Pick     ShardFunc         // places keys among the shards once added, defaults to the current
+	Progress io.Writer         // receives progress reports
+}
+
+// AddShard adds a shard, created with the schema of the first shard, and moves the rows
+// of the tables given in the options to the shards their keys now belong to. Other tables
+// are left as they are. Statements wait until the rows are moved.
+//
+// Primary keys and other unique columns must be unique across the shards (e.g. not
+// integer ids assigned by each shard), or moved rows fail on rows already there.
+// Rows are written to their new shard before they are removed from the old, so a
+// failed job can leave a row in both, to be removed before ReshardTables finishes it
+func (s *Shards) AddShard(file string, opts ReshardOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, err := Open(file, s.opts...)
+	if err != nil {
+		return err
+	}
+	var schema []string
+	fn := func(_ []string, row []interface{}) {
+		schema = append(schema, fmt.Sprint(row[0]))
+	}
+	const q = `SELECT sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 WHEN 'view' THEN 2 ELSE 3 END, name`
+	if err := query(s.dbs[0], fn, q); err != nil {
+		db.Close()
+		return err
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return fmt.Errorf("shard schema: %w", err)
+		}
+	}
+	s.files = append(s.files, file)
+	s.dbs = append(s.dbs, db)
+	if opts.Pick != nil {
+		s.pick = opts.Pick
+	}
+	return s.reshard(opts)
+}
+
+// ReshardTables moves the rows of the tables given in the options to the shards their
+// keys belong to, e.g. to complete a failed AddShard, or to change how keys are placed
+func (s *Shards) ReshardTables(opts ReshardOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if opts.Pick != nil {
+		s.pick = opts.Pick
+	}
+	return s.reshard(opts)
+}
+
+// reshard moves rows to the shards of their keys, with s locked
+func (s *Shards) reshard(opts ReshardOptions) error {
+	w := opts.Progress
+	if w == nil {
+		w = ioutil.Discard
+	}
+	tables := make([]string, 0, len(opts.Keys))
+	for table := range opts.Keys {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		for from := range s.dbs {
+			moved, err := s.moveRows(table, opts.Keys[table], from)
+			if err != nil {
+				return fmt.Errorf("reshard table: %s, shard: %s, error: %w", table, s.files[from], err)
+			}
+			fmt.Fprintf(w, "table: %s shard: %s moved: %d\n", table, s.files[from], moved)
+		}
+	}
+	return nil
+}
+
+// moveRows moves the rows of a table in a shard that belong elsewhere
+func (s *Shards) moveRows(table, key string, from int) (int, error) {
+	columns, err := tableColumns(s.dbs[from], table)
+	if err != nil {
+		return 0, err
+	}
+	keyIndex := -1
+	for i, c := range columns {
+		if strings.EqualFold(c, key) {
+			keyIndex = i
+		}
+	}
+	if keyIndex < 0 {
+		return 0, fmt.Errorf("no key column: %s", key)
+	}
+
+	// rows are gathered by destination before any are moved
+	moves := make(map[int][][]interface{})
+	var rowids []int64
+	fn := func(_ []string, row []interface{}) {
+		k := row[keyIndex+1]
+		if b, ok := k.([]byte); ok {
+			k = string(b)
+		}
+		if to := s.pick(fmt.Sprint(k), len(s.dbs)); to != from {
+			moves[to] = append(moves[to], append([]interface{}(nil), row[1:]...))
+			rowids = append(rowids, row[0].(int64))
+		}
+	}
+	q := fmt.Sprintf("SELECT rowid, %s FROM %s", quotedList(columns), quoteIdent(table))
+	if err := query(s.dbs[from], fn, q); err != nil {
+		return 0, err
+	}
+	if len(rowids) == 0 {
+		return 0, nil
+	}
+
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES(%s)", quoteIdent(table), quotedList(columns),
+		strings.TrimSuffix(strings.Repeat("?,", len(columns)), ","))
+	for to, rows := range moves {
+		if _, err := ExecBatch(s.dbs[to], insert, rows); err != nil {
+			return 0, err
+		}
+	}
+	deletes := make([][]interface{}, len(rowids))
+	for i, id := range rowids {
+		deletes[i] = []interface{}{id}
+	}
+	if _, err := ExecBatch(s.dbs[from], fmt.Sprintf("DELETE FROM %s WHERE rowid=?", quoteIdent(table)), deletes); err != nil {
+		return 0, err
+	}
+	return len(rowids), nil
+}
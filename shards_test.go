@@ -0,0 +1,97 @@
+package sqlite
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRangeShard(t *testing.T) {
+	pick := RangeShard("g", "p")
+	for key, want := range map[string]int{"a": 0, "g": 1, "m": 1, "p": 2, "z": 2} {
+		if got := pick(key, 3); got != want {
+			t.Errorf("key %q: got shard %d, want %d", key, got, want)
+		}
+	}
+	if got := pick("z", 2); got != 1 {
+		t.Errorf("got shard %d beyond the shards", got)
+	}
+}
+
+func TestShards(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "s0.db"), filepath.Join(dir, "s1.db")}
+	s, err := OpenShards(files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.ExecAll("create table users (name text primary key, age integer)"); err != nil {
+		t.Fatal(err)
+	}
+	const users = 50
+	for i := 0; i < users; i++ {
+		name := fmt.Sprintf("user%02d", i)
+		if _, err := s.Exec(name, "insert into users (name, age) values (?,?)", name, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, err := s.Query("user07", "select count(*) from users where name=?", "user07")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	for rows.Next() {
+		rows.Scan(&count)
+	}
+	rows.Close()
+	if count != 1 {
+		t.Fatalf("user07 not in its shard")
+	}
+
+	// check each row is where its key says
+	placed := func() {
+		t.Helper()
+		result, err := s.QueryAll("select name, (select file from pragma_database_list where name='main') from users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Rows) != users {
+			t.Fatalf("got %d users, want %d", len(result.Rows), users)
+		}
+		for _, row := range result.Rows {
+			name := fmt.Sprint(row[0])
+			if want := s.files[s.Shard(name)]; !strings.HasSuffix(fmt.Sprint(row[1]), filepath.Base(want)) {
+				t.Errorf("%s is in %s, not %s", name, row[1], want)
+			}
+		}
+	}
+	placed()
+
+	var progress strings.Builder
+	opts := ReshardOptions{Keys: map[string]string{"users": "name"}, Progress: &progress}
+	if err := s.AddShard(filepath.Join(dir, "s2.db"), opts); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 3 {
+		t.Fatalf("got %d shards", s.Len())
+	}
+	if !strings.Contains(progress.String(), "moved") {
+		t.Errorf("no progress reported")
+	}
+	placed()
+
+	// resharding again moves nothing
+	progress.Reset()
+	if err := s.ReshardTables(opts); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(progress.String(), "moved: 1") || strings.Contains(progress.String(), "moved: 2") {
+		t.Errorf("rows moved again: %s", progress.String())
+	}
+
+	if err := s.ReshardTables(ReshardOptions{Keys: map[string]string{"users": "nope"}}); err == nil {
+		t.Error("expected error for missing key column")
+	}
+}
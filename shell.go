@@ -0,0 +1,669 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLimit is the maximum number of rows a Shell shows for a query, unless overridden with ".limit N"
+var DefaultLimit = 1000
+
+// ScriptClient fetches the scripts read from http(s) URLs, unless a Shell has a client of its own
+var ScriptClient = &http.Client{Timeout: time.Minute}
+
+// ErrChecksum is returned for a script fetched from a URL that doesn't match its checksum
+var ErrChecksum = errors.New("script checksum mismatch")
+
+// ErrNotAllowed is returned for a statement of a script whose kind a Shell doesn't allow
+var ErrNotAllowed = errors.New("statement not allowed")
+
+// ShellIO directs the output of a Shell
+type ShellIO struct {
+	Results io.Writer // query results and the output of dot-commands, defaults to os.Stdout
+	Echo    io.Writer // statements echoed before they are run, defaults to Results
+	Errors  io.Writer // warnings about the script, defaults to os.Stderr
+}
+
+// ScriptError reports the statement of a script that failed
+type ScriptError struct {
+	File  string // source of the script, empty if it was not read from a file
+	Line  int    // line of the statement within the script
+	Index int    // position of the statement within the script, starting at 1
+	SQL   string // a snippet of the statement
+	Err   error
+}
+
+func (e *ScriptError) Error() string {
+	where := fmt.Sprintf("line %d", e.Line)
+	if e.File != "" {
+		where = fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+	if e.SQL == "" {
+		if split, ok := e.Err.(*SplitError); ok {
+			return fmt.Sprintf("%s: column %d: %s", where, split.Column, split.Msg)
+		}
+		return fmt.Sprintf("%s: %v", where, e.Err)
+	}
+	return fmt.Sprintf("%s: statement %d: %s: %v", where, e.Index, e.SQL, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// snippet trims a statement to the start of its first line
+func snippet(sql string) string {
+	const max = 60
+	trimmed := sql
+	if i := strings.IndexByte(trimmed, '\n'); i >= 0 {
+		trimmed = strings.TrimSpace(trimmed[:i])
+	}
+	if len(trimmed) > max {
+		trimmed = trimmed[:max]
+	}
+	if trimmed != sql {
+		trimmed += "..."
+	}
+	return trimmed
+}
+
+// Queryer executes statements and queries, as do *sql.DB, *sql.Tx, and *sql.Conn,
+// so scripts can be run within a transaction or on a single connection
+type Queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Shell emulates the sqlite3 client, running scripts of statements and dot-commands
+type Shell struct {
+	DB    Queryer
+	IO    ShellIO
+	Echo  bool // echo each statement before it is run, as with ".echo on"
+	Limit int  // maximum number of rows shown for a query, 0 for no limit
+
+	Client          *http.Client // fetches scripts from URLs, defaults to ScriptClient
+	RequireChecksum bool         // refuse scripts from URLs without a checksum
+
+	Data map[string]interface{} // if set, scripts are rendered as templates with it, see RenderScript
+
+	Vars map[string]string // variables substituted for $NAME or ${NAME}, outside SQL strings, as set by ".set NAME value"
+	Env  bool              // substitute environment variables for those not in Vars, as with ".env on"
+
+	Allow []Kind // the kinds of statements run, as classified by Classify, all if empty
+
+	// Recover runs each statement within a savepoint, as with ".recover on", so one that
+	// fails is rolled back and skipped, rather than ending the script, e.g. to apply
+	// what can be of a large dump in its transaction. The statements are run on a single
+	// connection, and those skipped are reported to IO.Errors and kept in Skipped
+	Recover bool
+	Skipped []*ScriptError
+}
+
+// NewShell returns a Shell for db, with the output directed per sio
+func NewShell(db Queryer, sio ShellIO) *Shell {
+	if sio.Results == nil {
+		sio.Results = os.Stdout
+	}
+	if sio.Echo == nil {
+		sio.Echo = sio.Results
+	}
+	if sio.Errors == nil {
+		sio.Errors = os.Stderr
+	}
+	return &Shell{DB: db, IO: sio, Limit: DefaultLimit, Vars: make(map[string]string)}
+}
+
+// File emulates ".read FILENAME", where the file may be an http(s) URL, see Shell.File
+func File(db Queryer, file string, echo bool, w io.Writer) error {
+	sh := NewShell(db, ShellIO{Results: w})
+	sh.Echo = echo
+	return sh.File(file)
+}
+
+// Commands emulates the client reading a series of commands
+func Commands(db Queryer, buffer string, echo bool, w io.Writer) error {
+	return RunCommands(db, buffer, w, &CommandsOptions{Echo: echo})
+}
+
+// CommandsOptions are the options of RunCommands
+type CommandsOptions struct {
+	Echo  bool   // echo each statement before it is run
+	Allow []Kind // the kinds of statements run, all if empty, e.g. to refuse DDL in scripts users submit
+
+	Recover bool      // skip the statements that fail, see Shell.Recover
+	Errors  io.Writer // receives the statements skipped, defaults to os.Stderr
+}
+
+// RunCommands runs the commands as does Commands, failing with ErrNotAllowed, before
+// running anything, if a statement is of a kind not allowed
+func RunCommands(db Queryer, buffer string, w io.Writer, opts *CommandsOptions) error {
+	if opts == nil {
+		opts = &CommandsOptions{}
+	}
+	sh := NewShell(db, ShellIO{Results: w, Errors: opts.Errors})
+	sh.Echo = opts.Echo
+	sh.Allow = opts.Allow
+	sh.Recover = opts.Recover
+	return sh.Run(buffer)
+}
+
+// File runs the script in file, or fetched from an http(s) URL. The checksum of a script
+// from a URL can be given as its fragment, e.g. "https://example.com/schema.sql#sha256=HEX"
+// (or sha512), failing with ErrChecksum if the script doesn't match
+func (s *Shell) File(file string) error {
+	if !isURL(file) {
+		out, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		return s.run(file, string(out))
+	}
+	url, sum := file, ""
+	if i := strings.IndexByte(file, '#'); i >= 0 {
+		url, sum = file[:i], file[i+1:]
+	}
+	out, err := s.fetch(url, sum)
+	if err != nil {
+		return err
+	}
+	return s.run(url, string(out))
+}
+
+// isURL reports whether the script file is to be fetched over HTTP
+func isURL(file string) bool {
+	lower := strings.ToLower(file)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// fetch returns the script at url, verified by the checksum sum, as "ALGORITHM=HEX"
+func (s *Shell) fetch(url, sum string) ([]byte, error) {
+	var h hash.Hash
+	var algo string
+	var want []byte
+	if sum != "" {
+		i := strings.IndexByte(sum, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid checksum: %q", sum)
+		}
+		algo = strings.ToLower(sum[:i])
+		switch algo {
+		case "sha256":
+			h = sha256.New()
+		case "sha512":
+			h = sha512.New()
+		default:
+			return nil, fmt.Errorf("unsupported checksum: %q", algo)
+		}
+		var err error
+		if want, err = hex.DecodeString(sum[i+1:]); err != nil {
+			return nil, fmt.Errorf("invalid checksum: %q", sum)
+		}
+	} else if s.RequireChecksum {
+		return nil, fmt.Errorf("no checksum for script: %s", url)
+	}
+	client := s.Client
+	if client == nil {
+		client = ScriptClient
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch script: %s, status: %s", url, resp.Status)
+	}
+	out, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if h != nil {
+		h.Write(out)
+		if got := h.Sum(nil); !bytes.Equal(got, want) {
+			return nil, fmt.Errorf("%w: %s has %s %x", ErrChecksum, url, algo, got)
+		}
+	}
+	return out, nil
+}
+
+// Run runs the statements and dot-commands in script
+func (s *Shell) Run(script string) error {
+	return s.run("", script)
+}
+
+// run runs script, reporting errors as a *ScriptError from file
+func (s *Shell) run(file, script string) error {
+	if s.Data != nil {
+		rendered, err := RenderScript(script, s.Data)
+		if err != nil {
+			return &ScriptError{File: file, Err: err}
+		}
+		script = rendered
+	}
+	statements, err := SplitStatements(script)
+	if err != nil {
+		serr := &ScriptError{File: file, Err: err}
+		if split, ok := err.(*SplitError); ok {
+			serr.Line = split.Line
+		}
+		return serr
+	}
+	// refuse the script before running any of it, rather than part of it
+	for i, stmt := range statements {
+		if err := s.allow(s.expand(stmt.SQL, stmt.Dot), stmt.Dot); err != nil {
+			return &ScriptError{File: file, Line: stmt.Line, Index: i + 1, SQL: snippet(stmt.SQL), Err: err}
+		}
+	}
+	// savepoints need the statements run on the same connection
+	if db, ok := s.DB.(*sql.DB); ok {
+		defer func() {
+			if conn, ok := s.DB.(*sql.Conn); ok {
+				conn.Close()
+				s.DB = db
+			}
+		}()
+	}
+	for i, stmt := range statements {
+		// transaction statements can't be run within a savepoint
+		recoverable := s.Recover && !stmt.Dot && Classify(s.expand(stmt.SQL, false)) != KindTransaction
+		var err error
+		switch {
+		case s.Recover && !stmt.Dot:
+			if err = s.pin(); err != nil {
+				err = fmt.Errorf("%w: %v", errSavepoint, err)
+			} else if recoverable {
+				err = s.savepoint(stmt)
+			} else {
+				err = s.exec(stmt)
+			}
+		default:
+			err = s.exec(stmt)
+		}
+		if err != nil {
+			if serr, ok := err.(*ScriptError); ok {
+				// already located in a file read by this one
+				return serr
+			}
+			serr := &ScriptError{File: file, Line: stmt.Line, Index: i + 1, SQL: snippet(stmt.SQL), Err: err}
+			if recoverable && !errors.Is(err, errSavepoint) {
+				s.Skipped = append(s.Skipped, serr)
+				fmt.Fprintf(s.IO.Errors, "skipped: %v\n", serr)
+				continue
+			}
+			return serr
+		}
+	}
+	return nil
+}
+
+// errSavepoint is returned for a statement that couldn't be run within a savepoint
+var errSavepoint = errors.New("savepoint failed")
+
+// pin runs the statements of the script on a single connection, if the shell
+// has a *sql.DB, until the script ends
+func (s *Shell) pin() error {
+	if db, ok := s.DB.(*sql.DB); ok {
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			return err
+		}
+		s.DB = conn
+	}
+	return nil
+}
+
+// savepoint runs the statement within a savepoint, which is rolled back if it fails
+func (s *Shell) savepoint(stmt Statement) error {
+	ctx := context.Background()
+	if _, err := s.DB.ExecContext(ctx, "SAVEPOINT shell_statement"); err != nil {
+		return fmt.Errorf("%w: %v", errSavepoint, err)
+	}
+	if err := s.exec(stmt); err != nil {
+		if _, rerr := s.DB.ExecContext(ctx, "ROLLBACK TO shell_statement"); rerr != nil {
+			return fmt.Errorf("%w: %v, rolling back: %v", errSavepoint, err, rerr)
+		}
+		s.DB.ExecContext(ctx, "RELEASE shell_statement")
+		return err
+	}
+	if _, err := s.DB.ExecContext(ctx, "RELEASE shell_statement"); err != nil {
+		return fmt.Errorf("%w: %v", errSavepoint, err)
+	}
+	return nil
+}
+
+// allow returns ErrNotAllowed if the statement is of a kind the shell doesn't allow.
+// The statement is split again, as variables may have expanded it to several, which
+// are refused, as the driver would run them all
+func (s *Shell) allow(sql string, dot bool) error {
+	if dot {
+		return nil
+	}
+	stmts, err := SplitStatements(sql)
+	if err != nil {
+		return err
+	}
+	if len(stmts) > 1 {
+		return fmt.Errorf("%w: %d statements in one, as expanded", ErrNotAllowed, len(stmts))
+	}
+	for _, stmt := range stmts {
+		if stmt.Dot {
+			return fmt.Errorf("%w: dot-command in a statement, as expanded", ErrNotAllowed)
+		}
+		if kind := Classify(stmt.SQL); !allowed(kind, s.Allow) {
+			return fmt.Errorf("%w: %s", ErrNotAllowed, kind)
+		}
+	}
+	return nil
+}
+
+// exec runs a single statement or dot-command
+func (s *Shell) exec(stmt Statement) error {
+	line := s.expand(stmt.SQL, stmt.Dot)
+	if stmt.Dot {
+		return s.dot(line)
+	}
+	// checked again, as variables may have changed the statement
+	if err := s.allow(line, false); err != nil {
+		return err
+	}
+	if s.Echo {
+		if EchoComments {
+			fmt.Fprintln(s.IO.Echo, "CMD> ", FormatSQL(s.expand(stmt.Text, false)))
+		} else {
+			fmt.Fprintln(s.IO.Echo, "CMD> ", FormatSQL(line))
+		}
+	}
+	if startsWith(line, "SELECT") {
+		return s.query(line)
+	}
+	_, err := s.DB.ExecContext(context.Background(), line)
+	return err
+}
+
+// dot runs a dot-command
+func (s *Shell) dot(line string) error {
+	cmd, arg := dotCommand(line)
+	switch cmd {
+	case ".echo":
+		echo, err := parseSwitch(arg)
+		if err != nil {
+			fmt.Fprintf(s.IO.Errors, "%s: %v\n", line, err)
+		}
+		s.Echo = echo
+	case ".set":
+		if arg == "" {
+			names := make([]string, 0, len(s.Vars))
+			for name := range s.Vars {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(s.IO.Results, "%s=%s\n", name, s.Vars[name])
+			}
+			break
+		}
+		name, value := dotCommand(arg)
+		if shellVar.FindString("$"+name) != "$"+name {
+			return fmt.Errorf("invalid variable name: %q", name)
+		}
+		if s.Vars == nil {
+			s.Vars = make(map[string]string)
+		}
+		// single quotes are kept, as those of an SQL string
+		if len(value) > 1 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		s.Vars[name] = value
+	case ".unset":
+		delete(s.Vars, arg)
+	case ".recover":
+		on, err := parseSwitch(arg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", line, err)
+		}
+		s.Recover = on
+	case ".env":
+		env, err := parseSwitch(arg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", line, err)
+		}
+		s.Env = env
+	case ".read":
+		if err := s.File(arg); err != nil {
+			if _, ok := err.(*ScriptError); ok {
+				return err
+			}
+			return fmt.Errorf("read file: %s, error: %w", arg, err)
+		}
+	case ".limit":
+		if arg == "" {
+			fmt.Fprintln(s.IO.Results, s.Limit)
+			break
+		}
+		limit, err := strconv.Atoi(arg)
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid limit: %q", arg)
+		}
+		s.Limit = limit
+	case ".recent":
+		var r *Recorder
+		if db, ok := s.DB.(*sql.DB); ok {
+			r = FlightRecorder(db)
+		}
+		if r == nil {
+			return fmt.Errorf("no flight recorder (see WithFlightRecorder)")
+		}
+		n := 0
+		if arg != "" {
+			var err error
+			if n, err = strconv.Atoi(arg); err != nil || n < 0 {
+				return fmt.Errorf("invalid count: %q", arg)
+			}
+		}
+		return dumpEvents(s.IO.Results, r.Recent(n))
+	case ".validate":
+		rules, err := LoadRules(arg)
+		if err != nil {
+			return err
+		}
+		violations, err := Validate(s.DB, rules)
+		if err != nil {
+			return err
+		}
+		WriteViolations(s.IO.Results, violations)
+		failed := 0
+		for _, v := range violations {
+			if v.Rule.Severity == SeverityError {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d rules failed", failed, len(rules))
+		}
+	case ".print":
+		fmt.Fprintln(s.IO.Results, unquote(arg))
+	case ".tables":
+		if err := listTables(s.DB, s.IO.Results); err != nil {
+			return fmt.Errorf("table error: %w", err)
+		}
+	case ".schema":
+		if err := listSchema(s.DB, s.IO.Results, unquote(arg)); err != nil {
+			return fmt.Errorf("schema error: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown command: %s", line)
+	}
+	return nil
+}
+
+// query shows the results of a query, up to the row limit
+func (s *Shell) query(q string) error {
+	rows, err := s.DB.QueryContext(context.Background(), q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := getColumns(rows)
+	if err != nil {
+		return err
+	}
+	dest := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for k := 0; k < len(dest); k++ {
+		ptrs[k] = &dest[k]
+	}
+
+	for count := 0; rows.Next(); count++ {
+		if s.Limit > 0 && count == s.Limit {
+			fmt.Fprintf(s.IO.Errors, "results truncated at %d rows (see .limit)\n", s.Limit)
+			break
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		s.showRow(columns, dest)
+		columns = nil // to signal we're past the first row
+	}
+	return rows.Err()
+}
+
+// showRow is a handler for the query func
+func (s *Shell) showRow(columns []string, row []interface{}) {
+	w := s.IO.Results
+	if columns != nil {
+		fmt.Fprintln(w, strings.Join(columns, "\t"))
+	}
+	for i, r := range row {
+		if i > 0 {
+			fmt.Fprint(w, "\t")
+		}
+		fmt.Fprint(w, r)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+// dotCommand splits a dot-command into the command and its argument
+func dotCommand(line string) (string, string) {
+	fields := strings.SplitN(line, " ", 2)
+	if len(fields) < 2 {
+		return fields[0], ""
+	}
+	return fields[0], strings.TrimSpace(fields[1])
+}
+
+// unquote trims the quotes around the argument of a dot-command
+func unquote(arg string) string {
+	str := strings.Trim(arg, `"`)
+	return strings.Trim(str, "'")
+}
+
+// shellVar matches a variable reference, as $NAME or ${NAME}
+var shellVar = regexp.MustCompile(`\$(?:([A-Za-z_][A-Za-z0-9_]*)|\{([A-Za-z_][A-Za-z0-9_]*)\})`)
+
+// expand substitutes the values of the variables referenced in line, leaving unknown
+// references, e.g. statement parameters, as they are. Values are substituted as they are,
+// but not within the strings and quoted identifiers of SQL statements, so a string value
+// is quoted by its variable rather than the statement, e.g. ".set city 'São Paulo'".
+// A statement that a value expands to more than one is refused, see allow
+func (s *Shell) expand(line string, dot bool) string {
+	if len(s.Vars) == 0 && !s.Env {
+		return line
+	}
+	if dot {
+		return s.substitute(line)
+	}
+	var b strings.Builder
+	from := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\'', '"', '`', '[':
+			end := quoteEnd(line, i)
+			if end < 0 {
+				end = len(line)
+			}
+			b.WriteString(s.substitute(line[from:i]))
+			b.WriteString(line[i:end])
+			from, i = end, end-1
+		}
+	}
+	b.WriteString(s.substitute(line[from:]))
+	return b.String()
+}
+
+// substitute replaces the references to variables in text with their values
+func (s *Shell) substitute(text string) string {
+	return shellVar.ReplaceAllStringFunc(text, func(ref string) string {
+		m := shellVar.FindStringSubmatch(ref)
+		name := m[1] + m[2]
+		if value, ok := s.Vars[name]; ok {
+			return value
+		}
+		if s.Env {
+			if value, ok := os.LookupEnv(name); ok {
+				return value
+			}
+		}
+		return ref
+	})
+}
+
+// parseSwitch parses the argument of a dot-command that is switched on or off
+func parseSwitch(arg string) (bool, error) {
+	switch strings.ToLower(arg) {
+	case "on", "yes":
+		return true, nil
+	case "off", "no":
+		return false, nil
+	}
+	return strconv.ParseBool(arg)
+}
+
+func startsWith(data, sub string) bool {
+	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(data)), strings.ToUpper(sub))
+}
+
+func listTables(db Queryer, w io.Writer) error {
+	q := `
+SELECT name FROM sqlite_master
+WHERE type='table'
+ORDER BY name
+`
+	fn := func(_ []string, row []interface{}) {
+		if len(row) > 0 {
+			fmt.Fprintln(w, row[0])
+		}
+	}
+	return query(db, fn, q)
+}
+
+// listSchema writes the statements creating the schema, formatted by FormatSQL,
+// limited to those of the table if given
+func listSchema(db Queryer, w io.Writer, table string) error {
+	q := `
+SELECT sql FROM sqlite_master
+WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%' AND (? = '' OR tbl_name = ? COLLATE NOCASE)
+ORDER BY tbl_name, CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 ELSE 2 END, name
+`
+	fn := func(_ []string, row []interface{}) {
+		fmt.Fprintf(w, "%s;\n", FormatSQL(fmt.Sprint(row[0])))
+	}
+	return query(db, fn, q, table, table)
+}
@@ -0,0 +1,359 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShellIO(t *testing.T) {
+	var results, echo, errs bytes.Buffer
+	sh := NewShell(memDB(t), ShellIO{Results: &results, Echo: &echo, Errors: &errs})
+	const script = `
+.echo on
+create table colors (name text);
+insert into colors values('red');
+select name from colors;
+.print done
+.echo maybe
+`
+	if err := sh.Run(script); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := results.String(), "name\nred\ndone\n"; got != want {
+		t.Errorf("expected results %q but got: %q", want, got)
+	}
+	if got := echo.String(); !strings.Contains(got, "CREATE TABLE colors") || strings.Contains(got, "red\n") {
+		t.Errorf("unexpected echo: %q", got)
+	}
+	if got := errs.String(); !strings.Contains(got, ".echo maybe") {
+		t.Errorf("expected warning for bad switch but got: %q", got)
+	}
+	if sh.Echo {
+		t.Error("expected echo to be off")
+	}
+}
+
+func TestScriptError(t *testing.T) {
+	dir := t.TempDir()
+	inner := filepath.Join(dir, "inner.sql")
+	const script = `create table t (id int);
+
+-- the second statement fails
+insert into t values(1);
+insert into nowhere
+  values(2);
+`
+	if err := ioutil.WriteFile(inner, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sh := NewShell(memDB(t), ShellIO{})
+	err := sh.Run("select 1 where 0;\n.read " + inner + "\n")
+	var serr *ScriptError
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected ScriptError but got: %v", err)
+	}
+	if serr.File != inner || serr.Line != 5 || serr.Index != 3 || serr.SQL != "insert into nowhere..." {
+		t.Fatalf("unexpected error location: %+v", serr)
+	}
+	t.Log(err)
+
+	err = sh.Run("select 1;\nselect 'oops")
+	if !errors.As(err, &serr) || serr.Line != 2 {
+		t.Fatalf("expected ScriptError on line 2 but got: %v", err)
+	}
+}
+
+func TestShellLimit(t *testing.T) {
+	var results, errs bytes.Buffer
+	sh := NewShell(memDB(t), ShellIO{Results: &results, Errors: &errs})
+	sh.Limit = 3
+	const script = `
+create table n (i int);
+insert into n values(1),(2),(3),(4),(5);
+select i from n;
+`
+	if err := sh.Run(script); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := results.String(), "i\n1\n2\n3\n"; got != want {
+		t.Errorf("expected %q but got: %q", want, got)
+	}
+	if !strings.Contains(errs.String(), "truncated at 3 rows") {
+		t.Errorf("expected truncation warning but got: %q", errs.String())
+	}
+
+	results.Reset()
+	if err := sh.Run(".limit 0\nselect i from n;\n.limit"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := results.String(), "i\n1\n2\n3\n4\n5\n0\n"; got != want {
+		t.Errorf("expected %q but got: %q", want, got)
+	}
+	if err := sh.Run(".limit lots"); err == nil {
+		t.Error("expected error for invalid limit")
+	}
+}
+
+func TestCommandsTx(t *testing.T) {
+	db := memDB(t)
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("create table fixtures (name text)"); err != nil {
+		t.Fatal(err)
+	}
+	const script = "insert into fixtures values('a');\ninsert into fixtures values('b');\nselect count(*) from fixtures;"
+
+	// a script run within a transaction is rolled back with it
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results bytes.Buffer
+	if err := Commands(tx, script, false, &results); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(results.String(), "2") {
+		t.Errorf("expected rows visible within the transaction but got: %q", results.String())
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := row(db, []interface{}{&count}, "select count(*) from fixtures"); err != nil || count != 0 {
+		t.Fatalf("expected rolled back rows but got: %d (%v)", count, err)
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Commands(conn, script, false, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if err := row(db, []interface{}{&count}, "select count(*) from fixtures"); err != nil || count != 2 {
+		t.Fatalf("expected 2 rows but got: %d (%v)", count, err)
+	}
+}
+
+func TestShellURL(t *testing.T) {
+	const script = "create table remote (id int);\ninsert into remote values(1);\n"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/schema.sql" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, script)
+	}))
+	defer ts.Close()
+	sum := sha256.Sum256([]byte(script))
+	url := ts.URL + "/schema.sql"
+
+	db := memDB(t)
+	if err := File(db, fmt.Sprintf("%s#sha256=%x", url, sum), false, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.QueryRow("select count(*) from remote").Scan(&n); err != nil || n != 1 {
+		t.Fatalf("script not run: %d, %v", n, err)
+	}
+
+	sh := NewShell(memDB(t), ShellIO{Results: ioutil.Discard})
+	if err := sh.Run(".read " + url + "#sha256=" + strings.Repeat("00", 32)); !errors.Is(err, ErrChecksum) {
+		t.Errorf("expected checksum error but got: %v", err)
+	}
+	if err := sh.Run(".read " + ts.URL + "/missing.sql"); err == nil {
+		t.Error("expected error for missing script")
+	}
+	sh.RequireChecksum = true
+	if err := sh.Run(".read " + url); err == nil {
+		t.Error("expected error for script without checksum")
+	}
+}
+
+func TestShellVars(t *testing.T) {
+	db := memDB(t)
+	var results bytes.Buffer
+	sh := NewShell(db, ShellIO{Results: &results})
+	os.Setenv("SHELL_TEST_ENV", "'staging'")
+	defer os.Unsetenv("SHELL_TEST_ENV")
+	const script = `
+.set table events
+.set greeting 'hello there'
+create table $table (env text, note text);
+insert into ${table} values('$SHELL_TEST_ENV', $greeting);
+.env on
+insert into $table values($SHELL_TEST_ENV, $param);
+select env, note from $table;
+.print "$greeting"
+.set
+`
+	if err := sh.Run(script); err == nil || !strings.Contains(err.Error(), "$param") {
+		t.Fatalf("expected unknown variable to be left as a parameter but got: %v", err)
+	}
+	sh.Vars["param"] = "'p'"
+	results.Reset()
+	if err := sh.Run("insert into $table values($SHELL_TEST_ENV, $param);\n" + script[strings.Index(script, "select"):]); err != nil {
+		t.Fatal(err)
+	}
+	got := results.String()
+	for _, want := range []string{"$SHELL_TEST_ENV\thello there", "staging\tp", "'hello there'\n", "table=events"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in output:\n%s", want, got)
+		}
+	}
+
+	// variables aren't substituted within strings and quoted identifiers
+	sh.Vars["name"] = "O'Brien"
+	sh.Vars["quoted"] = "'O''Brien'"
+	results.Reset()
+	const quoting = `
+insert into $table values('$name', $quoted);
+insert into $table values("$name", 'it''s $name');
+select note from "$table" where env = '$name';
+select note from [$table] where env = '$name';
+select note from $table where env = '$name' and note = $quoted;
+`
+	if err := sh.Run(quoting); err == nil || !strings.Contains(err.Error(), "$table") {
+		t.Fatalf("expected a quoted identifier to be left as it is but got: %v", err)
+	}
+	results.Reset()
+	if err := sh.Run(quoting[strings.Index(quoting, "select note from $table"):]); err != nil {
+		t.Fatal(err)
+	}
+	if got := results.String(); got != "note\nO'Brien\n" {
+		t.Errorf("unexpected results: %q", got)
+	}
+	var count int
+	if err := row(db, []interface{}{&count}, "select count(*) from events where env = '$name' and note = 'it''s $name'"); err != nil || count != 1 {
+		t.Errorf("expected the strings inserted as they are but got: %d (%v)", count, err)
+	}
+
+	if err := sh.Run(".set bad-name x"); err == nil {
+		t.Error("expected error for invalid name")
+	}
+	sh.Run(".unset table")
+	if _, ok := sh.Vars["table"]; ok {
+		t.Error("variable not unset")
+	}
+}
+
+func TestCommandsAllow(t *testing.T) {
+	db := memDB(t)
+	if _, err := db.Exec("create table notes (body text)"); err != nil {
+		t.Fatal(err)
+	}
+	var results bytes.Buffer
+	opts := &CommandsOptions{Allow: []Kind{KindQuery, KindWrite}}
+	const script = `
+insert into notes values('kept');
+select body from notes;
+`
+	if err := RunCommands(db, script, &results, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := results.String(); got != "body\nkept\n" {
+		t.Errorf("unexpected results: %q", got)
+	}
+	for _, refused := range []string{
+		"insert into notes values('lost');\ndrop table notes;",
+		"pragma user_version=7",
+		"attach ':memory:' as other",
+	} {
+		err := RunCommands(db, refused, ioutil.Discard, opts)
+		var serr *ScriptError
+		if !errors.Is(err, ErrNotAllowed) || !errors.As(err, &serr) {
+			t.Errorf("%q: expected ErrNotAllowed but got: %v", refused, err)
+		}
+	}
+	// nothing of a refused script is run
+	var count int
+	if err := row(db, []interface{}{&count}, "select count(*) from notes"); err != nil || count != 1 {
+		t.Errorf("expected 1 note but got: %d (%v)", count, err)
+	}
+
+	// variables are expanded before statements are classified
+	sh := NewShell(db, ShellIO{Results: ioutil.Discard})
+	sh.Allow = opts.Allow
+	if err := sh.Run(".set verb drop\n$verb table notes;"); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("expected ErrNotAllowed but got: %v", err)
+	}
+	// nor can variables add statements to those allowed
+	if _, err := db.Exec("create table users (id integer primary key)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, script := range []string{
+		".set x 1; DROP TABLE users\nSELECT $x;\n",
+		".set x 1; DROP TABLE users\nSELECT * FROM notes WHERE body = $x;\n",
+	} {
+		err := RunCommands(db, script, ioutil.Discard, &CommandsOptions{Allow: []Kind{KindQuery}})
+		if !errors.Is(err, ErrNotAllowed) {
+			t.Errorf("%q: expected ErrNotAllowed but got: %v", script, err)
+		}
+	}
+	if err := RunCommands(db, ".set x 1; DROP TABLE users\nSELECT $x;\n", ioutil.Discard, nil); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("expected ErrNotAllowed for statements added by a variable but got: %v", err)
+	}
+	if err := row(db, []interface{}{&count}, "select count(*) from users"); err != nil {
+		t.Errorf("expected the users table to remain: %v", err)
+	}
+	if err := Commands(db, "create table more (a)", false, ioutil.Discard); err != nil {
+		t.Errorf("expected all statements allowed by default but got: %v", err)
+	}
+}
+
+func TestShellRecover(t *testing.T) {
+	db := fileDB(t, t.TempDir())
+	const script = `
+BEGIN TRANSACTION;
+create table parts (id integer primary key, name text not null);
+insert into parts values(1, 'a');
+insert into parts values(1, 'duplicate');
+insert into parts values(2, null);
+insert into parts values(3, 'c');
+COMMIT;
+`
+	if err := Commands(db, script, false, ioutil.Discard); err == nil {
+		t.Fatal("expected the script to fail")
+	}
+	db.Exec("rollback")
+	db.Exec("drop table if exists parts")
+
+	var errs bytes.Buffer
+	if err := RunCommands(db, script, ioutil.Discard, &CommandsOptions{Recover: true, Errors: &errs}); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := row(db, []interface{}{&count}, "select count(*) from parts"); err != nil || count != 2 {
+		t.Errorf("expected 2 parts but got: %d (%v)", count, err)
+	}
+	if got := errs.String(); strings.Count(got, "skipped") != 2 || !strings.Contains(got, "line 5") {
+		t.Errorf("unexpected report of skipped statements: %q", got)
+	}
+
+	// switched on by the script, with the statements skipped kept
+	sh := NewShell(db, ShellIO{Results: ioutil.Discard, Errors: ioutil.Discard})
+	if err := sh.Run(".recover on\ninsert into parts values(3, 'again');\ninsert into parts values(4, 'd');"); err != nil {
+		t.Fatal(err)
+	}
+	if len(sh.Skipped) != 1 || sh.Skipped[0].Index != 2 || !strings.Contains(sh.Skipped[0].Error(), "UNIQUE") {
+		t.Errorf("unexpected statements skipped: %v", sh.Skipped)
+	}
+	if _, ok := sh.DB.(*sql.DB); !ok {
+		t.Error("expected the connection to be released after the script")
+	}
+	if err := row(db, []interface{}{&count}, "select count(*) from parts"); err != nil || count != 3 {
+		t.Errorf("expected 3 parts but got: %d (%v)", count, err)
+	}
+}
@@ -0,0 +1,242 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Statement is a single SQL statement or dot-command within a script
+type Statement struct {
+	SQL    string // text of the statement, without comments or the terminating semicolon
+	Text   string // text of the statement as written, including any comments that precede it
+	Offset int    // byte offset of the statement within the script
+	Line   int    // line number of the start of the statement, starting at 1
+	Column int    // column (in bytes) of the start of the statement, starting at 1
+	Dot    bool   // a dot-command (e.g., ".read FILENAME"), which runs to the end of its line
+}
+
+// SplitError reports a script that can't be split, such as one with an unterminated string
+type SplitError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SplitError) Error() string {
+	return fmt.Sprintf("line %d column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// SplitStatements splits a script into its statements and dot-commands.
+// Semicolons within strings, quoted identifiers, comments, and trigger bodies
+// do not end a statement, and empty statements are omitted
+func SplitStatements(sql string) ([]Statement, error) {
+	s := &splitter{sql: sql, line: 1, lineStart: 0, start: -1, lead: -1}
+	return s.split()
+}
+
+type splitter struct {
+	sql       string
+	list      []Statement
+	line      int // current line
+	lineStart int // offset of the start of the current line
+	start     int // offset of the current statement, -1 if none is pending
+	lead      int // offset of the first comment preceding the statement, -1 if none
+	stmt      Statement
+	comments  [][2]int // spans of the comments within the current statement
+
+	// trigger bodies contain semicolons, so track BEGIN/CASE ... END nesting
+	words   []string // leading keywords of the statement
+	trigger bool
+	begun   bool
+	depth   int
+}
+
+func (s *splitter) errorf(offset int, format string, args ...interface{}) error {
+	return &SplitError{Line: s.line, Column: offset - s.lineStart + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+// mark notes the start of a statement at offset i, if one isn't already pending
+func (s *splitter) mark(i int) {
+	if s.start < 0 {
+		s.start = i
+		s.stmt = Statement{Offset: i, Line: s.line, Column: i - s.lineStart + 1}
+	}
+}
+
+// comment notes a comment spanning sql[from:to]
+func (s *splitter) comment(from, to int) {
+	if s.start >= 0 {
+		s.comments = append(s.comments, [2]int{from, to})
+	} else if s.lead < 0 {
+		s.lead = from
+	}
+}
+
+// emit completes the pending statement, which ends at offset i
+func (s *splitter) emit(i int) {
+	if s.start >= 0 {
+		s.stmt.SQL = s.strip(i)
+		if s.stmt.SQL != "" {
+			text := s.start
+			if s.lead >= 0 {
+				text = s.lead
+			}
+			s.stmt.Text = strings.TrimRightFunc(s.sql[text:i], unicode.IsSpace)
+			s.list = append(s.list, s.stmt)
+		}
+		s.lead = -1
+	}
+	s.start = -1
+	s.comments = s.comments[:0]
+	s.words = s.words[:0]
+	s.trigger, s.begun, s.depth = false, false, 0
+}
+
+// strip returns the pending statement, which ends at offset i, without its comments.
+// A comment is replaced by a space unless whitespace already precedes it
+func (s *splitter) strip(i int) string {
+	var b strings.Builder
+	from := s.start
+	for _, span := range s.comments {
+		b.WriteString(s.sql[from:span[0]])
+		if span[0] > 0 && !unicode.IsSpace(rune(s.sql[span[0]-1])) {
+			b.WriteByte(' ')
+		}
+		from = span[1]
+	}
+	b.WriteString(s.sql[from:i])
+	return strings.TrimRightFunc(b.String(), unicode.IsSpace)
+}
+
+// newlines advances the line count for any newlines in sql[from:to]
+func (s *splitter) newlines(from, to int) {
+	for i := from; i < to; i++ {
+		if s.sql[i] == '\n' {
+			s.line++
+			s.lineStart = i + 1
+		}
+	}
+}
+
+func (s *splitter) split() ([]Statement, error) {
+	sql := s.sql
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\n':
+			s.line++
+			i++
+			s.lineStart = i
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			s.comment(i, i+end)
+			i += end
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return s.list, s.errorf(i, "unterminated comment")
+			}
+			s.newlines(i, i+2+end)
+			s.comment(i, i+end+4)
+			i += end + 4
+		case c == '.' && s.start < 0:
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			s.mark(i)
+			s.stmt.Dot = true
+			s.emit(i + end)
+			i += end
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			s.mark(i)
+			j := quoteEnd(sql, i)
+			if j < 0 {
+				return s.list, s.errorf(i, "unterminated %c", c)
+			}
+			s.newlines(i, j)
+			i = j
+		case c == ';':
+			if s.start >= 0 && (!s.trigger || (s.begun && s.depth <= 0)) {
+				s.emit(i)
+			}
+			i++
+		case isIdentChar(c):
+			j := i + 1
+			for j < len(sql) && isIdentChar(sql[j]) {
+				j++
+			}
+			s.mark(i)
+			s.keyword(strings.ToUpper(sql[i:j]))
+			i = j
+		default:
+			s.mark(i)
+			i++
+		}
+	}
+	if s.start >= 0 {
+		if s.trigger && s.depth > 0 {
+			return s.list, &SplitError{Line: s.stmt.Line, Column: s.stmt.Column, Msg: "unterminated trigger"}
+		}
+		s.emit(len(sql))
+	}
+	return s.list, nil
+}
+
+// keyword tracks the words that identify a trigger and its body
+func (s *splitter) keyword(word string) {
+	if len(s.words) < 3 {
+		s.words = append(s.words, word)
+		switch {
+		case len(s.words) == 2 && s.words[0] == "CREATE" && word == "TRIGGER",
+			len(s.words) == 3 && s.words[0] == "CREATE" && word == "TRIGGER" &&
+				(s.words[1] == "TEMP" || s.words[1] == "TEMPORARY"):
+			s.trigger = true
+		}
+	}
+	if !s.trigger {
+		return
+	}
+	switch word {
+	case "BEGIN":
+		s.begun = true
+		s.depth++
+	case "CASE":
+		s.depth++
+	case "END":
+		s.depth--
+	}
+}
+
+// quoteEnd returns the offset just past the string or quoted identifier starting at
+// sql[i], with its quote, or -1 if it's unterminated
+func quoteEnd(sql string, i int) int {
+	closer := sql[i]
+	if closer == '[' {
+		closer = ']'
+	}
+	j := i + 1
+	for {
+		end := strings.IndexByte(sql[j:], closer)
+		if end < 0 {
+			return -1
+		}
+		j += end + 1
+		// quotes are escaped by doubling them
+		if closer == ']' || j >= len(sql) || sql[j] != closer {
+			return j
+		}
+		j++
+	}
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
@@ -0,0 +1,159 @@
+package sqlite
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	const script = `.print 'starting'
+create table a (id int, s text); insert into a values(1, 'semi;colon');
+-- a comment; with a semicolon
+insert into a values(2, 'it''s');
+/* block; comment */ select "odd;name" from [we;ird];
+CREATE TRIGGER a_insert AFTER INSERT ON a
+BEGIN
+    update a set s = CASE WHEN s IS NULL THEN 'x' ELSE s END;
+    delete from a where id < 0;
+END;
+;;
+select 1`
+	want := []Statement{
+		{SQL: ".print 'starting'", Line: 1, Column: 1, Dot: true},
+		{SQL: "create table a (id int, s text)", Line: 2, Column: 1},
+		{SQL: "insert into a values(1, 'semi;colon')", Line: 2, Column: 34},
+		{SQL: "insert into a values(2, 'it''s')", Line: 4, Column: 1},
+		{SQL: `select "odd;name" from [we;ird]`, Line: 5, Column: 22},
+		{SQL: "CREATE TRIGGER a_insert", Line: 6, Column: 1},
+		{SQL: "select 1", Line: 12, Column: 1},
+	}
+	list, err := SplitStatements(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(want) {
+		for _, s := range list {
+			t.Logf("%d:%d %q", s.Line, s.Column, s.SQL)
+		}
+		t.Fatalf("expected %d statements but got: %d", len(want), len(list))
+	}
+	for i, w := range want {
+		got := list[i]
+		if !strings.HasPrefix(got.SQL, w.SQL) || got.Line != w.Line || got.Column != w.Column || got.Dot != w.Dot {
+			t.Errorf("statement %d: expected %+v but got: %+v", i, w, got)
+		}
+		if !strings.HasPrefix(script[got.Offset:], got.SQL) {
+			t.Errorf("statement %d: offset %d does not match", i, got.Offset)
+		}
+	}
+	if trigger := list[5].SQL; !strings.HasSuffix(trigger, "END") {
+		t.Errorf("trigger was split: %q", trigger)
+	}
+}
+
+func TestSplitStatementsComments(t *testing.T) {
+	const script = `-- load the lots
+insert into lots values('LOT--42', '/* not a comment */', 'http://example.com'); -- trailing
+/* header */
+select id, /* inline */ name -- why
+  from lots`
+	want := []Statement{
+		{
+			SQL:  `insert into lots values('LOT--42', '/* not a comment */', 'http://example.com')`,
+			Text: "-- load the lots\n" + `insert into lots values('LOT--42', '/* not a comment */', 'http://example.com')`,
+		},
+		{
+			SQL:  "select id,  name \n  from lots",
+			Text: "-- trailing\n/* header */\nselect id, /* inline */ name -- why\n  from lots",
+		},
+	}
+	list, err := SplitStatements(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(want) {
+		t.Fatalf("expected %d statements but got: %+v", len(want), list)
+	}
+	for i, w := range want {
+		if list[i].SQL != w.SQL {
+			t.Errorf("statement %d: expected SQL %q but got: %q", i, w.SQL, list[i].SQL)
+		}
+		if list[i].Text != w.Text {
+			t.Errorf("statement %d: expected text %q but got: %q", i, w.Text, list[i].Text)
+		}
+	}
+}
+
+func TestSplitStatementsTempTrigger(t *testing.T) {
+	const script = `create temp trigger t after delete on a begin select 1; select 2; end; select 3;`
+	list, err := SplitStatements(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 statements but got: %+v", list)
+	}
+}
+
+func TestSplitStatementsErrors(t *testing.T) {
+	for _, script := range []string{
+		"select 'unterminated",
+		"select 1;\nselect \"unterminated",
+		"select 1 /* unterminated",
+		"create trigger t after insert on a begin select 1;",
+	} {
+		if _, err := SplitStatements(script); err == nil {
+			t.Errorf("expected error for: %q", script)
+		} else {
+			t.Log(err)
+		}
+	}
+}
+
+func TestSplitStatementsErrorPosition(t *testing.T) {
+	_, err := SplitStatements("select 1;\n  select 'oops")
+	serr, ok := err.(*SplitError)
+	if !ok {
+		t.Fatalf("expected SplitError but got: %v", err)
+	}
+	if serr.Line != 2 || serr.Column != 10 {
+		t.Fatalf("expected line 2 column 10 but got: %+v", serr)
+	}
+}
+
+func FuzzSplitStatements(f *testing.F) {
+	for _, seed := range []string{
+		"select 1; select 2",
+		"insert into a values('a;b', \"c;d\", [e;f], `g;h`);",
+		"-- comment\n/* block */ .read file.sql\nselect 1",
+		"create trigger t after insert on a begin select case when 1 then 2 end; end;",
+		"select 'it''s';",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, script string) {
+		list, err := SplitStatements(script)
+		if err != nil {
+			return
+		}
+		last := -1
+		for _, s := range list {
+			if s.SQL == "" {
+				t.Fatalf("empty statement in %q", script)
+			}
+			if s.Offset <= last || script[s.Offset] != s.SQL[0] {
+				t.Fatalf("bad offset %d for %q in %q", s.Offset, s.SQL, script)
+			}
+			if !strings.Contains(script, s.Text) || !strings.Contains(s.Text, s.SQL[:1]) {
+				t.Fatalf("bad text %q for %q in %q", s.Text, s.SQL, script)
+			}
+			if !s.Dot && (strings.Contains(s.SQL, "/*") || strings.Contains(s.SQL, "--")) && !strings.ContainsAny(s.SQL, "'\"`[") {
+				t.Fatalf("comment left in %q", s.SQL)
+			}
+			if line := strings.Count(script[:s.Offset], "\n") + 1; line != s.Line {
+				t.Fatalf("expected line %d but got: %d", line, s.Line)
+			}
+			last = s.Offset
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package sqlite
+
+import (
+	"database/sql"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueryStat is the statistics of the statements sharing a fingerprint
+type QueryStat struct {
+	Fingerprint string        // the statement with its literals replaced by ?
+	Example     string        // the most recent statement with this fingerprint
+	Count       int64         // times run
+	Errors      int64         // times failed
+	Total       time.Duration // total time taken
+	Max         time.Duration // longest time taken
+}
+
+// Mean returns the average time taken
+func (q QueryStat) Mean() time.Duration {
+	if q.Count == 0 {
+		return 0
+	}
+	return q.Total / time.Duration(q.Count)
+}
+
+// QueryStats aggregates the statements run by a database by their fingerprint,
+// in the manner of PostgreSQL's pg_stat_statements
+type QueryStats struct {
+	mu    sync.Mutex
+	stats map[string]*QueryStat
+}
+
+// NewQueryStats returns an empty QueryStats
+func NewQueryStats() *QueryStats {
+	return &QueryStats{stats: make(map[string]*QueryStat)}
+}
+
+// WithQueryStats gathers the statistics of the statements run by the database,
+// which are available from TopQueries and StatsOf
+func WithQueryStats() Optional {
+	return func(c *Config) {
+		c.stats = NewQueryStats()
+	}
+}
+
+// StatsOf returns the statistics of a database opened WithQueryStats, or nil
+func StatsOf(db *sql.DB) *QueryStats {
+	d, ok := db.Driver().(*liteDriver)
+	if !ok {
+		return nil
+	}
+	d.c.Lock()
+	defer d.c.Unlock()
+	return d.c.stats
+}
+
+// TopQueries returns the n statements of a database opened WithQueryStats
+// that took the most time in total, or all of them if n is 0
+func TopQueries(db *sql.DB, n int) []QueryStat {
+	return StatsOf(db).Top(n)
+}
+
+// Trace implements TraceSink, aggregating statements
+func (s *QueryStats) Trace(ev TraceEvent) {
+	if ev.Kind != TraceStatement {
+		return
+	}
+	fp := Fingerprint(ev.SQL)
+	s.mu.Lock()
+	q, ok := s.stats[fp]
+	if !ok {
+		q = &QueryStat{Fingerprint: fp}
+		s.stats[fp] = q
+	}
+	q.Example = ev.SQL
+	q.Count++
+	if ev.Err != nil {
+		q.Errors++
+	}
+	q.Total += ev.Duration
+	if ev.Duration > q.Max {
+		q.Max = ev.Duration
+	}
+	s.mu.Unlock()
+}
+
+// Top returns the n statements that took the most time in total, or all of them if n is 0
+func (s *QueryStats) Top(n int) []QueryStat {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	list := make([]QueryStat, 0, len(s.stats))
+	for _, q := range s.stats {
+		list = append(list, *q)
+	}
+	s.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Total != list[j].Total {
+			return list[i].Total > list[j].Total
+		}
+		return list[i].Fingerprint < list[j].Fingerprint
+	})
+	if n > 0 && n < len(list) {
+		list = list[:n]
+	}
+	return list
+}
+
+// Reset discards the statistics gathered so far
+func (s *QueryStats) Reset() {
+	s.mu.Lock()
+	s.stats = make(map[string]*QueryStat)
+	s.mu.Unlock()
+}
+
+var (
+	fingerprintToken = regexp.MustCompile(`(?s)'(?:[^']|'')*'|[xX]'[0-9A-Fa-f]*'|"(?:[^"]|"")*"|` +
+		"`(?:[^`]|``)*`" + `|\[[^\]]*\]|--[^\n]*|/\*.*?\*/|[A-Za-z_][A-Za-z0-9_$]*|` +
+		`(?:0[xX][0-9A-Fa-f]+|(?:\d+\.?\d*|\.\d+)(?:[eE][+-]?\d+)?)|[?:@$][A-Za-z0-9_]*|\s+|.`)
+	fingerprintList = regexp.MustCompile(`\?(?:, ?\?)+`)
+)
+
+// Fingerprint normalizes a statement so those differing only in their literals,
+// whitespace, comments, or keyword case are the same: literals (and parameters)
+// become ?, lists of them become a single ?, and keywords are upper cased
+func Fingerprint(query string) string {
+	var b strings.Builder
+	space := false
+	for _, tok := range fingerprintToken.FindAllString(query, -1) {
+		c := tok[0]
+		switch {
+		case strings.TrimSpace(tok) == "", strings.HasPrefix(tok, "--"), strings.HasPrefix(tok, "/*"):
+			space = b.Len() > 0
+			continue
+		case c == '\'', c == '?', c == ':', c == '@', c == '$',
+			(c == 'x' || c == 'X') && len(tok) > 1 && tok[1] == '\'',
+			c >= '0' && c <= '9', c == '.' && len(tok) > 1:
+			tok = "?"
+		case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			if upper := strings.ToUpper(tok); sqlKeywords[upper] {
+				tok = upper
+			}
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteString(tok)
+	}
+	fp := fingerprintList.ReplaceAllString(b.String(), "?")
+	return strings.TrimSuffix(strings.TrimSpace(fp), ";")
+}
+
+// sqlKeywords are the keywords upper cased by Fingerprint
+var sqlKeywords = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`ABORT ACTION ADD AFTER ALL ALTER ALWAYS ANALYZE AND AS ASC
+		ATTACH AUTOINCREMENT BEFORE BEGIN BETWEEN BY CASCADE CASE CAST CHECK COLLATE COLUMN
+		COMMIT CONFLICT CONSTRAINT CREATE CROSS CURRENT CURRENT_DATE CURRENT_TIME
+		CURRENT_TIMESTAMP DATABASE DEFAULT DEFERRABLE DEFERRED DELETE DESC DETACH DISTINCT DO
+		DROP EACH ELSE END ESCAPE EXCEPT EXCLUDE EXCLUSIVE EXISTS EXPLAIN FAIL FILTER FIRST
+		FOLLOWING FOR FOREIGN FROM FULL GENERATED GLOB GROUP GROUPS HAVING IF IGNORE IMMEDIATE
+		IN INDEX INDEXED INITIALLY INNER INSERT INSTEAD INTERSECT INTO IS ISNULL JOIN KEY LAST
+		LEFT LIKE LIMIT MATCH MATERIALIZED NATURAL NO NOT NOTHING NOTNULL NULL NULLS OF OFFSET
+		ON OR ORDER OTHERS OUTER OVER PARTITION PLAN PRAGMA PRECEDING PRIMARY QUERY RAISE
+		RANGE RECURSIVE REFERENCES REGEXP REINDEX RELEASE RENAME REPLACE RESTRICT RETURNING
+		RIGHT ROLLBACK ROW ROWS SAVEPOINT SELECT SET TABLE TEMP TEMPORARY THEN TIES TO
+		TRANSACTION TRIGGER UNBOUNDED UNION UNIQUE UPDATE USING VACUUM VALUES VIEW VIRTUAL
+		WHEN WHERE WINDOW WITH WITHOUT`) {
+		sqlKeywords[k] = true
+	}
+}
@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"select * from t where id=1", "SELECT * FROM t WHERE id=?"},
+		{"SELECT *\n  FROM t -- comment\n WHERE id = 42;", "SELECT * FROM t WHERE id = ?"},
+		{"insert into t (a, b) values('it''s', -1.5e3)", "INSERT INTO t (a, b) VALUES(?, -?)"},
+		{"select * from t where id in (1, 2,3) and x=x'00ff'", "SELECT * FROM t WHERE id IN (?) AND x=?"},
+		{`select "from", t1.c2 from t1 where n=:name or n=?2`, `SELECT "from", t1.c2 FROM t1 WHERE n=? OR n=?`},
+		{"select /* hint */ 'a;b'", "SELECT ?"},
+	}
+	for _, tt := range tests {
+		if got := Fingerprint(tt.query); got != tt.want {
+			t.Errorf("%q: expected %q but got %q", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestTopQueries(t *testing.T) {
+	db, err := Open(":memory:", WithQueryStats())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("create table ts (id integer primary key, name text)"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := db.Exec("insert into ts (name) values(?)", "x"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("insert into ts (name) values('literal')"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Exec("select * from nosuch where id=1")
+
+	stats := TopQueries(db, 0)
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 fingerprints but got: %+v", stats)
+	}
+	counts := make(map[string]QueryStat)
+	for i, q := range stats {
+		counts[q.Fingerprint] = q
+		if i > 0 && q.Total > stats[i-1].Total {
+			t.Errorf("not in order of total time: %+v", stats)
+		}
+		if q.Max > q.Total || q.Mean() > q.Max {
+			t.Errorf("inconsistent times: %+v", q)
+		}
+	}
+	if q := counts["INSERT INTO ts (name) VALUES(?)"]; q.Count != 10 || q.Example != "insert into ts (name) values('literal')" {
+		t.Errorf("unexpected insert stats: %+v", q)
+	}
+	if q := counts["SELECT * FROM nosuch WHERE id=?"]; q.Count != 1 || q.Errors != 1 {
+		t.Errorf("unexpected error stats: %+v", q)
+	}
+	if top := TopQueries(db, 1); len(top) != 1 || top[0] != stats[0] {
+		t.Errorf("unexpected top query: %+v", top)
+	}
+
+	StatsOf(db).Reset()
+	if stats := TopQueries(db, 0); len(stats) != 0 {
+		t.Errorf("expected no stats after reset but got: %+v", stats)
+	}
+	if TopQueries(memDB(t), 0) != nil {
+		t.Error("expected no stats")
+	}
+}
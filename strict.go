@@ -0,0 +1,271 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// WithStrict makes misuse an error rather than something logged or ignored:
+// pragmas unknown to SQLite are rejected, as are functions registered twice
+// and opening a driver registered by an earlier WithDriver, and Close
+// returns the failure of its checkpoint
+func WithStrict() Optional {
+	return func(c *Config) {
+		c.strict = true
+	}
+}
+
+// isStrict reports whether the database was opened WithStrict
+func isStrict(db *sql.DB) bool {
+	d, ok := db.Driver().(*liteDriver)
+	if !ok {
+		return false
+	}
+	d.c.Lock()
+	defer d.c.Unlock()
+	return d.c.strict
+}
+
+var (
+	pragmaOnce  sync.Once
+	pragmaNames map[string]bool
+	pragmaErr   error
+)
+
+// knownPragmas returns the names of the pragmas known to SQLite
+func knownPragmas() (map[string]bool, error) {
+	pragmaOnce.Do(func() {
+		db, err := sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			pragmaErr = err
+			return
+		}
+		defer db.Close()
+		names := make(map[string]bool)
+		pragmaErr = query(db, func(_ []string, row []interface{}) {
+			if name, ok := row[0].(string); ok {
+				names[name] = true
+			}
+		}, "PRAGMA pragma_list")
+		pragmaNames = names
+	})
+	return pragmaNames, pragmaErr
+}
+
+// pragmaName returns the name of the pragma set by a setting such as "main.journal_mode=WAL"
+func pragmaName(pragma string) string {
+	name := pragma
+	if i := strings.IndexAny(name, "=("); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ToLower(name)
+}
+
+// validateStrict checks the configuration for the misuse rejected by WithStrict
+func (c *Config) validateStrict() error {
+	if len(c.pragmas) > 0 {
+		known, err := knownPragmas()
+		if err != nil {
+			return fmt.Errorf("can't list pragmas: %w", err)
+		}
+		for _, pragma := range c.pragmas {
+			if !known[pragmaName(pragma)] {
+				return fmt.Errorf("unknown pragma: %q", pragma)
+			}
+		}
+	}
+	names := make(map[string]bool)
+	var all []string
+	for _, f := range c.funcs {
+		all = append(all, f.Name)
+	}
+	for _, a := range c.aggs {
+		all = append(all, a.Name)
+	}
+	for _, w := range c.windows {
+		all = append(all, w.Name)
+	}
+	for _, name := range all {
+		key := strings.ToLower(name)
+		if names[key] {
+			return fmt.Errorf("function %q registered more than once", name)
+		}
+		names[key] = true
+	}
+	return nil
+}
+
+// strictSupported reports whether the SQLite library supports STRICT tables,
+// which were added in 3.37.0
+var strictSupported = func() bool {
+	_, version, _ := sqlite3.Version()
+	return version >= 3037000
+}
+
+// WithStrictTables makes the tables created by the package's helpers, e.g. by ImportCSV
+// and Downsample, STRICT tables, whose columns reject values of other types rather than
+// storing them as they are, if the SQLite library supports them. Their columns are of the
+// types STRICT tables allow, so dates are TEXT and bools INTEGER, see StrictType
+func WithStrictTables() Optional {
+	return func(c *Config) {
+		c.tables = true
+	}
+}
+
+// StrictTables reports whether the tables created by the package's helpers in the
+// database are STRICT, as it was opened WithStrictTables and SQLite supports them
+func StrictTables(db *sql.DB) bool {
+	d, ok := db.Driver().(*liteDriver)
+	if !ok || !strictSupported() {
+		return false
+	}
+	d.c.Lock()
+	defer d.c.Unlock()
+	return d.c.tables
+}
+
+// tableDef returns the definition of a table created by a helper, its columns and
+// their declared types, as STRICT if the tables of the database are
+func tableDef(db *sql.DB, defs [][2]string) string {
+	strict := StrictTables(db)
+	columns := make([]string, len(defs))
+	for i, def := range defs {
+		decl := def[1]
+		if strict {
+			decl = StrictType(decl)
+		}
+		columns[i] = strings.TrimSpace(def[0] + " " + decl)
+	}
+	def := "(" + strings.Join(columns, ", ") + ")"
+	if strict {
+		def += " STRICT"
+	}
+	return def
+}
+
+// Affinity returns the type affinity of a column with the declared type, per SQLite's
+// rules: INTEGER, TEXT, BLOB (of columns without a type), REAL, or NUMERIC
+func Affinity(decl string) string {
+	upper := strings.ToUpper(decl)
+	switch {
+	case strings.Contains(upper, "INT"):
+		return "INTEGER"
+	case strings.Contains(upper, "CHAR"), strings.Contains(upper, "CLOB"), strings.Contains(upper, "TEXT"):
+		return "TEXT"
+	case strings.Contains(upper, "BLOB"), strings.TrimSpace(upper) == "":
+		return "BLOB"
+	case strings.Contains(upper, "REAL"), strings.Contains(upper, "FLOA"), strings.Contains(upper, "DOUB"):
+		return "REAL"
+	}
+	return "NUMERIC"
+}
+
+// constraintKeywords start the constraints of a column, following its type
+var constraintKeywords = map[string]bool{
+	"CONSTRAINT": true, "PRIMARY": true, "NOT": true, "NULL": true, "UNIQUE": true, "CHECK": true,
+	"DEFAULT": true, "COLLATE": true, "REFERENCES": true, "GENERATED": true, "AS": true,
+}
+
+// StrictType returns the type of a STRICT table for a column with the declared type,
+// which may be followed by constraints, e.g. "DATETIME PRIMARY KEY": dates and times are
+// TEXT, bools INTEGER, others the type of their affinity, and numerics ANY
+func StrictType(decl string) string {
+	fields := strings.Fields(decl)
+	n := 0
+	for n < len(fields) && !constraintKeywords[strings.ToUpper(fields[n])] {
+		n++
+	}
+	typ, constraints := strings.Join(fields[:n], " "), strings.Join(fields[n:], " ")
+	upper := strings.ToUpper(typ)
+	switch {
+	case typ == "":
+		typ = "ANY"
+	case strings.Contains(upper, "BOOL"):
+		typ = "INTEGER"
+	case strings.Contains(upper, "DATE"), strings.Contains(upper, "TIME"):
+		typ = "TEXT"
+	case Affinity(typ) == "NUMERIC":
+		typ = "ANY"
+	default:
+		typ = Affinity(typ)
+	}
+	return strings.TrimSpace(typ + " " + constraints)
+}
+
+// AffinityDrift is the values of a column stored as a type its affinity doesn't
+// convert them from, as they couldn't be converted, e.g. text in an INTEGER column
+type AffinityDrift struct {
+	Table    string
+	Column   string
+	Declared string // the declared type of the column
+	Affinity string // of the declared type, see Affinity
+	Type     string // the storage class of the values, as by typeof
+	Rows     int64
+}
+
+func (d AffinityDrift) String() string {
+	return fmt.Sprintf("%s.%s %s (%s affinity): %d %s values", d.Table, d.Column, d.Declared, d.Affinity, d.Rows, d.Type)
+}
+
+// driftTypes are the storage classes that are drift for each affinity
+var driftTypes = map[string]string{
+	"INTEGER": "'text', 'blob', 'real'",
+	"REAL":    "'text', 'blob'",
+	"NUMERIC": "'text', 'blob'",
+	"TEXT":    "'blob'",
+}
+
+// CheckAffinityDrift returns the columns of the tables of the database with values
+// stored as types that don't match their declared affinity, which SQLite stores as
+// they are, rather than failing, so that bugs storing values of the wrong type, e.g.
+// numbers as text, go unnoticed until they are compared or sorted. Each column is
+// scanned, so this is best run by tests or maintenance rather than routinely
+func CheckAffinityDrift(db *sql.DB) ([]AffinityDrift, error) {
+	tables, err := Tables(db)
+	if err != nil {
+		return nil, err
+	}
+	var drift []AffinityDrift
+	for _, table := range tables {
+		var columns [][2]string
+		fn := func(_ []string, row []interface{}) {
+			columns = append(columns, [2]string{fmt.Sprint(row[1]), fmt.Sprint(row[2])})
+		}
+		if err := query(db, fn, "PRAGMA table_info("+quoteIdent(table)+")"); err != nil {
+			return drift, err
+		}
+		for _, column := range columns {
+			affinity := Affinity(column[1])
+			types, ok := driftTypes[affinity]
+			if !ok {
+				continue
+			}
+			name := quoteIdent(column[0])
+			q := fmt.Sprintf("SELECT typeof(%s) AS type, count(*) FROM %s WHERE typeof(%s) IN (%s) GROUP BY type ORDER BY type",
+				name, quoteIdent(table), name, types)
+			fn := func(_ []string, row []interface{}) {
+				n, _ := row[1].(int64)
+				drift = append(drift, AffinityDrift{
+					Table:    table,
+					Column:   column[0],
+					Declared: column[1],
+					Affinity: affinity,
+					Type:     fmt.Sprint(row[0]),
+					Rows:     n,
+				})
+			}
+			if err := query(db, fn, q); err != nil {
+				return drift, fmt.Errorf("table: %s, column: %s, error: %w", table, column[0], err)
+			}
+		}
+	}
+	return drift, nil
+}
@@ -0,0 +1,156 @@
+package sqlite
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStrictPragmas(t *testing.T) {
+	db, err := Open(":memory:", WithStrict(), WithPragmas("main.cache_size = -2000", "foreign_keys(1)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(":memory:", WithStrict(), WithPragmas("jornal_mode=WAL")); err == nil || !strings.Contains(err.Error(), "unknown pragma") {
+		t.Fatalf("expected unknown pragma error but got: %v", err)
+	}
+	// without strict the typo is silently ignored by SQLite
+	db, err = Open(":memory:", WithPragmas("jornal_mode=WAL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+}
+
+func TestStrictRegistration(t *testing.T) {
+	fn := FuncReg{Name: "twice", Impl: func(i int64) int64 { return i * 2 }, Pure: true}
+	if _, err := Open(":memory:", WithStrict(), WithFunctions(fn, fn)); err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Fatalf("expected duplicate function error but got: %v", err)
+	}
+
+	const name = "strict_driver"
+	db, err := Open(":memory:", WithStrict(), WithDriver(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := Open(":memory:", WithStrict(), WithDriver(name)); !errors.Is(err, ErrDriverRegistered) {
+		t.Fatalf("expected ErrDriverRegistered but got: %v", err)
+	}
+}
+
+func TestStrictClose(t *testing.T) {
+	logger := &testLogger{}
+	db, err := Open(":memory:", WithStrict(), WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if err := Close(db); err == nil {
+		t.Fatal("expected an error closing a closed database")
+	}
+	if len(logger.messages) > 0 {
+		t.Fatalf("expected nothing logged but got: %q", logger.messages)
+	}
+}
+
+func TestStrictTables(t *testing.T) {
+	db, err := Open(":memory:", WithStrictTables())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	plain := memDB(t)
+	defer plain.Close()
+	if StrictTables(db) != strictSupported() || StrictTables(plain) {
+		version, _, _ := Version()
+		t.Fatalf("expected strict tables only when supported, for %s", version)
+	}
+	if strictSupported() {
+		if _, err := ImportCSV(db, "points", strings.NewReader("x,when\n1,2021-01-02\n"), nil); err != nil {
+			t.Fatal(err)
+		}
+		var create string
+		if err := row(db, []interface{}{&create}, "select sql from sqlite_master where name='points'"); err != nil || !strings.HasSuffix(create, "STRICT") {
+			t.Errorf("expected a STRICT table but got: %q (%v)", create, err)
+		}
+	} else {
+		// tables are created as they were
+		if _, err := ImportCSV(db, "points", strings.NewReader("x,when\n1,2021-01-02\n"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(fn func() bool) { strictSupported = fn }(strictSupported)
+	strictSupported = func() bool { return true }
+	defs := [][2]string{{"bucket", "DATETIME PRIMARY KEY"}, {"ok", "BOOLEAN"}, {"n", "NUMERIC"}, {"data", ""}}
+	if def := tableDef(db, defs); def != "(bucket TEXT PRIMARY KEY, ok INTEGER, n ANY, data ANY) STRICT" {
+		t.Errorf("unexpected definition: %s", def)
+	}
+	if def := tableDef(plain, defs); def != "(bucket DATETIME PRIMARY KEY, ok BOOLEAN, n NUMERIC, data)" {
+		t.Errorf("unexpected definition: %s", def)
+	}
+}
+
+func TestAffinity(t *testing.T) {
+	tests := []struct {
+		decl, affinity, strict string
+	}{
+		{"INT", "INTEGER", "INTEGER"},
+		{"unsigned big int", "INTEGER", "INTEGER"},
+		{"VARCHAR(20)", "TEXT", "TEXT"},
+		{"clob", "TEXT", "TEXT"},
+		{"BLOB", "BLOB", "BLOB"},
+		{"", "BLOB", "ANY"},
+		{"DOUBLE PRECISION", "REAL", "REAL"},
+		{"float", "REAL", "REAL"},
+		{"DECIMAL(10,5)", "NUMERIC", "ANY"},
+		{"BOOLEAN", "NUMERIC", "INTEGER"},
+		{"DATETIME DEFAULT CURRENT_TIMESTAMP", "NUMERIC", "TEXT DEFAULT CURRENT_TIMESTAMP"},
+	}
+	for _, test := range tests {
+		if got := Affinity(test.decl); got != test.affinity {
+			t.Errorf("%q: expected affinity %s but got: %s", test.decl, test.affinity, got)
+		}
+		if got := StrictType(test.decl); got != test.strict {
+			t.Errorf("%q: expected strict type %s but got: %s", test.decl, test.strict, got)
+		}
+	}
+}
+
+func TestCheckAffinityDrift(t *testing.T) {
+	db := memDB(t)
+	defer db.Close()
+	const schema = `
+create table things (id integer primary key, n int, price real, name text, amount numeric, data);
+insert into things (n, price, name, amount, data) values
+	(1, 1.5, 'one', '12', 'any'),
+	('two', 2, 'two', 'x', 2),
+	(3.5, 'three', x'00', 3, x'01'),
+	('four', 4, 4, 4, 4);
+`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	drift, err := CheckAffinityDrift(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range drift {
+		got = append(got, d.String())
+	}
+	expected := []string{
+		"things.n int (INTEGER affinity): 1 real values",
+		"things.n int (INTEGER affinity): 2 text values",
+		"things.price real (REAL affinity): 1 text values",
+		"things.name text (TEXT affinity): 1 blob values",
+		"things.amount numeric (NUMERIC affinity): 1 text values",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
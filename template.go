@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// TemplateFuncs are the functions available to scripts rendered as templates, for
+// quoting values safely rather than pasting them into statements:
+//
+//	ident    quotes a name, e.g. of a table:    {{ident .table}}
+//	idents   quotes a list of names:            {{idents .columns}}
+//	literal  quotes a value as an SQL literal:  {{literal .env}}
+//	literals quotes a list of values:           IN ({{literals .ids}})
+var TemplateFuncs = template.FuncMap{
+	"ident":    templateIdent,
+	"idents":   func(v interface{}) (string, error) { return templateList(v, templateIdent) },
+	"literal":  templateLiteral,
+	"literals": func(v interface{}) (string, error) { return templateList(v, templateLiteral) },
+}
+
+// RenderScript renders the script as a text/template with the data, and the functions
+// of TemplateFuncs. Values should be added with the quoting functions, and keys missing
+// from the data are an error
+func RenderScript(script string, data map[string]interface{}) (string, error) {
+	t, err := template.New("script").Funcs(TemplateFuncs).Option("missingkey=error").Parse(script)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// FileTemplate is File, with the script rendered as a template with the data, see RenderScript
+func FileTemplate(db Queryer, file string, data map[string]interface{}, echo bool, w io.Writer) error {
+	sh := NewShell(db, ShellIO{Results: w})
+	sh.Echo = echo
+	sh.Data = data
+	return sh.File(file)
+}
+
+// CommandsTemplate is Commands, with the buffer rendered as a template with the data, see RenderScript
+func CommandsTemplate(db Queryer, buffer string, data map[string]interface{}, echo bool, w io.Writer) error {
+	sh := NewShell(db, ShellIO{Results: w})
+	sh.Echo = echo
+	sh.Data = data
+	return sh.Run(buffer)
+}
+
+// templateIdent quotes a value as a name
+func templateIdent(v interface{}) string {
+	return quoteIdent(fmt.Sprint(v))
+}
+
+// templateLiteral quotes a value as an SQL literal, with numbers of any size as numbers
+func templateLiteral(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return sqlLiteral(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return sqlLiteral(rv.Float())
+	}
+	return sqlLiteral(v)
+}
+
+// templateList quotes each element of a slice, separated by commas
+func templateList(v interface{}, quote func(interface{}) string) (string, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", fmt.Errorf("not a list: %v", v)
+	}
+	if b, ok := v.([]byte); ok {
+		return "", fmt.Errorf("not a list: %x", b)
+	}
+	list := make([]string, rv.Len())
+	for i := range list {
+		list[i] = quote(rv.Index(i).Interface())
+	}
+	return strings.Join(list, ", "), nil
+}
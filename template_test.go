@@ -0,0 +1,52 @@
+package sqlite
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestRenderScript(t *testing.T) {
+	data := map[string]interface{}{
+		"table":   `my "table"`,
+		"columns": []string{"id", "name"},
+		"name":    "O'Brien",
+		"ids":     []int{1, 2, 3},
+		"ratio":   0.5,
+	}
+	const script = `select {{idents .columns}} from {{ident .table}} where name={{literal .name}} and id in ({{literals .ids}}) and r={{literal .ratio}}`
+	got, err := RenderScript(script, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `select "id", "name" from "my ""table""" where name='O''Brien' and id in (1, 2, 3) and r=0.5`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if _, err := RenderScript("select {{.missing}}", data); err == nil {
+		t.Error("expected error for missing key")
+	}
+	if _, err := RenderScript("select {{literals .name}}", data); err == nil {
+		t.Error("expected error for a list that isn't")
+	}
+}
+
+func TestCommandsTemplate(t *testing.T) {
+	db := memDB(t)
+	data := map[string]interface{}{"table": "events_prod", "env": "prod"}
+	const script = `
+create table {{ident .table}} (env text);
+insert into {{ident .table}} values({{literal .env}});
+`
+	if err := CommandsTemplate(db, script, data, false, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	var env string
+	if err := db.QueryRow("select env from events_prod").Scan(&env); err != nil || env != "prod" {
+		t.Fatalf("got %q, %v", env, err)
+	}
+	var serr *ScriptError
+	if err := CommandsTemplate(db, "select {{.nope}}", data, false, ioutil.Discard); !errors.As(err, &serr) {
+		t.Errorf("expected ScriptError but got: %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// TempDef is a table or view of a TempSpec. The SQL of a table is either its column
+// definitions, e.g. "id INTEGER PRIMARY KEY, name TEXT", or a query to create it from,
+// and that of a view is its query
+type TempDef struct {
+	Name string
+	SQL  string
+}
+
+// TempSpec are the objects of a TempSchema, created in order: tables, views, then indexes.
+// Their SQL may refer to the objects of the spec by their names in braces, e.g.
+// {orders}, which are replaced by the names they have in the schema
+type TempSpec struct {
+	Tables  []TempDef
+	Views   []TempDef
+	Indexes []TempDef // with SQL of the table and columns indexed, e.g. "{orders} (customer)"
+}
+
+// tempSeq numbers the scratch spaces, for their prefixes
+var tempSeq int64
+
+// Scratch is a namespace of temporary tables and views, e.g. for the working data of
+// a request in a web handler. As temporary objects are only seen by the connection
+// that creates them, it holds on to a connection of its database, on which all its
+// statements are run. The objects are prefixed with the namespace, so the statements
+// refer to them by their names in braces, as in the spec
+type Scratch struct {
+	Prefix string
+	conn   *sql.Conn
+	names  map[string]string
+	drops  []string // in the order to drop them
+	mu     sync.Mutex
+	closed bool
+}
+
+// TempSchema creates the objects of the spec in a new scratch space, which must be
+// closed to drop them and return its connection to the pool. If they can't all be
+// dropped, the connection is discarded rather than returned, so it is always clean
+func TempSchema(db *sql.DB, spec TempSpec) (*Scratch, error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scratch{
+		Prefix: fmt.Sprintf("scratch%d_", atomic.AddInt64(&tempSeq, 1)),
+		conn:   conn,
+		names:  make(map[string]string),
+	}
+	for _, defs := range [][]TempDef{spec.Tables, spec.Views, spec.Indexes} {
+		for _, def := range defs {
+			s.names[def.Name] = s.Prefix + def.Name
+		}
+	}
+	create := func(kind string, def TempDef) error {
+		name := quoteIdent(s.names[def.Name])
+		body := s.Expand(def.SQL)
+		var stmt string
+		switch {
+		case kind == "INDEX":
+			stmt = fmt.Sprintf("CREATE INDEX temp.%s ON %s", name, body)
+		case kind == "VIEW" || isQuery(body):
+			stmt = fmt.Sprintf("CREATE TEMP %s %s AS %s", kind, name, body)
+		default:
+			stmt = fmt.Sprintf("CREATE TEMP TABLE %s (%s)", name, body)
+		}
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("temp schema: %s, error: %w", def.Name, err)
+		}
+		if kind != "INDEX" {
+			s.drops = append([]string{fmt.Sprintf("DROP %s IF EXISTS temp.%s", kind, name)}, s.drops...)
+		}
+		return nil
+	}
+	kinds := []struct {
+		kind string
+		defs []TempDef
+	}{{"TABLE", spec.Tables}, {"VIEW", spec.Views}, {"INDEX", spec.Indexes}}
+	for _, k := range kinds {
+		for _, def := range k.defs {
+			if err := create(k.kind, def); err != nil {
+				s.Close()
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// WithTempSchema calls fn with a scratch space of the spec, which is closed when it returns
+func WithTempSchema(db *sql.DB, spec TempSpec, fn func(*Scratch) error) error {
+	s, err := TempSchema(db, spec)
+	if err != nil {
+		return err
+	}
+	err = fn(s)
+	if cerr := s.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// isQuery reports whether the SQL is a query, rather than column definitions
+func isQuery(s string) bool {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH", "VALUES":
+		return true
+	}
+	return false
+}
+
+// Name returns the name of the object in the scratch space
+func (s *Scratch) Name(name string) string {
+	if n, ok := s.names[name]; ok {
+		return n
+	}
+	return s.Prefix + name
+}
+
+// Expand replaces the names in braces of the objects of the scratch space
+// in the statement with their names in the schema
+func (s *Scratch) Expand(stmt string) string {
+	pairs := make([]string, 0, len(s.names)*2)
+	for name, full := range s.names {
+		pairs = append(pairs, "{"+name+"}", quoteIdent(full))
+	}
+	return strings.NewReplacer(pairs...).Replace(stmt)
+}
+
+// Conn returns the connection that sees the objects
+func (s *Scratch) Conn() *sql.Conn {
+	return s.conn
+}
+
+// Exec runs a statement, with the names expanded, on the connection that sees the objects
+func (s *Scratch) Exec(stmt string, args ...interface{}) (sql.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.Expand(stmt), args...)
+}
+
+// Query runs a query, with the names expanded, on the connection that sees the objects
+func (s *Scratch) Query(q string, args ...interface{}) (*sql.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.Expand(q), args...)
+}
+
+// QueryRow runs a query returning a single row, with the names expanded,
+// on the connection that sees the objects
+func (s *Scratch) QueryRow(q string, args ...interface{}) *sql.Row {
+	return s.conn.QueryRowContext(context.Background(), s.Expand(q), args...)
+}
+
+// Close drops the objects and returns the connection to the pool, or discards
+// it if they can't be dropped. Closing it again does nothing
+func (s *Scratch) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	ctx := context.Background()
+	var err error
+	for _, drop := range s.drops {
+		if _, err = s.conn.ExecContext(ctx, drop); err != nil {
+			err = fmt.Errorf("temp schema: %s, error: %w", s.Prefix, err)
+			break
+		}
+	}
+	if err != nil {
+		s.conn.Raw(func(interface{}) error {
+			return driver.ErrBadConn
+		})
+	}
+	if cerr := s.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package sqlite
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestTempSchema(t *testing.T) {
+	db := fileDB(t, t.TempDir())
+	spec := TempSpec{
+		Tables: []TempDef{
+			{Name: "picks", SQL: "name TEXT PRIMARY KEY, score INTEGER"},
+			{Name: "big", SQL: "SELECT name, kind FROM structs WHERE kind > 20"},
+		},
+		Views:   []TempDef{{Name: "ranked", SQL: "SELECT p.name, p.score, b.kind FROM {picks} p JOIN {big} b USING (name)"}},
+		Indexes: []TempDef{{Name: "picks_score", SQL: "{picks} (score)"}},
+	}
+
+	// concurrent scratch spaces have their own objects
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := WithTempSchema(db, spec, func(s *Scratch) error {
+				var count int
+				if err := s.QueryRow("select count(*) from {big}").Scan(&count); err != nil {
+					return err
+				}
+				if count != 3 {
+					t.Errorf("expected 3 rows but got: %d", count)
+				}
+				if _, err := s.Exec("insert into {picks} select name, ? from {big}", i); err != nil {
+					return err
+				}
+				var score int
+				if err := s.QueryRow("select sum(score) from {ranked}").Scan(&score); err != nil {
+					return err
+				}
+				if score != 3*i {
+					t.Errorf("expected score %d but got: %d", 3*i, score)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	s, err := TempSchema(db, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := s.Name("picks"); name != s.Prefix+"picks" {
+		t.Errorf("unexpected name: %s", name)
+	}
+	AssertPlan(t, s.Conn(), s.Expand("select * from {picks} where score = 1"), PlanExpectations{UsesIndex: s.Name("picks_score")})
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("expected closing again to do nothing but got: %v", err)
+	}
+
+	// the objects are all dropped
+	db.SetMaxOpenConns(1)
+	var count int
+	if err := row(db, []interface{}{&count}, "select count(*) from sqlite_temp_master"); err != nil || count != 0 {
+		t.Errorf("expected no temp objects but got: %d (%v)", count, err)
+	}
+
+	spec.Views = append(spec.Views, TempDef{Name: "bad", SQL: "SELECT * FROM"})
+	if _, err := TempSchema(db, spec); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := row(db, []interface{}{&count}, "select count(*) from sqlite_temp_master"); err != nil || count != 0 {
+		t.Errorf("expected no temp objects after failure but got: %d (%v)", count, err)
+	}
+}
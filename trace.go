@@ -1,122 +1,234 @@
-// +build sqlite_trace trace
-
 package sqlite
 
 import (
+	"context"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"reflect"
+	"time"
 
 	sqlite3 "github.com/mattn/go-sqlite3"
 )
 
-// WithTracing enables SQLite tracing to the logger
-func WithTracing(logger *log.Logger) Optional {
-	if logger == nil {
-		logger = log.New(os.Stderr, "", log.LstdFlags)
-	}
+// TraceKind is the kind of a TraceEvent
+type TraceKind string
 
-	eventMask := sqlite3.TraceStmt | sqlite3.TraceProfile | sqlite3.TraceRow | sqlite3.TraceClose
-	hook := func(conn *sqlite3.SQLiteConn) error {
-		return conn.SetTrace(&sqlite3.TraceConfig{
-			Callback:        traceCallback(logger),
-			EventMask:       eventMask,
-			WantExpandedSQL: true,
-		})
-	}
+// The kinds of trace events. Without SQLite's own tracing, only statements are traced,
+// once they have run; with it, statements are traced as they start, and
+// profile events report the time they took once they complete
+const (
+	TraceStatement TraceKind = "statement"
+	TraceProfile   TraceKind = "profile"
+	TraceRow       TraceKind = "row"
+	TraceClose     TraceKind = "close"
+)
 
-	return func(c *Config) {
-		c.hook = hook
+// TraceEvent is a traced statement, or other event of a connection
+type TraceEvent struct {
+	Kind        TraceKind
+	Time        time.Time
+	SQL         string        // the statement, or the trigger running it
+	ExpandedSQL string        // the statement with its parameters expanded, from SQLite's own tracing
+	Args        []interface{} // the statement's arguments, without SQLite's own tracing
+	Duration    time.Duration
+	Err         error
+	AutoCommit  bool    // whether the connection was outside of a transaction
+	Conn        uintptr // the sqlite3 handle of the connection
+	Stmt        uintptr // the sqlite3 handle of the statement, from SQLite's own tracing
+}
+
+// TraceSink receives trace events. Trace is called by the goroutine running
+// the statement, so it should be quick, and safe for concurrent use
+type TraceSink interface {
+	Trace(TraceEvent)
+}
+
+// TraceFunc is a func used as a TraceSink
+type TraceFunc func(TraceEvent)
+
+// Trace implements TraceSink
+func (fn TraceFunc) Trace(ev TraceEvent) {
+	fn(ev)
+}
+
+// sameValue reports whether two settings, such as sinks or loggers, are the same
+func sameValue(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if ta, tb := reflect.TypeOf(a), reflect.TypeOf(b); ta != tb {
+		return false
+	} else if ta.Kind() == reflect.Func {
+		return funcID(a) == funcID(b)
+	} else if !ta.Comparable() {
+		return false
 	}
+	return a == b
 }
 
-// TraceHook enables SQLite tracing to the logger
-func TraceHook(logger *log.Logger) Hook {
+// LogSink returns a sink writing events to the logger (stderr if nil)
+func LogSink(logger *log.Logger) TraceSink {
 	if logger == nil {
 		logger = log.New(os.Stderr, "", log.LstdFlags)
 	}
+	return logSink{logger}
+}
 
-	eventMask := sqlite3.TraceStmt | sqlite3.TraceProfile | sqlite3.TraceRow | sqlite3.TraceClose
-	hook := func(conn *sqlite3.SQLiteConn) error {
-		return conn.SetTrace(&sqlite3.TraceConfig{
-			Callback:        traceCallback(logger),
-			EventMask:       eventMask,
-			WantExpandedSQL: true,
-		})
-	}
-	return hook
+type logSink struct {
+	logger *log.Logger
 }
 
-func traceCallback(logger *log.Logger) sqlite3.TraceUserCallback {
-	return func(info sqlite3.TraceInfo) int {
-		var dbErrText string
-		if info.DBError.Code != 0 || info.DBError.ExtendedCode != 0 {
-			dbErrText = fmt.Sprintf("; DB error: %#v", info.DBError)
+// Trace implements TraceSink
+func (s logSink) Trace(ev TraceEvent) {
+	// Show the Statement-or-Trigger text in curly braces ('{', '}')
+	// since from the *paired* ASCII characters they are
+	// the least used in SQL syntax, therefore better visual delimiters.
+	var expandedText string
+	if ev.ExpandedSQL != "" {
+		if ev.ExpandedSQL == ev.SQL {
+			expandedText = " = exp"
 		} else {
-			dbErrText = "."
+			expandedText = fmt.Sprintf(" expanded {%q}", ev.ExpandedSQL)
 		}
+	}
+	var argsText, timeText, errText string
+	if len(ev.Args) > 0 {
+		argsText = fmt.Sprintf(" args %v", ev.Args)
+	}
+	if ev.Duration > 0 || ev.Kind == TraceProfile {
+		timeText = fmt.Sprintf("; time %s", ev.Duration)
+	}
+	if ev.Err != nil {
+		errText = fmt.Sprintf("; error: %v", ev.Err)
+	}
+	modeText := "+Tx+"
+	if ev.AutoCommit {
+		modeText = "-AC-"
+	}
+	s.logger.Printf("Trace: %s %s conn 0x%x {%q}%s%s%s%s\n",
+		ev.Kind, modeText, ev.Conn, ev.SQL, expandedText, argsText, timeText, errText)
+}
 
-		// Show the Statement-or-Trigger text in curly braces ('{', '}')
-		// since from the *paired* ASCII characters they are
-		// the least used in SQL syntax, therefore better visual delimiters.
-		// Maybe show 'ExpandedSQL' the same way as 'StmtOrTrigger'.
-		//
-		// A known use of curly braces (outside strings) is
-		// for ODBC escape sequences. Not likely to appear here.
-		//
-		// Template languages, etc. don't matter, we should see their *result*
-		// at *this* level.
-		// Strange curly braces in SQL code that reached the database driver
-		// suggest that there is a bug in the application.
-		// The braces are likely to be either template syntax or
-		// a programming language's string interpolation syntax.
-
-		var expandedText string
-		if info.ExpandedSQL != "" {
-			if info.ExpandedSQL == info.StmtOrTrigger {
-				expandedText = " = exp"
-			} else {
-				expandedText = fmt.Sprintf(" expanded {%q}", info.ExpandedSQL)
-			}
+// ChannelSink returns a sink sending events to ch. Events are dropped
+// rather than blocking the statement when the channel is full
+func ChannelSink(ch chan<- TraceEvent) TraceSink {
+	return TraceFunc(func(ev TraceEvent) {
+		select {
+		case ch <- ev:
+		default:
 		}
+	})
+}
 
-		// SQLite docs as of September 6, 2016: Tracing and Profiling Functions
-		// https://www.sqlite.org/c3ref/profile.html
-		//
-		// The profile callback time is in units of nanoseconds, however
-		// the current implementation is only capable of millisecond resolution
-		// so the six least significant digits in the time are meaningless.
-		// Future versions of SQLite might provide greater resolution on the profiler callback.
-
-		var runTimeText string
-		if info.RunTimeNanosec == 0 {
-			if info.EventCode == sqlite3.TraceProfile {
-				runTimeText = "; time 0" // no measurement unit
-			}
-		} else {
-			const nanosPerMillisec = 1000000
-			if info.RunTimeNanosec%nanosPerMillisec == 0 {
-				runTimeText = fmt.Sprintf("; time %d ms", info.RunTimeNanosec/nanosPerMillisec)
-			} else {
-				// unexpected: better than millisecond resolution
-				runTimeText = fmt.Sprintf("; time %d ns!!!", info.RunTimeNanosec)
-			}
+// nativeTrace enables SQLite's own tracing for a connection.
+// It is only available when built with the "sqlite_trace" or "trace" tag
+var nativeTrace func(conn *sqlite3.SQLiteConn, sink TraceSink) error
+
+// ErrNoTrace is returned by a TraceHook when SQLite's tracing is not available
+var ErrNoTrace = errors.New(`SQLite tracing requires the build tag "sqlite_trace" or "trace"`)
+
+// WithTracing logs the statements of each connection to the logger (stderr if nil).
+// It is WithTraceSink(LogSink(logger))
+func WithTracing(logger *log.Logger) Optional {
+	return WithTraceSink(LogSink(logger))
+}
+
+// WithTraceSink sends the trace events of each connection to the sink.
+// When built with the "sqlite_trace" or "trace" tag, SQLite's own tracing is used,
+// which includes the statements run by triggers, rows, and connections closing.
+// Otherwise each statement is traced once it has run, with its arguments, time taken, and error
+func WithTraceSink(sink TraceSink) Optional {
+	return func(c *Config) {
+		c.trace = sink
+	}
+}
+
+// TraceHook returns a connection hook that enables SQLite's tracing to the logger.
+// Unless built with the "sqlite_trace" or "trace" tag the hook fails with ErrNoTrace,
+// so use WithTracing, which works either way
+func TraceHook(logger *log.Logger) Hook {
+	sink := LogSink(logger)
+	return func(conn *sqlite3.SQLiteConn) error {
+		if nativeTrace == nil {
+			return ErrNoTrace
 		}
+		return nativeTrace(conn, sink)
+	}
+}
 
-		var modeText string
-		if info.AutoCommit {
-			modeText = "-AC-"
-		} else {
-			modeText = "+Tx+"
+// traceStatement traces a statement run without SQLite's tracing
+func (c *liteConn) traceStatement(query string, args []driver.NamedValue, start time.Time, err error) {
+	ev := TraceEvent{
+		Kind:       TraceStatement,
+		Time:       start,
+		SQL:        query,
+		Duration:   time.Since(start),
+		Err:        err,
+		AutoCommit: c.AutoCommit(),
+		Conn:       c.id,
+	}
+	if len(args) > 0 {
+		ev.Args = make([]interface{}, len(args))
+		for i, arg := range args {
+			ev.Args[i] = arg.Value
 		}
-		logger.Printf("Trace: ev %d %s conn 0x%x, stmt 0x%x {%q}%s%s%s\n",
-			info.EventCode, modeText, info.ConnHandle, info.StmtHandle,
-			info.StmtOrTrigger, expandedText,
-			runTimeText,
-			dbErrText)
-		return 0
 	}
+	c.trace.Trace(ev)
 }
 
-// 2020/09/06 09:07:13 insert into founder (nid,app,version,vendor,etag,ip,port,ts,added)
-// values([<nil>     192.168.1.254 80 2020-09-06 09:07:13.686603 -0700 PDT 0001-01-01 00:00:00 +0000 UTC])%!(EXTRA []interface {}=[<nil>     192.168.1.254 80 2020-09-06 09:07:13.686603 -0700 PDT 0001-01-01 00:00:00 +0000 UTC])
+// ExecContext implements driver.ExecerContext
+func (c *liteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.trace == nil {
+		return c.SQLiteConn.ExecContext(ctx, query, args)
+	}
+	start := time.Now()
+	result, err := c.SQLiteConn.ExecContext(ctx, query, args)
+	c.traceStatement(query, args, start, err)
+	return result, err
+}
+
+// QueryContext implements driver.QueryerContext
+func (c *liteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.trace == nil {
+		return c.SQLiteConn.QueryContext(ctx, query, args)
+	}
+	start := time.Now()
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	c.traceStatement(query, args, start, err)
+	return rows, err
+}
+
+// PrepareContext implements driver.ConnPrepareContext
+func (c *liteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.SQLiteConn.PrepareContext(ctx, query)
+	if err != nil || c.trace == nil {
+		return stmt, err
+	}
+	return &traceStmt{SQLiteStmt: stmt.(*sqlite3.SQLiteStmt), query: query, conn: c}, nil
+}
+
+// traceStmt traces the statements run by a prepared statement
+type traceStmt struct {
+	*sqlite3.SQLiteStmt
+	query string
+	conn  *liteConn
+}
+
+// ExecContext implements driver.StmtExecContext
+func (s *traceStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	result, err := s.SQLiteStmt.ExecContext(ctx, args)
+	s.conn.traceStatement(s.query, args, start, err)
+	return result, err
+}
+
+// QueryContext implements driver.StmtQueryContext
+func (s *traceStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.SQLiteStmt.QueryContext(ctx, args)
+	s.conn.traceStatement(s.query, args, start, err)
+	return rows, err
+}
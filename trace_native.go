@@ -0,0 +1,55 @@
+//go:build sqlite_trace || trace
+// +build sqlite_trace trace
+
+// SQLite's own tracing, which the driver only supports with these build tags
+
+package sqlite
+
+import (
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	nativeTrace = func(conn *sqlite3.SQLiteConn, sink TraceSink) error {
+		return conn.SetTrace(&sqlite3.TraceConfig{
+			Callback:        traceCallback(sink),
+			EventMask:       sqlite3.TraceStmt | sqlite3.TraceProfile | sqlite3.TraceRow | sqlite3.TraceClose,
+			WantExpandedSQL: true,
+		})
+	}
+}
+
+var traceKinds = map[uint32]TraceKind{
+	sqlite3.TraceStmt:    TraceStatement,
+	sqlite3.TraceProfile: TraceProfile,
+	sqlite3.TraceRow:     TraceRow,
+	sqlite3.TraceClose:   TraceClose,
+}
+
+func traceCallback(sink TraceSink) sqlite3.TraceUserCallback {
+	return func(info sqlite3.TraceInfo) int {
+		ev := TraceEvent{
+			Kind:        traceKinds[info.EventCode],
+			Time:        time.Now(),
+			SQL:         info.StmtOrTrigger,
+			ExpandedSQL: info.ExpandedSQL,
+			AutoCommit:  info.AutoCommit,
+			Conn:        info.ConnHandle,
+			Stmt:        info.StmtHandle,
+			// SQLite docs as of September 6, 2016: Tracing and Profiling Functions
+			// https://www.sqlite.org/c3ref/profile.html
+			//
+			// The profile callback time is in units of nanoseconds, however
+			// the current implementation is only capable of millisecond resolution
+			// so the six least significant digits in the time are meaningless.
+			Duration: time.Duration(info.RunTimeNanosec),
+		}
+		if info.DBError.Code != 0 || info.DBError.ExtendedCode != 0 {
+			ev.Err = info.DBError
+		}
+		sink.Trace(ev)
+		return 0
+	}
+}
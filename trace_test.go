@@ -1,22 +1,23 @@
-// +build sqlite_trace trace
-
 package sqlite
 
 import (
+	"bytes"
 	"database/sql"
+	"errors"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 )
 
 func TestTrace(t *testing.T) {
-	var w io.Writer
+	var buf bytes.Buffer
+	var w io.Writer = &buf
 	if testing.Verbose() {
-		w = os.Stderr
-	} else {
-		w = ioutil.Discard
+		w = io.MultiWriter(&buf, os.Stderr)
 	}
 	l := log.New(w, "", 0)
 
@@ -37,6 +38,26 @@ func TestTrace(t *testing.T) {
 	dbDoInsertPrepared(db)
 	dbDoSelect(db)
 	dbDoSelectPrepared(db)
+
+	for _, want := range []string{insertDML, selectDML} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected trace of %q", want)
+		}
+	}
+}
+
+func TestTraceHook(t *testing.T) {
+	db, err := Open(":memory:", WithHook(TraceHook(log.New(ioutil.Discard, "", 0))))
+	if nativeTrace == nil {
+		if !errors.Is(err, ErrNoTrace) {
+			t.Fatalf("expected ErrNoTrace but got: %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
 }
 
 // 'DDL' stands for "Data Definition Language":
@@ -164,3 +185,65 @@ func rowsDoFetch(rows *sql.Rows, callerDescr string) {
 	}
 	log.Printf("Total %d rows for %s.\n", nRows, callerDescr)
 }
+
+func TestTraceSink(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []TraceEvent
+	)
+	collect := TraceFunc(func(ev TraceEvent) {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	})
+	ch := make(chan TraceEvent, 1)
+	db, err := Open(":memory:", WithTraceSink(collect))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("create table sink (x int)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("insert into sink values(?)", 42); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("insert into nosuch values(1)"); err == nil {
+		t.Fatal("expected error")
+	}
+	db.Close()
+
+	var inserted, failed, closed bool
+	for _, ev := range events {
+		switch {
+		case ev.Kind == TraceClose:
+			closed = true
+		case ev.Kind == TraceStatement && ev.Err != nil:
+			failed = true
+		case ev.SQL == "insert into sink values(?)" && (nativeTrace != nil || len(ev.Args) == 1 && ev.Args[0] == int64(42)):
+			inserted = true
+		}
+	}
+	if nativeTrace == nil && !failed {
+		t.Error("expected a traced error")
+	}
+	if !inserted || !closed {
+		t.Errorf("missing events: %+v", events)
+	}
+
+	// a full channel drops events rather than blocking
+	db, err = Open(":memory:", WithTraceSink(ChannelSink(ch)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i := 0; i < 3; i++ {
+		if _, err := db.Exec("select 1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-ch:
+	default:
+		t.Error("expected an event")
+	}
+}
@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// TxOptions controls the transaction of Transact
+type TxOptions struct {
+	// Immediate takes the write lock when the transaction begins, rather than
+	// at its first write, so it fails (or waits out the busy timeout) up front
+	// rather than partway through if another connection is writing
+	Immediate bool
+	// DeferForeignKeys checks foreign keys at commit, rather than at each
+	// statement, so rows can be loaded in any order (PRAGMA defer_foreign_keys)
+	DeferForeignKeys bool
+}
+
+// Tx is the part of a transaction used by the function run by Transact,
+// as provided by *sql.Tx and *sql.Conn
+type Tx interface {
+	Queryer
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// Transact runs fn in a transaction, which is committed if fn succeeds and rolled back otherwise.
+// The transaction is run on a single connection, as database/sql can't begin an immediate
+// transaction, so fn must use tx rather than db, and must not commit or roll back itself
+func Transact(db *sql.DB, fn func(tx Tx) error, opts TxOptions) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	begin := "BEGIN"
+	if opts.Immediate {
+		begin = "BEGIN IMMEDIATE"
+	}
+	if _, err := conn.ExecContext(ctx, begin); err != nil {
+		return err
+	}
+	done := false
+	defer func() {
+		if !done {
+			conn.ExecContext(ctx, "ROLLBACK")
+		}
+	}()
+	if opts.DeferForeignKeys {
+		// reset by SQLite when the transaction ends
+		if _, err := conn.ExecContext(ctx, "PRAGMA defer_foreign_keys=ON"); err != nil {
+			return err
+		}
+	}
+	if err := fn(conn); err != nil {
+		return err
+	}
+	// a commit that fails, e.g. on a deferred foreign key, leaves the transaction open
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	done = true
+	return nil
+}
@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTransact(t *testing.T) {
+	db, err := Open(":memory:", WithPragmas("foreign_keys=ON"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	const schema = `
+	create table parent (id integer primary key);
+	create table child (id integer primary key, parent_id int references parent(id));
+	`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// children before their parents, as a bulk load might do
+	load := func(tx Tx) error {
+		if _, err := tx.ExecContext(ctx, "insert into child values (1, 10)"); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "insert into parent values (10)")
+		return err
+	}
+	if err := Transact(db, load, TxOptions{}); err == nil {
+		t.Fatal("expected a foreign key error without deferral")
+	}
+	if err := Transact(db, load, TxOptions{DeferForeignKeys: true, Immediate: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a violation remaining at commit fails it, and leaves nothing behind
+	orphan := func(tx Tx) error {
+		_, err := tx.ExecContext(ctx, "insert into child values (2, 20)")
+		return err
+	}
+	if err := Transact(db, orphan, TxOptions{DeferForeignKeys: true}); err == nil || !strings.Contains(err.Error(), "FOREIGN KEY") {
+		t.Fatalf("expected a foreign key error at commit but got: %v", err)
+	}
+	failed := errors.New("failed")
+	err = Transact(db, func(tx Tx) error {
+		if _, err := tx.ExecContext(ctx, "insert into parent values (30)"); err != nil {
+			return err
+		}
+		return failed
+	}, TxOptions{})
+	if err != failed {
+		t.Fatalf("expected the error of fn but got: %v", err)
+	}
+	var parents, children int
+	if err := row(db, []interface{}{&parents}, "select count(*) from parent"); err != nil {
+		t.Fatal(err)
+	}
+	if err := row(db, []interface{}{&children}, "select count(*) from child"); err != nil {
+		t.Fatal(err)
+	}
+	if parents != 1 || children != 1 {
+		t.Fatalf("expected only the committed rows, but have %d parents and %d children", parents, children)
+	}
+	if _, err := db.Exec("insert into child values (3, 99)"); err == nil {
+		t.Fatal("expected foreign keys to be checked again after the transaction")
+	}
+}
+
+func TestTransactImmediate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "immediate.db")
+	db, err := Open(file + "?_busy_timeout=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("create table t (x)"); err != nil {
+		t.Fatal(err)
+	}
+	other, err := Open(file + "?_busy_timeout=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	err = Transact(db, func(Tx) error {
+		// the write lock is held already, so the other handle can't write
+		if _, err := other.Exec("insert into t values (1)"); err == nil {
+			return errors.New("expected the other write to fail")
+		}
+		return nil
+	}, TxOptions{Immediate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,86 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Trigger is a trigger of a table
+type Trigger struct {
+	Name  string
+	Table string
+	SQL   string // the statement that creates it
+}
+
+// ListTriggers returns the triggers of the table, or of all tables if table is empty
+func ListTriggers(db Queryer, table string) ([]Trigger, error) {
+	var list []Trigger
+	fn := func(_ []string, row []interface{}) {
+		list = append(list, Trigger{Name: fmt.Sprint(row[0]), Table: fmt.Sprint(row[1]), SQL: fmt.Sprint(row[2])})
+	}
+	const q = "SELECT name, tbl_name, sql FROM sqlite_master WHERE type='trigger' AND (?='' OR tbl_name=?) ORDER BY name"
+	return list, query(db, fn, q, table, table)
+}
+
+// DisableTriggers runs fn, e.g. a bulk load, without the triggers of the table, or of
+// all tables if table is empty. It runs in a transaction that drops the triggers and
+// recreates them once fn is done, so if fn fails, or the triggers can't be recreated,
+// nothing is changed. Other writers are locked out until it is done
+func DisableTriggers(db *sql.DB, table string, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	triggers, err := ListTriggers(tx, table)
+	if err != nil {
+		return err
+	}
+	for _, t := range triggers {
+		if _, err := tx.Exec("DROP TRIGGER " + quoteIdent(t.Name)); err != nil {
+			return err
+		}
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	for _, t := range triggers {
+		if _, err := tx.Exec(t.SQL); err != nil {
+			return fmt.Errorf("recreate trigger: %s, error: %w", t.Name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// CreateTriggerIfChanged creates the named trigger, replacing an existing one with a
+// different definition, and reports whether it did. SQLite keeps the definition of
+// a trigger in its own form, so the new one is compared in that form
+func CreateTriggerIfChanged(db *sql.DB, name, create string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	const q = "SELECT sql FROM sqlite_master WHERE type='trigger' AND name=?"
+	var existing string
+	switch err := tx.QueryRow(q, name).Scan(&existing); err {
+	case nil:
+		if _, err := tx.Exec("DROP TRIGGER " + quoteIdent(name)); err != nil {
+			return false, err
+		}
+	case sql.ErrNoRows:
+	default:
+		return false, err
+	}
+	if _, err := tx.Exec(create); err != nil {
+		return false, fmt.Errorf("trigger: %s, error: %w", name, err)
+	}
+	var created string
+	if err := tx.QueryRow(q, name).Scan(&created); err != nil {
+		return false, fmt.Errorf("trigger: %s was not created by: %s", name, create)
+	}
+	if created == existing {
+		return false, nil
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func triggerDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := memDB(t)
+	db.SetMaxOpenConns(1)
+	const schema = `
+	create table items (id integer primary key, name text);
+	create table audit (msg text);
+	create trigger items_insert after insert on items begin insert into audit values ('insert ' || new.name); end;
+	create trigger items_delete after delete on items begin insert into audit values ('delete ' || old.name); end;
+	create trigger audit_noop after insert on audit begin select 1; end;
+	`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func auditCount(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := row(db, []interface{}{&n}, "select count(*) from audit"); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestListTriggers(t *testing.T) {
+	db := triggerDB(t)
+	defer db.Close()
+	list, err := ListTriggers(db, "items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "items_delete" || list[1].Name != "items_insert" || list[0].Table != "items" {
+		t.Fatalf("unexpected triggers: %+v", list)
+	}
+	if all, err := ListTriggers(db, ""); err != nil || len(all) != 3 {
+		t.Fatalf("expected 3 triggers but got %+v (%v)", all, err)
+	}
+}
+
+func TestDisableTriggers(t *testing.T) {
+	db := triggerDB(t)
+	defer db.Close()
+	err := DisableTriggers(db, "items", func(tx *sql.Tx) error {
+		_, err := tx.Exec("insert into items (name) values ('a'), ('b')")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := auditCount(t, db); n != 0 {
+		t.Fatalf("expected no audits from the bulk load but got: %d", n)
+	}
+	if _, err := db.Exec("insert into items (name) values ('c')"); err != nil {
+		t.Fatal(err)
+	}
+	if n := auditCount(t, db); n != 1 {
+		t.Fatalf("expected the triggers to be restored, but got %d audits", n)
+	}
+
+	failed := errors.New("failed")
+	err = DisableTriggers(db, "", func(tx *sql.Tx) error { return failed })
+	if err != failed {
+		t.Fatalf("expected the error of fn but got: %v", err)
+	}
+	if list, _ := ListTriggers(db, ""); len(list) != 3 {
+		t.Fatalf("expected the triggers to be intact but got: %+v", list)
+	}
+}
+
+func TestCreateTriggerIfChanged(t *testing.T) {
+	db := triggerDB(t)
+	defer db.Close()
+	const same = "CREATE TRIGGER IF NOT EXISTS main.items_insert after insert on items begin insert into audit values ('insert ' || new.name); end"
+	if changed, err := CreateTriggerIfChanged(db, "items_insert", same); err != nil || changed {
+		t.Fatalf("expected no change but got %t (%v)", changed, err)
+	}
+	const other = "create trigger items_insert after insert on items begin insert into audit values ('added ' || new.name); end"
+	if changed, err := CreateTriggerIfChanged(db, "items_insert", other); err != nil || !changed {
+		t.Fatalf("expected a change but got %t (%v)", changed, err)
+	}
+	const added = "create trigger items_update after update on items begin select 1; end"
+	if changed, err := CreateTriggerIfChanged(db, "items_update", added); err != nil || !changed {
+		t.Fatalf("expected a new trigger but got %t (%v)", changed, err)
+	}
+	if _, err := CreateTriggerIfChanged(db, "misnamed", added); err == nil {
+		t.Fatal("expected an error for a mismatched name")
+	}
+	var msg string
+	if _, err := db.Exec("insert into items (name) values ('x')"); err != nil {
+		t.Fatal(err)
+	}
+	if err := row(db, []interface{}{&msg}, "select msg from audit"); err != nil || msg != "added x" {
+		t.Fatalf("expected the changed trigger to run but got %q (%v)", msg, err)
+	}
+}
@@ -0,0 +1,151 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// TTLSweepInterval is how often the rows of tables with a TTL are swept,
+	// or the TTL if shorter
+	TTLSweepInterval = time.Minute
+	// TTLBatchSize is the most rows a sweep deletes per transaction
+	TTLBatchSize = 500
+)
+
+// TTLStats are the sweeps of a table with a TTL
+type TTLStats struct {
+	Table       string
+	Sweeps      int64
+	Deleted     int64 // the rows deleted by all the sweeps
+	LastSweep   time.Time
+	LastDeleted int64
+	Err         error // of the last sweep
+}
+
+// TTL deletes the rows of a table once they have expired, in the background
+type TTL struct {
+	db     *sql.DB
+	policy RetentionPolicy
+	stop   chan struct{}
+	done   chan struct{}
+	mu     sync.Mutex
+	stats  TTLStats
+}
+
+// EnableTTL deletes the rows of the table once the time in the column is older than
+// the TTL, sweeping them every TTLSweepInterval, in batches of TTLBatchSize, until
+// stopped. The column holds unix seconds if declared an integer, or else text SQLite
+// can parse as a time, and is indexed (by its julianday if text) so the sweeps don't
+// scan the table. The rows deleted are counted by the Metrics counter "ttl.<table>",
+// as well as by Stats, e.g. for cache and session tables:
+//
+//	ttl, err := EnableTTL(db, "sessions", "last_seen", 24*time.Hour)
+//	...
+//	defer ttl.Stop()
+func EnableTTL(db *sql.DB, table, tsColumn string, ttl time.Duration) (*TTL, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid ttl: %v", ttl)
+	}
+	unix, err := integerColumn(db, table, tsColumn)
+	if err != nil {
+		return nil, err
+	}
+	expr := quoteIdent(tsColumn)
+	if !unix {
+		expr = "julianday(" + expr + ")"
+	}
+	index := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)",
+		quoteIdent("_ttl_"+table+"_"+tsColumn), quoteIdent(table), expr)
+	if _, err := db.Exec(index); err != nil {
+		return nil, fmt.Errorf("ttl: %s, error: %w", table, err)
+	}
+	if _, err := Counters(db); err != nil {
+		return nil, err
+	}
+	t := &TTL{
+		db:     db,
+		policy: RetentionPolicy{Table: table, Column: tsColumn, Unix: unix, MaxAge: ttl, BatchSize: TTLBatchSize},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		stats:  TTLStats{Table: table},
+	}
+	interval := TTLSweepInterval
+	if ttl < interval {
+		interval = ttl
+	}
+	go t.run(interval)
+	return t, nil
+}
+
+// integerColumn reports whether the column of the table is declared an integer
+func integerColumn(db *sql.DB, table, column string) (bool, error) {
+	var decl string
+	found := false
+	fn := func(_ []string, row []interface{}) {
+		if fmt.Sprint(row[1]) == column {
+			decl, found = strings.ToUpper(fmt.Sprint(row[2])), true
+		}
+	}
+	if err := query(db, fn, "PRAGMA table_info("+quoteIdent(table)+")"); err != nil {
+		return false, err
+	}
+	if !found {
+		return false, fmt.Errorf("no such column: %s.%s", table, column)
+	}
+	return strings.Contains(decl, "INT"), nil
+}
+
+// run sweeps the table at every interval until stopped
+func (t *TTL) run(interval time.Duration) {
+	defer close(t.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+		}
+		if _, err := t.Sweep(); err != nil {
+			loggerOf(t.db).Error("ttl sweep failed", "db", logName(t.db), "op", "ttl", "table", t.policy.Table, "error", err)
+		}
+	}
+}
+
+// Sweep deletes the expired rows now, returning the number deleted
+func (t *TTL) Sweep() (int64, error) {
+	r, err := retain(t.db, t.policy)
+	if err == nil && r.Deleted > 0 {
+		m := &Metrics{db: t.db}
+		_, err = m.Incr("ttl."+t.policy.Table, float64(r.Deleted))
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.Sweeps++
+	t.stats.Deleted += r.Deleted
+	t.stats.LastSweep = time.Now()
+	t.stats.LastDeleted = r.Deleted
+	t.stats.Err = err
+	return r.Deleted, err
+}
+
+// Stats returns the sweeps so far
+func (t *TTL) Stats() TTLStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+// Stop stops the sweeps, waiting for one running to finish. The index is kept
+func (t *TTL) Stop() {
+	select {
+	case <-t.stop:
+	default:
+		close(t.stop)
+	}
+	<-t.done
+}
@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTTL(t *testing.T) {
+	db := fileDB(t, t.TempDir())
+	const create = `
+create table sessions (id integer primary key, token text, last_seen timestamp);
+create table hits (id integer primary key, at integer);
+`
+	if _, err := db.Exec(create); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i := 0; i < 20; i++ {
+		age := time.Duration(i) * time.Hour
+		if _, err := db.Exec("insert into sessions (token, last_seen) values(?,?)", "token", now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("insert into hits (at) values(?)", now.Add(-age).Unix()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saved := TTLSweepInterval
+	TTLSweepInterval = 20 * time.Millisecond
+	defer func() { TTLSweepInterval = saved }()
+
+	sessions, err := EnableTTL(db, "sessions", "last_seen", 10*time.Hour-time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sessions.Stop()
+	hits, err := EnableTTL(db, "hits", "at", 15*time.Hour-time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hits.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for sessions.Stats().Deleted < 10 || hits.Stats().Deleted < 5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for sweeps: %+v %+v", sessions.Stats(), hits.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var kept int
+	if err := row(db, []interface{}{&kept}, "select count(*) from sessions"); err != nil || kept != 10 {
+		t.Errorf("expected 10 sessions kept but got: %d (%v)", kept, err)
+	}
+	if err := row(db, []interface{}{&kept}, "select count(*) from hits"); err != nil || kept != 15 {
+		t.Errorf("expected 15 hits kept but got: %d (%v)", kept, err)
+	}
+	if s := sessions.Stats(); s.Err != nil || s.Sweeps == 0 || s.Table != "sessions" {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	m, err := Counters(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metric, err := m.Get("ttl.sessions"); err != nil || metric.Value != 10 {
+		t.Errorf("expected 10 sessions counted but got: %+v (%v)", metric, err)
+	}
+
+	// the sweeps use the index
+	cond, args := sessions.policy.where(time.Now()).Build()
+	plan, err := QueryPlan(db, "select rowid from sessions where "+cond, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) == 0 || !strings.Contains(plan[0].Detail, "_ttl_sessions_last_seen") {
+		t.Errorf("expected the ttl index to be used: %v", plan)
+	}
+
+	sessions.Stop()
+	sessions.Stop()
+	if _, err := EnableTTL(db, "sessions", "nosuch", time.Hour); err == nil {
+		t.Error("expected error for missing column")
+	}
+	if _, err := EnableTTL(db, "sessions", "last_seen", 0); err == nil {
+		t.Error("expected error for invalid ttl")
+	}
+}
@@ -0,0 +1,158 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity is how serious the violation of a Rule is
+type Severity string
+
+// Severities of rules
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Rule is a check of the data, e.g. "no orders without customers", as either a predicate
+// that every row of a table must satisfy, or a query selecting the rows that violate it
+type Rule struct {
+	Name     string   `json:"name" yaml:"name"`
+	Severity Severity `json:"severity" yaml:"severity"`                   // error if not set
+	Table    string   `json:"table,omitempty" yaml:"table,omitempty"`     // the table checked by the predicate
+	Check    string   `json:"check,omitempty" yaml:"check,omitempty"`     // the predicate, as in a CHECK constraint, so NULL passes
+	Query    string   `json:"query,omitempty" yaml:"query,omitempty"`     // selects the rows that violate the rule, instead of a table and check
+	Samples  int      `json:"samples,omitempty" yaml:"samples,omitempty"` // the most violating rows returned, 5 if not set
+}
+
+// query returns the query selecting the rows that violate the rule
+func (r Rule) query() (string, error) {
+	switch {
+	case r.Query != "" && r.Table == "" && r.Check == "":
+		return strings.TrimSuffix(strings.TrimSpace(r.Query), ";"), nil
+	case r.Query == "" && r.Table != "" && r.Check != "":
+		return fmt.Sprintf("SELECT * FROM %s WHERE NOT (%s)", quoteIdent(r.Table), r.Check), nil
+	}
+	return "", fmt.Errorf("rule %q needs either a query, or a table and check", r.Name)
+}
+
+// Violation is a rule violated by rows of the database
+type Violation struct {
+	Rule    Rule
+	Count   int64 // the rows that violate it
+	Columns []string
+	Samples [][]interface{}
+}
+
+// Validate checks the data against the rules, returning the violations
+func Validate(db Queryer, rules []Rule) ([]Violation, error) {
+	var violations []Violation
+	for _, r := range rules {
+		if r.Severity == "" {
+			r.Severity = SeverityError
+		}
+		if r.Samples <= 0 {
+			r.Samples = 5
+		}
+		q, err := r.query()
+		if err != nil {
+			return nil, err
+		}
+		v := Violation{Rule: r}
+		ctx := context.Background()
+		rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT count(*) FROM (%s)", q))
+		if err == nil {
+			for rows.Next() {
+				err = rows.Scan(&v.Count)
+			}
+			rows.Close()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rule: %s, error: %w", r.Name, err)
+		}
+		if v.Count == 0 {
+			continue
+		}
+		if v.Columns, v.Samples, err = queryRows(db, fmt.Sprintf("SELECT * FROM (%s) LIMIT %d", q, r.Samples)); err != nil {
+			return nil, fmt.Errorf("rule: %s, error: %w", r.Name, err)
+		}
+		violations = append(violations, v)
+	}
+	return violations, nil
+}
+
+// WriteViolations reports the violations and their sample rows
+func WriteViolations(w io.Writer, violations []Violation) {
+	for _, v := range violations {
+		rows := "rows"
+		if v.Count == 1 {
+			rows = "row"
+		}
+		fmt.Fprintf(w, "%s: %s: %d %s\n", v.Rule.Severity, v.Rule.Name, v.Count, rows)
+		fmt.Fprintf(w, "\t%s\n", strings.Join(v.Columns, "\t"))
+		for _, row := range v.Samples {
+			values := make([]string, len(row))
+			for i, value := range row {
+				values[i] = fmt.Sprint(value)
+			}
+			fmt.Fprintf(w, "\t%s\n", strings.Join(values, "\t"))
+		}
+	}
+}
+
+// LoadRules reads rules from a file of YAML, or JSON, as a list of rules, or a map with
+// the list under the key "rules":
+//
+//	rules:
+//	  - name: orders have customers
+//	    severity: warning
+//	    table: orders
+//	    check: customer_id IN (SELECT id FROM customers)
+//	  - name: no negative totals
+//	    query: |
+//	      SELECT id, total FROM orders
+//	      WHERE total < 0
+func LoadRules(file string) ([]Rule, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	// unknown keys are refused, as they are likely misspelled
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var rules []Rule
+	if _, ok := doc.(map[string]interface{}); ok {
+		var list struct {
+			Rules []Rule `yaml:"rules"`
+		}
+		err = dec.Decode(&list)
+		rules = list.Rules
+	} else {
+		err = dec.Decode(&rules)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	for _, r := range rules {
+		switch r.Severity {
+		case "", SeverityError, SeverityWarning, SeverityInfo:
+		default:
+			return nil, fmt.Errorf("%s: rule %q has unknown severity: %q", file, r.Name, r.Severity)
+		}
+		if _, err := r.query(); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testRules = `# checks of the orders
+rules:
+  - name: orders have customers
+    severity: warning
+    table: orders
+    check: customer_id IN (SELECT id FROM customers)
+  - name: "no negative totals"
+    samples: 1
+    query: |
+      SELECT id, total FROM orders
+      WHERE total < 0
+  - name: 'customers have names'
+    table: customers
+    check: name <> ''
+`
+
+func TestValidate(t *testing.T) {
+	db := memDB(t)
+	db.SetMaxOpenConns(1)
+	const schema = `
+create table customers (id integer primary key, name text);
+create table orders (id integer primary key, customer_id int, total real);
+insert into customers values (1, 'ann'), (2, null);
+insert into orders values (1, 1, 10), (2, 9, -1), (3, 8, -2), (4, null, 5);
+`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := ioutil.WriteFile(file, []byte(testRules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadRules(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 || rules[1].Name != "no negative totals" || !strings.Contains(rules[1].Query, "\nWHERE total < 0") {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+
+	violations, err := Validate(db, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a NULL customer, like a NULL name, passes as it would a CHECK constraint
+	if len(violations) != 2 {
+		t.Fatalf("unexpected violations: %+v", violations)
+	}
+	if v := violations[0]; v.Count != 2 || v.Rule.Severity != SeverityWarning || len(v.Samples) != 2 {
+		t.Errorf("unexpected violation: %+v", v)
+	}
+	if v := violations[1]; v.Count != 2 || v.Rule.Severity != SeverityError || len(v.Samples) != 1 || v.Columns[1] != "total" {
+		t.Errorf("unexpected violation: %+v", v)
+	}
+
+	var results bytes.Buffer
+	sh := NewShell(db, ShellIO{Results: &results})
+	err = sh.Run(".validate " + file)
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 rules failed") {
+		t.Errorf("expected failed rules but got: %v", err)
+	}
+	if got := results.String(); !strings.Contains(got, "warning: orders have customers: 2 rows") || !strings.Contains(got, "error: no negative totals: 2 rows") {
+		t.Errorf("unexpected report:\n%s", got)
+	}
+
+	json := filepath.Join(t.TempDir(), "rules.json")
+	ioutil.WriteFile(json, []byte(`{"rules": [{"name": "totals", "table": "orders", "check": "total < 100"}]}`), 0644)
+	if err := sh.Run(".validate " + json); err != nil {
+		t.Errorf("expected valid data but got: %v", err)
+	}
+
+	for _, bad := range []string{
+		"- name: x\n  table: t\n",
+		"- name: x\n  query: select 1\n  severity: fatal\n",
+		"- name: x\n  color: red\n",
+		"name: x\n",
+		"rules:\n  - name: x\n    query: |\n  select 1\n",
+		`[{"name": "x", "query": "select 1", "color": "red"}]`,
+	} {
+		ioutil.WriteFile(file, []byte(bad), 0644)
+		if _, err := LoadRules(file); err == nil {
+			t.Errorf("expected error for rules:\n%s", bad)
+		}
+	}
+}
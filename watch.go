@@ -0,0 +1,134 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchInterval is how often Watch polls the data version of a database,
+// for changes its files' notifications were missed for, e.g. on network filesystems
+var WatchInterval = time.Second
+
+// FileEvent is a change to a database seen by Watch
+type FileEvent struct {
+	Path    string // the database
+	Version int64  // the data version after the change
+	Removed bool   // the database was removed or renamed, ending the watch
+	Time    time.Time
+	Err     error // the watch failed, ending it
+}
+
+// Watch notifies of changes to the database file made by other connections or processes,
+// e.g. so readers can refresh their caches. The files of the database (the database, and
+// its WAL or journal) are watched with fsnotify, and the changes confirmed with PRAGMA
+// data_version, which is also polled every WatchInterval. Changes are coalesced while
+// an event is waiting to be received, so a slow receiver gets the latest one. The watch
+// ends, closing the channel, when the func returned is called, or on a Removed or Err event
+func Watch(path string) (<-chan FileEvent, func()) {
+	events := make(chan FileEvent, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(events)
+		if err := watch(ctx, path, events); err != nil {
+			sendLatest(events, FileEvent{Path: path, Time: time.Now(), Err: err})
+		}
+	}()
+	return events, func() {
+		cancel()
+		<-done
+	}
+}
+
+// sendLatest sends the event, replacing any waiting to be received, as events
+// are only sent by the goroutine of the watch
+func sendLatest(events chan FileEvent, ev FileEvent) {
+	select {
+	case events <- ev:
+	default:
+		select {
+		case <-events:
+		default:
+		}
+		events <- ev
+	}
+}
+
+// watch sends the events of the database until the context is done
+func watch(ctx context.Context, path string, events chan FileEvent) error {
+	db, err := Open(path, WithExists(true))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	// data_version is per connection, so it is always read with the same one
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	dataVersion := func() (int64, error) {
+		var v int64
+		err := conn.QueryRowContext(ctx, "PRAGMA data_version").Scan(&v)
+		return v, err
+	}
+	version, err := dataVersion()
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	// the directory is watched, as the WAL and journal come and go
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(abs)); err != nil {
+		return fmt.Errorf("watch: %s, error: %w", path, err)
+	}
+	files := map[string]bool{abs: true, abs + "-wal": true, abs + "-journal": true}
+
+	send := func(ev FileEvent) {
+		ev.Path, ev.Time = path, time.Now()
+		sendLatest(events, ev)
+	}
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			return fmt.Errorf("watch: %s, error: %w", path, err)
+		case ev := <-watcher.Events:
+			if !files[ev.Name] {
+				continue
+			}
+			if ev.Name == abs && ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				send(FileEvent{Version: version, Removed: true})
+				return nil
+			}
+		case <-ticker.C:
+		}
+		v, err := dataVersion()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if v != version {
+			version = v
+			send(FileEvent{Version: v})
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	db := fileDB(t, dir)
+	file := filepath.Join(dir, "source.db")
+	events, stop := Watch(file)
+	defer stop()
+
+	next := func() FileEvent {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("watch ended")
+			}
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+		return FileEvent{}
+	}
+	// the watch is started by the time the first change is seen
+	time.Sleep(100 * time.Millisecond)
+	if _, err := db.Exec("insert into structs(name) values('watched')"); err != nil {
+		t.Fatal(err)
+	}
+	ev := next()
+	if ev.Err != nil || ev.Removed || ev.Path != file || ev.Version == 0 {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+
+	// changes while an event is waiting are coalesced
+	for i := 0; i < 3; i++ {
+		if _, err := db.Exec("insert into structs(name) values('more')"); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if ev2 := next(); ev2.Version <= ev.Version || len(events) != 0 {
+		t.Errorf("unexpected event: %+v (%d waiting)", ev2, len(events))
+	}
+
+	db.Close()
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	if ev := next(); !ev.Removed {
+		t.Errorf("expected removal but got: %+v", ev)
+	}
+	if _, ok := <-events; ok {
+		t.Error("expected the watch to end")
+	}
+
+	events, stop = Watch(filepath.Join(dir, "nosuch.db"))
+	if ev := <-events; ev.Err == nil {
+		t.Errorf("expected error for missing database but got: %+v", ev)
+	}
+	stop()
+}
@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+)
+
+// watermarkSchema keeps the high-watermarks of the exports, of any type, as are their columns
+const watermarkSchema = `CREATE TABLE IF NOT EXISTS _export_watermarks (
+  state TEXT NOT NULL,
+  tbl TEXT NOT NULL,
+  mark,
+  PRIMARY KEY (state, tbl)
+)`
+
+// ExportChangedRows writes the rows of the table added or changed since the export of the
+// state last ran as CSV, in the order of the watermark column, returning the number of rows.
+// The column must increase with each change of a row, e.g. a sequence or a time of the
+// change, and rows without a value are not exported. The highest value exported is kept
+// in the table _export_watermarks, by the state and table, once the rows are written, so
+// the rows are exported again if the writer fails. Any number of states can export
+// the same table, each at its own pace, e.g. to feed several systems
+func ExportChangedRows(db *sql.DB, w io.Writer, table, watermarkColumn, state string) (int, error) {
+	mark, err := Watermark(db, table, state)
+	if err != nil {
+		return 0, err
+	}
+	column := quoteIdent(watermarkColumn)
+	filter := W().NotNull(watermarkColumn)
+	if mark != nil {
+		filter.Gt(watermarkColumn, mark)
+	}
+	clause, args := whereClause("", filter)
+	// rows changed while they are exported are bounded by the highest value now,
+	// and are exported by the next run
+	var high interface{}
+	if err := row(db, []interface{}{&high}, "SELECT max("+column+") FROM "+quoteIdent(table)+clause, args...); err != nil {
+		return 0, err
+	}
+	if high == nil {
+		return 0, nil
+	}
+	clause, args = whereClause("", filter.Le(watermarkColumn, high))
+	rows, err := db.Query("SELECT * FROM "+quoteIdent(table)+clause+" ORDER BY "+column, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	n, err := writeCSV(w, rows)
+	if err != nil {
+		return n, fmt.Errorf("export table: %s, error: %w", table, err)
+	}
+	const advance = `INSERT INTO _export_watermarks (state, tbl, mark) VALUES(?, ?, ?)
+ON CONFLICT(state, tbl) DO UPDATE SET mark = excluded.mark`
+	if _, err := db.Exec(advance, state, table, high); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// Watermark returns the highest value of the watermark column of the table exported by
+// ExportChangedRows for the state, nil if it hasn't exported any
+func Watermark(db *sql.DB, table, state string) (interface{}, error) {
+	if _, err := db.Exec(watermarkSchema); err != nil {
+		return nil, err
+	}
+	var mark interface{}
+	err := row(db, []interface{}{&mark}, "SELECT mark FROM _export_watermarks WHERE state=? AND tbl=?", state, table)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return mark, err
+}
+
+// ResetWatermark forgets the rows of the table exported for the state, so its next
+// export includes them all
+func ResetWatermark(db *sql.DB, table, state string) error {
+	if _, err := db.Exec(watermarkSchema); err != nil {
+		return err
+	}
+	_, err := db.Exec("DELETE FROM _export_watermarks WHERE state=? AND tbl=?", state, table)
+	return err
+}
@@ -0,0 +1,73 @@
+package sqlite
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestExportChangedRows(t *testing.T) {
+	db := memDB(t)
+	const schema = `
+create table events (id integer primary key, name text, version integer);
+insert into events (name, version) values ('one', 1), ('two', 2), ('none', null);
+`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	export := func(state string, expected ...string) {
+		t.Helper()
+		var buf bytes.Buffer
+		n, err := ExportChangedRows(db, &buf, "events", "version", state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(expected) {
+			t.Errorf("expected %d rows but got: %d", len(expected), n)
+		}
+		var names []string
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n")[1:] {
+			names = append(names, strings.Split(line, ",")[1])
+		}
+		if strings.Join(names, " ") != strings.Join(expected, " ") {
+			t.Errorf("expected %v but got: %q", expected, buf.String())
+		}
+	}
+
+	export("feed", "one", "two")
+	export("feed")
+	if mark, err := Watermark(db, "events", "feed"); err != nil || mark != int64(2) {
+		t.Errorf("expected watermark 2 but got: %v (%v)", mark, err)
+	}
+	if _, err := db.Exec("update events set version = 3 where name = 'one'; insert into events (name, version) values ('four', 4)"); err != nil {
+		t.Fatal(err)
+	}
+	export("feed", "one", "four")
+
+	// a failed export is repeated
+	if _, err := db.Exec("update events set version = 5 where name = 'two'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExportChangedRows(db, failWriter{}, "events", "version", "feed"); err == nil {
+		t.Fatal("expected error from writer")
+	}
+	export("feed", "two")
+
+	// other states export at their own pace
+	export("other", "one", "four", "two")
+	if err := ResetWatermark(db, "events", "feed"); err != nil {
+		t.Fatal(err)
+	}
+	export("feed", "one", "four", "two")
+
+	if _, err := ExportChangedRows(db, &bytes.Buffer{}, "nosuch", "version", "feed"); err == nil {
+		t.Error("expected error for missing table")
+	}
+}
@@ -0,0 +1,153 @@
+package sqlite
+
+import (
+	"strings"
+)
+
+// Where builds the conditions of a WHERE clause with their values as parameters,
+// and the columns quoted, so filters built from input are safe to run, e.g.
+//
+//	cond, args := W().Eq("name", name).In("kind", kinds).Build()
+//	rows, err := db.Query("SELECT * FROM things WHERE "+cond, args...)
+//
+// The conditions are joined by AND, and a Where without any builds "1" (all rows)
+type Where struct {
+	conds []string
+	args  []interface{}
+}
+
+// W returns an empty Where
+func W() *Where {
+	return &Where{}
+}
+
+// cond adds the condition on the column with its arguments
+func (w *Where) cond(column, op string, args ...interface{}) *Where {
+	w.conds = append(w.conds, quoteIdent(column)+" "+op)
+	w.args = append(w.args, args...)
+	return w
+}
+
+// Eq adds column = value
+func (w *Where) Eq(column string, value interface{}) *Where {
+	return w.cond(column, "= ?", value)
+}
+
+// Ne adds column <> value
+func (w *Where) Ne(column string, value interface{}) *Where {
+	return w.cond(column, "<> ?", value)
+}
+
+// Lt adds column < value
+func (w *Where) Lt(column string, value interface{}) *Where {
+	return w.cond(column, "< ?", value)
+}
+
+// Le adds column <= value
+func (w *Where) Le(column string, value interface{}) *Where {
+	return w.cond(column, "<= ?", value)
+}
+
+// Gt adds column > value
+func (w *Where) Gt(column string, value interface{}) *Where {
+	return w.cond(column, "> ?", value)
+}
+
+// Ge adds column >= value
+func (w *Where) Ge(column string, value interface{}) *Where {
+	return w.cond(column, ">= ?", value)
+}
+
+// Between adds column BETWEEN low AND high
+func (w *Where) Between(column string, low, high interface{}) *Where {
+	return w.cond(column, "BETWEEN ? AND ?", low, high)
+}
+
+// Like adds column LIKE pattern
+func (w *Where) Like(column, pattern string) *Where {
+	return w.cond(column, "LIKE ?", pattern)
+}
+
+// In adds column IN (values...), which is false if there are no values
+func (w *Where) In(column string, values ...interface{}) *Where {
+	if len(values) == 0 {
+		w.conds = append(w.conds, "0")
+		return w
+	}
+	return w.cond(column, "IN ("+placeholders(len(values))+")", values...)
+}
+
+// NotIn adds column NOT IN (values...), which is true if there are no values
+func (w *Where) NotIn(column string, values ...interface{}) *Where {
+	if len(values) == 0 {
+		return w
+	}
+	return w.cond(column, "NOT IN ("+placeholders(len(values))+")", values...)
+}
+
+// IsNull adds column IS NULL
+func (w *Where) IsNull(column string) *Where {
+	return w.cond(column, "IS NULL")
+}
+
+// NotNull adds column IS NOT NULL
+func (w *Where) NotNull(column string) *Where {
+	return w.cond(column, "IS NOT NULL")
+}
+
+// Or adds the alternatives, each of the conditions of its own Where
+func (w *Where) Or(alternatives ...*Where) *Where {
+	var conds []string
+	for _, alt := range alternatives {
+		cond, args := alt.Build()
+		conds = append(conds, "("+cond+")")
+		w.args = append(w.args, args...)
+	}
+	if len(conds) == 0 {
+		conds = []string{"0"}
+	}
+	w.conds = append(w.conds, "("+strings.Join(conds, " OR ")+")")
+	return w
+}
+
+// And adds the conditions of the others
+func (w *Where) And(others ...*Where) *Where {
+	for _, other := range others {
+		if other != nil {
+			w.conds = append(w.conds, other.conds...)
+			w.args = append(w.args, other.args...)
+		}
+	}
+	return w
+}
+
+// Raw adds a condition as written, with the arguments of its parameters. It is not
+// checked, so must not be built from input
+func (w *Where) Raw(cond string, args ...interface{}) *Where {
+	if cond != "" {
+		w.conds = append(w.conds, "("+cond+")")
+		w.args = append(w.args, args...)
+	}
+	return w
+}
+
+// Build returns the conditions, without WHERE, and the arguments of their parameters
+func (w *Where) Build() (string, []interface{}) {
+	if w == nil || len(w.conds) == 0 {
+		return "1", nil
+	}
+	args := make([]interface{}, len(w.args))
+	copy(args, w.args)
+	return strings.Join(w.conds, " AND "), args
+}
+
+// whereClause returns the WHERE clause of the raw condition and the filter, either of
+// which may be empty, and the arguments of its parameters
+func whereClause(raw string, filter *Where) (string, []interface{}) {
+	if raw == "" && (filter == nil || len(filter.conds) == 0) {
+		return "", nil
+	}
+	w := W().Raw(raw).And(filter)
+	cond, args := w.Build()
+	return " WHERE " + cond, args
+}
@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWhere(t *testing.T) {
+	cond, args := W().Eq("name", "abc").In("kind", 23, 42).Gt("id", 0).Build()
+	if want := `"name" = ? AND "kind" IN (?,?) AND "id" > ?`; cond != want {
+		t.Errorf("expected %q but got: %q", want, cond)
+	}
+	if fmt.Sprint(args) != "[abc 23 42 0]" {
+		t.Errorf("unexpected args: %v", args)
+	}
+	if cond, args := W().Build(); cond != "1" || args != nil {
+		t.Errorf("expected empty where to match all but got: %q %v", cond, args)
+	}
+
+	db := structDb(t)
+	defer db.Close()
+	count := func(w *Where) int {
+		t.Helper()
+		cond, args := w.Build()
+		var n int
+		if err := row(db, []interface{}{&n}, "select count(*) from structs where "+cond, args...); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	tests := []struct {
+		w    *Where
+		want int
+	}{
+		{W(), 4},
+		{W().Eq("name", "abc"), 1},
+		{W().Ne("name", "abc"), 3},
+		{W().In("kind", 23, 42, 99), 2},
+		{W().In("kind"), 0},
+		{W().NotIn("kind", 23), 3},
+		{W().NotIn("kind"), 4},
+		{W().Between("kind", 20, 50), 2},
+		{W().Le("kind", 23).Ge("kind", 23), 1},
+		{W().Lt("kind", 23), 1},
+		{W().Like("data", "%of%"), 2},
+		{W().Or(W().Eq("name", "abc"), W().Eq("kind", 2)), 2},
+		{W().Or(), 0},
+		{W().NotNull("data").IsNull("name"), 0},
+		{W().Raw("length(name) = ?", 3).And(W().Eq("kind", 69)), 1},
+		// the values are parameters, not SQL
+		{W().Eq("name", "abc' or '1'='1"), 0},
+	}
+	for i, tt := range tests {
+		if got := count(tt.w); got != tt.want {
+			cond, _ := tt.w.Build()
+			t.Errorf("%d: %s: expected %d rows but got %d", i, cond, tt.want, got)
+		}
+	}
+
+	// the filters of the export, copy, and retention helpers
+	var buf bytes.Buffer
+	if err := ExportTableCSVFilter(db, &buf, "structs", W().In("kind", 23, 69)); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 3 {
+		t.Errorf("expected header and 2 rows but got:\n%s", buf.String())
+	}
+	buf.Reset()
+	opts := &DumpOptions{Where: map[string]string{"structs": "kind > 20"}, Filters: map[string]*Where{"structs": W().Ne("name", "def")}}
+	if err := Dump(db, &buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "INSERT INTO"); n != 2 {
+		t.Errorf("expected 2 rows dumped but got %d", n)
+	}
+	dst := memDB(t)
+	defer dst.Close()
+	if err := CopyTables(db, dst, []string{"structs"}, &CopyOptions{Filters: map[string]*Where{"structs": W().Eq("kind", 2)}}); err != nil {
+		t.Fatal(err)
+	}
+	var copied int
+	if err := row(dst, []interface{}{&copied}, "select count(*) from structs"); err != nil || copied != 1 {
+		t.Errorf("expected 1 row copied but got: %d (%v)", copied, err)
+	}
+	policy := RetentionPolicy{Table: "structs", Column: "kind", Unix: true, MaxAge: time.Hour, Filter: W().Lt("kind", 40)}
+	if _, err := Retention(db, []RetentionPolicy{policy}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(W()); n != 2 {
+		t.Errorf("expected 2 rows kept but got %d", n)
+	}
+}
@@ -0,0 +1,52 @@
+// Window function callbacks, forwarded to the Go implementations in window.go.
+// The sqlite3 symbols are provided by the go-sqlite3 driver, see handle.go
+
+#include <stdint.h>
+#include "window.h"
+
+extern int sqlite3_create_window_function(sqlite3*, const char*, int, int, void*,
+	void (*)(sqlite3_context*, int, sqlite3_value**),
+	void (*)(sqlite3_context*),
+	void (*)(sqlite3_context*),
+	void (*)(sqlite3_context*, int, sqlite3_value**),
+	void (*)(void*));
+
+#define SQLITE_UTF8          1
+#define SQLITE_DETERMINISTIC 0x000000800
+
+static void window_step(sqlite3_context *ctx, int argc, sqlite3_value **argv) {
+	windowStep(ctx, argc, argv, 0);
+}
+
+static void window_inverse(sqlite3_context *ctx, int argc, sqlite3_value **argv) {
+	windowStep(ctx, argc, argv, 1);
+}
+
+static void window_value(sqlite3_context *ctx) {
+	windowValue(ctx, 0);
+}
+
+static void window_final(sqlite3_context *ctx) {
+	windowValue(ctx, 1);
+}
+
+static void window_destroy(void *id) {
+	windowDestroy((uintptr_t)id);
+}
+
+int create_window(uintptr_t db, const char *name, int nargs, int pure, uintptr_t id) {
+	int flags = SQLITE_UTF8;
+	if (pure) {
+		flags |= SQLITE_DETERMINISTIC;
+	}
+	return sqlite3_create_window_function((sqlite3*)db, name, nargs, flags, (void*)id,
+		window_step, window_final, window_value, window_inverse, window_destroy);
+}
+
+void result_text(sqlite3_context *ctx, const char *s, int n) {
+	sqlite3_result_text(ctx, s, n, SQLITE_TRANSIENT);
+}
+
+void result_blob(sqlite3_context *ctx, const void *b, int n) {
+	sqlite3_result_blob(ctx, b, n, SQLITE_TRANSIENT);
+}
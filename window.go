@@ -0,0 +1,224 @@
+package sqlite
+
+/*
+#include <stdlib.h>
+#include "window.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// WindowFunc is an aggregate that can be used as a window function with an OVER clause.
+// Step adds a row to the window, and Inverse removes the oldest row added.
+// Value returns the aggregate of the rows currently in the window.
+// If the implementation has a Final() interface{} method, it is called
+// instead of Value once the aggregate is complete.
+//
+// Arguments are int64, float64, string, []byte, or nil, and results may be
+// any of those types as well as int or bool
+type WindowFunc interface {
+	Step(args ...interface{})
+	Inverse(args ...interface{})
+	Value() interface{}
+}
+
+// WindowReg contains the fields necessary to register a custom Sqlite window function
+type WindowReg struct {
+	Name  string
+	NArgs int               // number of arguments, or -1 for any number
+	New   func() WindowFunc // returns a new instance for each aggregation
+	Pure  bool
+}
+
+// WithWindows registers custom aggregate window functions
+func WithWindows(windows ...WindowReg) Optional {
+	return func(c *Config) {
+		c.windows = append(c.windows, windows...)
+	}
+}
+
+var (
+	wmu sync.Mutex
+
+	// registrations of window functions, and the aggregations in progress,
+	// are kept by id as C code can't hold Go pointers
+	windowRegs  = make(map[uintptr]*WindowReg)
+	windowFuncs = make(map[uintptr]WindowFunc)
+	windowID    uintptr
+)
+
+func nextWindowID() uintptr {
+	windowID++
+	return windowID
+}
+
+func registerWindows(conn *sqlite3.SQLiteConn, windows ...WindowReg) error {
+	if len(windows) == 0 {
+		return nil
+	}
+	handle, err := connHandle(conn)
+	if err != nil {
+		return err
+	}
+	for i := range windows {
+		w := windows[i]
+		if w.New == nil {
+			return fmt.Errorf("window function %q has no constructor", w.Name)
+		}
+		wmu.Lock()
+		id := nextWindowID()
+		windowRegs[id] = &w
+		wmu.Unlock()
+
+		name := C.CString(w.Name)
+		pure := C.int(0)
+		if w.Pure {
+			pure = 1
+		}
+		rc := C.create_window(C.uintptr_t(handle), name, C.int(w.NArgs), pure, C.uintptr_t(id))
+		C.free(unsafe.Pointer(name))
+		if rc != 0 {
+			// the destroy callback is invoked on failure
+			return fmt.Errorf("failed to register window function %q: error code %d", w.Name, int(rc))
+		}
+	}
+	return nil
+}
+
+// windowInstance returns the aggregation in progress for ctx,
+// creating it if create is set
+func windowInstance(ctx *C.sqlite3_context, create bool) (WindowFunc, *uintptr) {
+	size := C.int(0)
+	if create {
+		size = C.int(unsafe.Sizeof(uintptr(0)))
+	}
+	p := (*uintptr)(C.sqlite3_aggregate_context(ctx, size))
+	wmu.Lock()
+	defer wmu.Unlock()
+	if p != nil && *p != 0 {
+		return windowFuncs[*p], p
+	}
+	reg := windowRegs[uintptr(C.sqlite3_user_data(ctx))]
+	if reg == nil {
+		return nil, nil
+	}
+	fn := reg.New()
+	if p != nil {
+		*p = nextWindowID()
+		windowFuncs[*p] = fn
+	}
+	return fn, p
+}
+
+func windowArgs(argc C.int, argv **C.sqlite3_value) []interface{} {
+	values := (*[1 << 20]*C.sqlite3_value)(unsafe.Pointer(argv))[:argc:argc]
+	args := make([]interface{}, argc)
+	for i, v := range values {
+		switch C.sqlite3_value_type(v) {
+		case C.SQLITE_INTEGER:
+			args[i] = int64(C.sqlite3_value_int64(v))
+		case C.SQLITE_FLOAT:
+			args[i] = float64(C.sqlite3_value_double(v))
+		case C.SQLITE_TEXT:
+			n := C.sqlite3_value_bytes(v)
+			args[i] = C.GoStringN((*C.char)(unsafe.Pointer(C.sqlite3_value_text(v))), n)
+		case C.SQLITE_BLOB:
+			n := C.sqlite3_value_bytes(v)
+			args[i] = C.GoBytes(C.sqlite3_value_blob(v), n)
+		}
+	}
+	return args
+}
+
+func windowError(ctx *C.sqlite3_context, msg string) {
+	s := C.CString(msg)
+	C.sqlite3_result_error(ctx, s, -1)
+	C.free(unsafe.Pointer(s))
+}
+
+func windowResult(ctx *C.sqlite3_context, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		C.sqlite3_result_null(ctx)
+	case int64:
+		C.sqlite3_result_int64(ctx, C.longlong(v))
+	case int:
+		C.sqlite3_result_int64(ctx, C.longlong(v))
+	case bool:
+		if v {
+			C.sqlite3_result_int64(ctx, 1)
+		} else {
+			C.sqlite3_result_int64(ctx, 0)
+		}
+	case float64:
+		C.sqlite3_result_double(ctx, C.double(v))
+	case string:
+		s := C.CString(v)
+		C.result_text(ctx, s, C.int(len(v)))
+		C.free(unsafe.Pointer(s))
+	case []byte:
+		if len(v) == 0 {
+			C.result_blob(ctx, nil, 0)
+			return
+		}
+		C.result_blob(ctx, unsafe.Pointer(&v[0]), C.int(len(v)))
+	default:
+		windowError(ctx, fmt.Sprintf("unsupported result type: %T", value))
+	}
+}
+
+//export windowStep
+func windowStep(ctx *C.sqlite3_context, argc C.int, argv **C.sqlite3_value, inverse C.int) {
+	fn, _ := windowInstance(ctx, true)
+	if fn == nil {
+		windowError(ctx, "window function is not registered")
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			windowError(ctx, fmt.Sprint(r))
+		}
+	}()
+	if inverse != 0 {
+		fn.Inverse(windowArgs(argc, argv)...)
+	} else {
+		fn.Step(windowArgs(argc, argv)...)
+	}
+}
+
+//export windowValue
+func windowValue(ctx *C.sqlite3_context, final C.int) {
+	fn, p := windowInstance(ctx, false)
+	if fn == nil {
+		windowError(ctx, "window function is not registered")
+		return
+	}
+	if final != 0 && p != nil {
+		wmu.Lock()
+		delete(windowFuncs, *p)
+		wmu.Unlock()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			windowError(ctx, fmt.Sprint(r))
+		}
+	}()
+	if f, ok := fn.(interface{ Final() interface{} }); ok && final != 0 {
+		windowResult(ctx, f.Final())
+		return
+	}
+	windowResult(ctx, fn.Value())
+}
+
+//export windowDestroy
+func windowDestroy(id C.uintptr_t) {
+	wmu.Lock()
+	delete(windowRegs, uintptr(id))
+	wmu.Unlock()
+}
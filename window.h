@@ -0,0 +1,41 @@
+// Declarations of the parts of the SQLite API used by window.go and window.c.
+// The sqlite3 symbols are provided by the go-sqlite3 driver, see handle.go
+
+#include <stdint.h>
+
+typedef struct sqlite3 sqlite3;
+typedef struct sqlite3_context sqlite3_context;
+typedef struct sqlite3_value sqlite3_value;
+
+#define SQLITE_INTEGER 1
+#define SQLITE_FLOAT   2
+#define SQLITE_TEXT    3
+#define SQLITE_BLOB    4
+#define SQLITE_NULL    5
+
+#define SQLITE_TRANSIENT ((void (*)(void*))-1)
+
+extern void *sqlite3_user_data(sqlite3_context*);
+extern void *sqlite3_aggregate_context(sqlite3_context*, int);
+extern int sqlite3_value_type(sqlite3_value*);
+extern long long sqlite3_value_int64(sqlite3_value*);
+extern double sqlite3_value_double(sqlite3_value*);
+extern const unsigned char *sqlite3_value_text(sqlite3_value*);
+extern const void *sqlite3_value_blob(sqlite3_value*);
+extern int sqlite3_value_bytes(sqlite3_value*);
+extern void sqlite3_result_int64(sqlite3_context*, long long);
+extern void sqlite3_result_double(sqlite3_context*, double);
+extern void sqlite3_result_null(sqlite3_context*);
+extern void sqlite3_result_error(sqlite3_context*, const char*, int);
+extern void sqlite3_result_text(sqlite3_context*, const char*, int, void (*)(void*));
+extern void sqlite3_result_blob(sqlite3_context*, const void*, int, void (*)(void*));
+
+// implemented in window.c
+int create_window(uintptr_t db, const char *name, int nargs, int pure, uintptr_t id);
+void result_text(sqlite3_context *ctx, const char *s, int n);
+void result_blob(sqlite3_context *ctx, const void *b, int n);
+
+// exported by window.go
+extern void windowStep(sqlite3_context*, int, sqlite3_value**, int);
+extern void windowValue(sqlite3_context*, int);
+extern void windowDestroy(uintptr_t);